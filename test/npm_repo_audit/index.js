@@ -0,0 +1,2 @@
+const debug = require("debug");
+console.log("audit test passed:", debug.humanize(60000));
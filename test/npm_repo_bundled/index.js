@@ -0,0 +1,2 @@
+const timefmt = require("timefmt");
+console.log("bundled dep test passed:", timefmt(90 * 60 * 1000));
@@ -0,0 +1,41 @@
+#!/bin/bash
+set -euo pipefail
+
+# resolve --check passes on a directory generated from the same lockfile,
+# and fails listing each file that differs once it has drifted from it.
+PLEASE_JS=tools/please_js/please_js
+LOCKFILE=test/npm_repo_check/package-lock.json
+OUT="$(mktemp -d)/third_party"
+LOG="$OUT.log"
+
+resolve() {
+    "$PLEASE_JS" resolve --lockfile "$LOCKFILE" --out "$OUT" --subinclude-path "//build_defs:js" "$@"
+}
+
+resolve
+resolve --check
+
+# Drift: a hand edit, a deleted package and a package the lockfile lacks.
+echo "# edited by hand" >> "$OUT/ms/BUILD"
+rm -r "$OUT/debug"
+mkdir "$OUT/left-pad"
+touch "$OUT/left-pad/BUILD"
+
+if resolve --check 2> "$LOG"; then
+    echo "FAIL: --check passed on a drifted directory"
+    exit 1
+fi
+for want in "changed: ms/BUILD" "missing: debug/BUILD" "stale:   left-pad/BUILD" "regenerate it with --incremental"; do
+    if ! grep -qF "$want" "$LOG"; then
+        echo "FAIL: --check output is missing \"$want\":"
+        cat "$LOG"
+        exit 1
+    fi
+done
+
+# --check leaves the directory as it was.
+if [ -e "$OUT/debug" ] || ! grep -q "edited by hand" "$OUT/ms/BUILD"; then
+    echo "FAIL: --check modified the directory"
+    exit 1
+fi
+echo "PASS: --check"
@@ -0,0 +1,16 @@
+// Checks the edge each cycle strategy removed, from the cycle reports the
+// npm_repo rules wrote.
+const assert = require("assert");
+const fs = require("fs");
+
+const want = {
+  default_npm: { from: "react", to: "has-flag", reason: "back-edge", cycle: ["has-flag", "react-dom", "react"] },
+  prefer_peer_npm: { from: "react-dom", to: "react", reason: "peer", cycle: ["react", "has-flag", "react-dom"] },
+  prefer_dev_npm: { from: "has-flag", to: "react-dom", reason: "dev", cycle: ["react-dom", "react", "has-flag"] },
+};
+
+for (const [repo, edge] of Object.entries(want)) {
+  const report = JSON.parse(fs.readFileSync(`test/npm_repo_cycle_strategy/${repo}/cycles.json`, "utf8"));
+  assert.deepStrictEqual(report, [edge], `${repo}/cycles.json`);
+}
+console.log("cycle strategy test passed");
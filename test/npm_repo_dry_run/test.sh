@@ -0,0 +1,42 @@
+#!/bin/bash
+set -euo pipefail
+
+# resolve --dry-run prints the packages regenerating a directory would add,
+# change and remove, and leaves the directory as it was.
+PLEASE_JS=tools/please_js/please_js
+DIR=test/npm_repo_dry_run
+OUT="$(mktemp -d)/third_party"
+
+resolve() {
+    "$PLEASE_JS" resolve --lockfile "$1" --out "$OUT" --subinclude-path "//build_defs:js" "${@:2}"
+}
+
+resolve "$DIR/package-lock.old.json"
+cp -r "$OUT" "$OUT.before"
+
+resolve "$DIR/package-lock.json" --dry-run > "$OUT.log"
+# Only the version changed in debug's rule, and ms's is unchanged.
+EXPECTED="Added:
+  has-flag@3.0.0
+Changed:
+  debug: 4.4.0 -> 4.4.3
+Removed:
+  color-name@1.1.4"
+if [ "$(head -n 6 "$OUT.log")" != "$EXPECTED" ] || ! grep -q "^Would add 1 packages, change 1 and remove 1; " "$OUT.log"; then
+    echo "FAIL: unexpected --dry-run output:"
+    cat "$OUT.log"
+    exit 1
+fi
+if ! diff -r "$OUT.before" "$OUT"; then
+    echo "FAIL: --dry-run modified the directory"
+    exit 1
+fi
+
+# A dry run against the lockfile it was generated from has nothing to do.
+resolve "$DIR/package-lock.old.json" --dry-run > "$OUT.log"
+if ! grep -q "is up to date" "$OUT.log"; then
+    echo "FAIL: --dry-run reported changes against the same lockfile:"
+    cat "$OUT.log"
+    exit 1
+fi
+echo "PASS: --dry-run"
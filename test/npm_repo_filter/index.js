@@ -0,0 +1,3 @@
+const debug = require("debug");
+const supportsColor = require("supports-color");
+console.log("filter test passed:", debug.humanize(60000), typeof supportsColor.supportsColor);
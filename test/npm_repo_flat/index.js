@@ -0,0 +1,3 @@
+const debug = require("debug");
+const ms = require("ms");
+console.log("flat layout test passed:", debug.humanize(3600000), ms(60000));
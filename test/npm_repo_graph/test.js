@@ -0,0 +1,22 @@
+// Checks the JSON dependency graph npm_repo wrote for package-lock.json.
+const assert = require("assert");
+const fs = require("fs");
+
+const graph = JSON.parse(fs.readFileSync(process.argv[2], "utf8"));
+
+assert.deepStrictEqual(graph.nodes, [
+  { id: "//is-number", name: "is-number", version: "7.0.0", kind: "package" },
+  { id: "//is-number:is-number_v6_0_0", name: "is-number", version: "6.0.0", kind: "conflict" },
+  { id: "//kind-of", name: "kind-of", version: "6.0.3", kind: "package" },
+]);
+
+// One edge of the cycle between kind-of and its nested is-number is
+// removed, and reported as such.
+const edges = graph.edges.map((e) => `${e.from} -> ${e.to} (${e.kind})`);
+const cycle = graph.edges.filter((e) => e.kind === "cycle");
+assert.strictEqual(cycle.length, 1, edges.join("\n"));
+const ends = [cycle[0].from, cycle[0].to].sort();
+assert.deepStrictEqual(ends, ["//is-number:is-number_v6_0_0", "//kind-of"]);
+const kept = graph.edges.filter((e) => e.kind !== "cycle" && e.from === cycle[0].from && e.to === cycle[0].to);
+assert.deepStrictEqual(kept, [], edges.join("\n"));
+console.log("graph test passed");
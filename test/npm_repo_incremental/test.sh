@@ -0,0 +1,41 @@
+#!/bin/bash
+set -euo pipefail
+
+# resolve --incremental rewrites only the BUILD files whose contents change
+# and removes the directories of packages the lockfile no longer has.
+# updated/package-lock.json upgrades debug, keeps ms and drops once, and
+# wrappy with it.
+PLEASE_JS=tools/please_js/please_js
+OUT="$(mktemp -d)/third_party"
+LOG="$OUT.log"
+
+resolve() {
+    "$PLEASE_JS" resolve --lockfile "$1" --out "$OUT" --subinclude-path "//build_defs:js" --incremental 2> "$LOG"
+}
+
+fail() {
+    echo "FAIL: $1"
+    cat "$LOG"
+    exit 1
+}
+
+resolve test/npm_repo_incremental/package-lock.json
+for pkg in debug ms once wrappy; do
+    [ -f "$OUT/$pkg/BUILD" ] || fail "$pkg/BUILD wasn't written"
+done
+# Backdated, so rewriting it shows even within the same second.
+touch -d "2020-01-01 00:00:00" "$OUT/ms/BUILD"
+before="$(stat -c '%i %Y' "$OUT/ms/BUILD")"
+
+resolve test/npm_repo_incremental/updated/package-lock.json
+grep -q "Updated 1 files, removed 2" "$LOG" || fail "unexpected summary"
+[ "$(stat -c '%i %Y' "$OUT/ms/BUILD")" = "$before" ] || fail "ms/BUILD was rewritten"
+grep -q 'version = "4.4.3"' "$OUT/debug/BUILD" || fail "debug/BUILD wasn't updated"
+for pkg in once wrappy; do
+    [ ! -e "$OUT/$pkg" ] || fail "$pkg wasn't removed"
+done
+
+# Nothing changes the second time.
+resolve test/npm_repo_incremental/updated/package-lock.json
+grep -q "Updated 0 files, removed 0" "$LOG" || fail "unexpected summary"
+echo "PASS: --incremental"
@@ -0,0 +1,2 @@
+const debug = require("debug");
+console.log("license test passed:", debug.humanize(60000));
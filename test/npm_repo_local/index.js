@@ -0,0 +1,3 @@
+const greet = require("greet");
+const ms = require("ms");
+console.log("local dep test passed:", greet("tarball"), ms("1h"));
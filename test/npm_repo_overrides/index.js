@@ -0,0 +1,6 @@
+const debug = require("debug");
+const ms = require("ms");
+if (debug.humanize(3600000) !== ms(3600000)) {
+  throw new Error("debug and ms disagree: " + debug.humanize(3600000) + " vs " + ms(3600000));
+}
+console.log("overrides test passed:", ms(3600000));
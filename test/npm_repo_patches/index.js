@@ -0,0 +1,6 @@
+const greet = require("greet");
+const message = greet("patch");
+if (message !== "patched hello, patch") {
+  throw new Error("patch not applied: " + message);
+}
+console.log("patches test passed:", message);
@@ -0,0 +1,4 @@
+const debug = require("debug");
+const log = debug("test");
+log("platform dep test: debug loaded");
+console.log("platform dep test passed:", debug.humanize(3600000));
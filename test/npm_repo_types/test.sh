@@ -0,0 +1,46 @@
+#!/bin/bash
+set -euo pipefail
+
+# --types=label labels the @types packages' rules npm:types; --types=group
+# also puts them in //types with a types filegroup of them all, and fails
+# if a package's own rule is already called types.
+PLEASE_JS=tools/please_js/please_js
+TMP="$(mktemp -d)"
+
+resolve() {
+    local lockfile="$1" out="$2"
+    shift 2
+    "$PLEASE_JS" resolve --lockfile "$lockfile" --out "$out" --subinclude-path "//build_defs:js" "$@"
+}
+
+fail() {
+    echo "FAIL: $1"
+    exit 1
+}
+
+resolve test/npm_repo_types/package-lock.json "$TMP/label" --types=label
+for pkg in types_node types_yauzl; do
+    grep -q '"npm:types"' "$TMP/label/$pkg/BUILD" || fail "$pkg isn't labelled npm:types"
+done
+for pkg in ms undici-types; do
+    if grep -q '"npm:types"' "$TMP/label/$pkg/BUILD"; then
+        fail "$pkg is labelled npm:types"
+    fi
+done
+[ ! -e "$TMP/label/types" ] || fail "--types=label wrote a types directory"
+
+resolve test/npm_repo_types/package-lock.json "$TMP/group" --types=group
+GROUP="$TMP/group/types/BUILD"
+for want in 'name = "types_node"' 'name = "types_yauzl"' '"//types:types_node"' 'name = "types"' '":types_node"' '":types_yauzl"'; do
+    grep -qF "$want" "$GROUP" || fail "types/BUILD is missing $want"
+done
+grep -A1 'filegroup(' "$GROUP" | grep -q 'name = "types"' || fail "the types rule isn't a filegroup"
+[ ! -e "$TMP/group/types_node" ] || fail "--types=group left @types/node in a directory of its own"
+[ -e "$TMP/group/ms/BUILD" ] || fail "--types=group moved ms"
+
+# A package called types has its rule in //types too.
+if resolve test/npm_repo_types/clash/package-lock.json "$TMP/clash" --types=group 2> "$TMP/clash.log"; then
+    fail "--types=group passed with a package called types"
+fi
+grep -q "in the way of the types filegroup" "$TMP/clash.log" || fail "unexpected error: $(cat "$TMP/clash.log")"
+echo "PASS: --types"
@@ -0,0 +1,2 @@
+const { greet } = require("@acme/ui");
+console.log("workspaces test passed:", greet("1h"));
@@ -0,0 +1,3 @@
+const ms = require("ms");
+
+exports.greet = (duration) => `hello in ${ms(ms(duration), { long: true })}`;
@@ -0,0 +1,3 @@
+const debug = require("debug");
+const ms = require("ms");
+console.log("yarn test passed:", typeof debug, ms("1h"));
@@ -0,0 +1,10 @@
+import React from "react";
+
+export function App({ url }: { url: string }) {
+  return (
+    <main>
+      <h1>{import.meta.env.SSR ? "Rendered on the server" : "Rendered in the browser"}</h1>
+      <p>{url}</p>
+    </main>
+  );
+}
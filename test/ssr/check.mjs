@@ -0,0 +1,13 @@
+import { readFileSync } from "node:fs";
+import { render } from "./ssr_server/server.js";
+
+const html = render("/about");
+if (!html.includes("Rendered on the server") || !html.includes("/about")) {
+  throw new Error("unexpected server render: " + html);
+}
+
+const manifest = JSON.parse(readFileSync(new URL("./ssr_server/ssr-manifest.json", import.meta.url), "utf8"));
+if (!Object.keys(manifest).some((src) => src.endsWith("app.tsx"))) {
+  throw new Error("ssr-manifest.json has no entry for app.tsx: " + JSON.stringify(manifest));
+}
+console.log("ssr test passed");
@@ -0,0 +1,5 @@
+import React from "react";
+import { hydrateRoot } from "react-dom/client";
+import { App } from "./app";
+
+hydrateRoot(document.getElementById("root")!, <App url={location.pathname} />);
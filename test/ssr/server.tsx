@@ -0,0 +1,7 @@
+import React from "react";
+import { renderToString } from "react-dom/server";
+import { App } from "./app";
+
+export function render(url: string): string {
+  return renderToString(<App url={url} />);
+}
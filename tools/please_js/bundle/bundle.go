@@ -15,23 +15,58 @@ import (
 
 // Args holds the arguments for the bundle subcommand.
 type Args struct {
-	Entry          string
-	Out            string
-	OutDir         string
-	ModuleConfig   string
-	Format         string
-	Platform       string
-	Target         string
-	External       []string
-	Define         []string
-	Minify         bool
-	Splitting      bool
-	HTML           bool
-	EnvFile        string
-	EnvPrefix      string
-	Tsconfig       string
-	TailwindBin    string
-	TailwindConfig string
+	Entry                string
+	Out                  string
+	OutDir               string
+	ModuleConfig         string
+	ModuleAliases        []string
+	Format               string
+	Platform             string
+	Target               string
+	External             []string
+	ImportMap            string
+	RemoteImports        bool
+	RemoteCacheDir       string
+	RemoteLock           string
+	RemoteLocked         bool
+	RemoteOffline        bool
+	NpmCDN               string
+	Define               []string
+	Minify               bool
+	MinifyWith           string
+	TerserBin            string
+	TerserConfig         string
+	Node                 string
+	Splitting            bool
+	HTML                 bool
+	HTMLTemplate         string
+	Preconnect           []string
+	DNSPrefetch          []string
+	Preload              []string
+	FetchPriority        []string
+	InlineCSS            string
+	EnvFile              string
+	EnvPrefix            string
+	Mode                 string
+	Tsconfig             string
+	TailwindBin          string
+	TailwindConfig       string
+	AssetInlineLimit     int
+	AssetInlineOverrides []string
+	NodeExecutable       bool
+	CacheDir             string
+	ExtractMessages      string
+	MessageFunctions     []string
+	UnusedFiles          string
+	UnusedRoots          []string
+	UnusedIgnores        []string
+	ViteManifest         string
+	ViteManifestRoot     string
+	OutputManifest       string
+	SSREntry             string
+	SSROutDir            string
+	Prerender            []string
+	Flavor               string
 }
 
 // Run bundles JavaScript/TypeScript using esbuild.
@@ -42,26 +77,100 @@ func Run(args Args) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse moduleconfig: %w", err)
 	}
+	if err := common.ApplyModuleAliases(moduleMap, args.ModuleAliases); err != nil {
+		return err
+	}
 
-	// Configure and run esbuild
-	plugins := []api.Plugin{
-		common.ModuleResolvePlugin(moduleMap, args.Platform),
-		common.RawImportPlugin(),
+	inlineCSS := args.InlineCSS
+	if inlineCSS == "" {
+		inlineCSS = inlineCSSNone
 	}
-	// For browser builds, replace unresolved Node.js built-in imports with
-	// empty CJS modules. Registered AFTER ModuleResolvePlugin so that npm
-	// polyfill packages (e.g. "events", "buffer") are resolved first — only
-	// builtins with no npm counterpart get the empty stub.
-	if args.Platform != "node" {
-		plugins = append(plugins, common.NodeBuiltinEmptyPlugin())
+	switch inlineCSS {
+	case inlineCSSNone, inlineCSSCritical, inlineCSSAll:
+	default:
+		return fmt.Errorf("invalid --inline-css %q: must be critical, all or none", inlineCSS)
 	}
-	if args.TailwindBin != "" {
-		plugins = append(plugins, common.TailwindPlugin(args.TailwindBin, args.TailwindConfig))
+	if inlineCSS != inlineCSSNone && !(args.Splitting && args.HTML) {
+		return fmt.Errorf("--inline-css requires --splitting and --html")
+	}
+	if args.HTMLTemplate != "" && !(args.Splitting && args.HTML) {
+		return fmt.Errorf("--html-template requires --splitting and --html")
+	}
+	hints, err := parseResourceHints(args)
+	if err != nil {
+		return err
+	}
+	resolveExtensions, err := common.ResolveExtensions(args.Platform, args.Flavor)
+	if err != nil {
+		return err
+	}
+	if !hints.empty() && !(args.Splitting && args.HTML) {
+		return fmt.Errorf("--preconnect, --dns-prefetch, --preload and --fetch-priority require --splitting and --html")
+	}
+
+	if args.SSREntry != "" && !(args.Splitting && args.SSROutDir != "") {
+		return fmt.Errorf("--ssr-entry requires --splitting and --ssr-out-dir")
+	}
+
+	prerenderRoutes, err := parsePrerenderRoutes(args.Prerender, args.OutDir)
+	if err != nil {
+		return err
+	}
+	if len(prerenderRoutes) > 0 && !(args.Splitting && args.HTML && args.Platform != "node") {
+		return fmt.Errorf("--prerender requires --splitting and --html, for the browser")
+	}
+
+	useTerser := false
+	switch args.MinifyWith {
+	case "", minifierEsbuild:
+	case minifierTerser:
+		if !args.Minify {
+			return fmt.Errorf("--minify-with terser requires --minify")
+		}
+		if args.TerserBin == "" {
+			return fmt.Errorf("--minify-with terser requires --terser-bin")
+		}
+		useTerser = true
+	default:
+		return fmt.Errorf("invalid --minify-with %q: must be esbuild or terser", args.MinifyWith)
+	}
+	// With terser, esbuild only bundles and terser does all the minifying,
+	// so its compress and mangle options aren't second-guessed.
+	esbuildMinify := args.Minify && !useTerser
+
+	var importMap *common.ImportMap
+	if args.ImportMap != "" {
+		importMap, err = common.LoadImportMap(args.ImportMap)
+		if err != nil {
+			return err
+		}
+	}
+
+	var cache *buildCache
+	if args.CacheDir != "" {
+		cache, err = newBuildCache(args.CacheDir, args)
+		if err != nil {
+			return fmt.Errorf("failed to compute bundle cache key: %w", err)
+		}
+		if cache.restore() {
+			fmt.Fprintf(os.Stderr, "Restored %s from bundle cache\n", args.Entry)
+			return nil
+		}
+	}
+
+	// Configure and run esbuild
+	plugins, err := bundlePlugins(args, args.Platform, moduleMap, importMap)
+	if err != nil {
+		return err
 	}
 
+	mode := args.Mode
+	if mode == "" {
+		mode = "production"
+	}
 	define := common.ParseDefines(args.Define)
 	if args.EnvFile != "" {
-		envDefines, err := common.LoadEnvFiles(args.EnvFile, "production", args.EnvPrefix)
+		envDefines, err := common.LoadEnvFiles(args.EnvFile, mode, args.EnvPrefix)
 		if err != nil {
 			return fmt.Errorf("failed to load env files: %w", err)
 		}
@@ -71,7 +180,7 @@ func Run(args Args) error {
 			}
 		}
 	}
-	common.MergeEnvDefines(define, "production")
+	common.MergeEnvDefines(define, mode)
 
 	opts := api.BuildOptions{
 		EntryPoints:       []string{args.Entry},
@@ -86,10 +195,11 @@ func Run(args Args) error {
 		Plugins:           plugins,
 		Define:            define,
 		JSX:               api.JSXAutomatic,
-		MinifySyntax:      args.Minify,
-		MinifyWhitespace:  args.Minify,
-		MinifyIdentifiers: args.Minify,
+		MinifySyntax:      esbuildMinify,
+		MinifyWhitespace:  esbuildMinify,
+		MinifyIdentifiers: esbuildMinify,
 		Sourcemap:         api.SourceMapLinked,
+		ResolveExtensions: resolveExtensions,
 	}
 
 	if args.Splitting {
@@ -116,42 +226,228 @@ func Run(args Args) error {
 	if args.Tsconfig != "" {
 		opts.Tsconfig = args.Tsconfig
 	}
+	if cache != nil || args.ExtractMessages != "" || args.UnusedFiles != "" || args.ViteManifest != "" || args.OutputManifest != "" || useTerser || args.SSREntry != "" {
+		opts.Metafile = true
+	}
+	if args.NodeExecutable {
+		if args.Platform != "node" {
+			return fmt.Errorf("--node-executable requires --platform node")
+		}
+		// Emitted as a banner rather than prepended afterwards so the
+		// linked source map accounts for the extra line. esbuild already
+		// preserves a hashbang from the entry point, so don't add a second.
+		if !hasHashbang(args.Entry) {
+			opts.Banner = map[string]string{"js": nodeShebang}
+		}
+	}
+	// Worklets and workers are built with the plugins configured so far,
+	// then the rewriting plugin is added for the main build.
+	workers := common.NewWorkerBundler(opts.Outdir, opts)
+	opts.Plugins = append([]api.Plugin{workers.Plugin()}, opts.Plugins...)
 	result := api.Build(opts)
 
 	if len(result.Errors) > 0 {
 		return fmt.Errorf("esbuild bundle failed with %d errors", len(result.Errors))
 	}
 
+	if useTerser {
+		// Before finishNodeExecutable, which marks the entry output
+		// executable: terser replaces the file.
+		if err := runTerser(args, result.Metafile); err != nil {
+			return err
+		}
+	}
+
+	if args.NodeExecutable {
+		if err := finishNodeExecutable(args, opts.Format, result.Metafile); err != nil {
+			return err
+		}
+	}
+
+	extraOutputs := workers.Outputs()
+	if args.NodeExecutable {
+		outDir := filepath.Dir(args.Out)
+		if args.Splitting {
+			outDir = args.OutDir
+		}
+		extraOutputs = append(extraOutputs, filepath.Join(outDir, "package.json"))
+	}
+
+	if args.ExtractMessages != "" {
+		if err := extractMessages(args.ExtractMessages, args.MessageFunctions, result.Metafile, moduleMap); err != nil {
+			return fmt.Errorf("failed to extract messages: %w", err)
+		}
+		extraOutputs = append(extraOutputs, args.ExtractMessages)
+	}
+
+	if args.UnusedFiles != "" {
+		roots := args.UnusedRoots
+		if len(roots) == 0 {
+			roots = []string{filepath.Dir(args.Entry)}
+		}
+		if err := writeUnusedFiles(args.UnusedFiles, args.Entry, roots, args.UnusedIgnores, result.Metafile, workers.Inputs()); err != nil {
+			return fmt.Errorf("failed to write unused files report: %w", err)
+		}
+		extraOutputs = append(extraOutputs, args.UnusedFiles)
+	}
+
+	if args.ViteManifest != "" {
+		outDir := filepath.Dir(args.Out)
+		if args.Splitting {
+			outDir = args.OutDir
+		}
+		root := args.ViteManifestRoot
+		if root == "" {
+			root = "."
+		}
+		if err := writeViteManifest(args.ViteManifest, outDir, root, args.Entry, result.Metafile); err != nil {
+			return fmt.Errorf("failed to write Vite manifest: %w", err)
+		}
+		extraOutputs = append(extraOutputs, args.ViteManifest)
+	}
+
 	if args.Splitting && args.HTML {
-		if err := generateHTML(args.OutDir, args.Entry, result.Metafile); err != nil {
+		if err := generateHTML(args.OutDir, args.Entry, result.Metafile, inlineCSS, importMap, args.HTMLTemplate, define, hints); err != nil {
 			return fmt.Errorf("failed to generate index.html: %w", err)
 		}
+		extraOutputs = append(extraOutputs, filepath.Join(args.OutDir, "index.html"))
+	}
+
+	if len(prerenderRoutes) > 0 {
+		pages, err := prerender(args, prerenderRoutes, moduleMap, define)
+		if err != nil {
+			return err
+		}
+		extraOutputs = append(extraOutputs, pages...)
+	}
+
+	if args.OutputManifest != "" {
+		// Written after everything else in the output directory so it covers
+		// it all; a cache hit restores it along with the outputs it
+		// describes. The SSR server bundle below goes in a directory of its
+		// own and isn't listed.
+		if err := writeOutputManifest(args.OutputManifest, result.Metafile, extraOutputs); err != nil {
+			return fmt.Errorf("failed to write output manifest: %w", err)
+		}
+		extraOutputs = append(extraOutputs, args.OutputManifest)
+	}
+
+	if args.SSREntry != "" {
+		serverOutputs, err := buildSSRServer(args, moduleMap, define, result.Metafile)
+		if err != nil {
+			return err
+		}
+		extraOutputs = append(extraOutputs, serverOutputs...)
+	}
+
+	if cache != nil {
+		// A failed cache write shouldn't fail a build that succeeded.
+		if err := cache.save(result.Metafile, extraOutputs, args.TailwindConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to write bundle cache: %v\n", err)
+		}
 	}
 
 	return nil
 }
 
+// bundlePlugins returns the esbuild plugins for a build targeting platform.
+func bundlePlugins(args Args, platform string, moduleMap map[string]string, importMap *common.ImportMap) ([]api.Plugin, error) {
+	var plugins []api.Plugin
+	if importMap != nil {
+		// Ahead of module resolution, so mapped packages stay external even
+		// when the moduleconfig could bundle them.
+		plugins = append(plugins, common.ImportMapExternalPlugin(importMap))
+	}
+	if args.RemoteImports {
+		cacheDir := args.RemoteCacheDir
+		if cacheDir == "" {
+			if userCache, err := os.UserCacheDir(); err == nil {
+				cacheDir = filepath.Join(userCache, "please_js", "remote")
+			}
+		}
+		plugins = append(plugins, common.RemoteImportPlugin(common.RemoteImportOptions{
+			CacheDir: cacheDir,
+			LockFile: args.RemoteLock,
+			Locked:   args.RemoteLocked,
+			Offline:  args.RemoteOffline,
+			NpmCDN:   args.NpmCDN,
+		}))
+	}
+	plugins = append(plugins,
+		common.ModuleResolvePlugin(moduleMap, platform),
+		common.RawImportPlugin(),
+	)
+	// For browser builds, replace unresolved Node.js built-in imports with
+	// empty CJS modules. Registered AFTER ModuleResolvePlugin so that npm
+	// polyfill packages (e.g. "events", "buffer") are resolved first — only
+	// builtins with no npm counterpart get the empty stub.
+	if platform != "node" {
+		plugins = append(plugins, common.NodeBuiltinEmptyPlugin())
+	}
+	if args.TailwindBin != "" {
+		plugins = append(plugins, common.TailwindPlugin(args.TailwindBin, args.TailwindConfig, args.CacheDir, tailwindSourceDirs(args.Entry, moduleMap)))
+	}
+	if args.AssetInlineLimit > 0 || len(args.AssetInlineOverrides) > 0 {
+		overrides, err := common.ParseAssetInlineOverrides(args.AssetInlineOverrides)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, common.AssetInlinePlugin(args.AssetInlineLimit, overrides))
+	}
+	return plugins, nil
+}
+
+// tailwindSourceDirs returns the directories Tailwind v4 scans for class
+// names: the entry point's package and the local libraries it depends on.
+func tailwindSourceDirs(entry string, moduleMap map[string]string) []string {
+	return append([]string{filepath.Dir(entry)}, common.LocalModuleDirs(moduleMap)...)
+}
+
+// writeOutputManifest lists every file the build produced: esbuild's outputs
+// from the metafile plus the extra files written alongside them.
+func writeOutputManifest(path, metafile string, extraOutputs []string) error {
+	var meta metafileData
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return fmt.Errorf("failed to parse metafile: %w", err)
+	}
+	files := append([]string(nil), extraOutputs...)
+	for out := range meta.Outputs {
+		files = append(files, out)
+	}
+	return common.WriteOutputManifest(path, files)
+}
+
 // metafileData represents the relevant parts of esbuild's metafile JSON.
 type metafileData struct {
-	Outputs map[string]metafileOutput `json:"outputs"`
+	Inputs  map[string]json.RawMessage `json:"inputs"`
+	Outputs map[string]metafileOutput  `json:"outputs"`
 }
 
 type metafileOutput struct {
-	Imports    []metafileImport `json:"imports"`
-	EntryPoint string           `json:"entryPoint"`
+	Imports    []metafileImport           `json:"imports"`
+	EntryPoint string                     `json:"entryPoint"`
+	CSSBundle  string                     `json:"cssBundle"`
+	Inputs     map[string]json.RawMessage `json:"inputs"`
 }
 
 type metafileImport struct {
-	Path string `json:"path"`
-	Kind string `json:"kind"`
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	External bool   `json:"external"`
 }
 
 // generateHTML parses the esbuild metafile and writes an index.html with
 // module script tags and preload hints for shared chunks. The entry parameter
 // is the source entry point path (e.g. "src/main.js") used to identify
 // the correct output chunk when multiple entry points exist (dynamic imports
-// also get entryPoint fields in the metafile).
-func generateHTML(outDir string, entry string, metafile string) error {
+// also get entryPoint fields in the metafile). inlineCSS is the --inline-css
+// mode used for the stylesheets. A non-nil importMap is embedded so the
+// browser can resolve the imports left external for it. With a template the
+// tags are injected into it before </head> and </body> instead of into a
+// bare page, after its %NAME% placeholders are filled in from the env
+// defines in define. hints adds the --preconnect, --preload and
+// --fetch-priority tags and attributes.
+func generateHTML(outDir string, entry string, metafile string, inlineCSS string, importMap *common.ImportMap, template string, define map[string]string, hints *resourceHints) error {
 	var meta metafileData
 	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
 		return fmt.Errorf("failed to parse metafile: %w", err)
@@ -161,9 +457,10 @@ func generateHTML(outDir string, entry string, metafile string) error {
 
 	// Find the entry chunk matching the source entry point, and collect CSS files
 	var entryPath string
-	var cssFiles []string
+	var cssFiles, outputs []string
 	for path, output := range meta.Outputs {
 		rel := strings.TrimPrefix(path, prefix)
+		outputs = append(outputs, rel)
 		if output.EntryPoint == entry {
 			entryPath = rel
 		}
@@ -176,11 +473,12 @@ func generateHTML(outDir string, entry string, metafile string) error {
 		return fmt.Errorf("no entry point found in metafile")
 	}
 
-	// Collect shared chunks from the entry's static imports
+	// Collect shared chunks from the entry's static imports. External
+	// imports aren't chunks; the browser resolves them itself.
 	var preloadChunks []string
 	if entryOutput, ok := meta.Outputs[prefix+entryPath]; ok {
 		for _, imp := range entryOutput.Imports {
-			if imp.Kind == "import-statement" {
+			if imp.Kind == "import-statement" && !imp.External {
 				rel := strings.TrimPrefix(imp.Path, prefix)
 				preloadChunks = append(preloadChunks, rel)
 			}
@@ -192,18 +490,50 @@ func generateHTML(outDir string, entry string, metafile string) error {
 	sort.Strings(cssFiles)
 	sort.Strings(preloadChunks)
 
-	// Build the HTML
-	var b strings.Builder
-	b.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n  <meta charset=\"UTF-8\">\n  <meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\">\n")
-	for _, css := range cssFiles {
-		fmt.Fprintf(&b, "  <link rel=\"stylesheet\" href=\"%s\">\n", css)
+	// Build the tags for the head and the end of the body
+	var head strings.Builder
+	hints.writeConnectHints(&head)
+	if err := writeStylesheets(&head, outDir, cssFiles, inlineCSS, hints); err != nil {
+		return err
+	}
+	if importMap != nil {
+		// Must precede any module script or preload that uses it.
+		data, err := json.Marshal(importMap)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&head, "  <script type=\"importmap\">%s</script>\n", data)
 	}
 	for _, chunk := range preloadChunks {
-		fmt.Fprintf(&b, "  <link rel=\"modulepreload\" href=\"%s\">\n", chunk)
+		fmt.Fprintf(&head, "  <link rel=\"modulepreload\" href=\"%s\"%s>\n", chunk, hints.attr(chunk))
 	}
-	b.WriteString("</head>\n<body>\n  <div id=\"root\"></div>\n")
-	fmt.Fprintf(&b, "  <script type=\"module\" src=\"%s\"></script>\n", entryPath)
-	b.WriteString("</body>\n</html>\n")
+	if err := hints.writePreloads(&head, outputs); err != nil {
+		return err
+	}
+	body := fmt.Sprintf("  <script type=\"module\" src=\"%s\"%s></script>\n", entryPath, hints.attr(entryPath))
 
-	return os.WriteFile(filepath.Join(outDir, "index.html"), []byte(b.String()), 0644)
+	var html string
+	if template != "" {
+		data, err := os.ReadFile(template)
+		if err != nil {
+			return fmt.Errorf("failed to read HTML template: %w", err)
+		}
+		html = common.ReplaceEnvPlaceholders(string(data), define)
+		html = injectBefore(html, "</head>", head.String())
+		html = injectBefore(html, "</body>", body)
+	} else {
+		html = "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n  <meta charset=\"UTF-8\">\n  <meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\">\n" +
+			head.String() + "</head>\n<body>\n  <div id=\"root\"></div>\n" + body + "</body>\n</html>\n"
+	}
+
+	return os.WriteFile(filepath.Join(outDir, "index.html"), []byte(html), 0644)
+}
+
+// injectBefore inserts tags before the last occurrence of the closing tag
+// in html, or appends them if it has none.
+func injectBefore(html, closing, tags string) string {
+	if idx := strings.LastIndex(html, closing); idx >= 0 {
+		return html[:idx] + tags + html[idx:]
+	}
+	return html + tags
 }
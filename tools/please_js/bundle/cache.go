@@ -0,0 +1,205 @@
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tools/please_js/common"
+)
+
+// buildCache stores bundle outputs on disk so repeated builds with unchanged
+// inputs can skip esbuild entirely. esbuild's incremental state only lives in
+// memory, so across processes the cache works at the level of whole builds:
+//
+//   - the key covers the arguments, the please_js binary and every config
+//     file the build reads (moduleconfig, tsconfig, Tailwind config, import
+//     map, remote import lock, terser and its config, .env files)
+//   - a manifest records the hash of every input esbuild loaded (from the
+//     metafile) plus any Tailwind content files, and the outputs produced
+//
+// A build is restored when the key matches and every recorded input still
+// has the same hash. Tailwind output is cached separately by TailwindPlugin,
+// so a build that misses still avoids recompiling unchanged stylesheets.
+//
+// This trades hermeticity for speed: the cache directory lives outside the
+// build sandbox and persists between actions, so Please can't see or verify
+// it. Every entry is keyed and checked by content hash, so a hit produces the
+// same outputs a fresh build would, but a corrupted or tampered cache
+// directory can leak into build outputs, and remote execution workers won't
+// share it. The cache is therefore opt-in (BundleCacheDir).
+type buildCache struct {
+	dir string // <cache-dir>/builds/<key>
+}
+
+// cacheManifest lists a cached build's inputs and outputs. Paths are relative
+// to the working directory.
+type cacheManifest struct {
+	Inputs  map[string]string `json:"inputs"` // path → sha256
+	Outputs []string          `json:"outputs"`
+}
+
+// newBuildCache computes the cache key for a build.
+func newBuildCache(cacheDir string, args Args) (*buildCache, error) {
+	h := sha256.New()
+
+	keyArgs := args
+	keyArgs.CacheDir = ""
+	argsJSON, err := json.Marshal(keyArgs)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(argsJSON)
+
+	// Rebuilding please_js must invalidate everything it produced.
+	if exe, err := os.Executable(); err == nil {
+		if sum, err := common.HashFile(exe); err == nil {
+			fmt.Fprintf(h, "\x00exe\x00%s", sum)
+		}
+	}
+
+	configFiles := []string{args.ModuleConfig, args.Tsconfig, args.TailwindConfig, args.ImportMap, args.RemoteLock, args.TerserBin, args.TerserConfig, args.HTMLTemplate}
+	if args.EnvFile != "" {
+		envFiles, _ := filepath.Glob(args.EnvFile + "*")
+		sort.Strings(envFiles)
+		configFiles = append(configFiles, envFiles...)
+	}
+	for _, path := range configFiles {
+		if path == "" {
+			continue
+		}
+		sum, err := common.HashFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", path, err)
+		}
+		fmt.Fprintf(h, "\x00%s\x00%s", path, sum)
+	}
+
+	return &buildCache{
+		dir: filepath.Join(cacheDir, "builds", hex.EncodeToString(h.Sum(nil))),
+	}, nil
+}
+
+// restore copies a cached build's outputs into place. Returns false if there
+// is no cached build for this key or any of its inputs have changed.
+func (c *buildCache) restore() bool {
+	data, err := os.ReadFile(filepath.Join(c.dir, "manifest.json"))
+	if err != nil {
+		return false
+	}
+	var manifest cacheManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return false
+	}
+	for path, want := range manifest.Inputs {
+		if got, err := common.HashFile(path); err != nil || got != want {
+			return false
+		}
+	}
+	for _, out := range manifest.Outputs {
+		if err := copyFile(filepath.Join(c.dir, "files", out), out); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// save records a finished build. extraOutputs are files written outside
+// esbuild (index.html, package.json) that aren't listed in the metafile.
+func (c *buildCache) save(metafile string, extraOutputs []string, tailwindConfig string) error {
+	var meta metafileData
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return fmt.Errorf("failed to parse metafile: %w", err)
+	}
+
+	manifest := cacheManifest{Inputs: make(map[string]string)}
+	inputs := make([]string, 0, len(meta.Inputs))
+	for path := range meta.Inputs {
+		// Plugin namespaces prefix paths, e.g. "query-import:/abs/logo.svg".
+		if idx := strings.Index(path, ":"); idx > 0 {
+			if _, err := os.Stat(path); err != nil {
+				path = path[idx+1:]
+			}
+		}
+		inputs = append(inputs, path)
+	}
+	if tailwindConfig != "" {
+		inputs = append(inputs, common.TailwindContentFiles(tailwindConfig)...)
+	}
+	for _, path := range inputs {
+		sum, err := common.HashFile(path)
+		if err != nil {
+			// Virtual modules (stdin, data URLs) have nothing to hash.
+			continue
+		}
+		manifest.Inputs[path] = sum
+	}
+
+	for path := range meta.Outputs {
+		manifest.Outputs = append(manifest.Outputs, path)
+	}
+	manifest.Outputs = append(manifest.Outputs, extraOutputs...)
+	sort.Strings(manifest.Outputs)
+
+	// Write to a temporary directory and rename into place so a concurrent
+	// build never sees a partially written entry.
+	tmp := fmt.Sprintf("%s.tmp-%d", c.dir, os.Getpid())
+	os.RemoveAll(tmp)
+	for _, out := range manifest.Outputs {
+		if err := copyFile(out, filepath.Join(tmp, "files", out)); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "manifest.json"), data, 0644); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	os.RemoveAll(c.dir)
+	if err := os.Rename(tmp, c.dir); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	return nil
+}
+
+// copyFile copies src to dst, creating parent directories and preserving the
+// file mode (node executables keep their exec bit).
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// OpenFile's mode is masked by umask and ignored for existing files.
+	return os.Chmod(dst, info.Mode().Perm())
+}
@@ -0,0 +1,138 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cacheFixture is a source file, a config file and an output file in a temp
+// directory, with the metafile esbuild would have written for them.
+type cacheFixture struct {
+	dir, src, config, out string
+	metafile              string
+}
+
+func newCacheFixture(t *testing.T) cacheFixture {
+	t.Helper()
+	dir := t.TempDir()
+	f := cacheFixture{
+		dir:    dir,
+		src:    filepath.Join(dir, "src", "main.ts"),
+		config: filepath.Join(dir, "tsconfig.json"),
+		out:    filepath.Join(dir, "out", "main.js"),
+	}
+	writeTestFile(t, f.src, "console.log(1);")
+	writeTestFile(t, f.config, "{}")
+	writeTestFile(t, f.out, "console.log(1);\n")
+	f.metafile = fmt.Sprintf(`{"inputs": {%q: {}, "stdin": {}}, "outputs": {%q: {}}}`, f.src, f.out)
+	return f
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f cacheFixture) args() Args {
+	return Args{Entry: f.src, Out: f.out, Tsconfig: f.config, Minify: true}
+}
+
+func (f cacheFixture) cache(t *testing.T, args Args) *buildCache {
+	t.Helper()
+	cache, err := newBuildCache(filepath.Join(f.dir, "cache"), args)
+	if err != nil {
+		t.Fatalf("newBuildCache() error: %v", err)
+	}
+	return cache
+}
+
+func TestBuildCache_SaveAndRestore(t *testing.T) {
+	f := newCacheFixture(t)
+	extra := filepath.Join(f.dir, "out", "index.html")
+	writeTestFile(t, extra, "<html></html>")
+
+	if f.cache(t, f.args()).restore() {
+		t.Fatal("restore() = true before anything was saved")
+	}
+	if err := f.cache(t, f.args()).save(f.metafile, []string{extra}, ""); err != nil {
+		t.Fatalf("save() error: %v", err)
+	}
+
+	os.RemoveAll(filepath.Join(f.dir, "out"))
+	if !f.cache(t, f.args()).restore() {
+		t.Fatal("restore() = false after save with unchanged inputs")
+	}
+	for path, want := range map[string]string{f.out: "console.log(1);\n", extra: "<html></html>"} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("output %s not restored: %v", path, err)
+		}
+		if string(got) != want {
+			t.Errorf("restored %s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestBuildCache_InputChangeMisses(t *testing.T) {
+	f := newCacheFixture(t)
+	if err := f.cache(t, f.args()).save(f.metafile, nil, ""); err != nil {
+		t.Fatalf("save() error: %v", err)
+	}
+
+	writeTestFile(t, f.src, "console.log(2);")
+	if f.cache(t, f.args()).restore() {
+		t.Error("restore() = true after a metafile input changed")
+	}
+
+	os.Remove(f.src)
+	if f.cache(t, f.args()).restore() {
+		t.Error("restore() = true after a metafile input was deleted")
+	}
+}
+
+func TestBuildCache_KeyInvalidation(t *testing.T) {
+	f := newCacheFixture(t)
+	base := f.cache(t, f.args())
+
+	withCacheDir := f.args()
+	withCacheDir.CacheDir = "/elsewhere"
+	if got := f.cache(t, withCacheDir); got.dir != base.dir {
+		t.Errorf("key changed with --cache-dir: %s vs %s", got.dir, base.dir)
+	}
+
+	unminified := f.args()
+	unminified.Minify = false
+	if got := f.cache(t, unminified); got.dir == base.dir {
+		t.Error("key unchanged after an argument changed")
+	}
+
+	writeTestFile(t, f.config, `{"compilerOptions": {"jsx": "react"}}`)
+	if got := f.cache(t, f.args()); got.dir == base.dir {
+		t.Error("key unchanged after a config file changed")
+	}
+
+	envArgs := f.args()
+	envArgs.EnvFile = filepath.Join(f.dir, ".env")
+	writeTestFile(t, envArgs.EnvFile, "PLZ_A=1")
+	withEnv := f.cache(t, envArgs)
+	writeTestFile(t, envArgs.EnvFile+".production", "PLZ_A=2")
+	if got := f.cache(t, envArgs); got.dir == withEnv.dir {
+		t.Error("key unchanged after a .env.* file was added")
+	}
+}
+
+func TestBuildCache_MissingConfigFails(t *testing.T) {
+	f := newCacheFixture(t)
+	args := f.args()
+	args.Tsconfig = filepath.Join(f.dir, "missing.json")
+	if _, err := newBuildCache(filepath.Join(f.dir, "cache"), args); err == nil {
+		t.Error("newBuildCache() succeeded with a missing config file")
+	}
+}
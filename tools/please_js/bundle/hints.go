@@ -0,0 +1,151 @@
+package bundle
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// resourceHints are the loading hints added to the generated index.html:
+// connections to open early, assets to preload and fetch priorities for
+// the bundle's own tags.
+type resourceHints struct {
+	preconnect  []string
+	dnsPrefetch []string
+	preload     []string // patterns of asset outputs to preload
+	priorities  []fetchPriority
+}
+
+type fetchPriority struct {
+	pattern  string
+	priority string
+}
+
+// preloadTypes maps the asset extensions that can be preloaded to their
+// "as" destination and MIME type.
+var preloadTypes = map[string][2]string{
+	".woff2": {"font", "font/woff2"},
+	".woff":  {"font", "font/woff"},
+	".ttf":   {"font", "font/ttf"},
+	".otf":   {"font", "font/otf"},
+	".png":   {"image", "image/png"},
+	".jpg":   {"image", "image/jpeg"},
+	".jpeg":  {"image", "image/jpeg"},
+	".gif":   {"image", "image/gif"},
+	".webp":  {"image", "image/webp"},
+	".avif":  {"image", "image/avif"},
+	".svg":   {"image", "image/svg+xml"},
+}
+
+// parseResourceHints validates the hint flags. Priorities are
+// "pattern=high|low|auto" strings; patterns are globs matched against output
+// paths relative to the output directory, or against just the file name if
+// they have no slash (e.g. "main.js", "chunk-*.js", "*.woff2").
+func parseResourceHints(args Args) (*resourceHints, error) {
+	h := &resourceHints{
+		preconnect:  args.Preconnect,
+		dnsPrefetch: args.DNSPrefetch,
+		preload:     args.Preload,
+	}
+	for _, pattern := range args.Preload {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid --preload pattern %q: %w", pattern, err)
+		}
+	}
+	for _, spec := range args.FetchPriority {
+		pattern, priority, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --fetch-priority %q (expected pattern=priority)", spec)
+		}
+		switch priority {
+		case "high", "low", "auto":
+		default:
+			return nil, fmt.Errorf("invalid --fetch-priority %q: priority must be high, low or auto", spec)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid --fetch-priority pattern %q: %w", pattern, err)
+		}
+		h.priorities = append(h.priorities, fetchPriority{pattern, priority})
+	}
+	return h, nil
+}
+
+// empty reports whether any hints were asked for.
+func (h *resourceHints) empty() bool {
+	return len(h.preconnect) == 0 && len(h.dnsPrefetch) == 0 && len(h.preload) == 0 && len(h.priorities) == 0
+}
+
+// matchHintPattern reports whether an output path matches a hint pattern.
+func matchHintPattern(pattern, file string) bool {
+	if !strings.Contains(pattern, "/") {
+		file = path.Base(file)
+	}
+	ok, _ := path.Match(pattern, file)
+	return ok
+}
+
+// attr returns the fetchpriority attribute for an output file, with a
+// leading space, or "" if no pattern matches. The longest matching pattern
+// wins, so "main.js=high" overrides "*.js=low".
+func (h *resourceHints) attr(file string) string {
+	best := -1
+	for i, p := range h.priorities {
+		if matchHintPattern(p.pattern, file) && (best < 0 || len(p.pattern) > len(h.priorities[best].pattern)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return fmt.Sprintf(" fetchpriority=%q", h.priorities[best].priority)
+}
+
+// writeConnectHints writes the preconnect and dns-prefetch links, which go
+// first in <head> so the connections open while the rest is parsed.
+// Preconnects are made in CORS mode (crossorigin), as module scripts, fonts
+// and fetch() requests are.
+func (h *resourceHints) writeConnectHints(b *strings.Builder) {
+	for _, origin := range h.preconnect {
+		fmt.Fprintf(b, "  <link rel=\"preconnect\" href=\"%s\" crossorigin>\n", origin)
+	}
+	for _, origin := range h.dnsPrefetch {
+		fmt.Fprintf(b, "  <link rel=\"dns-prefetch\" href=\"%s\">\n", origin)
+	}
+}
+
+// writePreloads writes a <link rel="preload"> for each asset output
+// matching a --preload pattern.
+func (h *resourceHints) writePreloads(b *strings.Builder, outputs []string) error {
+	if len(h.preload) == 0 {
+		return nil
+	}
+	var files []string
+	for _, file := range outputs {
+		if strings.HasSuffix(file, ".map") {
+			continue
+		}
+		for _, pattern := range h.preload {
+			if matchHintPattern(pattern, file) {
+				files = append(files, file)
+				break
+			}
+		}
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		t, ok := preloadTypes[strings.ToLower(filepath.Ext(file))]
+		if !ok {
+			return fmt.Errorf("can't preload %s: only fonts and images can be preloaded", file)
+		}
+		crossorigin := ""
+		if t[0] == "font" {
+			// Fonts are always fetched in CORS mode; without this the
+			// preload is wasted and the font fetched twice.
+			crossorigin = " crossorigin"
+		}
+		fmt.Fprintf(b, "  <link rel=\"preload\" href=\"%s\" as=\"%s\" type=\"%s\"%s%s>\n", file, t[0], t[1], crossorigin, h.attr(file))
+	}
+	return nil
+}
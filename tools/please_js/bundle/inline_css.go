@@ -0,0 +1,180 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Values accepted by --inline-css.
+const (
+	inlineCSSNone     = "none"
+	inlineCSSCritical = "critical"
+	inlineCSSAll      = "all"
+)
+
+var cssSourceMapCommentRe = regexp.MustCompile(`/\*# sourceMappingURL=[^*]*\*/\s*`)
+
+// writeStylesheets writes the <head> tags for the bundle's CSS files to b
+// according to the --inline-css mode:
+//
+//   - none: a <link rel="stylesheet"> per file
+//   - all: each file's contents in a <style> tag
+//   - critical: the critical rules of each file in a <style> tag, and the
+//     full file preloaded and applied once it arrives (with a <noscript>
+//     fallback), so first paint isn't blocked on the whole stylesheet
+//
+// Links to the files get their --fetch-priority from hints.
+func writeStylesheets(b *strings.Builder, outDir string, cssFiles []string, mode string, hints *resourceHints) error {
+	for _, css := range cssFiles {
+		if mode == inlineCSSNone {
+			fmt.Fprintf(b, "  <link rel=\"stylesheet\" href=\"%s\"%s>\n", css, hints.attr(css))
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(outDir, css))
+		if err != nil {
+			return err
+		}
+		content := cssSourceMapCommentRe.ReplaceAllString(string(data), "")
+		if mode == inlineCSSCritical {
+			content = criticalCSS(content)
+		}
+		if content = strings.TrimSpace(content); content != "" {
+			// Keep the stylesheet from closing the <style> element early.
+			content = strings.ReplaceAll(content, "</style", `<\/style`)
+			fmt.Fprintf(b, "  <style>\n%s\n  </style>\n", content)
+		}
+		if mode == inlineCSSCritical {
+			fmt.Fprintf(b, "  <link rel=\"preload\" href=\"%s\" as=\"style\"%s onload=\"this.onload=null;this.rel='stylesheet'\">\n", css, hints.attr(css))
+			fmt.Fprintf(b, "  <noscript><link rel=\"stylesheet\" href=\"%s\"></noscript>\n", css)
+		}
+	}
+	return nil
+}
+
+// criticalCSS returns the rules of a stylesheet needed for first paint.
+// The bundle renders into an empty root element, so there is no markup to
+// measure the fold against; instead this keeps what applies to the page
+// before any component renders: @charset/@import/@layer statements,
+// @font-face, and style rules whose selectors only target elements (no
+// classes), such as resets, :root custom properties and html/body styles.
+// Conditional groups (@media, @supports, @layer blocks) are kept with just
+// their critical rules. Keyframes and class-based rules load with the full
+// stylesheet.
+func criticalCSS(css string) string {
+	var b strings.Builder
+	for _, rule := range splitCSSRules(css) {
+		switch {
+		case rule.block == "":
+			// Statement at-rules: @charset, @import, @layer a, b;
+			if strings.HasPrefix(rule.prelude, "@") {
+				b.WriteString(rule.prelude + ";\n")
+			}
+		case strings.HasPrefix(rule.prelude, "@font-face"):
+			b.WriteString(rule.prelude + " {" + rule.block + "}\n")
+		case strings.HasPrefix(rule.prelude, "@media"),
+			strings.HasPrefix(rule.prelude, "@supports"),
+			strings.HasPrefix(rule.prelude, "@layer"):
+			if inner := criticalCSS(rule.block); inner != "" {
+				b.WriteString(rule.prelude + " {\n" + inner + "}\n")
+			}
+		case strings.HasPrefix(rule.prelude, "@"):
+			// @keyframes, @page, @property, ...
+		case isCriticalSelector(rule.prelude):
+			b.WriteString(rule.prelude + " {" + rule.block + "}\n")
+		}
+	}
+	return b.String()
+}
+
+// isCriticalSelector reports whether every selector in a comma-separated
+// list avoids class selectors.
+func isCriticalSelector(selectors string) bool {
+	for _, sel := range strings.Split(selectors, ",") {
+		if strings.Contains(stripCSSStrings(sel), ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// stripCSSStrings removes [attr="..."] values from a selector so a dot in
+// an attribute value isn't mistaken for a class.
+func stripCSSStrings(sel string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(sel); i++ {
+		c := sel[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// cssRule is a top-level rule: a prelude (selector or at-rule header) and
+// its block contents, or a statement with an empty block.
+type cssRule struct {
+	prelude string
+	block   string
+}
+
+// splitCSSRules splits a stylesheet into its top-level rules, skipping
+// comments and respecting strings and nested blocks.
+func splitCSSRules(css string) []cssRule {
+	var rules []cssRule
+	start, depth, blockStart := 0, 0, 0
+	var prelude string
+	for i := 0; i < len(css); i++ {
+		switch c := css[i]; {
+		case c == '/' && i+1 < len(css) && css[i+1] == '*':
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				i = len(css)
+				break
+			}
+			if depth == 0 {
+				// Drop the comment from the prelude being collected.
+				css = css[:i] + css[i+2+end+2:]
+				i--
+			} else {
+				i += 2 + end + 1
+			}
+		case c == '"' || c == '\'':
+			for i++; i < len(css) && css[i] != c; i++ {
+				if css[i] == '\\' {
+					i++
+				}
+			}
+		case c == '{':
+			if depth == 0 {
+				prelude = strings.TrimSpace(css[start:i])
+				blockStart = i + 1
+			}
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				rules = append(rules, cssRule{prelude: prelude, block: css[blockStart:i]})
+				start = i + 1
+			}
+		case c == ';' && depth == 0:
+			if stmt := strings.TrimSpace(css[start:i]); stmt != "" {
+				rules = append(rules, cssRule{prelude: stmt})
+			}
+			start = i + 1
+		}
+	}
+	return rules
+}
@@ -0,0 +1,118 @@
+package bundle
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitCSSRules(t *testing.T) {
+	css := `@charset "utf-8";
+/* a comment with { braces } and ; */
+body { margin: 0; }
+a[title="}{;"]::after { content: "{"; }
+@media (min-width: 640px) {
+  /* } */
+  .p-4 { padding: 1rem; }
+}
+`
+	got := splitCSSRules(css)
+	want := []cssRule{
+		{prelude: `@charset "utf-8"`},
+		{prelude: "body", block: " margin: 0; "},
+		{prelude: `a[title="}{;"]::after`, block: ` content: "{"; `},
+		{prelude: "@media (min-width: 640px)", block: "\n  /* } */\n  .p-4 { padding: 1rem; }\n"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("splitCSSRules() returned %d rules %q, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("rule %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestIsCriticalSelector(t *testing.T) {
+	tests := map[string]bool{
+		"html, body":                true,
+		":root":                     true,
+		"h1 > a[href]":              true,
+		"#app":                      true,
+		`a[href$=".pdf"]`:           true,
+		`input[type='text']::after`: true,
+		".btn":                      false,
+		"body, .dark body":          false,
+		`a[href$=".pdf"].external`:  false,
+		"div:not(.hidden)":          false,
+	}
+	for sel, want := range tests {
+		if got := isCriticalSelector(sel); got != want {
+			t.Errorf("isCriticalSelector(%q) = %v, want %v", sel, got, want)
+		}
+	}
+}
+
+func TestCriticalCSS(t *testing.T) {
+	css := `@import url("fonts.css");
+@layer base, utilities;
+:root { --brand: #f00; }
+@font-face { font-family: Inter; src: url(inter.woff2); }
+.btn { color: red; }
+@keyframes spin { to { transform: rotate(1turn); } }
+@media (prefers-color-scheme: dark) {
+  body { background: #000; }
+  .card { background: #111; }
+  @supports (display: grid) {
+    main { display: grid; }
+    .grid { display: grid; }
+  }
+}
+@media print {
+  .no-print { display: none; }
+}
+@layer base {
+  h1 { font-size: 2rem; }
+}
+`
+	want := `@import url("fonts.css");
+@layer base, utilities;
+:root { --brand: #f00; }
+@font-face { font-family: Inter; src: url(inter.woff2); }
+@media (prefers-color-scheme: dark) {
+body { background: #000; }
+@supports (display: grid) {
+main { display: grid; }
+}
+}
+@layer base {
+h1 { font-size: 2rem; }
+}
+`
+	if got := criticalCSS(css); got != want {
+		t.Errorf("criticalCSS() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteStylesheets_CriticalEscapesStyleEnd(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "app.css"), `body::before { content: "</style>"; }
+.x { color: red; }
+/*# sourceMappingURL=app.css.map */`)
+
+	var b strings.Builder
+	if err := writeStylesheets(&b, dir, []string{"app.css"}, inlineCSSCritical, &resourceHints{}); err != nil {
+		t.Fatalf("writeStylesheets() error: %v", err)
+	}
+	got := b.String()
+	for _, want := range []string{`content: "<\/style>"`, `rel="preload" href="app.css"`, `<noscript><link rel="stylesheet" href="app.css"></noscript>`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("writeStylesheets() output missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{".x", "sourceMappingURL"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("writeStylesheets() output contains %q:\n%s", unwanted, got)
+		}
+	}
+}
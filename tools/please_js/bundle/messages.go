@@ -0,0 +1,154 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// messageSourceExts are the input extensions scanned for translatable messages.
+var messageSourceExts = map[string]bool{
+	".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".mjs": true, ".cjs": true,
+}
+
+// jsStringPattern matches a single JS string literal: double- or single-quoted
+// with escapes, or a template literal without substitutions.
+const jsStringPattern = `"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*'|` + "`[^`$]*`"
+
+var (
+	formattedMessageRe = regexp.MustCompile(`<FormattedMessage\b([^>]*?)/?>`)
+	jsxAttrRe          = regexp.MustCompile(`\b(id|defaultMessage|description)\s*=\s*\{?\s*(` + jsStringPattern + `)`)
+)
+
+// message is one entry in the extracted catalog.
+type message struct {
+	DefaultMessage string   `json:"defaultMessage,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	Files          []string `json:"files"`
+}
+
+// messageCallRe builds a regex matching calls to any of the given function
+// names with a string literal first argument and an optional string literal
+// default as the second, e.g. t("greeting") or i18n.t("greeting", "Hello").
+func messageCallRe(functions []string) *regexp.Regexp {
+	names := make([]string, len(functions))
+	for i, fn := range functions {
+		names[i] = regexp.QuoteMeta(fn)
+	}
+	return regexp.MustCompile(`(?:^|[^\w$])(?:` + strings.Join(names, "|") + `)\s*\(\s*(` +
+		jsStringPattern + `)(?:\s*,\s*(` + jsStringPattern + `))?`)
+}
+
+// extractMessages scans the source files that went into the bundle for
+// translatable messages and writes them to outPath as a JSON catalog keyed by
+// message id. Third-party packages (npm_module outputs and node_modules) are
+// skipped so only the application's own messages are collected.
+func extractMessages(outPath string, functions []string, metafile string, moduleMap map[string]string) error {
+	var meta metafileData
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return fmt.Errorf("failed to parse metafile: %w", err)
+	}
+
+	var packageDirs []string
+	for _, dir := range moduleMap {
+		if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
+			packageDirs = append(packageDirs, filepath.Clean(dir)+string(filepath.Separator))
+		}
+	}
+
+	var inputs []string
+	for path := range meta.Inputs {
+		if !messageSourceExts[filepath.Ext(path)] || strings.Contains(path, "node_modules/") {
+			continue
+		}
+		thirdParty := false
+		for _, dir := range packageDirs {
+			if strings.HasPrefix(path, dir) {
+				thirdParty = true
+				break
+			}
+		}
+		if !thirdParty {
+			inputs = append(inputs, path)
+		}
+	}
+	sort.Strings(inputs)
+
+	callRe := messageCallRe(functions)
+	catalog := make(map[string]*message)
+	add := func(id, defaultMessage, description, file string) {
+		if id == "" {
+			return
+		}
+		msg, ok := catalog[id]
+		if !ok {
+			msg = &message{}
+			catalog[id] = msg
+		}
+		if msg.DefaultMessage == "" {
+			msg.DefaultMessage = defaultMessage
+		}
+		if msg.Description == "" {
+			msg.Description = description
+		}
+		if len(msg.Files) == 0 || msg.Files[len(msg.Files)-1] != file {
+			msg.Files = append(msg.Files, file)
+		}
+	}
+
+	for _, path := range inputs {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			// Virtual modules have no file on disk.
+			continue
+		}
+		code := string(data)
+		for _, m := range callRe.FindAllStringSubmatch(code, -1) {
+			add(unquoteJS(m[1]), unquoteJS(m[2]), "", path)
+		}
+		for _, m := range formattedMessageRe.FindAllStringSubmatch(code, -1) {
+			attrs := make(map[string]string)
+			for _, a := range jsxAttrRe.FindAllStringSubmatch(m[1], -1) {
+				attrs[a[1]] = unquoteJS(a[2])
+			}
+			add(attrs["id"], attrs["defaultMessage"], attrs["description"], path)
+		}
+	}
+
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(outPath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(outPath, append(data, '\n'), 0644)
+}
+
+// unquoteJS returns the value of a JS string literal matched by
+// jsStringPattern, or "" for an empty match.
+func unquoteJS(lit string) string {
+	if len(lit) < 2 {
+		return ""
+	}
+	body := lit[1 : len(lit)-1]
+	switch lit[0] {
+	case '`':
+		return body
+	case '\'':
+		// Swap to double quotes so strconv can handle the escapes.
+		body = strings.ReplaceAll(body, `\'`, `'`)
+		body = strings.ReplaceAll(body, `"`, `\"`)
+	}
+	if s, err := strconv.Unquote(`"` + body + `"`); err == nil {
+		return s
+	}
+	return body
+}
@@ -0,0 +1,114 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMessageCallRe(t *testing.T) {
+	re := messageCallRe([]string{"t", "i18n.t"})
+	tests := []struct {
+		code        string
+		id, message string
+		match       bool
+	}{
+		{`t("greeting")`, `"greeting"`, "", true},
+		{`t( 'greeting' , "Hello" )`, `'greeting'`, `"Hello"`, true},
+		{"i18n.t(`farewell`, `Bye`)", "`farewell`", "`Bye`", true},
+		{`return t("a.b", "Say \"hi\"")`, `"a.b"`, `"Say \"hi\""`, true},
+		{`format(t("x"))`, `"x"`, "", true},
+		// Other functions ending in the name, and non-literal ids.
+		{`split("a")`, "", "", false},
+		{`$t("a")`, "", "", false},
+		{`t(key)`, "", "", false},
+		{"t(`greeting ${name}`)", "", "", false},
+	}
+	for _, tt := range tests {
+		m := re.FindStringSubmatch(tt.code)
+		if (m != nil) != tt.match {
+			t.Errorf("messageCallRe match %q = %v, want %v", tt.code, m != nil, tt.match)
+			continue
+		}
+		if m != nil && (m[1] != tt.id || m[2] != tt.message) {
+			t.Errorf("messageCallRe %q = (%s, %s), want (%s, %s)", tt.code, m[1], m[2], tt.id, tt.message)
+		}
+	}
+}
+
+func TestFormattedMessageRe(t *testing.T) {
+	code := `<p><FormattedMessage id="welcome" defaultMessage={"Welcome, {name}"} description='Shown on login' values={{ name }} /></p>
+<FormattedMessage
+  id={` + "`bye`" + `}
+  defaultMessage="Bye"
+>`
+	var got []string
+	for _, m := range formattedMessageRe.FindAllStringSubmatch(code, -1) {
+		attrs := make(map[string]string)
+		for _, a := range jsxAttrRe.FindAllStringSubmatch(m[1], -1) {
+			attrs[a[1]] = unquoteJS(a[2])
+		}
+		got = append(got, fmt.Sprintf("%s|%s|%s", attrs["id"], attrs["defaultMessage"], attrs["description"]))
+	}
+	want := []string{"welcome|Welcome, {name}|Shown on login", "bye|Bye|"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("FormattedMessage attributes = %q, want %q", got, want)
+	}
+}
+
+func TestUnquoteJS(t *testing.T) {
+	tests := map[string]string{
+		`"hello"`:        "hello",
+		`'hello'`:        "hello",
+		"`hello`":        "hello",
+		`"say \"hi\""`:   `say "hi"`,
+		`'it\'s'`:        "it's",
+		`'say "hi"'`:     `say "hi"`,
+		`"line\nbreak"`:  "line\nbreak",
+		`"café"`:         "café",
+		"`raw \\n kept`": `raw \n kept`,
+		`""`:             "",
+		"":               "",
+	}
+	for lit, want := range tests {
+		if got := unquoteJS(lit); got != want {
+			t.Errorf("unquoteJS(%q) = %q, want %q", lit, got, want)
+		}
+	}
+}
+
+func TestExtractMessages(t *testing.T) {
+	dir := t.TempDir()
+	app := filepath.Join(dir, "src", "app.tsx")
+	lib := filepath.Join(dir, "third_party", "lib", "index.js")
+	writeTestFile(t, app, `export const A = () => <h1>{t("title", "Home")}</h1>;
+export const B = () => <FormattedMessage id="title" description="Page title" />;`)
+	writeTestFile(t, lib, `t("lib.message", "From a package");`)
+	writeTestFile(t, filepath.Join(dir, "third_party", "lib", "package.json"), "{}")
+
+	metafile := fmt.Sprintf(`{"inputs": {%q: {}, %q: {}, "src/logo.svg": {}}, "outputs": {}}`, app, lib)
+	out := filepath.Join(dir, "out", "messages.json")
+	moduleMap := map[string]string{"lib": filepath.Join(dir, "third_party", "lib")}
+	if err := extractMessages(out, []string{"t"}, metafile, moduleMap); err != nil {
+		t.Fatalf("extractMessages() error: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var catalog map[string]message
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog) != 1 {
+		t.Fatalf("catalog = %v, want only the application's message", catalog)
+	}
+	got := catalog["title"]
+	if got.DefaultMessage != "Home" || got.Description != "Page title" || len(got.Files) != 1 || got.Files[0] != app {
+		t.Errorf("catalog[title] = %+v, want the call's default, the component's description and one file", got)
+	}
+}
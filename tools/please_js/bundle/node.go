@@ -0,0 +1,73 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// nodeShebang is prepended to node executables so they can be run directly.
+const nodeShebang = "#!/usr/bin/env node"
+
+// hasHashbang reports whether the file at path starts with "#!".
+func hasHashbang(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	buf := make([]byte, 2)
+	n, _ := f.Read(buf)
+	return n == 2 && string(buf) == "#!"
+}
+
+// finishNodeExecutable marks the entry output executable and writes a
+// package.json next to it declaring the module type, so Node interprets the
+// output correctly regardless of any package.json further up the tree.
+func finishNodeExecutable(args Args, format api.Format, metafile string) error {
+	entryOut := args.Out
+	outDir := filepath.Dir(args.Out)
+	if args.Splitting {
+		var meta metafileData
+		if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+			return fmt.Errorf("failed to parse metafile: %w", err)
+		}
+		entryOut = ""
+		for path, output := range meta.Outputs {
+			if output.EntryPoint == args.Entry && filepath.Ext(path) == ".js" {
+				entryOut = path
+			}
+		}
+		if entryOut == "" {
+			return fmt.Errorf("no entry point found in metafile")
+		}
+		outDir = args.OutDir
+	}
+
+	if err := os.Chmod(entryOut, 0755); err != nil {
+		return fmt.Errorf("failed to mark output executable: %w", err)
+	}
+	return writePackageType(outDir, format)
+}
+
+// writePackageType writes a package.json into outDir declaring the module
+// type of the format its JavaScript was bundled in.
+func writePackageType(outDir string, format api.Format) error {
+	pkgType := "commonjs"
+	if format == api.FormatESModule {
+		pkgType = "module"
+	}
+	data, err := json.MarshalIndent(struct {
+		Type string `json:"type"`
+	}{pkgType}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "package.json"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write package.json: %w", err)
+	}
+	return nil
+}
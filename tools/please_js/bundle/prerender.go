@@ -0,0 +1,232 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"tools/please_js/common"
+)
+
+// prerenderDOM is the module providing the DOM prerendered pages render in.
+// It comes from the moduleconfig like any other dependency.
+const prerenderDOM = "jsdom"
+
+// prerenderRunner renders each route of the app in jsdom. The app bundle is
+// evaluated in the route's window and the page is written out once the DOM
+// has been quiet for quietMs (or after 10s regardless). An uncaught error in
+// the app fails the route.
+const prerenderRunner = `
+import { mkdirSync, readFileSync, writeFileSync } from "node:fs";
+import { dirname } from "node:path";
+import { JSDOM, VirtualConsole } from "jsdom";
+
+const [htmlFile, appFile, origin, routesJSON, quietMs] = process.argv.slice(2);
+const html = readFileSync(htmlFile, "utf8");
+const app = readFileSync(appFile, "utf8");
+
+function settled(window, quiet) {
+  return new Promise((resolve) => {
+    let timer = setTimeout(done, quiet);
+    const observer = new window.MutationObserver(() => {
+      clearTimeout(timer);
+      timer = setTimeout(done, quiet);
+    });
+    const deadline = setTimeout(done, 10000);
+    function done() {
+      clearTimeout(timer);
+      clearTimeout(deadline);
+      observer.disconnect();
+      resolve();
+    }
+    observer.observe(window.document, { subtree: true, childList: true, attributes: true, characterData: true });
+  });
+}
+
+let failed = false;
+for (const { route, out } of JSON.parse(routesJSON)) {
+  const errors = [];
+  const virtualConsole = new VirtualConsole();
+  virtualConsole.sendTo(console, { omitJSDOMErrors: true });
+  virtualConsole.on("jsdomError", (err) => errors.push(err));
+  const dom = new JSDOM(html, { url: origin + route, runScripts: "outside-only", pretendToBeVisual: true, virtualConsole });
+  try {
+    dom.window.eval(app);
+    await settled(dom.window, Number(quietMs));
+  } catch (err) {
+    errors.push(err);
+  }
+  if (errors.length > 0) {
+    console.error("prerendering " + route + " failed: " + (errors[0].stack ?? errors[0]));
+    failed = true;
+  } else {
+    mkdirSync(dirname(out), { recursive: true });
+    writeFileSync(out, dom.serialize() + "\n");
+  }
+  dom.window.close();
+}
+process.exit(failed ? 1 : 0);
+`
+
+// prerenderQuietMs is how long the DOM must go unchanged before a page is
+// considered rendered.
+const prerenderQuietMs = 50
+
+// prerenderRoute is a route to prerender and the file its page goes to.
+type prerenderRoute struct {
+	Route string `json:"route"`
+	Out   string `json:"out"`
+}
+
+// parsePrerenderRoutes parses --prerender values, each a comma-separated
+// list of routes, into the files their pages are written to under outDir:
+// "/" to index.html and "/about" to about/index.html, so static hosts serve
+// them at the route's URL.
+func parsePrerenderRoutes(specs []string, outDir string) ([]prerenderRoute, error) {
+	var routes []prerenderRoute
+	seen := make(map[string]string)
+	for _, spec := range specs {
+		for _, route := range strings.Split(spec, ",") {
+			route = strings.TrimSpace(route)
+			if route == "" {
+				continue
+			}
+			if !strings.HasPrefix(route, "/") || strings.ContainsAny(route, "?#") {
+				return nil, fmt.Errorf("invalid --prerender route %q: must be a path starting with /", route)
+			}
+			clean := path.Clean(route)
+			if clean != strings.TrimSuffix(route, "/") && clean != route {
+				return nil, fmt.Errorf("invalid --prerender route %q: must not contain empty, . or .. segments", route)
+			}
+			rel := filepath.Join(filepath.FromSlash(strings.TrimPrefix(clean, "/")), "index.html")
+			if prev, ok := seen[rel]; ok {
+				return nil, fmt.Errorf("--prerender routes %q and %q both render to %s", prev, route, rel)
+			}
+			seen[rel] = route
+			routes = append(routes, prerenderRoute{Route: route, Out: filepath.Join(outDir, rel)})
+		}
+	}
+	return routes, nil
+}
+
+// prerender renders routes to static HTML by running the app in jsdom under
+// Node, starting from the generated index.html, which already references the
+// bundle's scripts and stylesheets. The app is bundled again as a single
+// script with the client's define, env and module resolution so it can be
+// evaluated in the page. Returns the files written.
+func prerender(args Args, routes []prerenderRoute, moduleMap, define map[string]string) ([]string, error) {
+	if _, ok := moduleMap[prerenderDOM]; !ok {
+		return nil, fmt.Errorf("--prerender requires %s as a dependency", prerenderDOM)
+	}
+	node := args.Node
+	if node == "" {
+		var err error
+		if node, err = exec.LookPath("node"); err != nil {
+			return nil, fmt.Errorf("--prerender needs Node.js: %w", err)
+		}
+	}
+
+	tmp, err := os.MkdirTemp("", "please_js-prerender")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	// The app, as a script evaluated in each page's window. Assets are named
+	// as in the client build so the rendered markup references its files.
+	plugins, err := bundlePlugins(args, "browser", moduleMap, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Already validated for the client build.
+	resolveExtensions, _ := common.ResolveExtensions(args.Platform, args.Flavor)
+	appFile := filepath.Join(tmp, "app.js")
+	result := api.Build(api.BuildOptions{
+		EntryPoints:       []string{args.Entry},
+		Bundle:            true,
+		Write:             true,
+		Format:            api.FormatIIFE,
+		Platform:          api.PlatformBrowser,
+		Target:            api.ESNext,
+		LogLevel:          api.LogLevelWarning,
+		External:          args.External,
+		Loader:            common.Loaders,
+		Plugins:           plugins,
+		Define:            define,
+		JSX:               api.JSXAutomatic,
+		Outfile:           appFile,
+		AssetNames:        "assets/[name]-[hash]",
+		ResolveExtensions: resolveExtensions,
+		Tsconfig:          args.Tsconfig,
+	})
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("esbuild prerender bundle failed with %d errors", len(result.Errors))
+	}
+
+	// The runner, bundled with jsdom so its dependencies resolve through the
+	// moduleconfig. canvas is an optional jsdom dependency that isn't needed.
+	runnerFile := filepath.Join(tmp, "runner.mjs")
+	result = api.Build(api.BuildOptions{
+		Stdin: &api.StdinOptions{
+			Contents:   prerenderRunner,
+			ResolveDir: ".",
+			Sourcefile: "prerender.js",
+		},
+		Bundle:   true,
+		Write:    true,
+		Format:   api.FormatESModule,
+		Platform: api.PlatformNode,
+		Target:   api.ESNext,
+		LogLevel: api.LogLevelError,
+		External: []string{"canvas"},
+		Plugins:  []api.Plugin{common.ModuleResolvePlugin(moduleMap, "node")},
+		Outfile:  runnerFile,
+		// jsdom's CommonJS dependencies call require, which ESM output lacks.
+		Banner: map[string]string{"js": `import { createRequire } from "node:module"; const require = createRequire(import.meta.url);`},
+	})
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("esbuild prerender runner bundle failed with %d errors", len(result.Errors))
+	}
+
+	routesJSON, err := json.Marshal(routes)
+	if err != nil {
+		return nil, err
+	}
+	origin := "http://localhost" + strings.TrimSuffix(baseURL(define), "/")
+	var stderr bytes.Buffer
+	cmd := exec.Command(node, runnerFile, filepath.Join(args.OutDir, "index.html"), appFile, origin, string(routesJSON), fmt.Sprint(prerenderQuietMs))
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("prerendering failed: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// index.html, which "/" replaces, is already an output.
+	var outputs []string
+	for _, route := range routes {
+		if route.Out != filepath.Join(args.OutDir, "index.html") {
+			outputs = append(outputs, route.Out)
+		}
+	}
+	return outputs, nil
+}
+
+// baseURL returns import.meta.env.BASE_URL from a define map, with a
+// trailing slash.
+func baseURL(define map[string]string) string {
+	var base string
+	if err := json.Unmarshal([]byte(define["import.meta.env.BASE_URL"]), &base); err != nil || base == "" {
+		return "/"
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return base
+}
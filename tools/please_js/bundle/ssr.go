@@ -0,0 +1,194 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"tools/please_js/common"
+)
+
+// ssrManifestName is written into the server output directory.
+const ssrManifestName = "ssr-manifest.json"
+
+// buildSSRServer bundles args.SSREntry for Node into args.SSROutDir, paired
+// with the client build that just finished: it shares the client's define
+// and env (with import.meta.env.SSR set to true) and its module resolution,
+// so both sides render the same code. It also writes ssr-manifest.json,
+// mapping each source module to the client files a page rendering it should
+// preload, and a package.json marking the bundle as ES modules so Node can
+// import it directly. Returns the files written.
+func buildSSRServer(args Args, moduleMap, define map[string]string, clientMetafile string) ([]string, error) {
+	if err := os.MkdirAll(args.SSROutDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create SSR output directory: %w", err)
+	}
+
+	serverDefine := make(map[string]string, len(define)+1)
+	for k, v := range define {
+		serverDefine[k] = v
+	}
+	serverDefine["import.meta.env.SSR"] = "true"
+
+	// Import maps are resolved by browsers, so the server bundles what the
+	// client leaves to the map.
+	plugins, err := bundlePlugins(args, "node", moduleMap, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Already validated for the client build.
+	resolveExtensions, _ := common.ResolveExtensions("node", args.Flavor)
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{args.SSREntry},
+		Bundle:      true,
+		Write:       true,
+		Format:      api.FormatESModule,
+		Platform:    api.PlatformNode,
+		Target:      api.ESNext,
+		LogLevel:    api.LogLevelInfo,
+		External:    args.External,
+		Loader:      common.Loaders,
+		Plugins:     plugins,
+		Define:      serverDefine,
+		JSX:         api.JSXAutomatic,
+		Sourcemap:   api.SourceMapLinked,
+		Outdir:      args.SSROutDir,
+		Splitting:   true,
+		ChunkNames:  "chunk-[hash]",
+		AssetNames:  "assets/[name]-[hash]",
+		Metafile:    true,
+		// The server renders the same flavor as the client.
+		ResolveExtensions: resolveExtensions,
+	})
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("esbuild SSR server bundle failed with %d errors", len(result.Errors))
+	}
+
+	var meta metafileData
+	if err := json.Unmarshal([]byte(result.Metafile), &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metafile: %w", err)
+	}
+	outputs := make([]string, 0, len(meta.Outputs)+2)
+	for path := range meta.Outputs {
+		outputs = append(outputs, path)
+	}
+	// Node treats .js as CommonJS unless told otherwise.
+	if err := writePackageType(args.SSROutDir, api.FormatESModule); err != nil {
+		return nil, err
+	}
+	outputs = append(outputs, filepath.Join(args.SSROutDir, "package.json"))
+
+	root := args.ViteManifestRoot
+	if root == "" {
+		root = "."
+	}
+	manifestPath := filepath.Join(args.SSROutDir, ssrManifestName)
+	if err := writeSSRManifest(manifestPath, args.OutDir, root, baseURL(define), clientMetafile); err != nil {
+		return nil, fmt.Errorf("failed to write SSR manifest: %w", err)
+	}
+	return append(outputs, manifestPath), nil
+}
+
+// writeSSRManifest writes a Vite-style SSR manifest. Each source module,
+// keyed relative to root, maps to the client files a page that rendered it
+// should preload, as URLs under base: the chunk containing it, everything that
+// chunk statically imports, and the stylesheets holding the CSS it imports.
+// After rendering, the server looks up the modules it used to emit the tags.
+func writeSSRManifest(outPath, clientOutDir, root, base, clientMetafile string) error {
+	var meta struct {
+		Inputs map[string]struct {
+			Imports []metafileImport `json:"imports"`
+		} `json:"inputs"`
+		Outputs map[string]metafileOutput `json:"outputs"`
+	}
+	if err := json.Unmarshal([]byte(clientMetafile), &meta); err != nil {
+		return fmt.Errorf("failed to parse metafile: %w", err)
+	}
+	srcKey, err := sourceKeys(root)
+	if err != nil {
+		return err
+	}
+
+	// The same stylesheet can end up in several CSS outputs (one per entry
+	// point importing it), so each CSS source maps to all of them.
+	cssOutputs := make(map[string][]string)
+	for path, output := range meta.Outputs {
+		if strings.HasSuffix(path, ".css") {
+			for input := range output.Inputs {
+				cssOutputs[input] = append(cssOutputs[input], path)
+			}
+		}
+	}
+
+	// staticClosure returns a chunk and the chunks and assets it statically
+	// imports, with their CSS bundles.
+	var staticClosure func(chunk string, seen map[string]bool) []string
+	staticClosure = func(chunk string, seen map[string]bool) []string {
+		if seen[chunk] {
+			return nil
+		}
+		seen[chunk] = true
+		output := meta.Outputs[chunk]
+		files := []string{chunk}
+		if output.CSSBundle != "" {
+			files = append(files, output.CSSBundle)
+		}
+		for _, imp := range output.Imports {
+			if imp.External || imp.Kind == "dynamic-import" {
+				continue
+			}
+			if isJSOutput(imp.Path) {
+				files = append(files, staticClosure(imp.Path, seen)...)
+			} else if _, ok := meta.Outputs[imp.Path]; ok {
+				files = append(files, imp.Path)
+			}
+		}
+		return files
+	}
+
+	manifest := make(map[string][]string)
+	add := func(input string, files []string) {
+		// Plugin namespaces ("remote:", "raw-import:") aren't modules the
+		// server can report.
+		if strings.Contains(input, ":") {
+			return
+		}
+		key := srcKey(input)
+		for _, file := range files {
+			manifest[key] = append(manifest[key], base+relSlash(clientOutDir, file))
+		}
+	}
+	for path, output := range meta.Outputs {
+		if !isJSOutput(path) {
+			continue
+		}
+		closure := staticClosure(path, make(map[string]bool))
+		for input := range output.Inputs {
+			if strings.HasSuffix(input, ".css") {
+				continue
+			}
+			files := append([]string(nil), closure...)
+			for _, imp := range meta.Inputs[input].Imports {
+				files = append(files, cssOutputs[imp.Path]...)
+			}
+			add(input, files)
+		}
+	}
+	for input, outputs := range cssOutputs {
+		add(input, outputs)
+	}
+	for key, urls := range manifest {
+		sort.Strings(urls)
+		manifest[key] = dedupeSorted(urls)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, append(data, '\n'), 0644)
+}
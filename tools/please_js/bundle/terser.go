@@ -0,0 +1,108 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Minifiers accepted by --minify-with.
+const (
+	minifierEsbuild = "esbuild"
+	minifierTerser  = "terser"
+)
+
+// terserOutputs returns the JavaScript files esbuild wrote, from the metafile.
+func terserOutputs(metafile string) ([]string, error) {
+	var meta metafileData
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metafile: %w", err)
+	}
+	var outputs []string
+	for path := range meta.Outputs {
+		if isJSOutput(path) {
+			outputs = append(outputs, path)
+		}
+	}
+	sort.Strings(outputs)
+	return outputs, nil
+}
+
+// runTerser minifies each JavaScript output in place with terser, composing
+// its source map with esbuild's so mappings still point at the original
+// sources. Outputs are independent, so they're minified in parallel.
+func runTerser(args Args, metafile string) error {
+	outputs, err := terserOutputs(metafile)
+	if err != nil {
+		return err
+	}
+
+	sem := make(chan struct{}, runtime.NumCPU())
+	errs := make([]error, len(outputs))
+	var wg sync.WaitGroup
+	for i, out := range outputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = terserFile(args, out)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// terserFile minifies one output. terser writes to a temporary file that is
+// renamed over the original, so a failure never leaves a half-written output.
+func terserFile(args Args, out string) error {
+	tmp := out + ".terser-tmp.js"
+	cmdArgs := []string{out, "--output", tmp}
+	if args.TerserConfig != "" {
+		cmdArgs = append(cmdArgs, "--config-file", args.TerserConfig)
+	} else {
+		cmdArgs = append(cmdArgs, "--compress", "--mangle")
+	}
+	if args.Format == "" || args.Format == "esm" || args.Splitting {
+		cmdArgs = append(cmdArgs, "--module")
+	}
+	mapFile := out + ".map"
+	hasMap := fileExists(mapFile)
+	if hasMap {
+		cmdArgs = append(cmdArgs, "--source-map", fmt.Sprintf("content='%s',filename='%s',url='%s'", mapFile, filepath.Base(out), filepath.Base(mapFile)))
+	}
+
+	bin, binArgs := args.TerserBin, cmdArgs
+	if args.Node != "" {
+		bin, binArgs = args.Node, append([]string{args.TerserBin}, cmdArgs...)
+	}
+	cmd := exec.Command(bin, binArgs...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmp)
+		os.Remove(tmp + ".map")
+		return fmt.Errorf("terser failed on %s: %w\n%s", out, err, strings.TrimSpace(string(output)))
+	}
+
+	if hasMap {
+		if err := os.Rename(tmp+".map", mapFile); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp, out)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
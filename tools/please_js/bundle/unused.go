@@ -0,0 +1,132 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"tools/please_js/common"
+)
+
+// unusedSourceExts are the file extensions considered by the unused-files
+// report. Assets are left out: they're often referenced from HTML or CSS
+// that never enters the module graph.
+var unusedSourceExts = map[string]bool{
+	".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".mjs": true, ".cjs": true,
+	".css": true,
+}
+
+// defaultUnusedIgnores are globs for files that are never reachable from an
+// application entry point but aren't dead either.
+var defaultUnusedIgnores = []string{
+	"**/*.d.ts",
+	"**/*.test.*",
+	"**/*.spec.*",
+	"**/__tests__/**",
+	"**/*.stories.*",
+}
+
+// writeUnusedFiles writes the source files under roots that the bundle of
+// entry never loaded to outPath, one path per line relative to the working
+// directory. Files loaded only by worklet and worker builds, given as
+// absolute workerInputs, count as used. Hidden directories, node_modules and
+// plz-out are skipped, as are files matching defaultUnusedIgnores or the
+// given ignore globs (relative to each root).
+func writeUnusedFiles(outPath, entry string, roots, ignores []string, metafile string, workerInputs []string) error {
+	var meta metafileData
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return fmt.Errorf("failed to parse metafile: %w", err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	used := make(map[string]bool, len(meta.Inputs))
+	for path := range meta.Inputs {
+		used[filepath.ToSlash(path)] = true
+	}
+	for _, path := range workerInputs {
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			used[filepath.ToSlash(rel)] = true
+		}
+	}
+
+	var unused []string
+	for _, root := range roots {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return err
+		}
+		var patterns []*regexp.Regexp
+		for _, glob := range append(defaultUnusedIgnores, ignores...) {
+			patterns = append(patterns, common.CompileGlob(filepath.ToSlash(filepath.Join(absRoot, glob)))...)
+		}
+
+		err = filepath.Walk(absRoot, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				name := info.Name()
+				if path != absRoot && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "plz-out") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !unusedSourceExts[filepath.Ext(path)] {
+				return nil
+			}
+			slashed := filepath.ToSlash(path)
+			for _, re := range patterns {
+				if re.MatchString(slashed) {
+					return nil
+				}
+			}
+			rel, err := filepath.Rel(cwd, path)
+			if err != nil {
+				return nil
+			}
+			rel = filepath.ToSlash(rel)
+			if !used[rel] {
+				unused = append(unused, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	sort.Strings(unused)
+	unused = dedupeSorted(unused)
+
+	if dir := filepath.Dir(outPath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	var b strings.Builder
+	for _, path := range unused {
+		b.WriteString(path)
+		b.WriteString("\n")
+	}
+	if len(unused) > 0 {
+		fmt.Fprintf(os.Stderr, "%d source files are not reachable from %s (see %s)\n", len(unused), entry, outPath)
+	}
+	return os.WriteFile(outPath, []byte(b.String()), 0644)
+}
+
+// dedupeSorted removes adjacent duplicates from a sorted list. Overlapping
+// roots would otherwise report the same file twice.
+func dedupeSorted(items []string) []string {
+	var result []string
+	for i, item := range items {
+		if i == 0 || item != items[i-1] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteUnusedFiles(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel := func(path string) string {
+		r, err := filepath.Rel(cwd, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return filepath.ToSlash(r)
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	files := map[string]string{
+		"main.tsx":                  `import "./app";`,
+		"app.tsx":                   `new Worker(new URL("./worker.ts", import.meta.url));`,
+		"worker.ts":                 `import "./worker_util";`,
+		"worker_util.ts":            "",
+		"dead.ts":                   "",
+		"styles/dead.css":           "",
+		"app.test.ts":               "",
+		"types.d.ts":                "",
+		"logo.svg":                  "",
+		"generated/schema.ts":       "",
+		"node_modules/lib/index.js": "",
+		".cache/stale.js":           "",
+	}
+	for path, content := range files {
+		writeTestFile(t, filepath.Join(src, filepath.FromSlash(path)), content)
+	}
+
+	// The main build loads the entry and app; the worker build the rest.
+	metafile := fmt.Sprintf(`{"inputs": {%q: {}, %q: {}}, "outputs": {}}`,
+		rel(filepath.Join(src, "main.tsx")), rel(filepath.Join(src, "app.tsx")))
+	workerInputs := []string{filepath.Join(src, "worker.ts"), filepath.Join(src, "worker_util.ts")}
+	out := filepath.Join(dir, "out", "unused.txt")
+	err = writeUnusedFiles(out, filepath.Join(src, "main.tsx"), []string{src}, []string{"generated/**"}, metafile, workerInputs)
+	if err != nil {
+		t.Fatalf("writeUnusedFiles() error: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{rel(filepath.Join(src, "dead.ts")), rel(filepath.Join(src, "styles", "dead.css"))}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unused files = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// viteManifestChunk is one entry of a Vite build manifest
+// (https://vite.dev/guide/backend-integration). Keys into the manifest are
+// source paths relative to the project root, or "_<file name>" for shared
+// chunks that have no source of their own.
+type viteManifestChunk struct {
+	File           string   `json:"file"`
+	Name           string   `json:"name,omitempty"`
+	Src            string   `json:"src,omitempty"`
+	IsEntry        bool     `json:"isEntry,omitempty"`
+	IsDynamicEntry bool     `json:"isDynamicEntry,omitempty"`
+	Imports        []string `json:"imports,omitempty"`
+	DynamicImports []string `json:"dynamicImports,omitempty"`
+	CSS            []string `json:"css,omitempty"`
+	Assets         []string `json:"assets,omitempty"`
+}
+
+// writeViteManifest writes the bundle's outputs to outPath in the schema of
+// Vite's manifest.json, so backend helpers that render script and link tags
+// from a Vite manifest can serve the bundle unchanged. File paths are
+// relative to outDir and source paths relative to root.
+func writeViteManifest(outPath, outDir, root, entry, metafile string) error {
+	var meta metafileData
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return fmt.Errorf("failed to parse metafile: %w", err)
+	}
+	srcKey, err := sourceKeys(root)
+	if err != nil {
+		return err
+	}
+	fileOf := func(output string) string { return relSlash(outDir, output) }
+
+	// First pass: give every JS chunk and asset its manifest key. CSS is
+	// attached to the chunks that import it rather than listed on its own.
+	keys := make(map[string]string, len(meta.Outputs))
+	for path, output := range meta.Outputs {
+		switch {
+		case strings.HasSuffix(path, ".map"), strings.HasSuffix(path, ".css"):
+		case isJSOutput(path) && output.EntryPoint != "":
+			keys[path] = srcKey(output.EntryPoint)
+		case isJSOutput(path):
+			keys[path] = "_" + filepath.Base(path)
+		case len(output.Inputs) == 1:
+			for input := range output.Inputs {
+				keys[path] = srcKey(input)
+			}
+		}
+	}
+
+	manifest := make(map[string]*viteManifestChunk, len(keys))
+	for path, key := range keys {
+		output := meta.Outputs[path]
+		chunk := &viteManifestChunk{File: fileOf(path)}
+		switch {
+		case isJSOutput(path) && output.EntryPoint != "":
+			chunk.Src = key
+			chunk.Name = strings.TrimSuffix(filepath.Base(output.EntryPoint), filepath.Ext(output.EntryPoint))
+			if output.EntryPoint == entry {
+				chunk.IsEntry = true
+			} else {
+				chunk.IsDynamicEntry = true
+			}
+		case !isJSOutput(path):
+			chunk.Src = key
+		}
+		if output.CSSBundle != "" {
+			chunk.CSS = append(chunk.CSS, fileOf(output.CSSBundle))
+		}
+		for _, imp := range output.Imports {
+			target, ok := keys[imp.Path]
+			switch {
+			case strings.HasSuffix(imp.Path, ".css") && !imp.External:
+				chunk.CSS = append(chunk.CSS, fileOf(imp.Path))
+			case !ok:
+				// External imports aren't part of the manifest.
+			case !isJSOutput(imp.Path):
+				chunk.Assets = append(chunk.Assets, fileOf(imp.Path))
+			case imp.Kind == "dynamic-import":
+				chunk.DynamicImports = append(chunk.DynamicImports, target)
+			default:
+				chunk.Imports = append(chunk.Imports, target)
+			}
+		}
+		for _, list := range []*[]string{&chunk.CSS, &chunk.Assets, &chunk.Imports, &chunk.DynamicImports} {
+			sort.Strings(*list)
+			*list = dedupeSorted(*list)
+		}
+		manifest[key] = chunk
+	}
+
+	// Map keys are sorted by encoding/json, keeping the output reproducible.
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(outPath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(outPath, append(data, '\n'), 0644)
+}
+
+// sourceKeys returns a function mapping source paths to manifest keys,
+// relative to root.
+func sourceKeys(root string) (func(string) string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return func(path string) string {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return filepath.ToSlash(path)
+		}
+		return relSlash(absRoot, abs)
+	}, nil
+}
+
+// relSlash returns path relative to dir with forward slashes, or path itself
+// if it can't be made relative.
+func relSlash(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// isJSOutput reports whether an output path is a JavaScript chunk.
+func isJSOutput(path string) bool {
+	switch filepath.Ext(path) {
+	case ".js", ".mjs", ".cjs":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// ParseAssetInlineOverrides parses "ext=limit" strings (e.g. ".svg=8192" or
+// "woff2=0") into a per-extension inline limit map. Extensions are normalised
+// to lower case with the leading dot.
+func ParseAssetInlineOverrides(specs []string) (map[string]int, error) {
+	result := make(map[string]int, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid asset inline override %q (expected ext=limit)", spec)
+		}
+		ext := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(parts[0]), "."))
+		if ext == "" {
+			return nil, fmt.Errorf("invalid asset inline override %q: missing extension", spec)
+		}
+		ext = "." + ext
+		limit, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset inline limit in %q: %w", spec, err)
+		}
+		result[ext] = limit
+	}
+	return result, nil
+}
+
+// AssetInlinePlugin returns an esbuild plugin that inlines small file-loader
+// assets (images, fonts, media) as data URLs instead of emitting them
+// as separate files. Files at or below the limit for their extension are
+// inlined; larger files fall through to the default file loader. A limit of
+// zero disables inlining for that extension.
+func AssetInlinePlugin(limit int, overrides map[string]int) api.Plugin {
+	var exts []string
+	for ext, loader := range Loaders {
+		if loader == api.LoaderFile {
+			exts = append(exts, regexp.QuoteMeta(ext))
+		}
+	}
+	sort.Strings(exts)
+	filter := `(?i)(` + strings.Join(exts, "|") + `)$`
+
+	return api.Plugin{
+		Name: "asset-inline",
+		Setup: func(build api.PluginBuild) {
+			build.OnLoad(api.OnLoadOptions{Filter: filter, Namespace: "file"},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					extLimit := limit
+					if l, ok := overrides[strings.ToLower(filepath.Ext(args.Path))]; ok {
+						extLimit = l
+					}
+					if extLimit <= 0 {
+						return api.OnLoadResult{}, nil
+					}
+					info, err := os.Stat(args.Path)
+					if err != nil || info.Size() > int64(extLimit) {
+						return api.OnLoadResult{}, nil
+					}
+					data, err := os.ReadFile(args.Path)
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+					contents := string(data)
+					return api.OnLoadResult{
+						Contents: &contents,
+						Loader:   api.LoaderDataURL,
+					}, nil
+				},
+			)
+		},
+	}
+}
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestParseAssetInlineOverrides(t *testing.T) {
+	got, err := ParseAssetInlineOverrides([]string{".svg=8192", "woff2=0", ".PNG=1024"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[".svg"] != 8192 {
+		t.Errorf(".svg = %d, want 8192", got[".svg"])
+	}
+	if l, ok := got[".woff2"]; !ok || l != 0 {
+		t.Errorf(".woff2 = %d (present %v), want 0", l, ok)
+	}
+	if got[".png"] != 1024 {
+		t.Errorf(".png = %d, want 1024 from .PNG", got[".png"])
+	}
+
+	for _, bad := range []string{"svg", ".svg=big", "=8192", ".=8192"} {
+		if _, err := ParseAssetInlineOverrides([]string{bad}); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestAssetInlinePlugin(t *testing.T) {
+	tmp := t.TempDir()
+	small := filepath.Join(tmp, "small.png")
+	large := filepath.Join(tmp, "large.png")
+	icon := filepath.Join(tmp, "icon.svg")
+	os.WriteFile(small, []byte("tiny"), 0o644)
+	os.WriteFile(large, []byte(strings.Repeat("x", 100)), 0o644)
+	os.WriteFile(icon, []byte("<svg/>"), 0o644)
+	entry := filepath.Join(tmp, "entry.js")
+	os.WriteFile(entry, []byte(
+		`import a from "./small.png"; import b from "./large.png"; import c from "./icon.svg"; console.log(a, b, c);`,
+	), 0o644)
+
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{entry},
+		Bundle:      true,
+		Write:       false,
+		Outdir:      filepath.Join(tmp, "out"),
+		Loader:      Loaders,
+		Plugins: []api.Plugin{
+			AssetInlinePlugin(16, map[string]int{".svg": 0}),
+		},
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build errors: %v", result.Errors[0].Text)
+	}
+
+	var js string
+	var emitted []string
+	for _, f := range result.OutputFiles {
+		if strings.HasSuffix(f.Path, ".js") {
+			js = string(f.Contents)
+		} else {
+			emitted = append(emitted, filepath.Base(f.Path))
+		}
+	}
+
+	if !strings.Contains(js, `"data:image/png`) {
+		t.Error("expected small.png to be inlined as a data URL")
+	}
+	var sawLarge, sawIcon bool
+	for _, name := range emitted {
+		if strings.HasPrefix(name, "large") {
+			sawLarge = true
+		}
+		if strings.HasPrefix(name, "icon") {
+			sawIcon = true
+		}
+		if strings.HasPrefix(name, "small") {
+			t.Errorf("small.png should not be emitted as a file, got %s", name)
+		}
+	}
+	if !sawLarge {
+		t.Error("expected large.png above the limit to be emitted as a file")
+	}
+	if !sawIcon {
+		t.Error("expected .svg override of 0 to disable inlining")
+	}
+}
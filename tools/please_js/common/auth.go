@@ -0,0 +1,94 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// authCookie is the cookie BasicAuth sets once a browser has logged in.
+const authCookie = "please_js_auth"
+
+// BasicAuth guards a dev server with a username and password, for servers
+// on a shared or VPN network.
+//
+// A browser that logs in also gets a session cookie, which BasicAuth
+// accepts in place of the password. The live reload endpoints rely on it:
+// EventSource can't set an Authorization header, and a page's own API calls
+// through a proxy rule may carry one meant for the backend.
+type BasicAuth struct {
+	user, pass string
+	session    string // the session cookie's value
+}
+
+// NewBasicAuth returns the guard for --auth, "user:pass": nil, for none,
+// without one.
+func NewBasicAuth(spec string) (*BasicAuth, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	user, pass, ok := strings.Cut(spec, ":")
+	if !ok || user == "" || pass == "" {
+		return nil, fmt.Errorf("invalid --auth %q: expected user:pass", spec)
+	}
+	// The session is only valid for this run of the server: a restart logs
+	// everyone out, as the browser logs back in without asking.
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate an auth session key: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(spec))
+	return &BasicAuth{user: user, pass: pass, session: hex.EncodeToString(mac.Sum(nil))}, nil
+}
+
+// Check reports whether a request may go on, answering it with 401 and a
+// login prompt if not. CORS preflights, which never carry credentials, may
+// go on. The server's own credentials are removed from the request once
+// checked, so they aren't forwarded to proxy targets. A nil BasicAuth lets
+// everything through.
+func (a *BasicAuth) Check(w http.ResponseWriter, r *http.Request) bool {
+	if a == nil {
+		return true
+	}
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		return true
+	}
+	if user, pass, ok := r.BasicAuth(); ok && a.matches(user, pass) {
+		r.Header.Del("Authorization")
+		if !a.hasSession(r) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     authCookie,
+				Value:    a.session,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		return true
+	}
+	if a.hasSession(r) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="please_js dev server", charset="UTF-8"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+// matches compares credentials in constant time.
+func (a *BasicAuth) matches(user, pass string) bool {
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.user)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(a.pass)) == 1
+	return userOK && passOK
+}
+
+// hasSession reports whether a request carries the session cookie.
+func (a *BasicAuth) hasSession(r *http.Request) bool {
+	c, err := r.Cookie(authCookie)
+	return err == nil && subtle.ConstantTimeCompare([]byte(c.Value), []byte(a.session)) == 1
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewBasicAuth(t *testing.T) {
+	if a, err := NewBasicAuth(""); err != nil || a != nil {
+		t.Errorf("expected no auth without --auth, got %v, %v", a, err)
+	}
+	for _, bad := range []string{"admin", ":secret", "admin:"} {
+		if _, err := NewBasicAuth(bad); err == nil {
+			t.Errorf("expected NewBasicAuth(%q) to fail", bad)
+		}
+	}
+}
+
+func TestBasicAuthCheck(t *testing.T) {
+	a, err := NewBasicAuth("admin:s3cr:et")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	if a.Check(w, httptest.NewRequest("GET", "/", nil)) {
+		t.Fatal("expected a request without credentials to be refused")
+	}
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected a 401 login prompt, got %d %v", w.Code, w.Header())
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.SetBasicAuth("admin", "wrong")
+	if a.Check(httptest.NewRecorder(), r) {
+		t.Error("expected a wrong password to be refused")
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.SetBasicAuth("admin", "s3cr:et")
+	w = httptest.NewRecorder()
+	if !a.Check(w, r) {
+		t.Fatal("expected the right credentials to be let through")
+	}
+	if r.Header.Get("Authorization") != "" {
+		t.Error("expected the credentials to be removed from the request")
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != authCookie {
+		t.Fatalf("expected a session cookie, got %v", cookies)
+	}
+
+	// The live reload stream can't send credentials, only the cookie.
+	r = httptest.NewRequest("GET", "/esbuild", nil)
+	r.AddCookie(cookies[0])
+	if !a.Check(httptest.NewRecorder(), r) {
+		t.Error("expected the session cookie to be let through")
+	}
+	r = httptest.NewRequest("GET", "/esbuild", nil)
+	r.AddCookie(&http.Cookie{Name: authCookie, Value: "forged"})
+	if a.Check(httptest.NewRecorder(), r) {
+		t.Error("expected a forged session cookie to be refused")
+	}
+
+	r = httptest.NewRequest("OPTIONS", "/main.js", nil)
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	if !a.Check(httptest.NewRecorder(), r) {
+		t.Error("expected a CORS preflight to be let through")
+	}
+
+	if !(*BasicAuth)(nil).Check(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil)) {
+		t.Error("expected nil auth to let everything through")
+	}
+}
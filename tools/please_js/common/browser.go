@@ -0,0 +1,31 @@
+package common
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// OpenBrowser opens url in the default browser, without waiting for it.
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// OpenURL returns the URL --open opens for a dev server at origin: path,
+// which may leave out its leading slash, or the root if it's empty.
+func OpenURL(origin, path string) string {
+	return origin + "/" + strings.TrimPrefix(path, "/")
+}
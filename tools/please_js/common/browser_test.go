@@ -0,0 +1,15 @@
+package common
+
+import "testing"
+
+func TestOpenURL(t *testing.T) {
+	for path, want := range map[string]string{
+		"/":         "https://localhost:8080/",
+		"/admin":    "https://localhost:8080/admin",
+		"admin?x=1": "https://localhost:8080/admin?x=1",
+	} {
+		if got := OpenURL("https://localhost:8080", path); got != want {
+			t.Errorf("OpenURL(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
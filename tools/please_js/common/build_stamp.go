@@ -0,0 +1,36 @@
+package common
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// BuildStamp identifies one rebuild or HMR update of a dev server: a
+// sequence number that only goes up, and when it happened. Dev servers
+// print it in their logs and send it with every live-reload event, so the
+// browser console can say which rebuild it's reacting to.
+type BuildStamp struct {
+	ID   int64
+	Time time.Time
+}
+
+// String formats the stamp as "#12 14:03:22.481".
+func (b BuildStamp) String() string {
+	return fmt.Sprintf("#%d %s", b.ID, b.Clock())
+}
+
+// Clock returns the stamp's wall-clock time to the millisecond.
+func (b BuildStamp) Clock() string {
+	return b.Time.Format("15:04:05.000")
+}
+
+// BuildCounter hands out BuildStamps. It's safe for concurrent use.
+type BuildCounter struct {
+	n atomic.Int64
+}
+
+// Next returns a stamp for a new build, numbered one more than the last.
+func (c *BuildCounter) Next() BuildStamp {
+	return BuildStamp{ID: c.n.Add(1), Time: time.Now()}
+}
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBuildStampString(t *testing.T) {
+	stamp := BuildStamp{ID: 12, Time: time.Date(2024, 1, 2, 14, 3, 22, 481_000_000, time.UTC)}
+	if got := stamp.String(); got != "#12 14:03:22.481" {
+		t.Errorf("String() = %q, want %q", got, "#12 14:03:22.481")
+	}
+}
+
+func TestBuildCounterIsMonotonic(t *testing.T) {
+	var c BuildCounter
+	var wg sync.WaitGroup
+	ids := make(chan int64, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids <- c.Next().ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	seen := map[int64]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("ID %d handed out twice", id)
+		}
+		seen[id] = true
+	}
+	if next := c.Next().ID; next != 101 {
+		t.Errorf("expected the 101st stamp to be #101, got #%d", next)
+	}
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// TreeNode is a node of a build's size tree, as a treemap draws it: an
+// output file, a directory of the inputs in it, or an input.
+type TreeNode struct {
+	Name     string      `json:"name"`
+	Bytes    int         `json:"bytes"`
+	Children []*TreeNode `json:"children,omitempty"`
+}
+
+// BundleTree returns the size tree of a build from its esbuild metafile:
+// each output, holding its inputs grouped by directory, each sized by the
+// bytes it adds to the output. Sourcemaps are left out. Chains of
+// directories with one child are merged, so src/components/ui is one node
+// rather than three.
+func BundleTree(metafile string) (*TreeNode, error) {
+	var meta struct {
+		Outputs map[string]struct {
+			Bytes  int `json:"bytes"`
+			Inputs map[string]struct {
+				BytesInOutput int `json:"bytesInOutput"`
+			} `json:"inputs"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metafile: %w", err)
+	}
+	root := &TreeNode{Name: "/"}
+	for outPath, out := range meta.Outputs {
+		if strings.HasSuffix(outPath, ".map") {
+			continue
+		}
+		output := &TreeNode{Name: outPath, Bytes: out.Bytes}
+		for inPath, in := range out.Inputs {
+			if in.BytesInOutput == 0 {
+				continue
+			}
+			output.add(inputSegments(inPath), in.BytesInOutput)
+		}
+		for _, child := range output.Children {
+			child.collapse()
+		}
+		output.sort()
+		root.Children = append(root.Children, output)
+		root.Bytes += output.Bytes
+	}
+	root.sort()
+	return root, nil
+}
+
+// inputSegments splits an input's path into the directories to file it
+// under. Leading ../ segments, as inputs outside the working directory
+// have, don't make directories of their own.
+func inputSegments(p string) []string {
+	// Inputs from plugins are namespaced, e.g. "raw:src/shader.glsl".
+	if i := strings.Index(p, ":"); i > 0 && !strings.Contains(p[:i], "/") {
+		p = p[i+1:]
+	}
+	var segments []string
+	for _, s := range strings.Split(path.Clean(p), "/") {
+		if s != ".." && s != "." && s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// add adds bytes under the path of segments, creating directories as it
+// goes. The node's own size is left alone: an output's is its file's, which
+// counts the runtime code between its inputs too.
+func (n *TreeNode) add(segments []string, bytes int) {
+	node := n
+	for _, s := range segments {
+		var child *TreeNode
+		for _, c := range node.Children {
+			if c.Name == s {
+				child = c
+				break
+			}
+		}
+		if child == nil {
+			child = &TreeNode{Name: s}
+			node.Children = append(node.Children, child)
+		}
+		child.Bytes += bytes
+		node = child
+	}
+}
+
+// collapse merges chains of directories with one child.
+func (n *TreeNode) collapse() {
+	for len(n.Children) == 1 && len(n.Children[0].Children) > 0 {
+		only := n.Children[0]
+		n.Name += "/" + only.Name
+		n.Children = only.Children
+	}
+	for _, child := range n.Children {
+		child.collapse()
+	}
+}
+
+// sort sorts the tree largest first.
+func (n *TreeNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		if n.Children[i].Bytes != n.Children[j].Bytes {
+			return n.Children[i].Bytes > n.Children[j].Bytes
+		}
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, child := range n.Children {
+		child.sort()
+	}
+}
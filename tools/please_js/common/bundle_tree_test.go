@@ -0,0 +1,68 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBundleTree(t *testing.T) {
+	metafile := `{
+		"inputs": {},
+		"outputs": {
+			"main.js": {
+				"bytes": 1100,
+				"inputs": {
+					"src/components/ui/button.tsx": {"bytesInOutput": 300},
+					"src/components/ui/dialog.tsx": {"bytesInOutput": 200},
+					"src/main.tsx": {"bytesInOutput": 100},
+					"../../node_modules/react/index.js": {"bytesInOutput": 400},
+					"src/types.ts": {"bytesInOutput": 0}
+				}
+			},
+			"main.js.map": {"bytes": 5000, "inputs": {}},
+			"main.css": {
+				"bytes": 50,
+				"inputs": {"raw:src/app.css": {"bytesInOutput": 50}}
+			}
+		}
+	}`
+	tree, err := BundleTree(metafile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &TreeNode{Name: "/", Bytes: 1150, Children: []*TreeNode{
+		{Name: "main.js", Bytes: 1100, Children: []*TreeNode{
+			{Name: "src", Bytes: 600, Children: []*TreeNode{
+				{Name: "components/ui", Bytes: 500, Children: []*TreeNode{
+					{Name: "button.tsx", Bytes: 300},
+					{Name: "dialog.tsx", Bytes: 200},
+				}},
+				{Name: "main.tsx", Bytes: 100},
+			}},
+			{Name: "node_modules/react", Bytes: 400, Children: []*TreeNode{
+				{Name: "index.js", Bytes: 400},
+			}},
+		}},
+		{Name: "main.css", Bytes: 50, Children: []*TreeNode{
+			{Name: "src", Bytes: 50, Children: []*TreeNode{
+				{Name: "app.css", Bytes: 50},
+			}},
+		}},
+	}}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("unexpected tree:\n%s", dumpTree(tree, ""))
+	}
+
+	if _, err := BundleTree("not json"); err == nil {
+		t.Error("expected a bad metafile to fail")
+	}
+}
+
+func dumpTree(n *TreeNode, indent string) string {
+	s := fmt.Sprintf("%s%s %d\n", indent, n.Name, n.Bytes)
+	for _, c := range n.Children {
+		s += dumpTree(c, indent+"  ")
+	}
+	return s
+}
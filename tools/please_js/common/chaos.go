@@ -0,0 +1,154 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChaosRule slows down or fails proxied requests under a path prefix, to
+// exercise loading states and error paths without touching the backend.
+type ChaosRule struct {
+	Prefix      string
+	MinDelay    time.Duration
+	MaxDelay    time.Duration // equal to MinDelay for a fixed delay
+	ErrorRate   float64       // fraction of requests failed, 0–1
+	ErrorStatus int
+}
+
+// Chaos applies fault injection rules to proxied requests.
+type Chaos struct {
+	rules []ChaosRule // sorted longest prefix first
+}
+
+// NewChaos parses --chaos rules of the form "prefix=spec", where spec is a
+// comma-separated list of:
+//
+//   - a delay ("500ms") or a random delay range ("100ms-2s")
+//   - a failure rate: "5%error" answers 5% of requests with a 500, and
+//     "5%503" with the given status
+func NewChaos(specs []string) (*Chaos, error) {
+	c := &Chaos{}
+	for _, spec := range specs {
+		prefix, rest, ok := strings.Cut(spec, "=")
+		prefix = strings.TrimSpace(prefix)
+		if !ok || prefix == "" {
+			return nil, fmt.Errorf("invalid --chaos %q: expected prefix=spec", spec)
+		}
+		rule := ChaosRule{Prefix: prefix}
+		for _, part := range strings.Split(rest, ",") {
+			if err := rule.parse(strings.TrimSpace(part)); err != nil {
+				return nil, fmt.Errorf("invalid --chaos %q: %w", spec, err)
+			}
+		}
+		c.rules = append(c.rules, rule)
+	}
+	sort.SliceStable(c.rules, func(i, j int) bool {
+		return len(c.rules[i].Prefix) > len(c.rules[j].Prefix)
+	})
+	return c, nil
+}
+
+func (r *ChaosRule) parse(part string) error {
+	if part == "" {
+		return nil
+	}
+	if rate, kind, ok := strings.Cut(part, "%"); ok {
+		pct, err := strconv.ParseFloat(rate, 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return fmt.Errorf("bad failure rate %q", part)
+		}
+		status := http.StatusInternalServerError
+		if kind != "error" {
+			if status, err = strconv.Atoi(kind); err != nil || status < 400 || status > 599 {
+				return fmt.Errorf("bad failure %q: expected N%%error or N%%<status>", part)
+			}
+		}
+		r.ErrorRate, r.ErrorStatus = pct/100, status
+		return nil
+	}
+	if from, to, ok := strings.Cut(part, "-"); ok {
+		lo, err1 := time.ParseDuration(from)
+		hi, err2 := time.ParseDuration(to)
+		if err1 != nil || err2 != nil || hi < lo {
+			return fmt.Errorf("bad delay range %q", part)
+		}
+		r.MinDelay, r.MaxDelay = lo, hi
+		return nil
+	}
+	d, err := time.ParseDuration(part)
+	if err != nil {
+		return fmt.Errorf("bad delay %q", part)
+	}
+	r.MinDelay, r.MaxDelay = d, d
+	return nil
+}
+
+// rule returns the rule with the longest prefix matching path.
+func (c *Chaos) rule(path string) *ChaosRule {
+	if c == nil {
+		return nil
+	}
+	for i := range c.rules {
+		if strings.HasPrefix(path, c.rules[i].Prefix) {
+			return &c.rules[i]
+		}
+	}
+	return nil
+}
+
+// Inject delays the request and may answer it with an injected failure,
+// according to the rule matching its path. It reports whether a failure was
+// written, in which case the request must not be proxied.
+func (c *Chaos) Inject(w http.ResponseWriter, r *http.Request) bool {
+	rule := c.rule(r.URL.Path)
+	if rule == nil {
+		return false
+	}
+	delay := rule.MinDelay
+	if spread := rule.MaxDelay - rule.MinDelay; spread > 0 {
+		delay += time.Duration(rand.Int63n(int64(spread) + 1))
+	}
+	var notes []string
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return true
+		}
+		notes = append(notes, "+"+delay.Round(time.Millisecond).String())
+	}
+	failed := rule.ErrorRate > 0 && rand.Float64() < rule.ErrorRate
+	if failed {
+		notes = append(notes, strconv.Itoa(rule.ErrorStatus))
+		http.Error(w, fmt.Sprintf("%d %s (injected by --chaos %s)", rule.ErrorStatus, http.StatusText(rule.ErrorStatus), rule.Prefix), rule.ErrorStatus)
+	}
+	if len(notes) > 0 {
+		fmt.Printf("  \033[35m[chaos] %s %s %s\033[0m\n", r.Method, r.URL.Path, strings.Join(notes, " "))
+	}
+	return failed
+}
+
+// PrintStatus prints one banner line per rule.
+func (c *Chaos) PrintStatus() {
+	if c == nil {
+		return
+	}
+	for _, rule := range c.rules {
+		var parts []string
+		switch {
+		case rule.MaxDelay > rule.MinDelay:
+			parts = append(parts, fmt.Sprintf("%s–%s delay", rule.MinDelay, rule.MaxDelay))
+		case rule.MinDelay > 0:
+			parts = append(parts, fmt.Sprintf("%s delay", rule.MinDelay))
+		}
+		if rule.ErrorRate > 0 {
+			parts = append(parts, fmt.Sprintf("%g%% %d", rule.ErrorRate*100, rule.ErrorStatus))
+		}
+		fmt.Printf("  \033[35m➜\033[0m  \033[1mChaos:\033[0m   %s  \033[2m%s\033[0m\n", rule.Prefix, strings.Join(parts, ", "))
+	}
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewChaos(t *testing.T) {
+	c, err := NewChaos([]string{"/api=100ms-2s,10%503", "/api/orders=500ms,5%error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := c.rule("/api/orders/42")
+	if orders == nil || orders.Prefix != "/api/orders" {
+		t.Fatalf("expected the longest prefix to match, got %+v", orders)
+	}
+	if orders.MinDelay != 500*time.Millisecond || orders.MaxDelay != orders.MinDelay || orders.ErrorRate != 0.05 || orders.ErrorStatus != 500 {
+		t.Errorf("unexpected /api/orders rule: %+v", orders)
+	}
+	api := c.rule("/api/users")
+	if api == nil || api.MinDelay != 100*time.Millisecond || api.MaxDelay != 2*time.Second || api.ErrorStatus != 503 {
+		t.Errorf("unexpected /api rule: %+v", api)
+	}
+	if c.rule("/static/app.js") != nil {
+		t.Error("expected no rule for an unmatched path")
+	}
+
+	for _, bad := range []string{"/api", "=500ms", "/api=fast", "/api=120%error", "/api=5%oops", "/api=2s-1s"} {
+		if _, err := NewChaos([]string{bad}); err == nil {
+			t.Errorf("expected NewChaos(%q) to fail", bad)
+		}
+	}
+}
+
+func TestChaosInject(t *testing.T) {
+	c, _ := NewChaos([]string{"/api/fail=100%502", "/api/slow=50ms"})
+
+	rec := httptest.NewRecorder()
+	if !c.Inject(rec, httptest.NewRequest("GET", "/api/fail", nil)) {
+		t.Fatal("expected a 100% failure rule to answer the request")
+	}
+	if rec.Code != 502 {
+		t.Errorf("expected injected 502, got %d", rec.Code)
+	}
+
+	start := time.Now()
+	if c.Inject(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/slow", nil)) {
+		t.Fatal("expected a delay-only rule to let the request through")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected at least 50ms delay, got %s", elapsed)
+	}
+
+	var none *Chaos
+	if none.Inject(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/fail", nil)) {
+		t.Error("expected a nil Chaos to inject nothing")
+	}
+}
@@ -3,9 +3,10 @@ package common
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -193,11 +194,17 @@ var Loaders = map[string]api.Loader{
 }
 
 // MergeEnvDefines merges auto-injected env defaults into a user define map,
-// only setting keys the user hasn't already provided.
+// only setting keys the user hasn't already provided. import.meta.env.MODE is
+// the mode as given; any mode other than "development" (e.g. "staging") is a
+// production build, so NODE_ENV stays "production" for libraries like React.
 func MergeEnvDefines(define map[string]string, mode string) {
 	isDev := mode == "development"
+	nodeEnv := "production"
+	if isDev {
+		nodeEnv = "development"
+	}
 	defaults := map[string]string{
-		"process.env.NODE_ENV":     fmt.Sprintf(`"%s"`, mode),
+		"process.env.NODE_ENV":     fmt.Sprintf(`"%s"`, nodeEnv),
 		"import.meta.env.MODE":     fmt.Sprintf(`"%s"`, mode),
 		"import.meta.env.DEV":      fmt.Sprintf("%t", isDev),
 		"import.meta.env.PROD":     fmt.Sprintf("%t", !isDev),
@@ -343,23 +350,69 @@ func ParsePlatform(p string) api.Platform {
 	}
 }
 
-// RawImportPlugin returns an esbuild plugin that strips ?raw suffixes from
-// import paths. Files loaded this way use the text loader, returning contents
-// as a string — equivalent to Vite's ?raw imports.
+// importQueryLoaders maps Vite-style import query suffixes to the loader
+// used for the file: ?raw returns the contents as a string, ?url returns the
+// emitted asset URL and ?inline returns a data URL. CSS imported with ?inline
+// is returned as a string instead, matching Vite.
+var importQueryLoaders = map[string]api.Loader{
+	"raw":    api.LoaderText,
+	"url":    api.LoaderFile,
+	"inline": api.LoaderDataURL,
+}
+
+// RawImportPlugin returns an esbuild plugin that handles ?raw, ?url and
+// ?inline import suffixes. The suffix is stripped, the remaining path is
+// resolved normally, and the file is loaded with the loader for that suffix
+// regardless of its extension — equivalent to Vite's query imports.
 func RawImportPlugin() api.Plugin {
 	return api.Plugin{
 		Name: "raw-import",
 		Setup: func(build api.PluginBuild) {
-			build.OnResolve(api.OnResolveOptions{Filter: `\?raw$`},
+			build.OnResolve(api.OnResolveOptions{Filter: `\?(raw|url|inline)$`},
 				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
-					// Strip ?raw suffix and resolve normally
-					cleanPath := strings.TrimSuffix(args.Path, "?raw")
-					// Resolve relative to the importer's directory
-					resolveDir := args.ResolveDir
-					resolved := filepath.Join(resolveDir, cleanPath)
+					idx := strings.LastIndex(args.Path, "?")
+					cleanPath, query := args.Path[:idx], args.Path[idx+1:]
+
+					// Resolve the stripped path so bare specifiers go through
+					// the module map; fall back to the importer's directory.
+					resolved := filepath.Join(args.ResolveDir, cleanPath)
+					if filepath.IsAbs(cleanPath) {
+						resolved = cleanPath
+					} else if !strings.HasPrefix(cleanPath, ".") {
+						result := build.Resolve(cleanPath, api.ResolveOptions{
+							ResolveDir: args.ResolveDir,
+							Kind:       args.Kind,
+							Importer:   args.Importer,
+						})
+						if len(result.Errors) == 0 && result.Path != "" {
+							resolved = result.Path
+						}
+					}
 					return api.OnResolveResult{
-						Path:      resolved,
-						Namespace: "file",
+						Path:       resolved,
+						Namespace:  "query-import",
+						Suffix:     "?" + query,
+						PluginData: query,
+						WatchFiles: []string{resolved},
+					}, nil
+				},
+			)
+			build.OnLoad(api.OnLoadOptions{Filter: `.*`, Namespace: "query-import"},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					data, err := os.ReadFile(args.Path)
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+					query, _ := args.PluginData.(string)
+					loader := importQueryLoaders[query]
+					if query == "inline" && filepath.Ext(args.Path) == ".css" {
+						loader = api.LoaderText
+					}
+					contents := string(data)
+					return api.OnLoadResult{
+						Contents:   &contents,
+						Loader:     loader,
+						ResolveDir: filepath.Dir(args.Path),
 					}, nil
 				},
 			)
@@ -399,11 +452,44 @@ func (c *tailwindCache) isStale(paths ...string) bool {
 	return false
 }
 
+// tailwindCacheKey hashes everything Tailwind output depends on: the CSS
+// input, the config, and every file matched by the config's content globs.
+// Returns "" if there is no config or it declares no content, since the
+// output can't be keyed reliably without knowing its content sources.
+func tailwindCacheKey(css []byte, tailwindConfig string) string {
+	if tailwindConfig == "" {
+		return ""
+	}
+	files := TailwindContentFiles(tailwindConfig)
+	if len(files) == 0 {
+		return ""
+	}
+	// Paths are hashed relative to the config so the key doesn't depend on
+	// where the build sandbox happens to be.
+	configDir, _ := filepath.Abs(filepath.Dir(tailwindConfig))
+	h := sha256.New()
+	h.Write(css)
+	for _, path := range append([]string{tailwindConfig}, files...) {
+		sum, err := HashFile(path)
+		if err != nil {
+			return ""
+		}
+		abs, _ := filepath.Abs(path)
+		rel, _ := filepath.Rel(configDir, abs)
+		fmt.Fprintf(h, "\x00%s\x00%s", filepath.ToSlash(rel), sum)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // TailwindPlugin returns an esbuild plugin that processes CSS files containing
-// @tailwind directives through the Tailwind CLI binary. CSS files without
-// @tailwind directives are left for esbuild's default CSS loader.
-// Results are cached in memory to avoid re-running Tailwind on unchanged files.
-func TailwindPlugin(tailwindBin, tailwindConfig string) api.Plugin {
+// @tailwind directives or a Tailwind v4 @import "tailwindcss" through the
+// Tailwind CLI binary. Other CSS files are left for esbuild's default CSS
+// loader. v4 stylesheets scan sourceDirs for class names (see
+// TailwindCommand). Results are cached in memory to avoid re-running Tailwind
+// on unchanged files. When cacheDir is set, output is also persisted there
+// keyed by the hash of the CSS input, config and content files, so it
+// survives across processes.
+func TailwindPlugin(tailwindBin, tailwindConfig, cacheDir string, sourceDirs []string) api.Plugin {
 	cache := &tailwindCache{}
 
 	return api.Plugin{
@@ -416,8 +502,8 @@ func TailwindPlugin(tailwindBin, tailwindConfig string) api.Plugin {
 						return api.OnLoadResult{}, err
 					}
 
-					// Only process files that contain @tailwind directives
-					if !bytes.Contains(content, []byte("@tailwind")) {
+					// Only process files that contain Tailwind directives
+					if !IsTailwindCSS(content) {
 						return api.OnLoadResult{}, nil
 					}
 
@@ -432,17 +518,23 @@ func TailwindPlugin(tailwindBin, tailwindConfig string) api.Plugin {
 						}, nil
 					}
 
-					cmdArgs := []string{"--input", args.Path}
-					if tailwindConfig != "" {
-						// Pass just the filename — cmd.Dir is set to the config's
-						// directory so relative content globs resolve correctly.
-						cmdArgs = append(cmdArgs, "--config", filepath.Base(tailwindConfig))
+					var diskPath string
+					if cacheDir != "" {
+						if key := tailwindCacheKey(content, tailwindConfig); key != "" {
+							diskPath = filepath.Join(cacheDir, "tailwind", key+".css")
+							if data, err := os.ReadFile(diskPath); err == nil {
+								cache.css = string(data)
+								cache.lastRunTime = time.Now()
+								css := cache.css
+								return api.OnLoadResult{
+									Contents: &css,
+									Loader:   api.LoaderCSS,
+								}, nil
+							}
+						}
 					}
 
-					cmd := exec.Command(tailwindBin, cmdArgs...)
-					if tailwindConfig != "" {
-						cmd.Dir = filepath.Dir(tailwindConfig)
-					}
+					cmd := TailwindCommand(tailwindBin, tailwindConfig, args.Path, content, sourceDirs)
 					var stdout, stderr bytes.Buffer
 					cmd.Stdout = &stdout
 					cmd.Stderr = &stderr
@@ -453,6 +545,12 @@ func TailwindPlugin(tailwindBin, tailwindConfig string) api.Plugin {
 
 					cache.css = stdout.String()
 					cache.lastRunTime = time.Now()
+					if diskPath != "" {
+						// Best effort: a failed write only costs a recompile next time.
+						if err := os.MkdirAll(filepath.Dir(diskPath), 0755); err == nil {
+							os.WriteFile(diskPath, stdout.Bytes(), 0644)
+						}
+					}
 
 					css := cache.css
 					return api.OnLoadResult{
@@ -164,3 +164,123 @@ func TestUnknownExternalPlugin_SkipsHashImports(t *testing.T) {
 		t.Errorf("expected resolved value 'world' in output:\n%s", output)
 	}
 }
+
+func TestRawImportPlugin_QuerySuffixes(t *testing.T) {
+	tmp := t.TempDir()
+	files := map[string]string{
+		"shader.glsl": "void main() {}",
+		"logo.svg":    "<svg/>",
+		"theme.css":   "body { color: red; }",
+		"util.js":     "export const x = 1;",
+		"entry.js": `import raw from "./util.js?raw";` + "\n" +
+			`import shader from "./shader.glsl?raw";` + "\n" +
+			`import url from "./logo.svg?url";` + "\n" +
+			`import inlined from "./logo.svg?inline";` + "\n" +
+			`import css from "./theme.css?inline";` + "\n" +
+			`console.log(raw, shader, url, inlined, css);` + "\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{filepath.Join(tmp, "entry.js")},
+		Bundle:      true,
+		Write:       false,
+		Outdir:      filepath.Join(tmp, "out"),
+		AssetNames:  "assets/[name]-[hash]",
+		Format:      api.FormatESModule,
+		Loader:      Loaders,
+		Plugins:     []api.Plugin{RawImportPlugin()},
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build errors: %v", result.Errors[0].Text)
+	}
+
+	var js string
+	var assets []string
+	for _, f := range result.OutputFiles {
+		if strings.HasSuffix(f.Path, ".js") {
+			js = string(f.Contents)
+		} else {
+			assets = append(assets, filepath.Base(f.Path))
+		}
+	}
+
+	for _, want := range []string{
+		`"export const x = 1;"`,  // ?raw on a JS file returns its source
+		`"void main() {}"`,       // ?raw on an unknown extension
+		`"data:image/svg+xml`,    // ?inline returns a data URL
+		`"body { color: red; }"`, // ?inline on CSS returns the stylesheet text
+		`"./assets/logo-`,        // ?url returns the emitted asset path
+	} {
+		if !strings.Contains(js, want) {
+			t.Errorf("expected %s in output:\n%s", want, js)
+		}
+	}
+	if len(assets) != 1 || !strings.HasPrefix(assets[0], "logo-") {
+		t.Errorf("expected only logo.svg to be emitted, got %v", assets)
+	}
+}
+
+func TestMergeEnvDefines_Mode(t *testing.T) {
+	tests := []struct {
+		mode     string
+		nodeEnv  string
+		dev      string
+		userMode string
+	}{
+		{mode: "development", nodeEnv: `"development"`, dev: "true"},
+		{mode: "production", nodeEnv: `"production"`, dev: "false"},
+		{mode: "staging", nodeEnv: `"production"`, dev: "false"},
+		{mode: "staging", nodeEnv: `"production"`, dev: "false", userMode: `"custom"`},
+	}
+	for _, tt := range tests {
+		define := map[string]string{}
+		if tt.userMode != "" {
+			define["import.meta.env.MODE"] = tt.userMode
+		}
+		MergeEnvDefines(define, tt.mode)
+
+		wantMode := `"` + tt.mode + `"`
+		if tt.userMode != "" {
+			wantMode = tt.userMode
+		}
+		if got := define["import.meta.env.MODE"]; got != wantMode {
+			t.Errorf("mode %s: MODE = %s, want %s", tt.mode, got, wantMode)
+		}
+		if got := define["process.env.NODE_ENV"]; got != tt.nodeEnv {
+			t.Errorf("mode %s: NODE_ENV = %s, want %s", tt.mode, got, tt.nodeEnv)
+		}
+		if got := define["import.meta.env.DEV"]; got != tt.dev {
+			t.Errorf("mode %s: DEV = %s, want %s", tt.mode, got, tt.dev)
+		}
+	}
+}
+
+func TestReplaceEnvPlaceholders(t *testing.T) {
+	define := map[string]string{
+		"import.meta.env.PLZ_GA_ID": `"G-123"`,
+		"import.meta.env.PLZ_TITLE": `"Tom & Jerry's <app>"`,
+		"import.meta.env.PROD":      "true",
+		"process.env.NODE_ENV":      `"production"`,
+	}
+	tests := []struct {
+		html string
+		want string
+	}{
+		{`<meta name="ga" content="%PLZ_GA_ID%">`, `<meta name="ga" content="G-123">`},
+		{`<title>%PLZ_TITLE%</title>`, `<title>Tom &amp; Jerry&#39;s &lt;app&gt;</title>`},
+		{`<body data-prod="%PROD%">`, `<body data-prod="true">`},
+		// Only import.meta.env defines are placeholders.
+		{`%NODE_ENV% %PLZ_MISSING%`, `%NODE_ENV% %PLZ_MISSING%`},
+		{`<div style="width: 100%">50% off</div>`, `<div style="width: 100%">50% off</div>`},
+	}
+	for _, tt := range tests {
+		if got := ReplaceEnvPlaceholders(tt.html, define); got != tt.want {
+			t.Errorf("ReplaceEnvPlaceholders(%q)\n got %q\nwant %q", tt.html, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressMinSize is the smallest response worth compressing; below it the
+// encoding's overhead eats most of the saving.
+const compressMinSize = 1024
+
+const (
+	// Build output is compressed once per rebuild, as a production build
+	// step would. Brotli's best level, 11, can take seconds on an unminified
+	// bundle; 9 comes close to its size.
+	precompressBrotliLevel = 9
+	precompressGzipLevel   = gzip.BestCompression
+	// Static files are compressed as they're served, at the levels web
+	// servers compress on the fly at.
+	dynamicBrotliLevel = 5
+	dynamicGzipLevel   = gzip.DefaultCompression
+)
+
+// Compression serves a dev server's responses gzip or brotli compressed, as
+// the browser's Accept-Encoding asks, so transfer sizes, and load times over
+// a --throttle'd connection, match production's. Build outputs are
+// compressed ahead of time, on each rebuild; static files as they're
+// served.
+type Compression struct {
+	mu    sync.RWMutex
+	built map[string]precompressed // URL path -> its compressed forms
+}
+
+// precompressed holds a build output's compressed forms, keyed by
+// Content-Encoding, for the output with hash.
+type precompressed struct {
+	hash  string
+	forms map[string][]byte
+}
+
+// NewCompression returns the compression for --compress: nil, for none,
+// unless it's enabled.
+func NewCompression(enabled bool) *Compression {
+	if !enabled {
+		return nil
+	}
+	return &Compression{built: map[string]precompressed{}}
+}
+
+// Update compresses a build's outputs, keyed by URL path, reusing the forms
+// of those whose hash hasn't changed since the last build. Outputs without a
+// hash are always compressed again.
+func (c *Compression) Update(files map[string][]byte, hashes map[string]string) {
+	if c == nil {
+		return
+	}
+	c.mu.RLock()
+	old := c.built
+	c.mu.RUnlock()
+
+	built := make(map[string]precompressed, len(files))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for path, data := range files {
+		if !compressibleFile(path, len(data)) {
+			continue
+		}
+		hash := hashes[path]
+		if prev, ok := old[path]; ok && hash != "" && prev.hash == hash {
+			built[path] = prev
+			continue
+		}
+		wg.Add(1)
+		go func(path string, data []byte) {
+			defer wg.Done()
+			forms := map[string][]byte{
+				"br":   compressBytes(data, "br", precompressBrotliLevel),
+				"gzip": compressBytes(data, "gzip", precompressGzipLevel),
+			}
+			mu.Lock()
+			built[path] = precompressed{hash: hash, forms: forms}
+			mu.Unlock()
+		}(path, data)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	c.built = built
+	c.mu.Unlock()
+}
+
+// ServeBuilt serves the compressed form of the build output at path, if the
+// request accepts one and it's been compressed for the output with hash,
+// reporting whether it did. The caller sets Content-Type. A nil Compression
+// serves nothing.
+func (c *Compression) ServeBuilt(w http.ResponseWriter, r *http.Request, path, hash string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	entry, ok := c.built[path]
+	c.mu.RUnlock()
+	if !ok || entry.hash != hash {
+		return false
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	encoding := acceptedEncoding(r)
+	if encoding == "" {
+		return false
+	}
+	data := entry.forms[encoding]
+	w.Header().Set("Content-Encoding", encoding)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+	return true
+}
+
+// Wrap returns a writer that compresses the response written to it on the
+// fly, if the request accepts an encoding and the response turns out to be
+// worth compressing, and a function to call once it's written. Range
+// requests are answered uncompressed, as their offsets are into the
+// uncompressed file. A nil Compression returns w as it is.
+func (c *Compression) Wrap(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	if c == nil || r.Header.Get("Range") != "" {
+		return w, func() {}
+	}
+	encoding := acceptedEncoding(r)
+	if encoding == "" {
+		return w, func() {}
+	}
+	cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+	return cw, cw.close
+}
+
+// acceptedEncoding returns the encoding a request's Accept-Encoding prefers
+// of br and gzip, brotli if it likes both as much, or "" for neither.
+func acceptedEncoding(r *http.Request) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "br" && name != "gzip" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		if q > 0 && (q > bestQ || (q == bestQ && name == "br")) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressibleFile reports whether a file is worth compressing: text, JSON,
+// sourcemaps and the like, of at least compressMinSize.
+func compressibleFile(path string, size int) bool {
+	if size < compressMinSize {
+		return false
+	}
+	ext := filepath.Ext(path)
+	return ext == ".map" || compressibleType(mime.TypeByExtension(ext))
+}
+
+// compressibleType reports whether content of a type is worth compressing.
+// Images, fonts and media mostly come compressed already.
+func compressibleType(contentType string) bool {
+	t, _, _ := strings.Cut(contentType, ";")
+	t = strings.TrimSpace(strings.ToLower(t))
+	switch {
+	case strings.HasPrefix(t, "text/"):
+		return true
+	case strings.HasSuffix(t, "+json"), strings.HasSuffix(t, "+xml"):
+		return true
+	}
+	switch t {
+	case "application/javascript", "application/json", "application/xml", "application/wasm", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// newEncoder returns a writer compressing into w with encoding, br or gzip.
+func newEncoder(w io.Writer, encoding string, level int) io.WriteCloser {
+	if encoding == "br" {
+		return brotli.NewWriterLevel(w, level)
+	}
+	enc, _ := gzip.NewWriterLevel(w, level)
+	return enc
+}
+
+// compressBytes compresses data with encoding, br or gzip.
+func compressBytes(data []byte, encoding string, level int) []byte {
+	var buf bytes.Buffer
+	enc := newEncoder(&buf, encoding, level)
+	enc.Write(data)
+	enc.Close()
+	return buf.Bytes()
+}
+
+// compressWriter compresses a response as it's written, once its headers
+// show it's worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	enc         io.WriteCloser // nil if the response is written as it is
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	h := cw.Header()
+	size, err := strconv.Atoi(h.Get("Content-Length"))
+	if status == http.StatusOK && h.Get("Content-Encoding") == "" &&
+		compressibleType(h.Get("Content-Type")) && (err != nil || size >= compressMinSize) {
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		h.Set("Content-Encoding", cw.encoding)
+		h.Add("Vary", "Accept-Encoding")
+		cw.enc = newEncoder(cw.ResponseWriter, cw.encoding, dynamicLevel(cw.encoding))
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *compressWriter) Flush() {
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close finishes the compressed stream.
+func (cw *compressWriter) close() {
+	if cw.enc != nil {
+		cw.enc.Close()
+	}
+}
+
+// dynamicLevel returns the level to compress with on the fly.
+func dynamicLevel(encoding string) int {
+	if encoding == "br" {
+		return dynamicBrotliLevel
+	}
+	return dynamicGzipLevel
+}
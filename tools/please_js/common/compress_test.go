@@ -0,0 +1,112 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                           "",
+		"identity":                   "",
+		"gzip, deflate":              "gzip",
+		"gzip, deflate, br":          "br",
+		"br;q=0.5, gzip":             "gzip",
+		"br;q=0, gzip;q=0":           "",
+		"GZIP;q=0.8, br;q=0.8, zstd": "br",
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", header)
+		if got := acceptedEncoding(r); got != want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressionServeBuilt(t *testing.T) {
+	c := NewCompression(true)
+	bundle := []byte(strings.Repeat("console.log('hello');\n", 200))
+	c.Update(map[string][]byte{"/main.js": bundle, "/tiny.js": []byte("x")}, map[string]string{"/main.js": "abc"})
+
+	for _, encoding := range []string{"br", "gzip"} {
+		r := httptest.NewRequest("GET", "/main.js", nil)
+		r.Header.Set("Accept-Encoding", encoding)
+		w := httptest.NewRecorder()
+		if !c.ServeBuilt(w, r, "/main.js", "abc") {
+			t.Fatalf("expected /main.js to be served %s compressed", encoding)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != encoding {
+			t.Errorf("expected Content-Encoding %s, got %q", encoding, got)
+		}
+		if w.Body.Len() >= len(bundle) {
+			t.Errorf("expected %s to shrink the bundle, got %d bytes of %d", encoding, w.Body.Len(), len(bundle))
+		}
+		if got := decompress(t, encoding, w.Body.Bytes()); !bytes.Equal(got, bundle) {
+			t.Errorf("%s body doesn't decompress to the bundle", encoding)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/main.js", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	if c.ServeBuilt(httptest.NewRecorder(), r, "/main.js", "def") {
+		t.Error("expected a stale hash not to be served compressed")
+	}
+	if c.ServeBuilt(httptest.NewRecorder(), r, "/tiny.js", "") {
+		t.Error("expected a tiny output not to be compressed")
+	}
+	if (*Compression)(nil).ServeBuilt(httptest.NewRecorder(), r, "/main.js", "abc") {
+		t.Error("expected nil compression to serve nothing")
+	}
+}
+
+func TestCompressionWrap(t *testing.T) {
+	c := NewCompression(true)
+	page := strings.Repeat("<p>hello</p>\n", 200)
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		w, done := c.Wrap(rec, r)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, page)
+		done()
+		return rec
+	}
+
+	r := httptest.NewRequest("GET", "/index.html", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := serve(r)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected the page gzipped, got headers %v", rec.Header())
+	}
+	if got := decompress(t, "gzip", rec.Body.Bytes()); string(got) != page {
+		t.Error("gzipped page doesn't decompress to the page")
+	}
+
+	r.Header.Set("Range", "bytes=0-10")
+	if rec := serve(r); rec.Header().Get("Content-Encoding") != "" {
+		t.Error("expected a range request to be served uncompressed")
+	}
+}
+
+func decompress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var r io.Reader = brotli.NewReader(bytes.NewReader(data))
+	if encoding == "gzip" {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
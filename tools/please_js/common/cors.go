@@ -0,0 +1,73 @@
+package common
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CORS sets the Access-Control-Allow-* headers on a dev server's responses,
+// for other locally running apps that load its modules and assets
+// cross-origin.
+type CORS struct {
+	origins []string // allowed origins, e.g. http://localhost:4000; empty for any
+}
+
+// NewCORS returns the policy for --cors and --cors-origin: nil, for none,
+// unless it's enabled or given origins, which it then only allows.
+func NewCORS(enabled bool, origins []string) *CORS {
+	if !enabled && len(origins) == 0 {
+		return nil
+	}
+	c := &CORS{}
+	for _, origin := range origins {
+		c.origins = append(c.origins, strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+	}
+	return c
+}
+
+// Handle sets the CORS headers for a request, and answers it if it's a
+// preflight, reporting whether it did. Any origin is allowed as "*"; given
+// origins are echoed back, with credentials allowed. A nil CORS does nothing.
+func (c *CORS) Handle(w http.ResponseWriter, r *http.Request) bool {
+	if c == nil {
+		return false
+	}
+	h := w.Header()
+	allowed := true
+	if len(c.origins) == 0 {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if allowed = c.allows(origin); allowed {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	if allowed {
+		h.Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+		// Chrome asks before a public page may reach a server on localhost.
+		if r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+			h.Set("Access-Control-Allow-Private-Network", "true")
+		}
+		h.Set("Access-Control-Max-Age", "600")
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// allows reports whether origin is one of the allowed ones.
+func (c *CORS) allows(origin string) bool {
+	for _, o := range c.origins {
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewCORSOff(t *testing.T) {
+	c := NewCORS(false, nil)
+	if c != nil {
+		t.Fatal("expected no policy without --cors or --cors-origin")
+	}
+	rec := httptest.NewRecorder()
+	if c.Handle(rec, httptest.NewRequest("OPTIONS", "/main.js", nil)) {
+		t.Error("expected a nil policy not to answer requests")
+	}
+	if len(rec.Header()) != 0 {
+		t.Errorf("expected no headers, got %v", rec.Header())
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	c := NewCORS(true, nil)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/main.js", nil)
+	req.Header.Set("Origin", "http://localhost:4000")
+	if c.Handle(rec, req) {
+		t.Error("expected a GET to be left to the server")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected any origin to be allowed, got %q", got)
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	c := NewCORS(false, []string{"http://localhost:4000/"})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("OPTIONS", "/api/orders", nil)
+	req.Header.Set("Origin", "http://localhost:4000")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "content-type, x-request-id")
+	req.Header.Set("Access-Control-Request-Private-Network", "true")
+	if !c.Handle(rec, req) {
+		t.Fatal("expected the preflight to be answered")
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+	for name, want := range map[string]string{
+		"Access-Control-Allow-Origin":          "http://localhost:4000",
+		"Access-Control-Allow-Credentials":     "true",
+		"Access-Control-Allow-Headers":         "content-type, x-request-id",
+		"Access-Control-Allow-Private-Network": "true",
+		"Vary":                                 "Origin",
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("expected %s: %s, got %q", name, want, got)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	req.Header.Set("Origin", "http://evil.example")
+	if !c.Handle(rec, req) {
+		t.Fatal("expected the preflight to be answered")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected another origin not to be allowed, got %q", got)
+	}
+}
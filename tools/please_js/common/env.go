@@ -2,8 +2,11 @@ package common
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"html"
 	"os"
+	"regexp"
 	"strings"
 )
 
@@ -74,3 +77,29 @@ func parseEnvFile(path, prefix string) (map[string]string, error) {
 	}
 	return result, scanner.Err()
 }
+
+// envPlaceholderRe matches %NAME% placeholders in HTML.
+var envPlaceholderRe = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_]*)%`)
+
+// ReplaceEnvPlaceholders substitutes %NAME% placeholders in an HTML document
+// with the import.meta.env.NAME define, so env values (analytics IDs, API
+// origins) can go in meta tags and inline attributes as well as in JS.
+// String values are unquoted and HTML-escaped; other literals (true, 42)
+// are inserted as written. Placeholders without a define are left alone, as
+// a literal % is common in HTML.
+func ReplaceEnvPlaceholders(doc string, define map[string]string) string {
+	if !strings.Contains(doc, "%") {
+		return doc
+	}
+	return envPlaceholderRe.ReplaceAllStringFunc(doc, func(match string) string {
+		value, ok := define["import.meta.env."+match[1:len(match)-1]]
+		if !ok {
+			return match
+		}
+		var str string
+		if err := json.Unmarshal([]byte(value), &str); err == nil {
+			value = str
+		}
+		return html.EscapeString(value)
+	})
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// loaderDeclarations are the module bodies declared for the loaders of
+// non-code imports. JS, TS and JSON imports are typed by TypeScript itself.
+var loaderDeclarations = map[api.Loader]string{
+	api.LoaderCSS:      "", // imported for its side effects only
+	api.LoaderLocalCSS: "  const classes: { readonly [name: string]: string };\n  export default classes;\n",
+	api.LoaderText:     "  const content: string;\n  export default content;\n",
+	api.LoaderFile:     "  const url: string;\n  export default url;\n",
+	api.LoaderDataURL:  "  const url: string;\n  export default url;\n",
+}
+
+const envTypesHeader = `// Generated by please_js env-types. Do not edit.
+
+interface ImportMetaEnv {
+  readonly MODE: string;
+  readonly DEV: boolean;
+  readonly PROD: boolean;
+  readonly SSR: boolean;
+  readonly BASE_URL: string;
+  readonly [key: string]: string | boolean | undefined;
+}
+
+interface ImportMeta {
+  readonly env: ImportMetaEnv;
+}
+`
+
+// EnvTypes returns ambient declarations for what the build provides beyond
+// plain TypeScript: import.meta.env and a module for each non-code import,
+// derived from Loaders and the ?raw, ?url and ?inline suffixes so they
+// always match what the loaders return.
+func EnvTypes() string {
+	var b strings.Builder
+	b.WriteString(envTypesHeader)
+
+	exts := make([]string, 0, len(Loaders))
+	for ext, loader := range Loaders {
+		if _, ok := loaderDeclarations[loader]; ok {
+			exts = append(exts, ext)
+		}
+	}
+	// TypeScript uses the first of several patterns matching equally well,
+	// so longer extensions (".module.css") go before ones they end with.
+	sort.Slice(exts, func(i, j int) bool {
+		if len(exts[i]) != len(exts[j]) {
+			return len(exts[i]) > len(exts[j])
+		}
+		return exts[i] < exts[j]
+	})
+	for _, ext := range exts {
+		writeModuleDeclaration(&b, "*"+ext, loaderDeclarations[Loaders[ext]])
+	}
+
+	queries := make([]string, 0, len(importQueryLoaders))
+	for query := range importQueryLoaders {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	for _, query := range queries {
+		writeModuleDeclaration(&b, "*?"+query, loaderDeclarations[importQueryLoaders[query]])
+	}
+	return b.String()
+}
+
+func writeModuleDeclaration(b *strings.Builder, pattern, body string) {
+	fmt.Fprintf(b, "\ndeclare module %q {\n%s}\n", pattern, body)
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvTypes(t *testing.T) {
+	got := EnvTypes()
+	for _, want := range []string{
+		"declare module \"*.svg\" {\n  const url: string;\n  export default url;\n}",
+		"declare module \"*.md\" {\n  const content: string;\n  export default content;\n}",
+		"declare module \"*.module.css\" {\n  const classes: { readonly [name: string]: string };\n  export default classes;\n}",
+		"declare module \"*.css\" {\n}",
+		"declare module \"*?raw\" {\n  const content: string;\n  export default content;\n}",
+		"declare module \"*?inline\" {\n  const url: string;\n  export default url;\n}",
+		"readonly MODE: string;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected EnvTypes to contain\n%s\ngot:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, `"*.module.css"`) > strings.Index(got, `"*.css"`) {
+		t.Error("expected *.module.css to be declared before *.css, so TypeScript picks it first")
+	}
+	for _, unwanted := range []string{`"*.json"`, `"*.ts"`, `"*.js"`, `"*.map"`} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("expected no declaration for %s, which TypeScript types itself", unwanted)
+		}
+	}
+	if EnvTypes() != got {
+		t.Error("expected EnvTypes to be deterministic")
+	}
+}
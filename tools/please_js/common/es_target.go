@@ -0,0 +1,73 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// esTargets maps the ES versions accepted by ParseTarget to esbuild's.
+var esTargets = map[string]api.Target{
+	"esnext": api.ESNext,
+	"es5":    api.ES5,
+	"es2015": api.ES2015,
+	"es6":    api.ES2015,
+	"es2016": api.ES2016,
+	"es2017": api.ES2017,
+	"es2018": api.ES2018,
+	"es2019": api.ES2019,
+	"es2020": api.ES2020,
+	"es2021": api.ES2021,
+	"es2022": api.ES2022,
+	"es2023": api.ES2023,
+	"es2024": api.ES2024,
+}
+
+// engines maps the runtime names accepted by ParseTarget to esbuild's.
+var engines = map[string]api.EngineName{
+	"chrome":  api.EngineChrome,
+	"deno":    api.EngineDeno,
+	"edge":    api.EngineEdge,
+	"firefox": api.EngineFirefox,
+	"hermes":  api.EngineHermes,
+	"ie":      api.EngineIE,
+	"ios":     api.EngineIOS,
+	"node":    api.EngineNode,
+	"opera":   api.EngineOpera,
+	"rhino":   api.EngineRhino,
+	"safari":  api.EngineSafari,
+}
+
+// ParseTarget parses a comma-separated target list as esbuild's --target
+// takes it: at most one ES version ("es2018") plus any runtime versions
+// ("node14", "chrome80.1"). Syntax newer than every one of them is
+// down-leveled. An empty list targets esnext.
+func ParseTarget(s string) (api.Target, []api.Engine, error) {
+	target := api.ESNext
+	var engineVersions []api.Engine
+	seenES := false
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		if t, ok := esTargets[part]; ok {
+			if seenES {
+				return 0, nil, fmt.Errorf("invalid target %q: more than one ES version", s)
+			}
+			target, seenES = t, true
+			continue
+		}
+		i := strings.IndexAny(part, "0123456789")
+		if i < 0 {
+			i = len(part)
+		}
+		engine, ok := engines[part[:i]]
+		if !ok || i == len(part) {
+			return 0, nil, fmt.Errorf("invalid target %q: must be an ES version (es2018) or a runtime version (node14)", part)
+		}
+		engineVersions = append(engineVersions, api.Engine{Name: engine, Version: part[i:]})
+	}
+	return target, engineVersions, nil
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		in      string
+		target  api.Target
+		engines []api.Engine
+	}{
+		{"", api.ESNext, nil},
+		{"esnext", api.ESNext, nil},
+		{"es2018", api.ES2018, nil},
+		{"ES2020", api.ES2020, nil},
+		{"node14", api.ESNext, []api.Engine{{Name: api.EngineNode, Version: "14"}}},
+		{"es2019, node12.20,chrome80", api.ES2019, []api.Engine{
+			{Name: api.EngineNode, Version: "12.20"},
+			{Name: api.EngineChrome, Version: "80"},
+		}},
+	}
+	for _, tt := range tests {
+		target, engines, err := ParseTarget(tt.in)
+		if err != nil {
+			t.Errorf("ParseTarget(%q): %v", tt.in, err)
+			continue
+		}
+		if target != tt.target || !reflect.DeepEqual(engines, tt.engines) {
+			t.Errorf("ParseTarget(%q) = %v, %v; want %v, %v", tt.in, target, engines, tt.target, tt.engines)
+		}
+	}
+}
+
+func TestParseTargetInvalid(t *testing.T) {
+	for _, in := range []string{"es2099", "node", "netscape4", "es2018,es2020"} {
+		if _, _, err := ParseTarget(in); err == nil {
+			t.Errorf("ParseTarget(%q): expected an error", in)
+		}
+	}
+}
+
+func TestParseTargetDownlevels(t *testing.T) {
+	target, engines, err := ParseTarget("node12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := api.Transform("const a = b?.c ?? d;", api.TransformOptions{Target: target, Engines: engines})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors[0].Text)
+	}
+	if code := string(result.Code); strings.Contains(code, "?.") || strings.Contains(code, "??") {
+		t.Errorf("expected optional chaining to be down-leveled for node12, got:\n%s", code)
+	}
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ServeETag sets a response's ETag to the hash of its content, and answers
+// 304 Not Modified if the request's If-None-Match already names it,
+// reporting whether it did. The ETag is weak, as the one hash stands for
+// the content whichever encoding it's served in. Cache-Control: no-cache
+// has the browser revalidate on every load, so a changed output is fetched
+// straight away while unchanged ones cost only a 304.
+func ServeETag(w http.ResponseWriter, r *http.Request, hash string) bool {
+	if hash == "" {
+		return false
+	}
+	etag := `W/"` + hash + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag[2:] {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeETag(t *testing.T) {
+	for _, tc := range []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`W/"old", W/"abc"`, true},
+		{`W/"old"`, false},
+		{"*", true},
+	} {
+		r := httptest.NewRequest("GET", "/main.js", nil)
+		if tc.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tc.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		if got := ServeETag(w, r, "abc"); got != tc.want {
+			t.Errorf("If-None-Match %q: got %v, want %v", tc.ifNoneMatch, got, tc.want)
+		}
+		if got := w.Header().Get("ETag"); got != `W/"abc"` {
+			t.Errorf("expected ETag W/\"abc\", got %q", got)
+		}
+		if tc.want && w.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %q: expected 304, got %d", tc.ifNoneMatch, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	if ServeETag(w, httptest.NewRequest("GET", "/", nil), "") || w.Header().Get("ETag") != "" {
+		t.Error("expected no ETag without a hash")
+	}
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// fallbackRewrite serves File for request paths matching Pattern.
+type fallbackRewrite struct {
+	Pattern *regexp.Regexp
+	File    string // URL path of the file under servedir, e.g. /admin.html
+}
+
+// HistoryFallback picks the page a dev server serves for a request nothing
+// else matched, so a single-page app's client-side routes load on refresh,
+// like connect-history-api-fallback. By default that's /index.html for any
+// path.
+type HistoryFallback struct {
+	rewrites []fallbackRewrite // tried in order; the first match wins
+	exclude  []string          // path prefixes that get a 404 instead
+	noDotted bool              // paths whose last segment has a dot get a 404 instead
+}
+
+// NewHistoryFallback parses --rewrites rules of the form "regex=file", and
+// takes the path prefixes (such as /api) and, with noDotted, the paths with
+// a dot in their last segment (such as /logo.png) that get a 404 rather than
+// /index.html, so requests for missing endpoints and assets show up as such.
+// Rewrites apply even to excluded paths.
+func NewHistoryFallback(rewrites, exclude []string, noDotted bool) (*HistoryFallback, error) {
+	f := &HistoryFallback{exclude: exclude, noDotted: noDotted}
+	for _, spec := range rewrites {
+		i := strings.LastIndex(spec, "=")
+		if i <= 0 || strings.TrimSpace(spec[i+1:]) == "" {
+			return nil, fmt.Errorf("invalid --rewrites %q: expected regex=file", spec)
+		}
+		re, err := regexp.Compile(spec[:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid --rewrites %q: %w", spec, err)
+		}
+		f.rewrites = append(f.rewrites, fallbackRewrite{Pattern: re, File: "/" + strings.TrimPrefix(strings.TrimSpace(spec[i+1:]), "/")})
+	}
+	return f, nil
+}
+
+// Resolve returns the URL path of the file to serve for a request path that
+// nothing else matched, or "" for a 404.
+func (f *HistoryFallback) Resolve(urlPath string) string {
+	for _, rw := range f.rewrites {
+		if rw.Pattern.MatchString(urlPath) {
+			return rw.File
+		}
+	}
+	for _, prefix := range f.exclude {
+		if strings.HasPrefix(urlPath, prefix) {
+			return ""
+		}
+	}
+	if f.noDotted && strings.Contains(path.Base(urlPath), ".") {
+		return ""
+	}
+	return "/index.html"
+}
@@ -0,0 +1,45 @@
+package common
+
+import "testing"
+
+func TestHistoryFallback(t *testing.T) {
+	f, err := NewHistoryFallback([]string{`^/admin(/|$)=admin.html`, `^/api/docs=/docs.html`}, []string{"/api"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"/":              "/index.html",
+		"/orders/42":     "/index.html",
+		"/admin":         "/admin.html",
+		"/admin/users":   "/admin.html",
+		"/administrator": "/index.html",
+		"/api/users":     "",
+		"/api/docs/v2":   "/docs.html",
+		"/logo.png":      "",
+		"/v1.2/notes":    "/index.html",
+	} {
+		if got := f.Resolve(path); got != want {
+			t.Errorf("Resolve(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestHistoryFallbackDefault(t *testing.T) {
+	f, err := NewHistoryFallback(nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/", "/orders/42", "/api/users", "/logo.png"} {
+		if got := f.Resolve(path); got != "/index.html" {
+			t.Errorf("Resolve(%q) = %q, want /index.html", path, got)
+		}
+	}
+}
+
+func TestNewHistoryFallbackErrors(t *testing.T) {
+	for _, spec := range []string{"no-file", "^/admin=", "=admin.html", "^/(admin=admin.html"} {
+		if _, err := NewHistoryFallback([]string{spec}, nil, false); err == nil {
+			t.Errorf("expected --rewrites %q to fail", spec)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package common
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// CleartextHTTP2 wraps a dev server's handler to also accept HTTP/2 without
+// TLS (h2c), for a dev server behind a proxy that terminates TLS and speaks
+// HTTP/2 to its backends. HTTP/1.1 requests are served as before.
+func CleartextHTTP2(h http.Handler) http.Handler {
+	return h2c.NewHandler(h, &http2.Server{})
+}
@@ -0,0 +1,23 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ParseHeaders parses --header values of the form "Name: value" into the
+// headers a dev server sets on every response. A header given more than
+// once is sent with each value.
+func ParseHeaders(specs []string) (http.Header, error) {
+	headers := make(http.Header, len(specs))
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid --header %q: expected \"Name: value\"", spec)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
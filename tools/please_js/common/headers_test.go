@@ -0,0 +1,30 @@
+package common
+
+import "testing"
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders([]string{
+		"Cross-Origin-Opener-Policy: same-origin",
+		"cross-origin-embedder-policy:require-corp",
+		"Link: </main.js>; rel=preload",
+		"Link: </style.css>; rel=preload",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := headers.Get("Cross-Origin-Opener-Policy"); got != "same-origin" {
+		t.Errorf("unexpected COOP %q", got)
+	}
+	if got := headers.Get("Cross-Origin-Embedder-Policy"); got != "require-corp" {
+		t.Errorf("unexpected COEP %q", got)
+	}
+	if got := headers.Values("Link"); len(got) != 2 {
+		t.Errorf("expected both Link values, got %v", got)
+	}
+
+	for _, spec := range []string{"no-colon", ": value", "Bad Name: value"} {
+		if _, err := ParseHeaders([]string{spec}); err == nil {
+			t.Errorf("expected ParseHeaders(%q) to fail", spec)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package common
+
+import (
+	"net"
+	"strconv"
+)
+
+// DevHosts returns the hosts to print a dev server's URLs with when it
+// listens on host, given the machine's network addresses: local, to reach
+// it from the machine itself, and network, to reach it from others. A
+// server on all interfaces ("" or 0.0.0.0) has both, one on a loopback
+// address only local, and one on a single other address only that.
+func DevHosts(host string, ips []string) (local string, network []string) {
+	switch host {
+	case "", "0.0.0.0", "::":
+		return "localhost", ips
+	case "localhost":
+		return "localhost", nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return host, nil
+	}
+	return "", []string{host}
+}
+
+// URLHost returns host as it's written in a URL, with an IPv6 address in
+// brackets.
+func URLHost(host string) string {
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// DevOrigin returns the origin of a dev server listening on host and port,
+// as the browser on the same machine reaches it.
+func DevOrigin(scheme, host string, port int) string {
+	local, network := DevHosts(host, nil)
+	if local == "" {
+		local = network[0]
+	}
+	return scheme + "://" + net.JoinHostPort(local, strconv.Itoa(port))
+}
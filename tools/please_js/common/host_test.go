@@ -0,0 +1,39 @@
+package common
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDevHosts(t *testing.T) {
+	ips := []string{"192.168.1.20", "10.0.0.5"}
+	for _, tc := range []struct {
+		host    string
+		local   string
+		network []string
+	}{
+		{"", "localhost", ips},
+		{"0.0.0.0", "localhost", ips},
+		{"localhost", "localhost", nil},
+		{"127.0.0.1", "127.0.0.1", nil},
+		{"::1", "::1", nil},
+		{"192.168.1.20", "", []string{"192.168.1.20"}},
+	} {
+		local, network := DevHosts(tc.host, ips)
+		if local != tc.local || !slices.Equal(network, tc.network) {
+			t.Errorf("DevHosts(%q) = %q, %v, want %q, %v", tc.host, local, network, tc.local, tc.network)
+		}
+	}
+}
+
+func TestDevOrigin(t *testing.T) {
+	for host, want := range map[string]string{
+		"":             "http://localhost:3000",
+		"::1":          "http://[::1]:3000",
+		"192.168.1.20": "http://192.168.1.20:3000",
+	} {
+		if got := DevOrigin("http", host, 3000); got != want {
+			t.Errorf("DevOrigin(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// ImportMap is a browser import map: bare specifiers (or "prefix/" keys)
+// mapped to the URLs the browser loads them from.
+type ImportMap struct {
+	Imports map[string]string            `json:"imports,omitempty"`
+	Scopes  map[string]map[string]string `json:"scopes,omitempty"`
+}
+
+// LoadImportMap reads an import map JSON file.
+func LoadImportMap(path string) (*ImportMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m ImportMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid import map %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Maps reports whether the browser would resolve specifier through the
+// import map: an exact key, or a key ending in "/" that prefixes it. Keys of
+// every scope count, since the bundle can't tell which scope applies.
+func (m *ImportMap) Maps(specifier string) bool {
+	if mapsSpecifier(m.Imports, specifier) {
+		return true
+	}
+	for _, imports := range m.Scopes {
+		if mapsSpecifier(imports, specifier) {
+			return true
+		}
+	}
+	return false
+}
+
+func mapsSpecifier(imports map[string]string, specifier string) bool {
+	if _, ok := imports[specifier]; ok {
+		return true
+	}
+	for key := range imports {
+		if strings.HasSuffix(key, "/") && strings.HasPrefix(specifier, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportMapExternalPlugin returns an esbuild plugin that marks every import
+// the import map covers as external, leaving the specifier in the output for
+// the browser to resolve. It must be registered before ModuleResolvePlugin,
+// which would otherwise bundle mapped packages from the moduleconfig.
+func ImportMapExternalPlugin(m *ImportMap) api.Plugin {
+	return api.Plugin{
+		Name: "importmap-external",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: "^[^.]"},
+				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					if args.Kind == api.ResolveEntryPoint || !m.Maps(args.Path) {
+						return api.OnResolveResult{}, nil
+					}
+					return api.OnResolveResult{Path: args.Path, External: true}, nil
+				},
+			)
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestImportMapMaps(t *testing.T) {
+	m := &ImportMap{
+		Imports: map[string]string{
+			"react":   "https://esm.sh/react@18",
+			"lodash/": "/shared/lodash/",
+		},
+		Scopes: map[string]map[string]string{
+			"/legacy/": {"moment": "/shared/moment.js"},
+		},
+	}
+	tests := []struct {
+		specifier string
+		want      bool
+	}{
+		{"react", true},
+		{"react-dom", false},
+		{"react/jsx-runtime", false},
+		{"lodash/debounce", true},
+		{"lodash", false},
+		{"moment", true},
+	}
+	for _, tt := range tests {
+		if got := m.Maps(tt.specifier); got != tt.want {
+			t.Errorf("Maps(%q) = %v, want %v", tt.specifier, got, tt.want)
+		}
+	}
+}
+
+func TestImportMapExternalPlugin(t *testing.T) {
+	tmp := t.TempDir()
+	pkgDir := filepath.Join(tmp, "node_modules", "react")
+	os.MkdirAll(pkgDir, 0o755)
+	os.WriteFile(filepath.Join(pkgDir, "package.json"), []byte(`{"name":"react","main":"index.js"}`), 0o644)
+	os.WriteFile(filepath.Join(pkgDir, "index.js"), []byte(`export const bundledReact = 1;`), 0o644)
+	entry := filepath.Join(tmp, "entry.js")
+	os.WriteFile(entry, []byte(`import { createElement } from "react";`+"\n"+`console.log(createElement);`+"\n"), 0o644)
+
+	// react is resolvable from the moduleconfig, but the import map wins.
+	moduleMap := map[string]string{"react": pkgDir}
+	m := &ImportMap{Imports: map[string]string{"react": "https://esm.sh/react@18"}}
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{entry},
+		Bundle:      true,
+		Write:       false,
+		Format:      api.FormatESModule,
+		Plugins: []api.Plugin{
+			ImportMapExternalPlugin(m),
+			ModuleResolvePlugin(moduleMap, "browser"),
+		},
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build errors: %v", result.Errors[0].Text)
+	}
+	output := string(result.OutputFiles[0].Contents)
+	if !strings.Contains(output, `from "react"`) {
+		t.Errorf("expected react left as a bare import:\n%s", output)
+	}
+	if strings.Contains(output, "bundledReact") {
+		t.Errorf("expected react not to be bundled:\n%s", output)
+	}
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// integrityHashes are the algorithms npm lockfiles record integrity with,
+// strongest first.
+var integrityHashes = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"sha512", sha512.New},
+	{"sha384", sha512.New384},
+	{"sha256", sha256.New},
+	{"sha1", sha1.New},
+}
+
+// VerifyIntegrity checks a file against a Subresource Integrity string, as
+// lockfiles record for tarballs: "sha512-<base64>", or several
+// space-separated hashes. As in browsers, only the strongest algorithm
+// listed counts, and the file matches if it matches any hash of that one.
+func VerifyIntegrity(path, integrity string) error {
+	want := map[string][]string{}
+	for _, field := range strings.Fields(integrity) {
+		algo, digest, ok := strings.Cut(field, "-")
+		if !ok {
+			return fmt.Errorf("invalid integrity %q", field)
+		}
+		want[algo] = append(want[algo], digest)
+	}
+	for _, h := range integrityHashes {
+		digests, ok := want[h.name]
+		if !ok {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		sum := h.new()
+		if _, err := io.Copy(sum, f); err != nil {
+			return err
+		}
+		got := base64.StdEncoding.EncodeToString(sum.Sum(nil))
+		for _, digest := range digests {
+			if got == digest {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: integrity mismatch: got %s-%s, expected %s", path, h.name, got, integrity)
+	}
+	return fmt.Errorf("integrity %q has no supported hash (sha512, sha384, sha256 or sha1)", integrity)
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyIntegrity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pkg.tgz")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const sha512 = "sha512-m3HSJL1i83hdltRq0+o9czGb+8KJDKra4t/3JRlnPKcjI8PZm6XBHXx6zG4UuMXaDEZjR1wuXDre9G9zvN7AQw=="
+	const sha1 = "sha1-qvTGHdzF6KLavt4PO0gs2a6pQ00="
+
+	for _, integrity := range []string{sha512, sha1, "sha512-bogus " + sha512, sha1 + " " + sha512} {
+		if err := VerifyIntegrity(path, integrity); err != nil {
+			t.Errorf("VerifyIntegrity(%q): %v", integrity, err)
+		}
+	}
+	// A matching weaker hash doesn't excuse a mismatched stronger one.
+	if err := VerifyIntegrity(path, sha1+" sha512-bogus"); err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Errorf("expected a mismatch, got %v", err)
+	}
+	if err := VerifyIntegrity(path, "md5-XUFAKrxLKna5cZ2REBfFkg=="); err == nil {
+		t.Error("expected an error for an unsupported hash")
+	}
+}
@@ -0,0 +1,115 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "line comments removed",
+			input: "{\n  // this is a comment\n  \"key\": \"value\"\n}",
+			want:  "{\n  \n  \"key\": \"value\"\n}",
+		},
+		{
+			name:  "block comments removed",
+			input: "{\n  /* block comment */\n  \"key\": \"value\"\n}",
+			want:  "{\n  \n  \"key\": \"value\"\n}",
+		},
+		{
+			name:  "trailing commas removed",
+			input: "{\"a\": 1, \"b\": 2,}",
+			want:  "{\"a\": 1, \"b\": 2}",
+		},
+		{
+			name:  "trailing comma in array",
+			input: "[1, 2, 3,]",
+			want:  "[1, 2, 3]",
+		},
+		{
+			name:  "trailing comma with whitespace",
+			input: "{\"a\": 1,\n}",
+			want:  "{\"a\": 1}",
+		},
+		{
+			name:  "comments inside strings preserved",
+			input: "{\"key\": \"value // not a comment\"}",
+			want:  "{\"key\": \"value // not a comment\"}",
+		},
+		{
+			name:  "block comment inside string preserved",
+			input: "{\"key\": \"value /* not a comment */ more\"}",
+			want:  "{\"key\": \"value /* not a comment */ more\"}",
+		},
+		{
+			name:  "escaped quotes in strings handled",
+			input: "{\"key\": \"has \\\"escaped\\\" quotes // still string\"}",
+			want:  "{\"key\": \"has \\\"escaped\\\" quotes // still string\"}",
+		},
+		{
+			name:  "escaped quote before comment",
+			input: "{\"k\": \"val\\\"\" // comment\n}",
+			want:  "{\"k\": \"val\\\"\" \n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(StripJSONC([]byte(tt.input)))
+			if got != tt.want {
+				t.Errorf("StripJSONC() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripJSONC_ComplexProducesValidJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "multiple comment types in one document",
+			input: `{
+  // line comment
+  "a": 1, /* inline block */
+  /* multi
+     line
+     block */
+  "b": 2,
+}`,
+		},
+		{
+			name: "real-world tsconfig with comments and trailing commas",
+			input: `{
+  // TypeScript configuration
+  "compilerOptions": {
+    "target": "ES2020",
+    "module": "ESNext",
+    /* Path aliases for imports */
+    "baseUrl": ".",
+    "paths": {
+      "@/*": ["./src/*"],
+      "~utils": ["./src/utils/index.ts"],
+    },
+    "strict": true, // enable strict mode
+  },
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StripJSONC([]byte(tt.input))
+			var parsed map[string]interface{}
+			if err := json.Unmarshal(got, &parsed); err != nil {
+				t.Errorf("StripJSONC() produced invalid JSON: %v\noutput:\n%s", err, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,438 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockCheckInterval is how often the mock directory is checked for changes.
+const mockCheckInterval = 500 * time.Millisecond
+
+// mockNodeTimeout bounds how long a JS mock may take to load or answer.
+const mockNodeTimeout = 10 * time.Second
+
+// mockNodeScript loads a JS mock file with Node.js. Without a route key it
+// prints the file's routes, with null for handler functions; with one, it
+// calls that route's handler with the request, read from stdin as it can be
+// larger than a command-line argument may be, and prints the response.
+const mockNodeScript = `
+import { pathToFileURL } from "node:url";
+const [file, key] = process.argv.slice(1);
+const mod = await import(pathToFileURL(file).href);
+const routes = mod.default ?? mod;
+if (!key) {
+  const out = {};
+  for (const [k, v] of Object.entries(routes)) out[k] = typeof v === "function" ? null : v;
+  process.stdout.write(JSON.stringify(out));
+} else {
+  const chunks = [];
+  for await (const chunk of process.stdin) chunks.push(chunk);
+  const req = JSON.parse(Buffer.concat(chunks).toString("utf8"));
+  const route = routes[key];
+  const res = typeof route === "function" ? await route(req) : route;
+  process.stdout.write(JSON.stringify(res ?? {}));
+}
+`
+
+// MockAPI serves fake backend routes defined by the .json and .js files in
+// a directory, so frontend work can go on without the real services. Each
+// file maps routes, "METHOD /path" or just "/path" for any method, to
+// responses:
+//
+//	{
+//	  "GET /api/users": {"body": [{"id": 1, "name": "Ada"}]},
+//	  "POST /api/users": {"status": 201, "latency": "300ms", "body": {"id": 2}},
+//	  "/api/users/:id": {"status": 404, "headers": {"X-Mock": "yes"}}
+//	}
+//
+// A path segment ":name" matches any one segment and a final "*" the rest
+// of the path; literal segments win over them. A JSON body is sent as
+// application/json, and a string one as text/plain. JS files default-export
+// the same map, where a response can also be a function of the request
+// ({method, path, params, query, headers, body}) returning one; Node.js runs
+// them per request. Files are reloaded when they change.
+type MockAPI struct {
+	dir  string
+	node string // Node.js binary, for .js files; "" to look for node on PATH
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	stamp     string // the files' names, sizes and mod times as last loaded
+	routes    []*mockRoute
+}
+
+// mockRoute is one route of a mock file.
+type mockRoute struct {
+	method   string   // "" for any
+	segments []string // the path's segments; ":name" matches one, a final "*" the rest
+	file     string
+	key      string        // the route's key in file, to call a JS handler by
+	handler  bool          // a JS function, called per request
+	response *mockResponse // nil for a handler
+}
+
+// mockResponse is the response a mock route answers with.
+type mockResponse struct {
+	Status  int               `json:"status"`
+	Latency string            `json:"latency"` // e.g. "300ms"
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// mockRequest is what a JS handler is called with.
+type mockRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Params  map[string]string `json:"params"`
+	Query   map[string]string `json:"query"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// NewMockAPI returns a MockAPI serving the routes in dir, running JS mocks
+// with node.
+func NewMockAPI(dir, node string) *MockAPI {
+	return &MockAPI{dir: dir, node: node}
+}
+
+// Serve answers a request from a mock route, reporting whether one matched.
+// A nil MockAPI matches nothing.
+func (m *MockAPI) Serve(w http.ResponseWriter, r *http.Request) bool {
+	if m == nil {
+		return false
+	}
+	route, params := m.match(r.Method, r.URL.Path)
+	if route == nil {
+		return false
+	}
+	resp := route.response
+	if route.handler {
+		var err error
+		if resp, err = m.callHandler(route, r, params); err != nil {
+			fmt.Fprintf(os.Stderr, "  \033[31m[mock] %s %s: %v\033[0m\n", r.Method, r.URL.Path, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return true
+		}
+	}
+	resp.write(w, r)
+	return true
+}
+
+// match returns the route for a request and the values of its :params,
+// reloading the routes first if the files have changed.
+func (m *MockAPI) match(method, urlPath string) (*mockRoute, map[string]string) {
+	m.mu.Lock()
+	if time.Since(m.lastCheck) >= mockCheckInterval {
+		m.lastCheck = time.Now()
+		if stamp := m.dirStamp(); stamp != m.stamp {
+			m.stamp = stamp
+			m.routes = m.load()
+		}
+	}
+	routes := m.routes
+	m.mu.Unlock()
+
+	segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	for _, route := range routes {
+		if route.method != "" && route.method != method {
+			continue
+		}
+		if params, ok := route.matchPath(segments); ok {
+			return route, params
+		}
+	}
+	return nil, nil
+}
+
+// matchPath matches a request path's segments against the route's.
+func (route *mockRoute) matchPath(segments []string) (map[string]string, bool) {
+	params := map[string]string{}
+	for i, seg := range route.segments {
+		if seg == "*" && i == len(route.segments)-1 {
+			params["*"] = strings.Join(segments[i:], "/")
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if strings.HasPrefix(seg, ":") {
+			params[seg[1:]] = segments[i]
+		} else if seg != segments[i] {
+			return nil, false
+		}
+	}
+	return params, len(segments) == len(route.segments)
+}
+
+// dirStamp summarises the mock files, to tell when they change.
+func (m *MockAPI) dirStamp() string {
+	var b strings.Builder
+	filepath.WalkDir(m.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return b.String()
+}
+
+// load reads the routes in the mock files, most specific first. Files that
+// can't be read are reported and skipped.
+func (m *MockAPI) load() []*mockRoute {
+	var routes []*mockRoute
+	filepath.WalkDir(m.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		var fileRoutes []*mockRoute
+		switch filepath.Ext(path) {
+		case ".json":
+			fileRoutes, err = loadJSONMocks(path)
+		case ".js", ".mjs":
+			fileRoutes, err = m.loadJSMocks(path)
+		default:
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: mock %s: %v\n", path, err)
+			return nil
+		}
+		routes = append(routes, fileRoutes...)
+		return nil
+	})
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].moreSpecific(routes[j])
+	})
+	return routes
+}
+
+// moreSpecific orders routes so those with a wildcard go last, and
+// otherwise ones with more literal segments, more segments or a method go
+// first.
+func (route *mockRoute) moreSpecific(other *mockRoute) bool {
+	wild, otherWild := route.wildcard(), other.wildcard()
+	if wild != otherWild {
+		return !wild
+	}
+	if lit, otherLit := route.literals(), other.literals(); lit != otherLit {
+		return lit > otherLit
+	}
+	if len(route.segments) != len(other.segments) {
+		return len(route.segments) > len(other.segments)
+	}
+	return route.method != "" && other.method == ""
+}
+
+func (route *mockRoute) wildcard() bool {
+	return len(route.segments) > 0 && route.segments[len(route.segments)-1] == "*"
+}
+
+func (route *mockRoute) literals() int {
+	n := 0
+	for _, seg := range route.segments {
+		if seg != "*" && !strings.HasPrefix(seg, ":") {
+			n++
+		}
+	}
+	return n
+}
+
+// loadJSONMocks reads the routes in a .json mock file.
+func loadJSONMocks(path string) ([]*mockRoute, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var responses map[string]*mockResponse
+	if err := json.Unmarshal(StripJSONC(data), &responses); err != nil {
+		return nil, err
+	}
+	var routes []*mockRoute
+	for key, resp := range responses {
+		route, err := newMockRoute(path, key)
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			resp = &mockResponse{}
+		}
+		if err := resp.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		route.response = resp
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// loadJSMocks reads the routes a .js mock file exports, with Node.js.
+func (m *MockAPI) loadJSMocks(path string) ([]*mockRoute, error) {
+	out, err := m.runNode(path, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var responses map[string]*mockResponse
+	if err := json.Unmarshal(out, &responses); err != nil {
+		return nil, fmt.Errorf("unexpected routes: %w", err)
+	}
+	var routes []*mockRoute
+	for key, resp := range responses {
+		route, err := newMockRoute(path, key)
+		if err != nil {
+			return nil, err
+		}
+		if resp != nil {
+			if err := resp.validate(); err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		route.response, route.handler = resp, resp == nil
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// callHandler calls a JS route's handler with a request.
+func (m *MockAPI) callHandler(route *mockRoute, r *http.Request, params map[string]string) (*mockResponse, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	req := mockRequest{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Params:  params,
+		Query:   map[string]string{},
+		Headers: map[string]string{},
+		Body:    string(body),
+	}
+	for k := range r.URL.Query() {
+		req.Query[k] = r.URL.Query().Get(k)
+	}
+	for k := range r.Header {
+		req.Headers[strings.ToLower(k)] = r.Header.Get(k)
+	}
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	out, err := m.runNode(route.file, route.key, reqJSON)
+	if err != nil {
+		return nil, err
+	}
+	var resp mockResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("unexpected response from %s: %w", route.key, err)
+	}
+	return &resp, nil
+}
+
+// runNode runs mockNodeScript on a JS mock file.
+func (m *MockAPI) runNode(file, key string, req []byte) ([]byte, error) {
+	node := m.node
+	if node == "" {
+		var err error
+		if node, err = exec.LookPath("node"); err != nil {
+			return nil, fmt.Errorf("JS mocks need Node.js: %w", err)
+		}
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mockNodeTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, node, "--input-type=module", "-e", mockNodeScript, abs, key)
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// newMockRoute parses a route key, "METHOD /path" or "/path".
+func newMockRoute(file, key string) (*mockRoute, error) {
+	method, path, ok := strings.Cut(strings.TrimSpace(key), " ")
+	if !ok {
+		method, path = "", method
+	}
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid route %q: expected \"METHOD /path\" or \"/path\"", key)
+	}
+	return &mockRoute{
+		method:   strings.ToUpper(method),
+		segments: strings.Split(strings.Trim(path, "/"), "/"),
+		file:     file,
+		key:      key,
+	}, nil
+}
+
+// validate checks a response's options.
+func (resp *mockResponse) validate() error {
+	if resp.Status != 0 && (resp.Status < 100 || resp.Status > 599) {
+		return fmt.Errorf("invalid status %d", resp.Status)
+	}
+	if resp.Latency != "" {
+		if _, err := time.ParseDuration(resp.Latency); err != nil {
+			return fmt.Errorf("invalid latency %q", resp.Latency)
+		}
+	}
+	return nil
+}
+
+// write sends the response, after its latency.
+func (resp *mockResponse) write(w http.ResponseWriter, r *http.Request) {
+	if resp.Latency != "" {
+		if d, err := time.ParseDuration(resp.Latency); err == nil {
+			select {
+			case <-time.After(d):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	body := bytes.TrimSpace(resp.Body)
+	if len(body) == 0 || bytes.Equal(body, []byte("null")) {
+		w.WriteHeader(status)
+		return
+	}
+	var text string
+	if json.Unmarshal(body, &text) == nil {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, text)
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
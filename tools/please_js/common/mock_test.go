@@ -0,0 +1,126 @@
+package common
+
+import (
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveMock(t *testing.T, m *MockAPI, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if !m.Serve(rec, httptest.NewRequest(method, target, strings.NewReader(body))) {
+		rec.Code = 0
+	}
+	return rec
+}
+
+func TestMockAPIJSON(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "users.json"), []byte(`{
+		// Comments are allowed.
+		"GET /api/users": {"body": [{"id": 1}]},
+		"POST /api/users": {"status": 201, "headers": {"X-Mock": "yes"}, "body": {"id": 2}},
+		"/api/users/:id": {"body": "user"},
+		"/api/users/me": {"body": "me"},
+		"/api/*": {"status": 404, "latency": "20ms"},
+	}`), 0644)
+	m := NewMockAPI(dir, "")
+
+	rec := serveMock(t, m, "GET", "/api/users", "")
+	if rec.Code != 200 || rec.Body.String() != `[{"id": 1}]` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected GET /api/users: %d %s %v", rec.Code, rec.Body, rec.Header())
+	}
+	rec = serveMock(t, m, "POST", "/api/users", `{"name": "Ada"}`)
+	if rec.Code != 201 || rec.Header().Get("X-Mock") != "yes" {
+		t.Errorf("unexpected POST /api/users: %d %v", rec.Code, rec.Header())
+	}
+	rec = serveMock(t, m, "DELETE", "/api/users/7", "")
+	if rec.Body.String() != "user" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("expected /api/users/:id for any method, got %q", rec.Body)
+	}
+	if rec := serveMock(t, m, "GET", "/api/users/me", ""); rec.Body.String() != "me" {
+		t.Errorf("expected the literal route to win over :id, got %q", rec.Body)
+	}
+	start := time.Now()
+	if rec := serveMock(t, m, "GET", "/api/orders/1", ""); rec.Code != 404 {
+		t.Errorf("expected the wildcard route's 404, got %d", rec.Code)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("expected the route's latency")
+	}
+	if rec := serveMock(t, m, "GET", "/main.js", ""); rec.Code != 0 {
+		t.Errorf("expected /main.js not to be mocked, got %d", rec.Code)
+	}
+}
+
+func TestMockAPIReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "health.json")
+	os.WriteFile(path, []byte(`{"/api/health": {"body": "ok"}}`), 0644)
+	m := NewMockAPI(dir, "")
+	if rec := serveMock(t, m, "GET", "/api/health", ""); rec.Body.String() != "ok" {
+		t.Fatalf("unexpected body %q", rec.Body)
+	}
+
+	os.WriteFile(path, []byte(`{"/api/health": {"body": "degraded"}}`), 0644)
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	m.lastCheck = time.Time{}
+	if rec := serveMock(t, m, "GET", "/api/health", ""); rec.Body.String() != "degraded" {
+		t.Errorf("expected the changed file to be reloaded, got %q", rec.Body)
+	}
+}
+
+func TestMockAPIInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{"/api/slow": {"latency": "soon"}}`), 0644)
+	os.WriteFile(filepath.Join(dir, "good.json"), []byte(`{"api/x": {}, "/api/ok": {}}`), 0644)
+	os.WriteFile(filepath.Join(dir, "fine.json"), []byte(`{"/api/fine": {}}`), 0644)
+	m := NewMockAPI(dir, "")
+	if rec := serveMock(t, m, "GET", "/api/slow", ""); rec.Code != 0 {
+		t.Error("expected a file with a bad latency to be skipped")
+	}
+	if rec := serveMock(t, m, "GET", "/api/ok", ""); rec.Code != 0 {
+		t.Error("expected a file with a bad route to be skipped")
+	}
+	if rec := serveMock(t, m, "GET", "/api/fine", ""); rec.Code != 200 {
+		t.Errorf("expected the valid file's route, got %d", rec.Code)
+	}
+}
+
+func TestMockAPIJS(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node not found on PATH, skipping JS mock test")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "orders.mjs"), []byte(`export default {
+  "GET /api/orders": { body: [] },
+  "GET /api/orders/:id": (req) => ({ headers: { "X-Query": req.query.v }, body: { id: req.params.id } }),
+  "POST /api/orders": async (req) => ({ status: 201, body: JSON.parse(req.body) }),
+};`), 0644)
+	m := NewMockAPI(dir, node)
+
+	if rec := serveMock(t, m, "GET", "/api/orders", ""); rec.Body.String() != "[]" {
+		t.Errorf("unexpected GET /api/orders: %q", rec.Body)
+	}
+	rec := serveMock(t, m, "GET", "/api/orders/42?v=1", "")
+	if rec.Body.String() != `{"id":"42"}` || rec.Header().Get("X-Query") != "1" {
+		t.Errorf("unexpected GET /api/orders/42: %q %v", rec.Body, rec.Header())
+	}
+	rec = serveMock(t, m, "POST", "/api/orders", `{"qty":3}`)
+	if rec.Code != 201 || rec.Body.String() != `{"qty":3}` {
+		t.Errorf("unexpected POST /api/orders: %d %q", rec.Code, rec.Body)
+	}
+	// Bodies larger than Linux allows in one command-line argument still
+	// reach the handler.
+	large := `{"note":"` + strings.Repeat("x", 256<<10) + `"}`
+	rec = serveMock(t, m, "POST", "/api/orders", large)
+	if rec.Code != 201 || rec.Body.String() != large {
+		t.Errorf("unexpected large POST /api/orders: %d, %d bytes", rec.Code, rec.Body.Len())
+	}
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ApplyModuleAliases points modules at other moduleconfig entries, for
+// building an app against a second version of a dependency installed under
+// an npm alias (e.g. "react18": "npm:react@18"). Each alias is
+// "name=entry": "react=react18" resolves react, and react/... subpaths, to
+// the package installed as react18. Aliases apply together, so the aliased
+// packages' own imports of each other (react-dom's of react) see the
+// aliases too.
+func ApplyModuleAliases(moduleMap map[string]string, aliases []string) error {
+	resolved := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		name, entry, ok := strings.Cut(alias, "=")
+		if !ok || name == "" || entry == "" {
+			return fmt.Errorf("invalid module alias %q: must be name=entry", alias)
+		}
+		dir, ok := moduleMap[entry]
+		if !ok {
+			return fmt.Errorf("invalid module alias %q: %s isn't in the moduleconfig", alias, entry)
+		}
+		resolved[name] = dir
+	}
+	for name, dir := range resolved {
+		moduleMap[name] = dir
+	}
+	return nil
+}
+
+// AliasImportMap applies module aliases to an import map's imports, as
+// ApplyModuleAliases does to a moduleconfig: name, and its name/ prefix
+// key, map to the URLs entry's do. An alias whose entry the import map
+// doesn't have is skipped, since it may only map the modules a page uses.
+func AliasImportMap(imports map[string]string, aliases []string) error {
+	resolved := make(map[string]string)
+	for _, alias := range aliases {
+		name, entry, ok := strings.Cut(alias, "=")
+		if !ok || name == "" || entry == "" {
+			return fmt.Errorf("invalid module alias %q: must be name=entry", alias)
+		}
+		if url, ok := imports[entry]; ok {
+			resolved[name] = url
+		}
+		if url, ok := imports[entry+"/"]; ok {
+			resolved[name+"/"] = url
+		}
+	}
+	for key, url := range resolved {
+		imports[key] = url
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package common
+
+import "testing"
+
+func TestApplyModuleAliases(t *testing.T) {
+	moduleMap := map[string]string{
+		"react":       "plz-out/gen/npm/react",
+		"react-dom":   "plz-out/gen/npm/react-dom",
+		"react18":     "plz-out/gen/npm/react18",
+		"react-dom18": "plz-out/gen/npm/react-dom18",
+	}
+	if err := ApplyModuleAliases(moduleMap, []string{"react=react18", "react-dom=react-dom18"}); err != nil {
+		t.Fatal(err)
+	}
+	if moduleMap["react"] != "plz-out/gen/npm/react18" || moduleMap["react-dom"] != "plz-out/gen/npm/react-dom18" {
+		t.Errorf("expected react and react-dom to point at the aliased versions, got %v", moduleMap)
+	}
+	if moduleMap["react18"] != "plz-out/gen/npm/react18" {
+		t.Errorf("expected the alias entry itself to be kept, got %q", moduleMap["react18"])
+	}
+}
+
+func TestApplyModuleAliasesSwap(t *testing.T) {
+	moduleMap := map[string]string{"a": "dir/a", "b": "dir/b"}
+	if err := ApplyModuleAliases(moduleMap, []string{"a=b", "b=a"}); err != nil {
+		t.Fatal(err)
+	}
+	if moduleMap["a"] != "dir/b" || moduleMap["b"] != "dir/a" {
+		t.Errorf("expected aliases to apply together, got %v", moduleMap)
+	}
+}
+
+func TestApplyModuleAliasesInvalid(t *testing.T) {
+	for _, alias := range []string{"react", "react=", "=react18", "react=react17"} {
+		moduleMap := map[string]string{"react": "dir/react", "react18": "dir/react18"}
+		if err := ApplyModuleAliases(moduleMap, []string{alias}); err == nil {
+			t.Errorf("ApplyModuleAliases(%q): expected an error", alias)
+		}
+	}
+}
+
+func TestAliasImportMap(t *testing.T) {
+	imports := map[string]string{
+		"react":    "/@deps/react.js",
+		"react/":   "/@deps/react/",
+		"react18":  "/@deps/react18.js",
+		"react18/": "/@deps/react18/",
+		"lodash":   "/@deps/lodash.js",
+	}
+	if err := AliasImportMap(imports, []string{"react=react18", "lodash=lodash4"}); err != nil {
+		t.Fatal(err)
+	}
+	if imports["react"] != "/@deps/react18.js" || imports["react/"] != "/@deps/react18/" {
+		t.Errorf("expected react and its subpaths to map to react18's URLs, got %v", imports)
+	}
+	if imports["lodash"] != "/@deps/lodash.js" {
+		t.Errorf("expected an alias of an unmapped entry to be skipped, got %q", imports["lodash"])
+	}
+	if err := AliasImportMap(imports, []string{"react"}); err == nil {
+		t.Error("expected an error for an alias without an entry")
+	}
+}
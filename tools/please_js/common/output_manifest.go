@@ -0,0 +1,145 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OutputManifestEntry is one file listed in an output manifest.
+type OutputManifestEntry struct {
+	Path   string // slash-separated, relative to the manifest's directory
+	Size   int64
+	SHA256 string
+}
+
+// WriteOutputManifest writes a manifest of files to manifestPath, one line
+// per file of the form "<sha256>  <size>  <path>", sorted by path. Paths are
+// relative to the manifest's own directory, so the manifest stays valid
+// wherever the output directory is copied to. The manifest itself is never
+// listed.
+func WriteOutputManifest(manifestPath string, files []string) error {
+	root := filepath.Dir(manifestPath)
+	self, _ := filepath.Abs(manifestPath)
+	seen := make(map[string]bool)
+	var entries []OutputManifestEntry
+	for _, file := range files {
+		abs, _ := filepath.Abs(file)
+		if abs == self || seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		rel, err := filepath.Rel(root, file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%s is outside the manifest directory %s", file, root)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		sum, err := HashFile(file)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, OutputManifestEntry{Path: filepath.ToSlash(rel), Size: info.Size(), SHA256: sum})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s  %d  %s\n", e.SHA256, e.Size, e.Path)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(manifestPath, []byte(b.String()), 0644)
+}
+
+// WriteOutputManifestDir writes a manifest of every regular file under the
+// manifest's directory.
+func WriteOutputManifestDir(manifestPath string) error {
+	var files []string
+	err := filepath.WalkDir(filepath.Dir(manifestPath), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return WriteOutputManifest(manifestPath, files)
+}
+
+// ReadOutputManifest parses a manifest written by WriteOutputManifest.
+func ReadOutputManifest(manifestPath string) ([]OutputManifestEntry, error) {
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []OutputManifestEntry
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "  ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected \"<sha256>  <size>  <path>\"", manifestPath, n)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid size %q", manifestPath, n, fields[1])
+		}
+		entries = append(entries, OutputManifestEntry{Path: fields[2], Size: size, SHA256: fields[0]})
+	}
+	return entries, scanner.Err()
+}
+
+// VerifyOutputManifest checks that every file a manifest lists exists with
+// the recorded size and, unless sizeOnly, the recorded hash. Comparing sizes
+// alone is enough to catch truncated or missing outputs without reading
+// every file. All mismatches are reported together.
+func VerifyOutputManifest(manifestPath string, sizeOnly bool) error {
+	entries, err := ReadOutputManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	root := filepath.Dir(manifestPath)
+	var problems []string
+	for _, e := range entries {
+		path := filepath.Join(root, filepath.FromSlash(e.Path))
+		info, err := os.Stat(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: missing", e.Path))
+			continue
+		}
+		if info.Size() != e.Size {
+			problems = append(problems, fmt.Sprintf("%s: size %d, expected %d", e.Path, info.Size(), e.Size))
+			continue
+		}
+		if sizeOnly {
+			continue
+		}
+		if sum, err := HashFile(path); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", e.Path, err))
+		} else if sum != e.SHA256 {
+			problems = append(problems, fmt.Sprintf("%s: sha256 %s, expected %s", e.Path, sum, e.SHA256))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s does not match its outputs:\n  %s", manifestPath, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
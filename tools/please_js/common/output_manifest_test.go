@@ -0,0 +1,55 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutputManifest(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "chunks"), 0o755)
+	os.WriteFile(filepath.Join(dir, "index.js"), []byte("console.log(1);\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "chunks", "a.js"), []byte("export {};\n"), 0o644)
+	manifest := filepath.Join(dir, "outputs.sha256")
+
+	if err := WriteOutputManifestDir(manifest); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(manifest)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "  11  chunks/a.js") || !strings.HasSuffix(lines[1], "  16  index.js") {
+		t.Fatalf("unexpected manifest:\n%s", data)
+	}
+
+	if err := VerifyOutputManifest(manifest, false); err != nil {
+		t.Fatalf("expected a fresh manifest to verify: %v", err)
+	}
+
+	// Same size, different content: only the hash check notices.
+	os.WriteFile(filepath.Join(dir, "index.js"), []byte("console.log(2);\n"), 0o644)
+	if err := VerifyOutputManifest(manifest, true); err != nil {
+		t.Errorf("expected a size-only check to pass: %v", err)
+	}
+	if err := VerifyOutputManifest(manifest, false); err == nil || !strings.Contains(err.Error(), "index.js: sha256") {
+		t.Errorf("expected a hash mismatch for index.js, got %v", err)
+	}
+
+	// Partial outputs: a truncated and a missing file.
+	os.WriteFile(filepath.Join(dir, "index.js"), []byte("con"), 0o644)
+	os.Remove(filepath.Join(dir, "chunks", "a.js"))
+	err := VerifyOutputManifest(manifest, true)
+	if err == nil || !strings.Contains(err.Error(), "index.js: size 3, expected 16") || !strings.Contains(err.Error(), "chunks/a.js: missing") {
+		t.Errorf("expected both partial outputs reported, got %v", err)
+	}
+}
+
+func TestWriteOutputManifest_OutsideDir(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "other.js")
+	os.WriteFile(outside, []byte("x"), 0o644)
+	if err := WriteOutputManifest(filepath.Join(dir, "out", "outputs.sha256"), []string{outside}); err == nil {
+		t.Error("expected a file outside the manifest directory to be rejected")
+	}
+}
@@ -0,0 +1,144 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// DefaultEnvPrefix is the prefix .env variables need to be exposed, unless
+// --env-prefix or the project config gives another.
+const DefaultEnvPrefix = "PLZ_"
+
+// ProjectConfig is a please_js.config.json: an app's defaults for the
+// bundle, dev and esm-dev subcommands, so they needn't each be threaded
+// through flags in BUILD files. Flags given as well win over it.
+type ProjectConfig struct {
+	Define    map[string]string          `json:"define"`    // as --define
+	Proxy     map[string]json.RawMessage `json:"proxy"`     // routes, as in a --proxy-config file
+	Alias     map[string]string          `json:"alias"`     // module name → moduleconfig entry, as --module-alias
+	Loader    map[string]string          `json:"loader"`    // extension → esbuild loader, e.g. ".js": "jsx"
+	EnvPrefix *string                    `json:"envPrefix"` // as --env-prefix
+}
+
+// LoadProjectConfig reads a --config file (comments allowed), e.g.
+//
+//	{
+//	  "define": {"__APP_VERSION__": "\"1.2.0\""},
+//	  "proxy": {"/api": "http://localhost:3001"},
+//	  "alias": {"react": "react18"},
+//	  "loader": {".js": "jsx"},
+//	  "envPrefix": "APP_"
+//	}
+//
+// An empty path returns an empty config. Unknown fields are an error, so a
+// misspelt one doesn't go unnoticed.
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	c := &ProjectConfig{}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project config: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(StripJSONC(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("failed to parse project config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Defines returns the config's defines as --define values, followed by
+// flags, which override them.
+func (c *ProjectConfig) Defines(flags []string) []string {
+	return append(keyValues(c.Define), flags...)
+}
+
+// ModuleAliases returns the config's aliases as --module-alias values,
+// followed by flags, which override them.
+func (c *ProjectConfig) ModuleAliases(flags []string) []string {
+	return append(keyValues(c.Alias), flags...)
+}
+
+// ProxyRules returns the config's proxy routes, in prefix order. Those of
+// --proxy-config and --proxy override them for the same prefix.
+func (c *ProjectConfig) ProxyRules() ([]ProxyRule, error) {
+	prefixes := make([]string, 0, len(c.Proxy))
+	for prefix := range c.Proxy {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	rules := make([]ProxyRule, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		rule, err := parseProxyRoute(prefix, c.Proxy[prefix])
+		if err != nil {
+			return nil, fmt.Errorf("project config: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// EnvPrefixOr returns flag, the --env-prefix given, or if it's empty the
+// config's, or DefaultEnvPrefix.
+func (c *ProjectConfig) EnvPrefixOr(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if c.EnvPrefix != nil {
+		return *c.EnvPrefix
+	}
+	return DefaultEnvPrefix
+}
+
+// loaderNames are the esbuild loaders the config can give an extension, by
+// the names esbuild's --loader takes.
+var loaderNames = map[string]api.Loader{
+	"js":        api.LoaderJS,
+	"jsx":       api.LoaderJSX,
+	"ts":        api.LoaderTS,
+	"tsx":       api.LoaderTSX,
+	"json":      api.LoaderJSON,
+	"css":       api.LoaderCSS,
+	"local-css": api.LoaderLocalCSS,
+	"text":      api.LoaderText,
+	"file":      api.LoaderFile,
+	"dataurl":   api.LoaderDataURL,
+	"base64":    api.LoaderBase64,
+	"binary":    api.LoaderBinary,
+	"copy":      api.LoaderCopy,
+	"empty":     api.LoaderEmpty,
+}
+
+// ApplyLoaders sets the config's loaders in Loaders, which every subcommand
+// builds with. It's called once, before building anything.
+func (c *ProjectConfig) ApplyLoaders() error {
+	for ext, name := range c.Loader {
+		if !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("invalid loader extension %q in project config: must start with a dot", ext)
+		}
+		loader, ok := loaderNames[name]
+		if !ok {
+			return fmt.Errorf("invalid loader %q for %s in project config", name, ext)
+		}
+		Loaders[ext] = loader
+	}
+	return nil
+}
+
+// keyValues returns m as key=value strings, in key order.
+func keyValues(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k, v := range m {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func writeProjectConfig(t *testing.T, config string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "please_js.config.json")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProjectConfig(t *testing.T) {
+	c, err := LoadProjectConfig(writeProjectConfig(t, `{
+	// Shared by every subcommand.
+	"define": {"__APP_VERSION__": "\"1.2.0\"", "__DEBUG__": "false"},
+	"proxy": {
+		"/auth": "http://localhost:9000",
+		"/api": {"target": "http://localhost:3001", "rewrite": "/api->/"},
+	},
+	"alias": {"react": "react18"},
+	"envPrefix": "APP_",
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defines := c.Defines([]string{"__DEBUG__=true"})
+	want := []string{`__APP_VERSION__="1.2.0"`, "__DEBUG__=false", "__DEBUG__=true"}
+	if !reflect.DeepEqual(defines, want) {
+		t.Errorf("Defines = %q, want %q", defines, want)
+	}
+	if got := ParseDefines(defines)["__DEBUG__"]; got != "true" {
+		t.Errorf("expected the --define flag to win, got %q", got)
+	}
+
+	if aliases := c.ModuleAliases(nil); !reflect.DeepEqual(aliases, []string{"react=react18"}) {
+		t.Errorf("ModuleAliases = %q", aliases)
+	}
+
+	rules, err := c.ProxyRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Prefix != "/api" || rules[1].Prefix != "/auth" {
+		t.Fatalf("expected the /api and /auth routes in prefix order, got %+v", rules)
+	}
+	if rules[0].RewriteTo != "/" || rules[1].Target.Host != "localhost:9000" {
+		t.Errorf("unexpected routes: %+v", rules)
+	}
+
+	if got := c.EnvPrefixOr(""); got != "APP_" {
+		t.Errorf("expected the config's env prefix, got %q", got)
+	}
+	if got := c.EnvPrefixOr("VITE_"); got != "VITE_" {
+		t.Errorf("expected --env-prefix to win, got %q", got)
+	}
+}
+
+func TestLoadProjectConfigNone(t *testing.T) {
+	c, err := LoadProjectConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defines := c.Defines([]string{"A=1"}); !reflect.DeepEqual(defines, []string{"A=1"}) {
+		t.Errorf("expected just the flags, got %q", defines)
+	}
+	if rules, err := c.ProxyRules(); err != nil || len(rules) != 0 {
+		t.Errorf("expected no proxy rules, got %v, %v", rules, err)
+	}
+	if got := c.EnvPrefixOr(""); got != DefaultEnvPrefix {
+		t.Errorf("expected the default env prefix, got %q", got)
+	}
+}
+
+func TestLoadProjectConfigEmptyEnvPrefix(t *testing.T) {
+	c, err := LoadProjectConfig(writeProjectConfig(t, `{"envPrefix": ""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.EnvPrefixOr(""); got != "" {
+		t.Errorf("expected an empty env prefix to expose every variable, got %q", got)
+	}
+}
+
+func TestLoadProjectConfigErrors(t *testing.T) {
+	for _, config := range []string{
+		`[]`,
+		`{"defines": {"A": "1"}}`,
+		`{"define": {"A": 1}}`,
+	} {
+		if _, err := LoadProjectConfig(writeProjectConfig(t, config)); err == nil {
+			t.Errorf("LoadProjectConfig(%s): expected an error", config)
+		}
+	}
+	if _, err := LoadProjectConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	c, err := LoadProjectConfig(writeProjectConfig(t, `{"proxy": {"/api": {"rewrite": "/api->/"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ProxyRules(); err == nil {
+		t.Error("expected an error for a route without a target")
+	}
+}
+
+func TestProjectConfigApplyLoaders(t *testing.T) {
+	prev, had := Loaders[".js"]
+	defer func() {
+		if had {
+			Loaders[".js"] = prev
+		}
+		delete(Loaders, ".glsl")
+	}()
+
+	c := &ProjectConfig{Loader: map[string]string{".js": "jsx", ".glsl": "text"}}
+	if err := c.ApplyLoaders(); err != nil {
+		t.Fatal(err)
+	}
+	if Loaders[".js"] != api.LoaderJSX || Loaders[".glsl"] != api.LoaderText {
+		t.Errorf("expected the config's loaders, got .js=%v .glsl=%v", Loaders[".js"], Loaders[".glsl"])
+	}
+
+	for _, loader := range []map[string]string{{"js": "jsx"}, {".js": "coffee"}} {
+		if err := (&ProjectConfig{Loader: loader}).ApplyLoaders(); err == nil {
+			t.Errorf("ApplyLoaders(%v): expected an error", loader)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package common
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ProxyOptions tunes the connections dev servers make to proxy targets.
+type ProxyOptions struct {
+	MaxIdleConns    int           // idle keep-alive connections kept per target host
+	MaxConns        int           // total connections per target host (0 = unlimited)
+	IdleTimeout     time.Duration // how long an idle connection is kept open
+	DialTimeout     time.Duration // TCP connect timeout
+	ResponseTimeout time.Duration // wait for response headers (0 = no limit)
+	RetryRefused    time.Duration // keep retrying refused connections this long (0 = off)
+	Wait            time.Duration // wait this long at startup for targets to come up (0 = don't wait)
+	Chaos           []string      // fault injection rules, see NewChaos
+	VerifyTLS       bool          // verify targets' certificates, for Secure rules
+
+	// Set-Cookie Domain and Path rewrites, keyed by the value the backend
+	// sends ("*" matches any). An empty replacement drops the attribute.
+	CookieDomainRewrite map[string]string
+	CookiePathRewrite   map[string]string
+}
+
+// NewProxyTransport returns the transport shared by every proxy rule of a
+// dev server, so all rules draw on one pool of keep-alive connections
+// rather than each opening its own. Unless opts.VerifyTLS is set, TLS
+// verification is skipped because dev servers commonly proxy to localhost
+// HTTPS with self-signed certs.
+func NewProxyTransport(opts ProxyOptions) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: !opts.VerifyTLS},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   opts.MaxIdleConns,
+		MaxConnsPerHost:       opts.MaxConns,
+		IdleConnTimeout:       opts.IdleTimeout,
+		ResponseHeaderTimeout: opts.ResponseTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	var rt http.RoundTripper = transport
+	if opts.RetryRefused > 0 {
+		rt = &retryRefusedTransport{base: rt, window: opts.RetryRefused}
+	}
+	if len(opts.CookieDomainRewrite) > 0 || len(opts.CookiePathRewrite) > 0 {
+		rt = &cookieRewriteTransport{base: rt, domains: opts.CookieDomainRewrite, paths: opts.CookiePathRewrite}
+	}
+	return rt
+}
+
+// ParseCookieRewrites parses --proxy-cookie-domain / --proxy-cookie-path
+// values: "from=to" rewrites one value, and a bare "to" rewrites any.
+func ParseCookieRewrites(specs []string) map[string]string {
+	if len(specs) == 0 {
+		return nil
+	}
+	rewrites := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		from, to, ok := strings.Cut(spec, "=")
+		if !ok {
+			from, to = "*", spec
+		}
+		rewrites[strings.TrimSpace(from)] = strings.TrimSpace(to)
+	}
+	return rewrites
+}
+
+// cookieRewriteTransport rewrites the Domain and Path of cookies set by a
+// proxied backend, so cookies scoped to e.g. a staging host are stored for
+// localhost instead of being rejected by the browser.
+type cookieRewriteTransport struct {
+	base    http.RoundTripper
+	domains map[string]string
+	paths   map[string]string
+}
+
+func (t *cookieRewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	cookies := resp.Header["Set-Cookie"]
+	for i, cookie := range cookies {
+		cookies[i] = rewriteSetCookie(cookie, t.domains, t.paths)
+	}
+	return resp, nil
+}
+
+// rewriteSetCookie applies domain and path rewrites to one Set-Cookie value.
+// Domains match case-insensitively and ignoring a leading dot.
+func rewriteSetCookie(cookie string, domains, paths map[string]string) string {
+	parts := strings.Split(cookie, ";")
+	out := []string{parts[0]}
+	for _, part := range parts[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		var rewrites map[string]string
+		var match func(string) bool
+		switch strings.ToLower(name) {
+		case "domain":
+			rewrites = domains
+			match = func(from string) bool {
+				return strings.EqualFold(strings.TrimPrefix(from, "."), strings.TrimPrefix(value, "."))
+			}
+		case "path":
+			rewrites = paths
+			match = func(from string) bool { return from == value }
+		}
+		if rewrites == nil {
+			out = append(out, part)
+			continue
+		}
+		to, ok := lookupRewrite(rewrites, match)
+		switch {
+		case !ok:
+			out = append(out, part)
+		case to != "":
+			out = append(out, " "+name+"="+to)
+		}
+	}
+	return strings.Join(out, ";")
+}
+
+// lookupRewrite returns the replacement for the first key match accepts,
+// falling back to the "*" entry.
+func lookupRewrite(rewrites map[string]string, match func(string) bool) (string, bool) {
+	for from, to := range rewrites {
+		if from != "*" && match(from) {
+			return to, true
+		}
+	}
+	to, ok := rewrites["*"]
+	return to, ok
+}
+
+// retryRefusedTransport retries requests whose connection was refused, which
+// is what a proxied backend does while it's still starting up. Only requests
+// without a body are retried: the body of a failed attempt has already been
+// closed by the underlying transport.
+type retryRefusedTransport struct {
+	base   http.RoundTripper
+	window time.Duration
+}
+
+func (t *retryRefusedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		return t.base.RoundTrip(req)
+	}
+	deadline := time.Now().Add(t.window)
+	backoff := 50 * time.Millisecond
+	for {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil || !errors.Is(err, syscall.ECONNREFUSED) || time.Now().Add(backoff).After(deadline) {
+			return resp, err
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		if backoff < time.Second {
+			backoff *= 2
+		}
+	}
+}
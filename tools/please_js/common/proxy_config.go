@@ -0,0 +1,97 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// proxyRoute is a route in a --proxy-config file. Fields left out keep the
+// defaults of --proxy rules.
+type proxyRoute struct {
+	Target       string            `json:"target"`
+	Rewrite      string            `json:"rewrite"` // from->to, as in --proxy rules
+	ChangeOrigin *bool             `json:"changeOrigin"`
+	Secure       *bool             `json:"secure"`
+	WS           *bool             `json:"ws"`
+	Headers      map[string]string `json:"headers"`
+}
+
+// LoadProxyConfig reads the proxy rules in a --proxy-config file: a JSON
+// object (comments allowed) mapping path prefixes to routes, e.g.
+//
+//	{
+//	  "/api": {
+//	    "target": "https://staging.example.com",
+//	    "rewrite": "/api->/",
+//	    "changeOrigin": true,
+//	    "secure": true,
+//	    "ws": false,
+//	    "headers": {"X-Dev-User": "alice"}
+//	  },
+//	  "/auth": "http://localhost:9000"
+//	}
+//
+// A string route is read as the target of a --proxy rule, options and all.
+func LoadProxyConfig(path string) ([]ProxyRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proxy config: %w", err)
+	}
+	var routes map[string]json.RawMessage
+	if err := json.Unmarshal(StripJSONC(data), &routes); err != nil {
+		return nil, fmt.Errorf("failed to parse proxy config %s: %w", path, err)
+	}
+	rules := make([]ProxyRule, 0, len(routes))
+	for prefix, raw := range routes {
+		rule, err := parseProxyRoute(prefix, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseProxyRoute parses one route of a --proxy-config file.
+func parseProxyRoute(prefix string, raw json.RawMessage) (ProxyRule, error) {
+	var spec string
+	if err := json.Unmarshal(raw, &spec); err == nil {
+		return ParseProxyRule(prefix + "=" + spec)
+	}
+	var route proxyRoute
+	if err := json.Unmarshal(raw, &route); err != nil {
+		return ProxyRule{}, fmt.Errorf("invalid proxy route %q: %w", prefix, err)
+	}
+	if prefix == "" || route.Target == "" {
+		return ProxyRule{}, fmt.Errorf("invalid proxy route %q: expected a prefix and a target", prefix)
+	}
+	u, err := url.Parse(route.Target)
+	if err != nil {
+		return ProxyRule{}, fmt.Errorf("invalid proxy target %q: %w", route.Target, err)
+	}
+	rule := ProxyRule{Prefix: prefix, Target: u, ChangeOrigin: true, WS: true}
+	if route.Rewrite != "" {
+		if rule.RewriteFrom, rule.RewriteTo, err = parseRewrite(route.Rewrite); err != nil {
+			return ProxyRule{}, err
+		}
+	}
+	if route.ChangeOrigin != nil {
+		rule.ChangeOrigin = *route.ChangeOrigin
+	}
+	if route.Secure != nil {
+		rule.Secure = *route.Secure
+	}
+	if route.WS != nil {
+		rule.WS = *route.WS
+	}
+	if len(route.Headers) > 0 {
+		rule.Headers = make(http.Header, len(route.Headers))
+		for name, value := range route.Headers {
+			rule.Headers.Set(name, value)
+		}
+	}
+	return rule, nil
+}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProxyConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.json")
+	os.WriteFile(path, []byte(`{
+	// Staging, as our own user.
+	"/api": {
+		"target": "https://staging.example.com",
+		"rewrite": "/api->/",
+		"changeOrigin": false,
+		"secure": true,
+		"ws": false,
+		"headers": {"X-Dev-User": "alice"},
+	},
+	"/auth": "http://localhost:9000;header=X-Env:dev",
+}`), 0644)
+
+	rules, err := LoadProxyConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPrefix := make(map[string]ProxyRule)
+	for _, rule := range rules {
+		byPrefix[rule.Prefix] = rule
+	}
+
+	api := byPrefix["/api"]
+	if api.Target == nil || api.Target.Host != "staging.example.com" {
+		t.Fatalf("unexpected /api rule: %+v", api)
+	}
+	if api.RewriteFrom != "/api" || api.RewriteTo != "/" {
+		t.Errorf("unexpected rewrite %q -> %q", api.RewriteFrom, api.RewriteTo)
+	}
+	if api.ChangeOrigin || !api.Secure || api.WS {
+		t.Errorf("expected the route's changeOrigin, secure and ws, got %+v", api)
+	}
+	if api.Headers.Get("X-Dev-User") != "alice" {
+		t.Errorf("unexpected headers: %v", api.Headers)
+	}
+
+	auth := byPrefix["/auth"]
+	if auth.Target == nil || auth.Target.Host != "localhost:9000" || auth.Headers.Get("X-Env") != "dev" {
+		t.Errorf("unexpected /auth rule: %+v", auth)
+	}
+	if !auth.ChangeOrigin || auth.Secure || !auth.WS {
+		t.Errorf("expected a string route to get --proxy defaults, got %+v", auth)
+	}
+}
+
+func TestLoadProxyConfigErrors(t *testing.T) {
+	for _, config := range []string{
+		`[]`,
+		`{"/api": {"rewrite": "/api->/"}}`,
+		`{"/api": {"target": "http://localhost:9000", "rewrite": "/api"}}`,
+		`{"/api": 9000}`,
+	} {
+		path := filepath.Join(t.TempDir(), "proxy.json")
+		os.WriteFile(path, []byte(config), 0644)
+		if _, err := LoadProxyConfig(path); err == nil {
+			t.Errorf("expected %s to fail", config)
+		}
+	}
+	if _, err := LoadProxyConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected a missing file to fail")
+	}
+}
@@ -0,0 +1,219 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyHealth tracks whether each proxy target is reachable. Targets start
+// out unknown, are probed at startup, and then follow the outcome of proxied
+// requests: a failed dial marks a target down, any response marks it up.
+// Transitions are logged so a backend going away (or coming back) is visible
+// in the dev server output.
+type ProxyHealth struct {
+	mu      sync.Mutex
+	targets []*proxyTarget
+}
+
+type proxyTarget struct {
+	prefix string
+	url    *url.URL
+	up     bool
+	known  bool
+}
+
+// NewProxyHealth returns an empty health tracker.
+func NewProxyHealth() *ProxyHealth {
+	return &ProxyHealth{}
+}
+
+// Attach registers a proxy rule and installs handlers on its reverse proxy
+// that update the target's health and answer failures with an error page
+// naming the backend, instead of an empty 502.
+func (h *ProxyHealth) Attach(prefix string, target *url.URL, proxy *httputil.ReverseProxy) {
+	t := &proxyTarget{prefix: prefix, url: target}
+	h.mu.Lock()
+	h.targets = append(h.targets, t)
+	h.mu.Unlock()
+
+	proxy.ModifyResponse = func(*http.Response) error {
+		h.set(t, true, nil)
+		return nil
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(err, context.Canceled) {
+			// The browser went away; nothing to report.
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if isDialError(err) {
+			h.set(t, false, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "  \033[31m[proxy] %s %s: %v\033[0m\n", r.Method, r.URL.Path, err)
+		}
+		writeProxyErrorPage(w, r, t, err)
+	}
+}
+
+// set records a target's health, logging when it changes after startup.
+func (h *ProxyHealth) set(t *proxyTarget, up bool, err error) {
+	h.mu.Lock()
+	changed := t.known && t.up != up
+	t.up, t.known = up, true
+	h.mu.Unlock()
+	if !changed {
+		return
+	}
+	if up {
+		fmt.Printf("  \033[32m✓ proxy %s → %s is up\033[0m\n", t.prefix, t.url)
+	} else {
+		fmt.Printf("  \033[31m✗ proxy %s → %s is down: %v\033[0m\n", t.prefix, t.url, err)
+	}
+}
+
+// Check probes every target once with a TCP connect.
+func (h *ProxyHealth) Check(timeout time.Duration) {
+	h.mu.Lock()
+	targets := append([]*proxyTarget(nil), h.targets...)
+	h.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t *proxyTarget) {
+			defer wg.Done()
+			up := probeTarget(t.url, timeout) == nil
+			h.mu.Lock()
+			t.up, t.known = up, true
+			h.mu.Unlock()
+		}(t)
+	}
+	wg.Wait()
+}
+
+// Wait polls the targets until all of them accept connections or the wait
+// elapses, so the dev server doesn't open in the browser before its API is
+// up. It reports whether every target came up.
+func (h *ProxyHealth) Wait(wait time.Duration) bool {
+	h.mu.Lock()
+	empty := len(h.targets) == 0
+	h.mu.Unlock()
+	if empty {
+		return true
+	}
+	deadline := time.Now().Add(wait)
+	announced := false
+	for {
+		h.Check(time.Second)
+		down := h.down()
+		if len(down) == 0 {
+			return true
+		}
+		if !announced {
+			fmt.Printf("  \033[33mWaiting up to %s for proxy targets: %s\033[0m\n", wait, strings.Join(down, ", "))
+			announced = true
+		}
+		if time.Now().After(deadline) {
+			fmt.Printf("  \033[33mProxy targets still down after %s, starting anyway: %s\033[0m\n", wait, strings.Join(down, ", "))
+			return false
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// down returns the targets last seen unreachable, as "prefix → url".
+func (h *ProxyHealth) down() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var down []string
+	for _, t := range h.targets {
+		if t.known && !t.up {
+			down = append(down, fmt.Sprintf("%s → %s", t.prefix, t.url))
+		}
+	}
+	return down
+}
+
+// PrintStatus prints one banner line per proxy rule with its target's health.
+func (h *ProxyHealth) PrintStatus() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, t := range h.targets {
+		status := "\033[2m?\033[0m"
+		if t.known && t.up {
+			status = "\033[32m✓ up\033[0m"
+		} else if t.known {
+			status = "\033[31m✗ down\033[0m"
+		}
+		fmt.Printf("  \033[36m➜\033[0m  \033[1mProxy:\033[0m   %s → %s  %s\n", t.prefix, t.url, status)
+	}
+}
+
+// probeTarget checks that something accepts TCP connections at target.
+func probeTarget(target *url.URL, timeout time.Duration) error {
+	host := target.Host
+	if target.Port() == "" {
+		port := "80"
+		if target.Scheme == "https" || target.Scheme == "wss" {
+			port = "443"
+		}
+		host = net.JoinHostPort(target.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// isDialError reports whether a proxy error means the target couldn't be
+// reached at all, as opposed to failing mid-request.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// writeProxyErrorPage answers a failed proxy request with a 502 naming the
+// backend. Browsers navigating to the URL get an HTML page; fetch/XHR
+// callers get plain text.
+func writeProxyErrorPage(w http.ResponseWriter, r *http.Request, t *proxyTarget, err error) {
+	summary := fmt.Sprintf("The proxy target for %s (%s) is not responding.", t.prefix, t.url)
+	if !isDialError(err) {
+		summary = fmt.Sprintf("The request to the proxy target for %s (%s) failed.", t.prefix, t.url)
+	}
+	hint := "Start the backend, or pass --proxy-wait to hold the dev server until it's up."
+
+	w.Header().Set("Cache-Control", "no-store")
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(w, "502 Bad Gateway: %s\n%v\n%s\n", summary, err, hint)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusBadGateway)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>502 · %s</title></head>
+<body style="font-family:system-ui,sans-serif;max-width:40rem;margin:4rem auto;padding:0 1rem;color:#222">
+<h1 style="font-size:1.4rem">Backend unavailable</h1>
+<p>%s</p>
+<pre style="background:#f4f4f4;padding:.75rem;overflow:auto">%s %s
+%s</pre>
+<p style="color:#666">%s</p>
+</body>
+</html>
+`, html.EscapeString(t.url.Host), html.EscapeString(summary),
+		html.EscapeString(r.Method), html.EscapeString(r.URL.RequestURI()),
+		html.EscapeString(err.Error()), html.EscapeString(hint))
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyHealth_WaitForTargetStartup(t *testing.T) {
+	addr := freeAddr(t)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		http.Serve(ln, http.NotFoundHandler())
+	}()
+
+	health := NewProxyHealth()
+	u, _ := url.Parse("http://" + addr)
+	health.Attach("/api", u, httputil.NewSingleHostReverseProxy(u))
+	if !health.Wait(5 * time.Second) {
+		t.Fatal("expected target to come up within the wait")
+	}
+	if down := health.down(); len(down) != 0 {
+		t.Errorf("expected no targets down, got %v", down)
+	}
+}
+
+func TestProxyHealth_WaitGivesUp(t *testing.T) {
+	health := NewProxyHealth()
+	u, _ := url.Parse("http://" + freeAddr(t))
+	health.Attach("/api", u, httputil.NewSingleHostReverseProxy(u))
+	if health.Wait(300 * time.Millisecond) {
+		t.Fatal("expected wait to give up on a target that never starts")
+	}
+	if down := health.down(); len(down) != 1 || !strings.HasPrefix(down[0], "/api → ") {
+		t.Errorf("expected /api reported down, got %v", down)
+	}
+}
+
+func TestProxyHealth_ErrorPageNamesBackend(t *testing.T) {
+	health := NewProxyHealth()
+	u, _ := url.Parse("http://" + freeAddr(t))
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.Transport = NewProxyTransport(ProxyOptions{DialTimeout: time.Second})
+	health.Attach("/api", u, proxy)
+
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected an HTML page for browser navigation, got %q", ct)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, u.Host) || !strings.Contains(body, "/api") {
+		t.Errorf("expected error page to name the backend, got:\n%s", body)
+	}
+
+	// fetch() callers get plain text.
+	req = httptest.NewRequest("GET", "/api/users", nil)
+	rec = httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected plain text for non-HTML requests, got %q", ct)
+	}
+	if down := health.down(); len(down) != 1 {
+		t.Errorf("expected failed dial to mark the target down, got %v", down)
+	}
+}
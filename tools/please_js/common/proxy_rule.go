@@ -0,0 +1,153 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ProxyRule routes the requests under a path prefix to a backend.
+type ProxyRule struct {
+	Prefix       string
+	Target       *url.URL
+	RewriteFrom  string      // path prefix to replace before proxying; "" for none
+	RewriteTo    string      // what to replace it with
+	Headers      http.Header // set on every proxied request
+	ChangeOrigin bool        // send the target's host as the Host header
+	Secure       bool        // verify the target's TLS certificate
+	WS           bool        // tunnel WebSocket upgrades to the target
+}
+
+// ParseProxyRule parses a --proxy rule, "prefix=target", optionally followed
+// by ;-separated options:
+//
+//   - rewrite=from->to replaces a leading from in the request path with to,
+//     so rewrite=/api->/ sends /api/users to the target's /users
+//   - header=Name:value sets a request header (repeatable)
+func ParseProxyRule(spec string) (ProxyRule, error) {
+	parts := strings.Split(spec, ";")
+	prefix, target, ok := strings.Cut(parts[0], "=")
+	prefix = strings.TrimSpace(prefix)
+	if !ok || prefix == "" {
+		return ProxyRule{}, fmt.Errorf("invalid proxy rule %q: expected prefix=target", spec)
+	}
+	u, err := url.Parse(strings.TrimSpace(target))
+	if err != nil {
+		return ProxyRule{}, fmt.Errorf("invalid proxy target %q: %w", target, err)
+	}
+	rule := ProxyRule{Prefix: prefix, Target: u, ChangeOrigin: true, WS: true}
+	for _, opt := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "":
+		case "rewrite":
+			if rule.RewriteFrom, rule.RewriteTo, err = parseRewrite(value); err != nil {
+				return ProxyRule{}, err
+			}
+		case "header":
+			name, v, ok := strings.Cut(value, ":")
+			if !ok || strings.TrimSpace(name) == "" {
+				return ProxyRule{}, fmt.Errorf("invalid proxy header %q: expected Name:value", value)
+			}
+			if rule.Headers == nil {
+				rule.Headers = http.Header{}
+			}
+			rule.Headers.Add(strings.TrimSpace(name), strings.TrimSpace(v))
+		default:
+			return ProxyRule{}, fmt.Errorf("unknown proxy option %q in %q", key, spec)
+		}
+	}
+	return rule, nil
+}
+
+// parseRewrite parses a "from->to" path rewrite.
+func parseRewrite(value string) (string, string, error) {
+	from, to, ok := strings.Cut(value, "->")
+	if !ok || strings.TrimSpace(from) == "" {
+		return "", "", fmt.Errorf("invalid proxy rewrite %q: expected from->to", value)
+	}
+	return strings.TrimSpace(from), strings.TrimSpace(to), nil
+}
+
+// rewritePath applies the rule's rewrite to a request path.
+func (r ProxyRule) rewritePath(path string) (string, bool) {
+	if r.RewriteFrom == "" || !strings.HasPrefix(path, r.RewriteFrom) {
+		return path, false
+	}
+	path = strings.TrimSuffix(r.RewriteTo, "/") + strings.TrimPrefix(path, r.RewriteFrom)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path, true
+}
+
+// NewReverseProxy returns a reverse proxy for the rule, sending requests
+// through transport. With ChangeOrigin, as --proxy rules have, it rewrites
+// the Host header to the target's, so backends behind virtual hosts or CORS
+// checks see the origin they expect. Other headers are forwarded as they are,
+// except that a transport from NewProxyTransport with cookie rewrites
+// configured rewrites the Domain and Path of Set-Cookie headers (see
+// cookieRewriteTransport).
+func (r ProxyRule) NewReverseProxy(transport http.RoundTripper) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(r.Target)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		if path, ok := r.rewritePath(req.URL.Path); ok {
+			req.URL.Path, req.URL.RawPath = path, ""
+		}
+		director(req)
+		if r.ChangeOrigin {
+			req.Host = r.Target.Host
+		}
+		for name, values := range r.Headers {
+			req.Header[name] = values
+		}
+	}
+	proxy.Transport = transport
+	return proxy
+}
+
+// Proxy is the reverse proxy serving a rule.
+type Proxy struct {
+	*httputil.ReverseProxy
+	Rule ProxyRule
+}
+
+// NewProxies returns the reverse proxies for rules, keyed by prefix, and
+// their prefixes sorted longest-first, so /api/v2 matches before /api. A
+// later rule for a prefix replaces an earlier one. All proxies share one
+// keep-alive connection pool, tuned by opts, except that Secure rules get
+// one that verifies certificates. Each reports to health.
+func NewProxies(rules []ProxyRule, opts ProxyOptions, health *ProxyHealth) (map[string]*Proxy, []string) {
+	byPrefix := make(map[string]ProxyRule, len(rules))
+	var prefixes []string
+	for _, rule := range rules {
+		if _, ok := byPrefix[rule.Prefix]; !ok {
+			prefixes = append(prefixes, rule.Prefix)
+		}
+		byPrefix[rule.Prefix] = rule
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+
+	proxies := make(map[string]*Proxy, len(prefixes))
+	transports := map[bool]http.RoundTripper{}
+	for _, prefix := range prefixes {
+		rule := byPrefix[prefix]
+		transport, ok := transports[rule.Secure]
+		if !ok {
+			o := opts
+			o.VerifyTLS = rule.Secure
+			transport = NewProxyTransport(o)
+			transports[rule.Secure] = transport
+		}
+		proxy := rule.NewReverseProxy(transport)
+		health.Attach(prefix, rule.Target, proxy)
+		proxies[prefix] = &Proxy{ReverseProxy: proxy, Rule: rule}
+	}
+	return proxies, prefixes
+}
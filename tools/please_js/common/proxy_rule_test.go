@@ -0,0 +1,95 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseProxyRule(t *testing.T) {
+	rule, err := ParseProxyRule("/api=http://localhost:9000;rewrite=/api->/;header=X-Dev-User:alice;header=X-Env: dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Prefix != "/api" || rule.Target.Host != "localhost:9000" {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+	if rule.RewriteFrom != "/api" || rule.RewriteTo != "/" {
+		t.Errorf("unexpected rewrite %q -> %q", rule.RewriteFrom, rule.RewriteTo)
+	}
+	if rule.Headers.Get("X-Dev-User") != "alice" || rule.Headers.Get("X-Env") != "dev" {
+		t.Errorf("unexpected headers: %v", rule.Headers)
+	}
+
+	for _, bad := range []string{"no-equals-sign", "=http://localhost", "/api=http://localhost;rewrite=/api", "/api=http://localhost;header=novalue", "/api=http://localhost;retries=3"} {
+		if _, err := ParseProxyRule(bad); err == nil {
+			t.Errorf("expected ParseProxyRule(%q) to fail", bad)
+		}
+	}
+}
+
+func TestProxyRuleRewritePath(t *testing.T) {
+	for _, tc := range []struct {
+		from, to, path, want string
+	}{
+		{"/api", "/", "/api/users", "/users"},
+		{"/api", "/", "/api", "/"},
+		{"/api", "/v2", "/api/users", "/v2/users"},
+		{"/api/", "", "/api/users", "/users"},
+		{"/api", "/", "/static/app.js", "/static/app.js"},
+	} {
+		got, _ := ProxyRule{RewriteFrom: tc.from, RewriteTo: tc.to}.rewritePath(tc.path)
+		if got != tc.want {
+			t.Errorf("rewrite %s->%s of %s = %s, want %s", tc.from, tc.to, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestProxyRuleNewReverseProxy(t *testing.T) {
+	var gotPath, gotUser, gotHost string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotUser, gotHost = r.URL.Path, r.Header.Get("X-Dev-User"), r.Host
+	}))
+	defer backend.Close()
+
+	rule, err := ParseProxyRule("/api=" + backend.URL + "/backend;rewrite=/api->/;header=X-Dev-User:alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule.NewReverseProxy(http.DefaultTransport).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/users", nil))
+	if gotPath != "/backend/users" {
+		t.Errorf("expected the rewritten path under the target's, got %s", gotPath)
+	}
+	if gotUser != "alice" {
+		t.Errorf("expected the X-Dev-User header to be set, got %q", gotUser)
+	}
+	if gotHost != rule.Target.Host {
+		t.Errorf("expected the Host header to be the target's, got %s", gotHost)
+	}
+}
+
+func TestNewProxies(t *testing.T) {
+	var rules []ProxyRule
+	for _, spec := range []string{"/api=http://localhost:8080", "/api/v2=http://localhost:8081", "/api=http://localhost:9090"} {
+		rule, err := ParseProxyRule(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	rules[1].ChangeOrigin = false
+
+	proxies, prefixes := NewProxies(rules, ProxyOptions{}, NewProxyHealth())
+	if len(prefixes) != 2 || prefixes[0] != "/api/v2" || prefixes[1] != "/api" {
+		t.Fatalf("expected prefixes [/api/v2 /api], got %v", prefixes)
+	}
+	if got := proxies["/api"].Rule.Target.Host; got != "localhost:9090" {
+		t.Errorf("expected the later /api rule to win, got %s", got)
+	}
+
+	req := httptest.NewRequest("GET", "http://localhost:3000/api/v2/users", nil)
+	proxies["/api/v2"].Director(req)
+	if req.Host != "localhost:3000" {
+		t.Errorf("expected the Host header to be kept without changeOrigin, got %s", req.Host)
+	}
+}
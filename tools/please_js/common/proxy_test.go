@@ -0,0 +1,119 @@
+package common
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a localhost address nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestProxyTransport_RetriesRefusedWhileBackendStarts(t *testing.T) {
+	addr := freeAddr(t)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("up"))
+		}))
+	}()
+
+	transport := NewProxyTransport(ProxyOptions{MaxIdleConns: 4, DialTimeout: time.Second, RetryRefused: 5 * time.Second})
+	req, _ := http.NewRequest("GET", "http://"+addr+"/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected request to succeed once the backend started, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestProxyTransport_NoRetry(t *testing.T) {
+	addr := freeAddr(t)
+	transport := NewProxyTransport(ProxyOptions{DialTimeout: time.Second})
+	req, _ := http.NewRequest("GET", "http://"+addr+"/", nil)
+	start := time.Now()
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatal("expected connection refused")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected refused connection to fail immediately without --proxy-retry")
+	}
+}
+
+func TestProxyTransport_DoesNotRetryRequestsWithBody(t *testing.T) {
+	addr := freeAddr(t)
+	transport := NewProxyTransport(ProxyOptions{DialTimeout: time.Second, RetryRefused: 5 * time.Second})
+	req, _ := http.NewRequest("POST", "http://"+addr+"/", strings.NewReader("payload"))
+	start := time.Now()
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatal("expected connection refused")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected request with a body not to be retried")
+	}
+}
+
+func TestRewriteSetCookie(t *testing.T) {
+	domains := ParseCookieRewrites([]string{"staging.example.com=localhost", ".auth.example.com="})
+	paths := ParseCookieRewrites([]string{"/api/=/"})
+	tests := []struct {
+		cookie string
+		want   string
+	}{
+		{"sid=1; Domain=staging.example.com; Path=/api/; HttpOnly", "sid=1; Domain=localhost; Path=/; HttpOnly"},
+		{"sid=1; domain=.STAGING.example.com", "sid=1; domain=localhost"},
+		{"tok=2; Domain=auth.example.com; Secure", "tok=2; Secure"},
+		{"other=3; Domain=cdn.example.com; Path=/static", "other=3; Domain=cdn.example.com; Path=/static"},
+		{"plain=4", "plain=4"},
+	}
+	for _, tt := range tests {
+		if got := rewriteSetCookie(tt.cookie, domains, paths); got != tt.want {
+			t.Errorf("rewriteSetCookie(%q) = %q, want %q", tt.cookie, got, tt.want)
+		}
+	}
+
+	// A bare value rewrites any domain.
+	wildcard := ParseCookieRewrites([]string{"localhost"})
+	if got := rewriteSetCookie("a=1; Domain=api.example.com", wildcard, nil); got != "a=1; Domain=localhost" {
+		t.Errorf("expected wildcard rewrite, got %q", got)
+	}
+}
+
+func TestProxyTransport_RewritesCookies(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "sid=1; Domain=staging.example.com; Path=/")
+		w.Header().Add("Set-Cookie", "pref=dark; Path=/")
+	}))
+	defer backend.Close()
+
+	transport := NewProxyTransport(ProxyOptions{CookieDomainRewrite: map[string]string{"*": ""}})
+	req, _ := http.NewRequest("GET", backend.URL, nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	got := resp.Header.Values("Set-Cookie")
+	if len(got) != 2 || got[0] != "sid=1; Path=/" || got[1] != "pref=dark; Path=/" {
+		t.Errorf("expected domain dropped from cookies, got %q", got)
+	}
+}
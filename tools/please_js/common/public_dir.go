@@ -0,0 +1,123 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// publicPollInterval is how often public directories are checked for
+// changes.
+const publicPollInterval = 200 * time.Millisecond
+
+// PublicDirs serves the files in --public-dir directories at a dev server's
+// root, as they are, for assets that live outside its servedir. The first
+// directory with a file wins.
+type PublicDirs struct {
+	dirs []string // absolute
+}
+
+// NewPublicDirs returns the public directories for --public-dir: nil, for
+// none, without any.
+func NewPublicDirs(dirs []string) (*PublicDirs, error) {
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+	p := &PublicDirs{}
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("--public-dir %s isn't a directory", dir)
+		}
+		p.dirs = append(p.dirs, abs)
+	}
+	return p, nil
+}
+
+// Find returns the file a URL path names in the public directories, or ""
+// if there isn't one. A nil PublicDirs has none.
+func (p *PublicDirs) Find(urlPath string) string {
+	if p == nil {
+		return ""
+	}
+	// Cleaned as an absolute path, .. can't climb out of the directory.
+	rel := filepath.FromSlash(path.Clean("/" + urlPath))
+	for _, dir := range p.dirs {
+		file := filepath.Join(dir, rel)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file
+		}
+	}
+	return ""
+}
+
+// Watch polls the public directories in the background, calling onChange
+// with the URL paths of the files added, changed or removed since the last
+// poll. Hidden files and directories are skipped, as are files ignore
+// matches. A nil PublicDirs watches nothing.
+func (p *PublicDirs) Watch(ignore *WatchIgnore, onChange func(changed []string)) {
+	if p == nil {
+		return
+	}
+	go func() {
+		mtimes := p.scan(ignore)
+		for range time.Tick(publicPollInterval) {
+			next := p.scan(ignore)
+			var changed []string
+			for urlPath, mt := range next {
+				if old, ok := mtimes[urlPath]; !ok || !old.Equal(mt) {
+					changed = append(changed, urlPath)
+				}
+			}
+			for urlPath := range mtimes {
+				if _, ok := next[urlPath]; !ok {
+					changed = append(changed, urlPath)
+				}
+			}
+			mtimes = next
+			if len(changed) > 0 {
+				sort.Strings(changed)
+				onChange(changed)
+			}
+		}
+	}()
+}
+
+// scan returns the mtimes of the files in the public directories that
+// ignore doesn't match, keyed by URL path.
+func (p *PublicDirs) scan(ignore *WatchIgnore) map[string]time.Time {
+	mtimes := make(map[string]time.Time)
+	for _, dir := range p.dirs {
+		filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if file != dir && strings.HasPrefix(info.Name(), ".") {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() || ignore.Match(file) {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, file)
+			if err != nil {
+				return nil
+			}
+			urlPath := "/" + filepath.ToSlash(rel)
+			if _, ok := mtimes[urlPath]; !ok {
+				mtimes[urlPath] = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return mtimes
+}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPublicDirsFind(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(first, "img"), 0755)
+	os.WriteFile(filepath.Join(first, "img", "logo.svg"), []byte("<svg/>"), 0644)
+	os.WriteFile(filepath.Join(first, "robots.txt"), []byte("first"), 0644)
+	os.WriteFile(filepath.Join(second, "robots.txt"), []byte("second"), 0644)
+	os.WriteFile(filepath.Join(second, "favicon.ico"), []byte("ico"), 0644)
+
+	p, err := NewPublicDirs([]string{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for urlPath, want := range map[string]string{
+		"/img/logo.svg": filepath.Join(first, "img", "logo.svg"),
+		"/robots.txt":   filepath.Join(first, "robots.txt"),
+		"/favicon.ico":  filepath.Join(second, "favicon.ico"),
+		"/img":          "",
+		"/missing.png":  "",
+		"/../" + filepath.Base(first) + "/robots.txt": "",
+	} {
+		if got := p.Find(urlPath); got != want {
+			t.Errorf("Find(%q) = %q, want %q", urlPath, got, want)
+		}
+	}
+
+	if _, err := NewPublicDirs([]string{filepath.Join(first, "robots.txt")}); err == nil {
+		t.Error("expected a file as --public-dir to fail")
+	}
+	if p, err := NewPublicDirs(nil); err != nil || p.Find("/robots.txt") != "" {
+		t.Errorf("expected no public dirs to find nothing, got %v", err)
+	}
+}
+
+func TestPublicDirsWatch(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "data.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(dir, ".DS_Store"), nil, 0644)
+	p, err := NewPublicDirs([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan []string, 1)
+	p.Watch(NewWatchIgnore([]string{"**/*.md"}, dir), func(changed []string) { changes <- changed })
+
+	expect := func(want ...string) {
+		t.Helper()
+		select {
+		case changed := <-changes:
+			if !reflect.DeepEqual(changed, want) {
+				t.Errorf("expected %v to change, got %v", want, changed)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %v to change", want)
+		}
+	}
+	time.Sleep(2 * publicPollInterval)
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.css"), []byte("body{}"), 0644)
+	expect("/new.css")
+	os.Remove(filepath.Join(dir, "data.json"))
+	expect("/data.json")
+}
@@ -0,0 +1,323 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// RemoteImportOptions configures RemoteImportPlugin.
+type RemoteImportOptions struct {
+	CacheDir string // downloaded modules, stored by content hash
+	LockFile string // URL → integrity pins; new URLs are added unless Locked
+	Locked   bool   // every URL must already be pinned in LockFile
+	Offline  bool   // never download; every module must be cached (implies Locked)
+	NpmCDN   string // base URL that npm: specifiers are fetched from
+	Client   *http.Client
+}
+
+// DefaultNpmCDN serves npm: specifiers as ES modules.
+const DefaultNpmCDN = "https://esm.sh"
+
+// remoteNamespace holds modules loaded from URLs, so relative imports inside
+// them resolve against their URL rather than the filesystem.
+const remoteNamespace = "remote"
+
+// RemoteImportPlugin returns an esbuild plugin that bundles https:// imports
+// and npm: specifiers (fetched from opts.NpmCDN) instead of leaving them
+// external. Downloads are stored in opts.CacheDir under their SHA-256, with
+// the lock file recording each URL's integrity ("sha256-<base64>") so later
+// builds get exactly the same bytes. With opts.Locked, URLs missing from the
+// lock fail the build, and with opts.Offline nothing is downloaded, so CI
+// builds stay hermetic.
+func RemoteImportPlugin(opts RemoteImportOptions) api.Plugin {
+	return api.Plugin{
+		Name: "remote-import",
+		Setup: func(build api.PluginBuild) {
+			r := &remoteFetcher{opts: opts, fetched: make(map[string]*remoteFetch)}
+			if r.opts.Client == nil {
+				r.opts.Client = &http.Client{Timeout: 60 * time.Second}
+			}
+			if r.opts.NpmCDN == "" {
+				r.opts.NpmCDN = DefaultNpmCDN
+			}
+			if r.opts.Offline {
+				r.opts.Locked = true
+			}
+
+			build.OnStart(func() (api.OnStartResult, error) {
+				lock, err := loadRemoteLock(opts.LockFile)
+				if err != nil {
+					return api.OnStartResult{}, err
+				}
+				r.mu.Lock()
+				r.lock, r.lockDirty = lock, false
+				r.fetched = make(map[string]*remoteFetch)
+				r.mu.Unlock()
+				return api.OnStartResult{}, nil
+			})
+
+			build.OnResolve(api.OnResolveOptions{Filter: `^(https://|npm:)`},
+				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					u := args.Path
+					if spec, ok := strings.CutPrefix(u, "npm:"); ok {
+						u = strings.TrimSuffix(r.opts.NpmCDN, "/") + "/" + spec
+					}
+					return api.OnResolveResult{Path: u, Namespace: remoteNamespace}, nil
+				},
+			)
+
+			// Imports inside a remote module are relative to its URL. CDNs
+			// such as esm.sh emit root-relative paths ("/react@18/...").
+			build.OnResolve(api.OnResolveOptions{Filter: `^(\.{0,2}/)`, Namespace: remoteNamespace},
+				func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					base, err := url.Parse(args.Importer)
+					if err != nil {
+						return api.OnResolveResult{}, err
+					}
+					ref, err := url.Parse(args.Path)
+					if err != nil {
+						return api.OnResolveResult{}, err
+					}
+					return api.OnResolveResult{Path: base.ResolveReference(ref).String(), Namespace: remoteNamespace}, nil
+				},
+			)
+
+			build.OnLoad(api.OnLoadOptions{Filter: `.*`, Namespace: remoteNamespace},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					data, contentType, err := r.fetch(args.Path)
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+					contents := string(data)
+					return api.OnLoadResult{Contents: &contents, Loader: remoteLoader(args.Path, contentType)}, nil
+				},
+			)
+
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				r.mu.Lock()
+				defer r.mu.Unlock()
+				if !r.lockDirty || opts.LockFile == "" || r.opts.Locked {
+					return api.OnEndResult{}, nil
+				}
+				r.lockDirty = false
+				return api.OnEndResult{}, writeRemoteLock(opts.LockFile, r.lock)
+			})
+		},
+	}
+}
+
+// remoteFetcher downloads remote modules at most once per build.
+type remoteFetcher struct {
+	opts      RemoteImportOptions
+	mu        sync.Mutex
+	lock      map[string]string
+	lockDirty bool
+	fetched   map[string]*remoteFetch
+}
+
+type remoteFetch struct {
+	once        sync.Once
+	data        []byte
+	contentType string
+	err         error
+}
+
+func (r *remoteFetcher) fetch(rawURL string) ([]byte, string, error) {
+	r.mu.Lock()
+	f, ok := r.fetched[rawURL]
+	if !ok {
+		f = &remoteFetch{}
+		r.fetched[rawURL] = f
+	}
+	r.mu.Unlock()
+	f.once.Do(func() {
+		f.data, f.contentType, f.err = r.load(rawURL)
+	})
+	return f.data, f.contentType, f.err
+}
+
+// load returns a module's contents from the cache, or downloads it and
+// checks it against (or records it in) the lock.
+func (r *remoteFetcher) load(rawURL string) ([]byte, string, error) {
+	r.mu.Lock()
+	pinned := r.lock[rawURL]
+	r.mu.Unlock()
+	if pinned == "" && !r.opts.Locked {
+		// Unpinned URLs are still cached, indexed by URL.
+		pinned = readRemoteIndex(r.opts.CacheDir, rawURL)
+	}
+
+	if pinned != "" && r.opts.CacheDir != "" {
+		if data, err := os.ReadFile(remoteBlobPath(r.opts.CacheDir, pinned)); err == nil && integrityOf(data) == pinned {
+			return data, "", nil
+		}
+	}
+	if r.opts.Offline {
+		if pinned == "" {
+			return nil, "", fmt.Errorf("%s is not pinned in %s (offline)", rawURL, r.lockName())
+		}
+		return nil, "", fmt.Errorf("%s is not in the remote import cache %s (offline)", rawURL, r.opts.CacheDir)
+	}
+
+	data, contentType, err := r.download(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	integrity := integrityOf(data)
+
+	r.mu.Lock()
+	locked := r.lock[rawURL]
+	r.mu.Unlock()
+	switch {
+	case locked != "" && locked != integrity:
+		return nil, "", fmt.Errorf("integrity mismatch for %s: %s pins %s but the server sent %s", rawURL, r.lockName(), locked, integrity)
+	case locked == "" && r.opts.Locked:
+		return nil, "", fmt.Errorf("%s is not pinned in %s; add %q: %q", rawURL, r.lockName(), rawURL, integrity)
+	case locked == "" && r.opts.LockFile != "":
+		r.mu.Lock()
+		r.lock[rawURL] = integrity
+		r.lockDirty = true
+		r.mu.Unlock()
+	}
+
+	if r.opts.CacheDir != "" {
+		// A failed cache write only costs a download next time.
+		if err := writeFileAtomic(remoteBlobPath(r.opts.CacheDir, integrity), data); err == nil {
+			writeFileAtomic(remoteIndexPath(r.opts.CacheDir, rawURL), []byte(integrity))
+		}
+	}
+	return data, contentType, nil
+}
+
+func (r *remoteFetcher) download(rawURL string) ([]byte, string, error) {
+	resp, err := r.opts.Client.Get(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to download %s: %s", rawURL, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+func (r *remoteFetcher) lockName() string {
+	if r.opts.LockFile == "" {
+		return "the remote import lock"
+	}
+	return r.opts.LockFile
+}
+
+// remoteLoader picks the loader for a remote module from its URL's
+// extension, falling back to the response's content type and then to JS
+// (CDN module URLs are often extensionless).
+func remoteLoader(rawURL, contentType string) api.Loader {
+	if u, err := url.Parse(rawURL); err == nil {
+		if loader, ok := Loaders[path.Ext(u.Path)]; ok {
+			return loader
+		}
+	}
+	switch {
+	case strings.HasPrefix(contentType, "text/css"):
+		return api.LoaderCSS
+	case strings.HasPrefix(contentType, "application/json"):
+		return api.LoaderJSON
+	}
+	return api.LoaderJS
+}
+
+// integrityOf returns a Subresource Integrity style sha256 hash.
+func integrityOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// remoteBlobPath returns where content with the given integrity is cached.
+func remoteBlobPath(cacheDir, integrity string) string {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(integrity, "sha256-"))
+	if err != nil {
+		raw = []byte(integrity)
+	}
+	return filepath.Join(cacheDir, "sha256", hex.EncodeToString(raw))
+}
+
+// remoteIndexPath returns where the integrity last downloaded for an
+// unpinned URL is recorded.
+func remoteIndexPath(cacheDir, rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(cacheDir, "urls", hex.EncodeToString(sum[:]))
+}
+
+func readRemoteIndex(cacheDir, rawURL string) string {
+	if cacheDir == "" {
+		return ""
+	}
+	data, err := os.ReadFile(remoteIndexPath(cacheDir, rawURL))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// loadRemoteLock reads a lock file; a missing file is an empty lock.
+func loadRemoteLock(lockFile string) (map[string]string, error) {
+	lock := make(map[string]string)
+	if lockFile == "" {
+		return lock, nil
+	}
+	data, err := os.ReadFile(lockFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid remote import lock %s: %w", lockFile, err)
+	}
+	return lock, nil
+}
+
+func writeRemoteLock(lockFile string, lock map[string]string) error {
+	// Map keys are sorted by encoding/json, keeping diffs minimal.
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(lockFile, append(data, '\n'), 0644)
+}
+
+// writeFileAtomic writes via a temporary file so concurrent builds sharing a
+// cache never read a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.tmp-%d", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func newRemoteTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	files := map[string]string{
+		"/lib/mod.js": `import { dep } from "./dep.js"; export const value = dep + 1;`,
+		"/lib/dep.js": `export const dep = 41;`,
+		"/pkg@1":      `export default "from-npm-cdn";`,
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func buildWithRemote(t *testing.T, entrySource string, opts RemoteImportOptions) api.BuildResult {
+	t.Helper()
+	entry := filepath.Join(t.TempDir(), "entry.js")
+	if err := os.WriteFile(entry, []byte(entrySource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return api.Build(api.BuildOptions{
+		EntryPoints: []string{entry},
+		Bundle:      true,
+		Write:       false,
+		Format:      api.FormatESModule,
+		Plugins:     []api.Plugin{RemoteImportPlugin(opts)},
+	})
+}
+
+func TestRemoteImportPlugin(t *testing.T) {
+	server := newRemoteTestServer(t)
+	tmp := t.TempDir()
+	cacheDir := filepath.Join(tmp, "cache")
+	lockFile := filepath.Join(tmp, "remote-lock.json")
+	entry := `import { value } from "` + server.URL + `/lib/mod.js"; import pkg from "npm:pkg@1"; console.log(value, pkg);`
+
+	result := buildWithRemote(t, entry, RemoteImportOptions{
+		CacheDir: cacheDir,
+		LockFile: lockFile,
+		NpmCDN:   server.URL,
+		Client:   server.Client(),
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build errors: %s", result.Errors[0].Text)
+	}
+	output := string(result.OutputFiles[0].Contents)
+	if !strings.Contains(output, "41") || !strings.Contains(output, "from-npm-cdn") {
+		t.Errorf("expected remote modules bundled, got:\n%s", output)
+	}
+
+	data, err := os.ReadFile(lockFile)
+	if err != nil {
+		t.Fatalf("expected lock file to be written: %v", err)
+	}
+	var lock map[string]string
+	json.Unmarshal(data, &lock)
+	if len(lock) != 3 || !strings.HasPrefix(lock[server.URL+"/lib/dep.js"], "sha256-") {
+		t.Errorf("expected all three URLs pinned, got %v", lock)
+	}
+
+	// Offline builds are served entirely from the cache.
+	server.Close()
+	result = buildWithRemote(t, entry, RemoteImportOptions{
+		CacheDir: cacheDir,
+		LockFile: lockFile,
+		Offline:  true,
+		NpmCDN:   server.URL,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("offline build errors: %s", result.Errors[0].Text)
+	}
+}
+
+func TestRemoteImportPlugin_Locked(t *testing.T) {
+	server := newRemoteTestServer(t)
+	tmp := t.TempDir()
+	lockFile := filepath.Join(tmp, "remote-lock.json")
+	entry := `import { dep } from "` + server.URL + `/lib/dep.js"; console.log(dep);`
+
+	// Not pinned: the error names the entry to add.
+	os.WriteFile(lockFile, []byte(`{}`), 0o644)
+	result := buildWithRemote(t, entry, RemoteImportOptions{
+		CacheDir: filepath.Join(tmp, "cache1"),
+		LockFile: lockFile,
+		Locked:   true,
+		Client:   server.Client(),
+	})
+	if len(result.Errors) == 0 || !strings.Contains(result.Errors[0].Text, "is not pinned") || !strings.Contains(result.Errors[0].Text, "sha256-") {
+		t.Fatalf("expected an unpinned URL error with its integrity, got %v", result.Errors)
+	}
+	if data, _ := os.ReadFile(lockFile); string(data) != "{}" {
+		t.Errorf("expected a locked build to leave the lock file alone, got %s", data)
+	}
+
+	// Pinned to different content: integrity mismatch.
+	os.WriteFile(lockFile, []byte(`{"`+server.URL+`/lib/dep.js": "sha256-AAAA"}`), 0o644)
+	result = buildWithRemote(t, entry, RemoteImportOptions{
+		CacheDir: filepath.Join(tmp, "cache2"),
+		LockFile: lockFile,
+		Locked:   true,
+		Client:   server.Client(),
+	})
+	if len(result.Errors) == 0 || !strings.Contains(result.Errors[0].Text, "integrity mismatch") {
+		t.Fatalf("expected an integrity mismatch, got %v", result.Errors)
+	}
+}
@@ -0,0 +1,229 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestSummaryDelay is how long summary mode waits for requests to stop
+// before printing the line that sums them up.
+const requestSummaryDelay = time.Second
+
+// RequestEntry is one request a dev server answered.
+type RequestEntry struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"` // req, proxy, mock or fallback
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Status int       `json:"status"`
+	Bytes  int64     `json:"bytes"`
+	Ms     int64     `json:"ms"`
+	Detail string    `json:"detail,omitempty"` // e.g. the page a fallback served
+}
+
+// RequestLog prints a dev server's requests, and writes them to an access
+// log. Its mode is one of:
+//
+//   - full: a line per request
+//   - summary: a line per failed request, and one summing up the others
+//     once they pause
+//   - off: nothing
+//
+// Lines are text, or with asJSON one JSON object per line. The access log
+// gets every request, in the same format without colours.
+type RequestLog struct {
+	mode   string
+	asJSON bool
+	out    io.Writer
+	file   *os.File // nil without an access log
+
+	mu       sync.Mutex
+	statuses map[int]int   // summary mode: statuses of the requests not yet summed up
+	total    time.Duration // and their combined time
+	timer    *time.Timer
+}
+
+// NewRequestLog returns the log for --log-requests, --log-format and
+// --access-log, printing to out.
+func NewRequestLog(mode, format, accessLog string, out io.Writer) (*RequestLog, error) {
+	switch mode {
+	case "":
+		mode = "full"
+	case "full", "summary", "off":
+	default:
+		return nil, fmt.Errorf("invalid --log-requests %q: expected off, summary or full", mode)
+	}
+	if format != "" && format != "text" && format != "json" {
+		return nil, fmt.Errorf("invalid --log-format %q: expected text or json", format)
+	}
+	l := &RequestLog{mode: mode, asJSON: format == "json", out: out}
+	if accessLog != "" {
+		f, err := os.OpenFile(accessLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open access log: %w", err)
+		}
+		l.file = f
+	}
+	return l, nil
+}
+
+// Log records a request.
+func (l *RequestLog) Log(e RequestEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		fmt.Fprintln(l.file, l.format(e, false))
+	}
+	switch {
+	case l.mode == "off":
+	case l.mode == "full" || e.Status >= 400:
+		fmt.Fprintln(l.out, l.format(e, true))
+	default:
+		if l.statuses == nil {
+			l.statuses = make(map[int]int)
+		}
+		l.statuses[e.Status]++
+		l.total += time.Duration(e.Ms) * time.Millisecond
+		if l.timer != nil {
+			l.timer.Stop()
+		}
+		l.timer = time.AfterFunc(requestSummaryDelay, l.summarise)
+	}
+}
+
+// summarise prints the line summing up the requests since the last one.
+func (l *RequestLog) summarise() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	var codes []int
+	for status, count := range l.statuses {
+		n += count
+		codes = append(codes, status)
+	}
+	if n == 0 {
+		return
+	}
+	sort.Ints(codes)
+	avg := l.total.Milliseconds() / int64(n)
+	if l.asJSON {
+		counts := make(map[string]int, len(codes))
+		for _, status := range codes {
+			counts[fmt.Sprint(status)] = l.statuses[status]
+		}
+		data, _ := json.Marshal(struct {
+			Time     time.Time      `json:"time"`
+			Kind     string         `json:"kind"`
+			Requests int            `json:"requests"`
+			Statuses map[string]int `json:"statuses"`
+			AvgMs    int64          `json:"avg_ms"`
+		}{time.Now(), "summary", n, counts, avg})
+		fmt.Fprintln(l.out, string(data))
+	} else {
+		parts := make([]string, len(codes))
+		for i, status := range codes {
+			parts[i] = fmt.Sprintf("%d × %d", l.statuses[status], status)
+		}
+		fmt.Fprintf(l.out, "  \033[2m[req] %d requests: %s (avg %dms)\033[0m\n", n, strings.Join(parts, ", "), avg)
+	}
+	l.statuses, l.total = nil, 0
+}
+
+// format formats an entry as a line, coloured for the terminal with color.
+func (l *RequestLog) format(e RequestEntry, color bool) string {
+	if l.asJSON {
+		data, _ := json.Marshal(e)
+		return string(data)
+	}
+	detail := ""
+	if e.Detail != "" {
+		detail = " " + e.Detail
+	}
+	kind := e.Kind
+	if kind == "fallback" {
+		kind, detail = "req", " fallback"+detail
+	}
+	line := fmt.Sprintf("  [%s] %s %s → %d%s (%dms)", kind, e.Method, e.Path, e.Status, detail, e.Ms)
+	if !color {
+		return e.Time.Format(time.RFC3339) + line
+	}
+	style := "2" // dim
+	switch {
+	case e.Status >= 500:
+		style = "31" // red
+	case e.Status >= 400:
+		style = "33" // yellow
+	}
+	return "\033[" + style + "m" + line + "\033[0m"
+}
+
+// Close flushes a pending summary and closes the access log.
+func (l *RequestLog) Close() error {
+	l.mu.Lock()
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.mu.Unlock()
+	l.summarise()
+	if l.file != nil {
+		return l.file.Close()
+	}
+	return nil
+}
+
+// LoggedResponse wraps a ResponseWriter to record the status and size of
+// the response, for a RequestLog.
+type LoggedResponse struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int64
+}
+
+func (lr *LoggedResponse) WriteHeader(status int) {
+	if lr.Status == 0 {
+		lr.Status = status
+	}
+	lr.ResponseWriter.WriteHeader(status)
+}
+
+func (lr *LoggedResponse) Write(p []byte) (int, error) {
+	if lr.Status == 0 {
+		lr.Status = http.StatusOK
+	}
+	n, err := lr.ResponseWriter.Write(p)
+	lr.Bytes += int64(n)
+	return n, err
+}
+
+func (lr *LoggedResponse) Flush() {
+	if f, ok := lr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection over, as for a WebSocket upgrade.
+func (lr *LoggedResponse) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := lr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && lr.Status == 0 {
+		lr.Status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap gives http.ResponseController the underlying writer.
+func (lr *LoggedResponse) Unwrap() http.ResponseWriter {
+	return lr.ResponseWriter
+}
@@ -0,0 +1,89 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRequestLog(t *testing.T) {
+	for _, tc := range []struct{ mode, format string }{{"loud", ""}, {"full", "xml"}} {
+		if _, err := NewRequestLog(tc.mode, tc.format, "", &bytes.Buffer{}); err == nil {
+			t.Errorf("expected NewRequestLog(%q, %q) to fail", tc.mode, tc.format)
+		}
+	}
+}
+
+func TestRequestLogFull(t *testing.T) {
+	var out bytes.Buffer
+	l, err := NewRequestLog("", "", "", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Log(RequestEntry{Kind: "fallback", Method: "GET", Path: "/users/1", Status: 200, Ms: 3, Detail: "/index.html"})
+	if got := out.String(); !strings.Contains(got, "[req] GET /users/1 → 200 fallback /index.html (3ms)") {
+		t.Errorf("unexpected log line %q", got)
+	}
+}
+
+func TestRequestLogSummary(t *testing.T) {
+	var out bytes.Buffer
+	l, err := NewRequestLog("summary", "", "", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Log(RequestEntry{Kind: "req", Method: "GET", Path: "/a.js", Status: 200, Ms: 2})
+	l.Log(RequestEntry{Kind: "req", Method: "GET", Path: "/b.js", Status: 200, Ms: 4})
+	l.Log(RequestEntry{Kind: "req", Method: "GET", Path: "/c.js", Status: 304})
+	l.Log(RequestEntry{Kind: "req", Method: "GET", Path: "/missing", Status: 404})
+	if got := out.String(); !strings.Contains(got, "/missing → 404") || strings.Contains(got, "/a.js") {
+		t.Errorf("expected only the failed request to be logged before the summary, got %q", got)
+	}
+	l.Close()
+	if got := out.String(); !strings.Contains(got, "3 requests: 2 × 200, 1 × 304 (avg 2ms)") {
+		t.Errorf("expected a summary line, got %q", got)
+	}
+}
+
+func TestRequestLogJSONAndAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	path := filepath.Join(t.TempDir(), "access.log")
+	l, err := NewRequestLog("off", "json", path, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.Log(RequestEntry{Time: when, Kind: "proxy", Method: "POST", Path: "/api/users", Status: 502, Bytes: 12, Ms: 40, Detail: "http://localhost:3000"})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected nothing printed with --log-requests=off, got %q", out.String())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e RequestEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		t.Fatalf("expected a JSON line in the access log, got %q: %v", data, err)
+	}
+	if e.Kind != "proxy" || e.Status != 502 || e.Detail != "http://localhost:3000" || !e.Time.Equal(when) {
+		t.Errorf("unexpected access log entry %+v", e)
+	}
+}
+
+func TestLoggedResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	lw := &LoggedResponse{ResponseWriter: rec}
+	http.NotFound(lw, httptest.NewRequest("GET", "/", nil))
+	if lw.Status != http.StatusNotFound || lw.Bytes != int64(rec.Body.Len()) {
+		t.Errorf("expected 404 with %d bytes recorded, got %d with %d", rec.Body.Len(), lw.Status, lw.Bytes)
+	}
+}
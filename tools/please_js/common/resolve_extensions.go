@@ -0,0 +1,47 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// defaultResolveExtensions is esbuild's default resolve order.
+var defaultResolveExtensions = []string{".tsx", ".ts", ".jsx", ".js", ".css", ".json"}
+
+var flavorRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ResolveExtensions returns the resolve extensions for a build, so that an
+// import of "./util" picks the variant of util.ts made for it.
+//
+// With flavor "enterprise", billing.enterprise.ts is preferred over
+// billing.ts, and files for other flavors (billing.oss.ts) are never picked
+// up. Multi-edition products build each flavor from one codebase, with the
+// choice made at compile time so the other variants aren't in the bundle at
+// all.
+//
+// util.browser.ts is preferred when building for the browser and
+// util.node.ts when building for node (the platform is parsed as for
+// ParsePlatform), so isomorphic code can keep its
+// platform specific parts apart without runtime checks. The flavor is
+// chosen before the platform.
+func ResolveExtensions(platform, flavor string) ([]string, error) {
+	var suffixes []string
+	if flavor != "" {
+		if !flavorRe.MatchString(flavor) {
+			return nil, fmt.Errorf("invalid flavor %q: must be letters, digits, - and _", flavor)
+		}
+		suffixes = append(suffixes, "."+flavor)
+	}
+	if platform == "node" {
+		suffixes = append(suffixes, ".node")
+	} else {
+		suffixes = append(suffixes, ".browser")
+	}
+	exts := make([]string, 0, (len(suffixes)+1)*len(defaultResolveExtensions))
+	for _, suffix := range suffixes {
+		for _, ext := range defaultResolveExtensions {
+			exts = append(exts, suffix+ext)
+		}
+	}
+	return append(exts, defaultResolveExtensions...), nil
+}
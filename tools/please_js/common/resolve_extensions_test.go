@@ -0,0 +1,86 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestResolveExtensionsFlavor(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.ts"), []byte(`import { edition } from "./edition"; import { shared } from "./shared"; console.log(edition, shared);`), 0644)
+	os.WriteFile(filepath.Join(dir, "edition.enterprise.ts"), []byte(`export const edition = "enterprise";`), 0644)
+	os.WriteFile(filepath.Join(dir, "edition.oss.ts"), []byte(`export const edition = "oss";`), 0644)
+	os.WriteFile(filepath.Join(dir, "shared.ts"), []byte(`export const shared = "shared";`), 0644)
+
+	for _, flavor := range []string{"enterprise", "oss"} {
+		exts, err := ResolveExtensions("browser", flavor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := api.Build(api.BuildOptions{
+			EntryPoints:       []string{filepath.Join(dir, "main.ts")},
+			Bundle:            true,
+			Write:             false,
+			ResolveExtensions: exts,
+			LogLevel:          api.LogLevelSilent,
+		})
+		if len(result.Errors) > 0 {
+			t.Fatalf("flavor %s: %s", flavor, result.Errors[0].Text)
+		}
+		code := string(result.OutputFiles[0].Contents)
+		other := map[string]string{"enterprise": "oss", "oss": "enterprise"}[flavor]
+		if !strings.Contains(code, `"`+flavor+`"`) || strings.Contains(code, `"`+other+`"`) {
+			t.Errorf("flavor %s: expected only the %s variant, got:\n%s", flavor, flavor, code)
+		}
+		if !strings.Contains(code, `"shared"`) {
+			t.Errorf("flavor %s: expected unflavored files to resolve, got:\n%s", flavor, code)
+		}
+	}
+
+	if _, err := ResolveExtensions("browser", "../x"); err == nil {
+		t.Error("expected an error for an invalid flavor")
+	}
+}
+
+func TestResolveExtensionsPlatform(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.ts"), []byte(`import { storage } from "./storage"; import { shared } from "./shared"; console.log(storage, shared);`), 0644)
+	os.WriteFile(filepath.Join(dir, "storage.browser.ts"), []byte(`export const storage = "localStorage";`), 0644)
+	os.WriteFile(filepath.Join(dir, "storage.node.ts"), []byte(`export const storage = "fs";`), 0644)
+	os.WriteFile(filepath.Join(dir, "storage.ts"), []byte(`export const storage = "memory";`), 0644)
+	os.WriteFile(filepath.Join(dir, "shared.ts"), []byte(`export const shared = "shared";`), 0644)
+
+	for platform, want := range map[string]string{"browser": "localStorage", "node": "fs"} {
+		exts, err := ResolveExtensions(platform, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := api.Build(api.BuildOptions{
+			EntryPoints:       []string{filepath.Join(dir, "main.ts")},
+			Bundle:            true,
+			Write:             false,
+			Platform:          ParsePlatform(platform),
+			ResolveExtensions: exts,
+			LogLevel:          api.LogLevelSilent,
+		})
+		if len(result.Errors) > 0 {
+			t.Fatalf("platform %s: %s", platform, result.Errors[0].Text)
+		}
+		code := string(result.OutputFiles[0].Contents)
+		if !strings.Contains(code, `"`+want+`"`) || strings.Contains(code, `"memory"`) {
+			t.Errorf("platform %s: expected only the %s variant, got:\n%s", platform, want, code)
+		}
+		if !strings.Contains(code, `"shared"`) {
+			t.Errorf("platform %s: expected files without variants to resolve, got:\n%s", platform, code)
+		}
+	}
+
+	exts, _ := ResolveExtensions("node", "enterprise")
+	if exts[0] != ".enterprise.tsx" || exts[len(exts)-1] != ".json" {
+		t.Errorf("expected flavor variants first and esbuild's defaults last, got %v", exts)
+	}
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// StatusURLPath is where dev servers report their state as JSON, for editor
+// extensions and status lines to poll rather than scrape the terminal.
+const StatusURLPath = "/__status"
+
+// StatusMessage is an error or warning a dev server reports, with where it
+// is.
+type StatusMessage struct {
+	Text   string `json:"text"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// StatusMessages converts esbuild's messages for a BuildStatus.
+func StatusMessages(msgs []api.Message) []StatusMessage {
+	var out []StatusMessage
+	for _, m := range msgs {
+		sm := StatusMessage{Text: m.Text}
+		if m.Location != nil {
+			sm.File, sm.Line, sm.Column = m.Location.File, m.Location.Line, m.Location.Column
+		}
+		out = append(out, sm)
+	}
+	return out
+}
+
+// BuildStatus tracks a dev server's builds, or in ESM mode its updates, and
+// the errors and warnings standing against them, for StatusURLPath. The
+// zero value is ready to use, with nothing built yet.
+type BuildStatus struct {
+	mu       sync.Mutex
+	recorded bool
+	stamp    BuildStamp
+	duration time.Duration
+	messages map[string]statusMessages // by source
+}
+
+type statusMessages struct {
+	errors, warnings []StatusMessage
+}
+
+// Record records a finished build or update, and how long it took; 0 if
+// it isn't timed.
+func (b *BuildStatus) Record(stamp BuildStamp, duration time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recorded, b.stamp, b.duration = true, stamp, duration
+}
+
+// SetMessages replaces the errors and warnings from one source: the whole
+// build, or a module whose transform failed. Setting none clears them.
+func (b *BuildStatus) SetMessages(source string, errors, warnings []StatusMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(errors) == 0 && len(warnings) == 0 {
+		delete(b.messages, source)
+		return
+	}
+	if b.messages == nil {
+		b.messages = make(map[string]statusMessages)
+	}
+	b.messages[source] = statusMessages{errors, warnings}
+}
+
+// statusReport is the JSON StatusURLPath serves.
+type statusReport struct {
+	Server     string          `json:"server"`               // dev or esm-dev
+	Status     string          `json:"status"`               // starting, ok or error
+	Build      int64           `json:"build,omitempty"`      // ID of the last build or update, as in the server log
+	Time       *time.Time      `json:"time,omitempty"`       // when it finished
+	DurationMs int64           `json:"durationMs,omitempty"` // how long it took
+	Errors     []StatusMessage `json:"errors,omitempty"`
+	Warnings   []StatusMessage `json:"warnings,omitempty"`
+	Clients    int             `json:"clients"` // connected live reload clients
+	Deps       int             `json:"deps"`    // dependencies loaded
+}
+
+// Serve writes the status as JSON, for server, with its number of
+// connected live reload clients and loaded dependencies.
+func (b *BuildStatus) Serve(w http.ResponseWriter, server string, clients, deps int) {
+	b.mu.Lock()
+	report := statusReport{Server: server, Status: "starting", Clients: clients, Deps: deps}
+	if b.recorded {
+		report.Status = "ok"
+		report.Build = b.stamp.ID
+		finished := b.stamp.Time
+		report.Time = &finished
+		report.DurationMs = b.duration.Milliseconds()
+	}
+	sources := make([]string, 0, len(b.messages))
+	for source := range b.messages {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		report.Errors = append(report.Errors, b.messages[source].errors...)
+		report.Warnings = append(report.Warnings, b.messages[source].warnings...)
+	}
+	b.mu.Unlock()
+	if len(report.Errors) > 0 {
+		report.Status = "error"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(report)
+}
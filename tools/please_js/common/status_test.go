@@ -0,0 +1,49 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestBuildStatus(t *testing.T) {
+	var b BuildStatus
+	serve := func() statusReport {
+		t.Helper()
+		w := httptest.NewRecorder()
+		b.Serve(w, "dev", 2, 40)
+		var report statusReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	if report := serve(); report.Status != "starting" || report.Build != 0 || report.Clients != 2 || report.Deps != 40 {
+		t.Errorf("expected a starting status before the first build, got %+v", report)
+	}
+
+	b.Record(BuildStamp{ID: 3, Time: time.Now()}, 120*time.Millisecond)
+	b.SetMessages("", StatusMessages([]api.Message{
+		{Text: "Could not resolve \"./missing\"", Location: &api.Location{File: "src/app.tsx", Line: 4, Column: 7}},
+	}), nil)
+	b.SetMessages("/abs/src/util.ts", nil, []StatusMessage{{Text: "unused import"}})
+	report := serve()
+	if report.Status != "error" || report.Build != 3 || report.DurationMs != 120 || report.Time == nil {
+		t.Errorf("expected the failed build's status, got %+v", report)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].File != "src/app.tsx" || report.Errors[0].Line != 4 {
+		t.Errorf("expected the build's error with its location, got %+v", report.Errors)
+	}
+	if len(report.Warnings) != 1 {
+		t.Errorf("expected the module's warning, got %+v", report.Warnings)
+	}
+
+	b.SetMessages("", nil, nil)
+	if report := serve(); report.Status != "ok" || len(report.Errors) != 0 {
+		t.Errorf("expected ok once the errors are cleared, got %+v", report)
+	}
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tailwindV4ImportRe matches Tailwind v4's entry import, capturing whatever
+// follows the specifier (layer(), source(), ...) up to the semicolon.
+var tailwindV4ImportRe = regexp.MustCompile(`@import\s+(?:url\(\s*)?["']tailwindcss["']\s*\)?([^;]*);`)
+
+// IsTailwindCSS reports whether a stylesheet has to go through the Tailwind
+// CLI: either v3 @tailwind directives or a v4 @import "tailwindcss".
+func IsTailwindCSS(css []byte) bool {
+	return bytes.Contains(css, []byte("@tailwind")) || IsTailwindV4(css)
+}
+
+// IsTailwindV4 reports whether a stylesheet uses Tailwind v4's CSS-first
+// configuration, i.e. imports "tailwindcss" instead of using @tailwind.
+func IsTailwindV4(css []byte) bool {
+	return tailwindV4ImportRe.Match(css)
+}
+
+// TailwindCommand returns the Tailwind CLI invocation compiling cssPath to
+// stdout.
+//
+// v3 stylesheets are compiled in the config's directory so its relative
+// content globs resolve. v4 has no content globs: it scans the working
+// directory for class names, which in a monorepo is the whole repository.
+// Instead, the stylesheet is passed on stdin with scanning limited to
+// sourceDirs — the package and its local libraries, i.e. the directories the
+// module graph is drawn from — unless it already chooses its own source().
+// A v3 config, if given, is loaded through v4's @config compatibility.
+func TailwindCommand(tailwindBin, tailwindConfig, cssPath string, css []byte, sourceDirs []string) *exec.Cmd {
+	if !IsTailwindV4(css) {
+		cmdArgs := []string{"--input", cssPath}
+		if tailwindConfig != "" {
+			// Pass just the filename — cmd.Dir is set to the config's
+			// directory so relative content globs resolve correctly.
+			cmdArgs = append(cmdArgs, "--config", filepath.Base(tailwindConfig))
+		}
+		cmd := exec.Command(tailwindBin, cmdArgs...)
+		if tailwindConfig != "" {
+			cmd.Dir = filepath.Dir(tailwindConfig)
+		}
+		return cmd
+	}
+
+	// Reading from stdin, v4 resolves relative @import/@source/@config paths
+	// against the working directory, so run beside the stylesheet.
+	cmd := exec.Command(tailwindBin, "--input", "-")
+	cmd.Dir = filepath.Dir(cssPath)
+	cmd.Stdin = bytes.NewReader(tailwindV4Input(css, tailwindConfig, sourceDirs))
+	return cmd
+}
+
+// tailwindV4Input rewrites a v4 stylesheet so automatic content detection
+// only covers sourceDirs, and loads tailwindConfig via @config when the
+// stylesheet doesn't reference a config itself.
+func tailwindV4Input(css []byte, tailwindConfig string, sourceDirs []string) []byte {
+	var extra strings.Builder
+	if tailwindConfig != "" && !bytes.Contains(css, []byte("@config")) {
+		abs, _ := filepath.Abs(tailwindConfig)
+		fmt.Fprintf(&extra, "@config %q;\n", filepath.ToSlash(abs))
+	}
+
+	out := css
+	if len(sourceDirs) > 0 {
+		rewritten := false
+		out = tailwindV4ImportRe.ReplaceAllFunc(css, func(match []byte) []byte {
+			m := tailwindV4ImportRe.FindSubmatch(match)
+			if bytes.Contains(m[1], []byte("source(")) {
+				return match
+			}
+			rewritten = true
+			// Copy: match aliases css, which appending in place would overwrite.
+			stmt := append([]byte(nil), bytes.TrimSuffix(match, []byte(";"))...)
+			return append(stmt, " source(none);"...)
+		})
+		if rewritten {
+			dirs := append([]string(nil), sourceDirs...)
+			sort.Strings(dirs)
+			for _, dir := range dirs {
+				abs, _ := filepath.Abs(dir)
+				fmt.Fprintf(&extra, "@source %q;\n", filepath.ToSlash(abs))
+			}
+		}
+	}
+	if extra.Len() == 0 {
+		return out
+	}
+	// @config and @source may appear anywhere at the top level, but @import
+	// must come first, so append rather than prepend.
+	result := append([]byte(nil), out...)
+	if len(result) > 0 && result[len(result)-1] != '\n' {
+		result = append(result, '\n')
+	}
+	return append(result, extra.String()...)
+}
+
+// LocalModuleDirs returns the moduleconfig directories holding first-party
+// code (js_library outputs), sorted. npm packages are recognised by their
+// package.json and left out.
+func LocalModuleDirs(moduleMap map[string]string) []string {
+	var dirs []string
+	for _, dir := range moduleMap {
+		if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
+			continue
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -0,0 +1,164 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Tailwind config parsing regexes. The config is JS, so rather than evaluate
+// it we pull string literals out of its content array — which is how nearly
+// every config declares content, either directly or via content.files.
+var (
+	tailwindContentRe = regexp.MustCompile(`(?s)content\s*:\s*(?:\{[^}]*?files\s*:\s*)?\[(.*?)\]`)
+	jsStringRe        = regexp.MustCompile(`"([^"]*)"|'([^']*)'|` + "`([^`]*)`")
+)
+
+// TailwindContentGlobs extracts the content globs from a Tailwind config
+// file as absolute, slash-separated globs. Relative globs are resolved
+// against the config's directory, where the Tailwind CLI runs. Negated globs
+// are ignored. Returns nil if the config can't be read or declares no content.
+func TailwindContentGlobs(configPath string) []string {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil
+	}
+	m := tailwindContentRe.FindSubmatch(data)
+	if m == nil {
+		return nil
+	}
+	configDir, _ := filepath.Abs(filepath.Dir(configPath))
+
+	var globs []string
+	for _, sm := range jsStringRe.FindAllSubmatch(m[1], -1) {
+		glob := string(sm[1]) + string(sm[2]) + string(sm[3])
+		if glob == "" || strings.HasPrefix(glob, "!") {
+			continue
+		}
+		if !filepath.IsAbs(glob) {
+			glob = filepath.Join(configDir, glob)
+		}
+		globs = append(globs, filepath.ToSlash(glob))
+	}
+	return globs
+}
+
+// ParseTailwindContent compiles a Tailwind config's content globs to regexes
+// matching absolute, slash-separated paths.
+func ParseTailwindContent(configPath string) []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, glob := range TailwindContentGlobs(configPath) {
+		patterns = append(patterns, CompileGlob(glob)...)
+	}
+	return patterns
+}
+
+// CompileGlob compiles a glob to anchored regexes matching slash-separated
+// paths, one per {a,b} brace expansion. Supports "**", "*" and "?".
+func CompileGlob(glob string) []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, expanded := range expandBraces(glob) {
+		if re, err := regexp.Compile(globToRegexp(expanded)); err == nil {
+			patterns = append(patterns, re)
+		}
+	}
+	return patterns
+}
+
+// GlobBase returns the directory part of a glob before its first wildcard,
+// i.e. the deepest directory that can contain every match.
+// "/app/src/**/*.tsx" → "/app/src".
+func GlobBase(glob string) string {
+	idx := strings.IndexAny(glob, "*?{[")
+	if idx < 0 {
+		return filepath.Dir(glob)
+	}
+	slash := strings.LastIndex(glob[:idx], "/")
+	if slash <= 0 {
+		return "/"
+	}
+	return glob[:slash]
+}
+
+// expandBraces expands the first {a,b} group in a glob, recursively.
+// "src/**/*.{ts,tsx}" → ["src/**/*.ts", "src/**/*.tsx"].
+func expandBraces(glob string) []string {
+	open := strings.Index(glob, "{")
+	if open < 0 {
+		return []string{glob}
+	}
+	end := strings.Index(glob[open:], "}")
+	if end < 0 {
+		return []string{glob}
+	}
+	end += open
+	var result []string
+	for _, alt := range strings.Split(glob[open+1:end], ",") {
+		result = append(result, expandBraces(glob[:open]+alt+glob[end+1:])...)
+	}
+	return result
+}
+
+// globToRegexp converts a brace-free glob to an anchored regex. "**/" matches
+// zero or more directories, "*" anything within a path segment, "?" a single
+// character.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// TailwindContentFiles returns the files under the Tailwind config's
+// directory matched by its content globs, sorted. Hidden directories,
+// node_modules and plz-out are skipped. Returns nil if the config declares
+// no content globs.
+func TailwindContentFiles(configPath string) []string {
+	patterns := ParseTailwindContent(configPath)
+	if len(patterns) == 0 {
+		return nil
+	}
+	root, _ := filepath.Abs(filepath.Dir(configPath))
+
+	var files []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != root && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "plz-out") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		slashed := filepath.ToSlash(path)
+		for _, re := range patterns {
+			if re.MatchString(slashed) {
+				files = append(files, path)
+				break
+			}
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	got := expandBraces("src/**/*.{ts,tsx}/{a,b}")
+	want := []string{"src/**/*.ts/a", "src/**/*.ts/b", "src/**/*.tsx/a", "src/**/*.tsx/b"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expandBraces() = %v, want %v", got, want)
+	}
+}
+
+func TestParseTailwindContent_FilesObject(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "tailwind.config.js")
+	os.WriteFile(config, []byte(`export default {
+  content: { relative: true, files: ["./app/**/*.tsx"] },
+};
+`), 0644)
+
+	globs := ParseTailwindContent(config)
+	if len(globs) != 1 || !globs[0].MatchString(filepath.ToSlash(filepath.Join(dir, "app/page.tsx"))) {
+		t.Errorf("expected content.files glob to match app/page.tsx, got %v", globs)
+	}
+}
+
+func TestTailwindContentFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"index.html", "src/App.tsx", "src/util.ts", "node_modules/pkg/x.tsx"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644)
+	}
+	config := filepath.Join(dir, "tailwind.config.js")
+	os.WriteFile(config, []byte(`module.exports = { content: ["./index.html", "./**/*.tsx"] };`), 0644)
+
+	var got []string
+	for _, f := range TailwindContentFiles(config) {
+		rel, _ := filepath.Rel(dir, f)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{"index.html", "src/App.tsx"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("TailwindContentFiles() = %v, want %v", got, want)
+	}
+
+	if files := TailwindContentFiles(filepath.Join(dir, "missing.js")); files != nil {
+		t.Errorf("expected nil for missing config, got %v", files)
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	tests := map[string]string{
+		"/app/src/**/*.tsx":   "/app/src",
+		"/app/index.html":     "/app",
+		"/app/{src,lib}/*.ts": "/app",
+		"/app/src/page?.tsx":  "/app/src",
+		"/*.js":               "/",
+	}
+	for glob, want := range tests {
+		if got := GlobBase(glob); got != want {
+			t.Errorf("GlobBase(%q) = %q, want %q", glob, got, want)
+		}
+	}
+}
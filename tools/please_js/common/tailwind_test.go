@@ -0,0 +1,109 @@
+package common
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsTailwindCSS(t *testing.T) {
+	tests := []struct {
+		css    string
+		any    bool
+		isV4   bool
+		reason string
+	}{
+		{`@tailwind base; @tailwind utilities;`, true, false, "v3 directives"},
+		{`@import "tailwindcss";`, true, true, "v4 import"},
+		{`@import 'tailwindcss' layer(base);`, true, true, "v4 import with layer"},
+		{`@import url("tailwindcss");`, true, true, "v4 url() import"},
+		{`@import "tailwindcss/preflight";`, false, false, "partial import isn't the v4 entry"},
+		{`@import "./theme.css"; body { margin: 0 }`, false, false, "plain CSS"},
+	}
+	for _, tt := range tests {
+		if got := IsTailwindCSS([]byte(tt.css)); got != tt.any {
+			t.Errorf("IsTailwindCSS(%s) = %v, want %v (%s)", tt.css, got, tt.any, tt.reason)
+		}
+		if got := IsTailwindV4([]byte(tt.css)); got != tt.isV4 {
+			t.Errorf("IsTailwindV4(%s) = %v, want %v (%s)", tt.css, got, tt.isV4, tt.reason)
+		}
+	}
+}
+
+func TestTailwindV4Input(t *testing.T) {
+	css := "@import \"tailwindcss\";\n@theme { --color-brand: #f00; }\n"
+	got := string(tailwindV4Input([]byte(css), "", []string{"/repo/app", "/repo/libs/ui"}))
+	want := "@import \"tailwindcss\" source(none);\n@theme { --color-brand: #f00; }\n" +
+		"@source \"/repo/app\";\n@source \"/repo/libs/ui\";\n"
+	if got != want {
+		t.Errorf("tailwindV4Input() =\n%s\nwant:\n%s", got, want)
+	}
+
+	// url() and layer() forms get source(none) after whatever they carry.
+	for css, want := range map[string]string{
+		`@import url("tailwindcss");`:                 `@import url("tailwindcss") source(none);`,
+		`@import url('tailwindcss') layer(base);`:     `@import url('tailwindcss') layer(base) source(none);`,
+		`@import "tailwindcss" layer(framework);`:     `@import "tailwindcss" layer(framework) source(none);`,
+		`@import "tailwindcss" prefix(tw) important;`: `@import "tailwindcss" prefix(tw) important source(none);`,
+	} {
+		got := string(tailwindV4Input([]byte(css), "", []string{"/repo/app"}))
+		if got != want+"\n@source \"/repo/app\";\n" {
+			t.Errorf("tailwindV4Input(%s) =\n%s\nwant %s followed by @source", css, got, want)
+		}
+	}
+
+	// An explicit source() is the user's choice; leave detection alone.
+	for _, own := range []string{
+		`@import "tailwindcss" source("../src");`,
+		`@import "tailwindcss" layer(base) source(none);`,
+		`@import url("tailwindcss") source("../../libs");`,
+	} {
+		if got := string(tailwindV4Input([]byte(own), "", []string{"/repo/app"})); got != own {
+			t.Errorf("expected stylesheet with its own source() unchanged, got:\n%s", got)
+		}
+	}
+
+	// A v3 config is loaded through @config unless one is already referenced.
+	got = string(tailwindV4Input([]byte(`@import "tailwindcss";`), "/repo/app/tailwind.config.js", nil))
+	if got != "@import \"tailwindcss\";\n@config \"/repo/app/tailwind.config.js\";\n" {
+		t.Errorf("expected @config appended, got:\n%s", got)
+	}
+	withConfig := `@import "tailwindcss"; @config "./tw.js";`
+	if got := string(tailwindV4Input([]byte(withConfig), "/repo/app/tailwind.config.js", nil)); got != withConfig {
+		t.Errorf("expected existing @config kept, got:\n%s", got)
+	}
+}
+
+func TestTailwindCommand(t *testing.T) {
+	v3 := TailwindCommand("tailwindcss", "/repo/app/tailwind.config.js", "/repo/app/src/main.css", []byte("@tailwind base;"), []string{"/repo/app"})
+	if got := strings.Join(v3.Args[1:], " "); got != "--input /repo/app/src/main.css --config tailwind.config.js" {
+		t.Errorf("v3 args = %s", got)
+	}
+	if v3.Dir != "/repo/app" || v3.Stdin != nil {
+		t.Errorf("expected v3 to run in the config dir without stdin, got dir %q", v3.Dir)
+	}
+
+	v4 := TailwindCommand("tailwindcss", "", "/repo/app/src/main.css", []byte(`@import "tailwindcss";`), []string{"/repo/app"})
+	if got := strings.Join(v4.Args[1:], " "); got != "--input -" {
+		t.Errorf("v4 args = %s", got)
+	}
+	if v4.Dir != filepath.FromSlash("/repo/app/src") {
+		t.Errorf("expected v4 to run beside the stylesheet, got dir %q", v4.Dir)
+	}
+	stdin, _ := io.ReadAll(v4.Stdin)
+	if !strings.Contains(string(stdin), `@source "/repo/app";`) {
+		t.Errorf("expected v4 stdin to carry @source directives, got:\n%s", stdin)
+	}
+
+	// --tailwind-config with a v4 stylesheet goes through @config, not --config.
+	v4Config := TailwindCommand("tailwindcss", "/repo/app/tailwind.config.js", "/repo/app/src/main.css", []byte(`@import "tailwindcss";`), []string{"/repo/app"})
+	if got := strings.Join(v4Config.Args[1:], " "); got != "--input -" {
+		t.Errorf("v4 args with config = %s", got)
+	}
+	stdin, _ = io.ReadAll(v4Config.Stdin)
+	want := "@import \"tailwindcss\" source(none);\n@config \"/repo/app/tailwind.config.js\";\n@source \"/repo/app\";\n"
+	if string(stdin) != want {
+		t.Errorf("v4 stdin with config =\n%s\nwant:\n%s", stdin, want)
+	}
+}
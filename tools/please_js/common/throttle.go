@@ -0,0 +1,141 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Throttle slows a dev server's responses down, as a slow network would, to
+// exercise loading states and race conditions.
+type Throttle struct {
+	latency     time.Duration // added before each response
+	bytesPerSec int64         // 0 for unlimited
+}
+
+// NewThrottle returns the throttle for --throttle and --bandwidth, or nil
+// if neither slows anything down. bandwidth is in bits per second, e.g.
+// "1mbps", "512kbps" or "1.5mbps".
+func NewThrottle(latency time.Duration, bandwidth string) (*Throttle, error) {
+	var bytesPerSec int64
+	if bandwidth != "" {
+		bits, err := parseBandwidth(bandwidth)
+		if err != nil {
+			return nil, err
+		}
+		bytesPerSec = bits / 8
+		if bytesPerSec < 1 {
+			bytesPerSec = 1
+		}
+	}
+	if latency <= 0 && bytesPerSec == 0 {
+		return nil, nil
+	}
+	return &Throttle{latency: latency, bytesPerSec: bytesPerSec}, nil
+}
+
+// parseBandwidth parses a rate such as "1mbps" into bits per second.
+func parseBandwidth(s string) (int64, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	scale := 1.0
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{{"gbps", 1e9}, {"mbps", 1e6}, {"kbps", 1e3}, {"bps", 1}} {
+		if strings.HasSuffix(v, unit.suffix) {
+			v, scale = strings.TrimSuffix(v, unit.suffix), unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --bandwidth %q: expected a rate such as 1mbps or 512kbps", s)
+	}
+	return int64(n * scale), nil
+}
+
+// Wrap waits out the throttle's latency, then returns a ResponseWriter that
+// writes no faster than its bandwidth. It reports false if the request was
+// cancelled while waiting. A nil Throttle returns w as it is.
+func (t *Throttle) Wrap(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, bool) {
+	if t == nil {
+		return w, true
+	}
+	if t.latency > 0 && !sleepCtx(r.Context(), t.latency) {
+		return w, false
+	}
+	if t.bytesPerSec == 0 {
+		return w, true
+	}
+	return &throttledWriter{ResponseWriter: w, ctx: r.Context(), bytesPerSec: t.bytesPerSec}, true
+}
+
+// throttledWriter paces a response's body to a rate.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	bytesPerSec int64
+	start       time.Time
+	written     int64
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	if tw.start.IsZero() {
+		tw.start = time.Now()
+	}
+	// Write in tenths of a second's worth, so the body trickles in rather
+	// than arriving in one burst after a pause.
+	chunk := int(tw.bytesPerSec / 10)
+	if chunk < 512 {
+		chunk = 512
+	}
+	total := 0
+	for len(p) > 0 {
+		n := chunk
+		if n > len(p) {
+			n = len(p)
+		}
+		wrote, err := tw.ResponseWriter.Write(p[:n])
+		total += wrote
+		tw.written += int64(wrote)
+		if err != nil {
+			return total, err
+		}
+		tw.Flush()
+		p = p[n:]
+		due := tw.start.Add(time.Duration(tw.written * int64(time.Second) / tw.bytesPerSec))
+		if !sleepCtx(tw.ctx, time.Until(due)) {
+			return total, tw.ctx.Err()
+		}
+	}
+	return total, nil
+}
+
+func (tw *throttledWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController the underlying writer.
+func (tw *throttledWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// sleepCtx sleeps for d, reporting false if ctx is done first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
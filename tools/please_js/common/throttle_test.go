@@ -0,0 +1,66 @@
+package common
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewThrottle(t *testing.T) {
+	if th, err := NewThrottle(0, ""); err != nil || th != nil {
+		t.Errorf("expected no throttle without --throttle or --bandwidth, got %v, %v", th, err)
+	}
+	for _, tc := range []struct {
+		bandwidth string
+		want      int64
+	}{
+		{"1mbps", 125000},
+		{"512kbps", 64000},
+		{"1.5Mbps", 187500},
+		{"8000", 1000},
+	} {
+		th, err := NewThrottle(0, tc.bandwidth)
+		if err != nil {
+			t.Errorf("NewThrottle(%q): %v", tc.bandwidth, err)
+			continue
+		}
+		if th.bytesPerSec != tc.want {
+			t.Errorf("NewThrottle(%q) = %d bytes/s, want %d", tc.bandwidth, th.bytesPerSec, tc.want)
+		}
+	}
+	for _, bad := range []string{"fast", "0mbps", "-1kbps"} {
+		if _, err := NewThrottle(0, bad); err == nil {
+			t.Errorf("expected NewThrottle(%q) to fail", bad)
+		}
+	}
+}
+
+func TestThrottleWrap(t *testing.T) {
+	th, err := NewThrottle(30*time.Millisecond, "80kbps") // 10KB/s
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	w, ok := th.Wrap(rec, httptest.NewRequest("GET", "/main.js", nil))
+	if !ok {
+		t.Fatal("expected the request to go ahead")
+	}
+	body := bytes.Repeat([]byte("x"), 2000)
+	if n, err := w.Write(body); err != nil || n != len(body) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	// 30ms of latency, then 2KB at 10KB/s.
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected the response to take at least 200ms, took %v", elapsed)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), body) {
+		t.Error("expected the whole body to be written")
+	}
+
+	var nilThrottle *Throttle
+	if got, ok := nilThrottle.Wrap(rec, httptest.NewRequest("GET", "/", nil)); got != rec || !ok {
+		t.Error("expected a nil throttle to return the writer as it is")
+	}
+}
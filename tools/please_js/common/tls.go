@@ -0,0 +1,129 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// devCertValidity is how long a generated dev certificate is valid for. It's
+// regenerated a day before it expires.
+const devCertValidity = 365 * 24 * time.Hour
+
+// devNextProtos prefers HTTP/2 over TLS, so the many small modules and
+// sourcemaps a dev server serves load in parallel over one connection,
+// instead of queueing behind the browser's six HTTP/1.1 connections.
+var devNextProtos = []string{"h2", "http/1.1"}
+
+// DevTLSConfig returns the TLS config for a dev server. With certFile and
+// keyFile it serves that certificate; otherwise it serves a self-signed one
+// for localhost and hosts, generated on first use and cached under the user
+// cache directory, so the browser only has to be told to trust it once.
+func DevTLSConfig(certFile, keyFile string, hosts []string) (*tls.Config, string, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, "", fmt.Errorf("--cert and --key must be given together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: devNextProtos}, certFile, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to find a directory for the dev certificate: %w", err)
+	}
+	dir := filepath.Join(cacheDir, "please_js", "tls")
+	cert, err := loadOrCreateDevCert(dir, hosts)
+	if err != nil {
+		return nil, "", err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: devNextProtos}, filepath.Join(dir, "cert.pem"), nil
+}
+
+// loadOrCreateDevCert returns the self-signed certificate cached in dir,
+// generating a new one if there isn't one, it's about to expire or it
+// doesn't cover every one of hosts.
+func loadOrCreateDevCert(dir string, hosts []string) (tls.Certificate, error) {
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	hosts = append([]string{"localhost", "127.0.0.1", "::1"}, hosts...)
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil && coversHosts(cert, hosts) {
+		return cert, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate dev certificate: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate dev certificate: %w", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"please_js dev server"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(devCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate dev certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate dev certificate: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	// Caching is best-effort: without it the certificate only lasts until
+	// the server exits.
+	if err := os.MkdirAll(dir, 0700); err == nil {
+		if err := os.WriteFile(keyFile, keyPEM, 0600); err == nil {
+			os.WriteFile(certFile, certPEM, 0644)
+		}
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// coversHosts reports whether a certificate is valid for every one of
+// hosts for at least another day.
+func coversHosts(cert tls.Certificate, hosts []string) bool {
+	if len(cert.Certificate) == 0 {
+		return false
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil || time.Now().Add(24*time.Hour).After(leaf.NotAfter) {
+		return false
+	}
+	for _, h := range hosts {
+		if leaf.VerifyHostname(h) != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"bytes"
+	"crypto/x509"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOrCreateDevCert(t *testing.T) {
+	dir := t.TempDir()
+	cert, err := loadOrCreateDevCert(dir, []string{"192.168.1.20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "127.0.0.1", "::1", "192.168.1.20"} {
+		if err := leaf.VerifyHostname(host); err != nil {
+			t.Errorf("expected the certificate to cover %s: %v", host, err)
+		}
+	}
+
+	cached, err := loadOrCreateDevCert(dir, []string{"192.168.1.20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cached.Certificate[0], cert.Certificate[0]) {
+		t.Error("expected the cached certificate to be reused")
+	}
+
+	other, err := loadOrCreateDevCert(dir, []string{"10.0.0.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(other.Certificate[0], cert.Certificate[0]) {
+		t.Error("expected a new certificate for a host the cached one doesn't cover")
+	}
+}
+
+func TestDevTLSConfigNeedsCertAndKey(t *testing.T) {
+	if _, _, err := DevTLSConfig(filepath.Join(t.TempDir(), "cert.pem"), "", nil); err == nil {
+		t.Error("expected --cert without --key to fail")
+	}
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// WatchIgnore is the files --watch-ignore keeps a dev server from reacting
+// to, such as tests, stories and docs that never affect the app.
+type WatchIgnore struct {
+	patterns []*regexp.Regexp // matched against absolute, slash-separated paths
+}
+
+// NewWatchIgnore returns the files --watch-ignore values ignore, each a
+// comma-separated list of globs, e.g. "**/*.stories.tsx,**/__tests__/**".
+// Relative globs are resolved against root. Without any it returns nil,
+// which ignores nothing.
+func NewWatchIgnore(specs []string, root string) *WatchIgnore {
+	var w WatchIgnore
+	for _, spec := range specs {
+		for _, glob := range splitGlobs(spec) {
+			if !filepath.IsAbs(glob) {
+				glob = filepath.Join(root, glob)
+			}
+			w.patterns = append(w.patterns, CompileGlob(filepath.ToSlash(glob))...)
+		}
+	}
+	if len(w.patterns) == 0 {
+		return nil
+	}
+	return &w
+}
+
+// Match reports whether changes to the file at path are ignored. A nil
+// WatchIgnore ignores nothing.
+func (w *WatchIgnore) Match(path string) bool {
+	if w == nil {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	slashed := filepath.ToSlash(abs)
+	for _, re := range w.patterns {
+		if re.MatchString(slashed) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitGlobs splits a comma-separated list of globs, leaving the commas of
+// {a,b} groups alone.
+func splitGlobs(spec string) []string {
+	var globs []string
+	depth, start := 0, 0
+	for i, c := range spec {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				globs = append(globs, spec[start:i])
+				start = i + 1
+			}
+		}
+	}
+	globs = append(globs, spec[start:])
+	var out []string
+	for _, glob := range globs {
+		if glob = strings.TrimSpace(glob); glob != "" {
+			out = append(out, glob)
+		}
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWatchIgnore(t *testing.T) {
+	root := t.TempDir()
+	w := NewWatchIgnore([]string{"**/*.stories.tsx,**/__tests__/**", "docs/**"}, root)
+	for path, want := range map[string]bool{
+		"src/Button.stories.tsx":       true,
+		"Button.stories.tsx":           true,
+		"src/__tests__/Button.test.ts": true,
+		"docs/intro.md":                true,
+		"src/Button.tsx":               false,
+		"src/docs/intro.md":            false,
+		"src/Button.stories.ts":        false,
+	} {
+		if got := w.Match(filepath.Join(root, path)); got != want {
+			t.Errorf("Match(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestWatchIgnoreBraces(t *testing.T) {
+	root := t.TempDir()
+	w := NewWatchIgnore([]string{"**/*.{test,spec}.ts, **/*.md"}, root)
+	for path, want := range map[string]bool{
+		"a.test.ts": true,
+		"a.spec.ts": true,
+		"README.md": true,
+		"a.ts":      false,
+	} {
+		if got := w.Match(filepath.Join(root, path)); got != want {
+			t.Errorf("Match(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestWatchIgnoreNone(t *testing.T) {
+	w := NewWatchIgnore([]string{"", " , "}, t.TempDir())
+	if w != nil {
+		t.Fatalf("expected nil without globs, got %+v", w)
+	}
+	if w.Match("src/Button.tsx") {
+		t.Error("expected a nil WatchIgnore to ignore nothing")
+	}
+}
+
+func TestSplitGlobs(t *testing.T) {
+	got := splitGlobs("**/*.{stories,test}.tsx, **/__tests__/** ,")
+	want := []string{"**/*.{stories,test}.tsx", "**/__tests__/**"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitGlobs = %q, want %q", got, want)
+	}
+}
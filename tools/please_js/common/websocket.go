@@ -0,0 +1,129 @@
+package common
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+)
+
+// IsWebSocketUpgrade reports whether a request asks to upgrade its
+// connection to a WebSocket.
+func IsWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServeWebSocket tunnels a WebSocket upgrade to the target of a proxy rule,
+// then copies the connection's bytes both ways until either side closes.
+// The request is routed by the proxy's Director, as the rule's other
+// requests are, but sent over its own HTTP/1.1 connection: the shared
+// transport may speak HTTP/2 to the target, which can't upgrade. A target
+// that can't be reached is reported through the proxy's ErrorHandler. With
+// verifyTLS, a wss target's certificate is verified.
+func ServeWebSocket(w http.ResponseWriter, r *http.Request, proxy *httputil.ReverseProxy, dialTimeout time.Duration, verifyTLS bool) {
+	out := r.Clone(r.Context())
+	proxy.Director(out)
+	out.RequestURI = ""
+
+	fail := proxy.ErrorHandler
+	if fail == nil {
+		fail = func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	}
+	backend, err := dialBackend(out, dialTimeout, verifyTLS)
+	if err != nil {
+		fail(w, r, err)
+		return
+	}
+	defer backend.Close()
+	if err := out.Write(backend); err != nil {
+		fail(w, r, err)
+		return
+	}
+	br := bufio.NewReader(backend)
+	resp, err := http.ReadResponse(br, out)
+	if err != nil {
+		fail(w, r, err)
+		return
+	}
+	if proxy.ModifyResponse != nil {
+		proxy.ModifyResponse(resp)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		// The target refused the upgrade; pass its answer on.
+		defer resp.Body.Close()
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket proxying isn't supported on this connection", http.StatusInternalServerError)
+		return
+	}
+	client, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+	fmt.Fprintf(client, "HTTP/1.1 %s\r\n", resp.Status)
+	resp.Header.Write(client)
+	io.WriteString(client, "\r\n")
+
+	// Either side closing ends the tunnel; closing both unblocks the other
+	// copy. Bytes already read into the buffers go first.
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(backend, clientBuf.Reader)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, br)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// dialBackend connects to the host a proxied request is addressed to, over
+// TLS for https and wss targets. As for other proxied requests, the
+// target's certificate is only verified with verifyTLS.
+func dialBackend(req *http.Request, timeout time.Duration, verifyTLS bool) (net.Conn, error) {
+	secure := req.URL.Scheme == "https" || req.URL.Scheme == "wss"
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+		addr = net.JoinHostPort(req.URL.Hostname(), port)
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	if secure {
+		return tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+			InsecureSkipVerify: !verifyTLS,
+			ServerName:         req.URL.Hostname(),
+			NextProtos:         []string{"http/1.1"},
+		})
+	}
+	return dialer.DialContext(req.Context(), "tcp", addr)
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"regexp"
+	"strings"
+)
+
+// workerRefRe matches workers and shared workers created from a module
+// relative URL, with their options if given:
+//
+//	new Worker(new URL("./worker.ts", import.meta.url), { type: "module" })
+//	new SharedWorker(new URL("./sync.js", import.meta.url))
+//
+// Group 1 is the URL expression, group 2 the path and group 3 the options.
+// A plain string path is resolved against the document, not the module, so
+// it's left for the server to handle as any other URL.
+var workerRefRe = regexp.MustCompile(`\bnew\s+(?:Shared)?Worker\s*\(\s*(new\s+URL\s*\(\s*["'](\.{1,2}/[^"']+)["']\s*,\s*import\.meta\.url\s*\))\s*(,\s*\{[^{}]*\})?`)
+
+// moduleTypeRe matches the type: "module" worker option.
+var moduleTypeRe = regexp.MustCompile(`\btype\s*:\s*["']module["']`)
+
+// HasWorkerRefs reports whether code may create a worker from a module
+// relative URL, as a cheap check before RewriteWorkerRefs.
+func HasWorkerRefs(code string) bool {
+	return strings.Contains(code, "Worker") && workerRefRe.MatchString(code)
+}
+
+// RewriteWorkerRefs replaces the URL of each Worker and SharedWorker created
+// from a module relative path with the expression returned by rewrite, or
+// leaves it alone if rewrite returns "". module reports whether the worker
+// is created with { type: "module" }: module workers can use import
+// statements, while classic workers must be a single script. Either way a
+// worker runs in its own scope without the page's chunks or import map, so
+// it's built with its own dependency graph.
+func RewriteWorkerRefs(code string, rewrite func(path string, module bool) string) string {
+	return workerRefRe.ReplaceAllStringFunc(code, func(match string) string {
+		m := workerRefRe.FindStringSubmatch(match)
+		replacement := rewrite(m[2], moduleTypeRe.MatchString(m[3]))
+		if replacement == "" {
+			return match
+		}
+		return strings.Replace(match, m[1], replacement, 1)
+	})
+}
@@ -0,0 +1,264 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// workletDir and workerDir are where worklet and worker modules are written
+// inside the output directory.
+const (
+	workletDir = "worklets"
+	workerDir  = "workers"
+)
+
+// WorkerBundler builds the modules a bundle runs in their own scope (worklets
+// loaded with addModule(), workers and shared workers) as standalone outputs,
+// since those scopes can't share the bundle's chunks. Each is built once per
+// format, however many modules reference it.
+type WorkerBundler struct {
+	opts    api.BuildOptions // template for worker builds
+	absOut  string
+	write   bool // write outputs to disk rather than keep them in files
+	mu      sync.Mutex
+	built   map[workerKey]*workerBuild
+	outputs []string         // written paths
+	files   []api.OutputFile // outputs kept in memory
+}
+
+type workerKey struct {
+	src    string // absolute source path
+	format api.Format
+}
+
+type workerBuild struct {
+	once   sync.Once
+	file   string   // path relative to the output directory
+	inputs []string // source files, for watching
+	err    error
+}
+
+// NewWorkerBundler returns a bundler writing into outDir with the main
+// build's plugins and options. With an empty outDir (single-file bundles,
+// which have nowhere to put extra modules) references are only reported.
+func NewWorkerBundler(outDir string, main api.BuildOptions) *WorkerBundler {
+	if outDir == "" {
+		return &WorkerBundler{}
+	}
+	absOut, _ := filepath.Abs(outDir)
+	return &WorkerBundler{
+		absOut: absOut,
+		write:  true,
+		opts: api.BuildOptions{
+			Bundle:            true,
+			Write:             false,
+			Platform:          api.PlatformBrowser,
+			Target:            main.Target,
+			External:          main.External,
+			Loader:            main.Loader,
+			Plugins:           main.Plugins,
+			Define:            main.Define,
+			JSX:               main.JSX,
+			ResolveExtensions: main.ResolveExtensions,
+			Tsconfig:          main.Tsconfig,
+			MinifySyntax:      main.MinifySyntax,
+			MinifyWhitespace:  main.MinifyWhitespace,
+			MinifyIdentifiers: main.MinifyIdentifiers,
+			Sourcemap:         main.Sourcemap,
+			Outdir:            outDir,
+			AssetNames:        main.AssetNames,
+			LogLevel:          api.LogLevelWarning,
+			Metafile:          true,
+		},
+		built: make(map[workerKey]*workerBuild),
+	}
+}
+
+// InMemoryWorkerPlugin returns the rewriting plugin for a watch-mode build
+// that doesn't write its outputs: the worker outputs are appended to the
+// build result instead, and rebuilt with it.
+func InMemoryWorkerPlugin(outDir string, main api.BuildOptions) api.Plugin {
+	b := NewWorkerBundler(outDir, main)
+	b.write = false
+	rewrite := b.Plugin()
+	return api.Plugin{
+		Name: rewrite.Name,
+		Setup: func(build api.PluginBuild) {
+			build.OnStart(func() (api.OnStartResult, error) {
+				b.mu.Lock()
+				if b.built != nil {
+					b.built = make(map[workerKey]*workerBuild)
+				}
+				b.files = nil
+				b.mu.Unlock()
+				return api.OnStartResult{}, nil
+			})
+			rewrite.Setup(build)
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				b.mu.Lock()
+				result.OutputFiles = append(result.OutputFiles, b.files...)
+				b.mu.Unlock()
+				return api.OnEndResult{}, nil
+			})
+		},
+	}
+}
+
+// Plugin returns the esbuild plugin that rewrites worklet and worker
+// references in source files to the built modules.
+func (b *WorkerBundler) Plugin() api.Plugin {
+	return api.Plugin{
+		Name: "worker",
+		Setup: func(build api.PluginBuild) {
+			build.OnLoad(api.OnLoadOptions{Filter: `\.(js|jsx|ts|tsx|mjs|cjs)$`, Namespace: "file"},
+				func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					if strings.Contains(args.Path, "/node_modules/") {
+						return api.OnLoadResult{}, nil
+					}
+					data, err := os.ReadFile(args.Path)
+					if err != nil {
+						return api.OnLoadResult{}, nil
+					}
+					contents := string(data)
+					hasWorklets, hasWorkers := HasWorkletRefs(contents), HasWorkerRefs(contents)
+					if !hasWorklets && !hasWorkers {
+						// Let esbuild load it as usual.
+						return api.OnLoadResult{}, nil
+					}
+					var buildErr error
+					var watchFiles []string
+					ref := func(ref, dir string, format api.Format) string {
+						src := filepath.Join(filepath.Dir(args.Path), filepath.FromSlash(ref))
+						if b.built == nil {
+							fmt.Fprintf(os.Stderr, "warning: %s loads %s, which is only bundled with --splitting\n", args.Path, ref)
+							return ""
+						}
+						w, err := b.build(src, dir, format)
+						if err != nil {
+							buildErr = err
+							return ""
+						}
+						watchFiles = append(watchFiles, w.inputs...)
+						// Chunks are written to the root of the output
+						// directory, so the URL is relative to them.
+						return "new URL(" + strconv.Quote("./"+w.file) + ", import.meta.url)"
+					}
+					if hasWorklets {
+						// Worklets are always modules.
+						contents = RewriteWorkletRefs(contents, func(path string) string {
+							return ref(path, workletDir, api.FormatESModule)
+						})
+					}
+					if hasWorkers {
+						contents = RewriteWorkerRefs(contents, func(path string, module bool) string {
+							if module {
+								return ref(path, workerDir, api.FormatESModule)
+							}
+							// A classic worker can't use import statements.
+							return ref(path, workerDir, api.FormatIIFE)
+						})
+					}
+					if buildErr != nil {
+						return api.OnLoadResult{}, buildErr
+					}
+					return api.OnLoadResult{
+						Contents:   &contents,
+						Loader:     Loaders[filepath.Ext(args.Path)],
+						ResolveDir: filepath.Dir(args.Path),
+						WatchFiles: watchFiles,
+					}, nil
+				},
+			)
+		},
+	}
+}
+
+// build bundles the module at src in the given format into dir, returning
+// its build.
+func (b *WorkerBundler) build(src, dir string, format api.Format) (*workerBuild, error) {
+	b.mu.Lock()
+	key := workerKey{src, format}
+	w, ok := b.built[key]
+	if !ok {
+		w = &workerBuild{}
+		b.built[key] = w
+	}
+	b.mu.Unlock()
+
+	w.once.Do(func() {
+		opts := b.opts
+		opts.EntryPoints = []string{src}
+		opts.Format = format
+		opts.EntryNames = dir + "/[name]-[hash]"
+		result := api.Build(opts)
+		if len(result.Errors) > 0 {
+			w.err = fmt.Errorf("failed to bundle %s: %s", src, result.Errors[0].Text)
+			return
+		}
+		var meta struct {
+			Inputs map[string]json.RawMessage `json:"inputs"`
+		}
+		json.Unmarshal([]byte(result.Metafile), &meta)
+		for input := range meta.Inputs {
+			if abs, err := filepath.Abs(input); err == nil {
+				w.inputs = append(w.inputs, abs)
+			}
+		}
+		var written []string
+		for _, out := range result.OutputFiles {
+			rel, err := filepath.Rel(b.absOut, out.Path)
+			if err != nil {
+				w.err = err
+				return
+			}
+			if ext := filepath.Ext(out.Path); ext == ".js" || ext == ".mjs" {
+				w.file = filepath.ToSlash(rel)
+			}
+			if !b.write {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(out.Path), 0755); err != nil {
+				w.err = err
+				return
+			}
+			if err := os.WriteFile(out.Path, out.Contents, 0644); err != nil {
+				w.err = err
+				return
+			}
+			written = append(written, filepath.Join(opts.Outdir, rel))
+		}
+		b.mu.Lock()
+		b.outputs = append(b.outputs, written...)
+		if !b.write {
+			b.files = append(b.files, result.OutputFiles...)
+		}
+		b.mu.Unlock()
+	})
+	return w, w.err
+}
+
+// Outputs returns the paths of the files written so far.
+func (b *WorkerBundler) Outputs() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.outputs
+}
+
+// Inputs returns the absolute paths of the source files the builds so far
+// loaded.
+func (b *WorkerBundler) Inputs() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var inputs []string
+	for _, w := range b.built {
+		inputs = append(inputs, w.inputs...)
+	}
+	return inputs
+}
@@ -0,0 +1,58 @@
+package common
+
+import "testing"
+
+func TestRewriteWorkerRefs(t *testing.T) {
+	rewrite := func(path string, module bool) string {
+		if path == "./skip.js" {
+			return ""
+		}
+		kind := "classic"
+		if module {
+			kind = "module"
+		}
+		return `"/` + kind + `/` + path + `"`
+	}
+	tests := []struct {
+		code string
+		want string
+	}{
+		{
+			`new Worker(new URL("./worker.ts", import.meta.url), { type: "module" });`,
+			`new Worker("/module/./worker.ts", { type: "module" });`,
+		},
+		{
+			`new SharedWorker(new URL('../sync.js', import.meta.url), {name: "sync", type: 'module'})`,
+			`new SharedWorker("/module/../sync.js", {name: "sync", type: 'module'})`,
+		},
+		{
+			`const w = new Worker(new URL("./legacy.js", import.meta.url));`,
+			`const w = new Worker("/classic/./legacy.js");`,
+		},
+		{
+			`new SharedWorker(new URL("./sync.js", import.meta.url), { name: "sync" })`,
+			`new SharedWorker("/classic/./sync.js", { name: "sync" })`,
+		},
+		{
+			// Document-relative and remote URLs are served as they are.
+			`new Worker("./worker.js"); new Worker(new URL("https://cdn.example/w.js"));`,
+			`new Worker("./worker.js"); new Worker(new URL("https://cdn.example/w.js"));`,
+		},
+		{
+			// An empty rewrite keeps the reference.
+			`new Worker(new URL("./skip.js", import.meta.url))`,
+			`new Worker(new URL("./skip.js", import.meta.url))`,
+		},
+	}
+	for _, tt := range tests {
+		if got := RewriteWorkerRefs(tt.code, rewrite); got != tt.want {
+			t.Errorf("RewriteWorkerRefs(%q)\n got %q\nwant %q", tt.code, got, tt.want)
+		}
+	}
+	if HasWorkerRefs(`new Worker("./worker.js")`) {
+		t.Error("expected no worker reference for a document-relative URL")
+	}
+	if !HasWorkerRefs(`new SharedWorker(new URL("./w.js", import.meta.url))`) {
+		t.Error("expected a worker reference")
+	}
+}
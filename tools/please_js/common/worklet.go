@@ -0,0 +1,43 @@
+package common
+
+import (
+	"regexp"
+	"strings"
+)
+
+// workletRefRe matches worklet modules loaded with a relative path, either as
+// a plain string or resolved against the module:
+//
+//	ctx.audioWorklet.addModule("./processor.js")
+//	CSS.paintWorklet.addModule(new URL("./checkerboard.ts", import.meta.url))
+//
+// Group 1 is the argument; group 2 or 3 the path.
+var workletRefRe = regexp.MustCompile(`\.addModule\s*\(\s*(new\s+URL\s*\(\s*["'](\.{1,2}/[^"']+)["']\s*,\s*import\.meta\.url\s*\)|["'](\.{1,2}/[^"']+)["'])`)
+
+// HasWorkletRefs reports whether code may load a worklet module, as a cheap
+// check before RewriteWorkletRefs.
+func HasWorkletRefs(code string) bool {
+	return strings.Contains(code, "addModule") && workletRefRe.MatchString(code)
+}
+
+// RewriteWorkletRefs replaces the argument of each relative addModule()
+// call with the expression returned by rewrite for its path, or leaves it
+// alone if rewrite returns "". Worklets run in their own global scope without
+// the page's import map or bundle, so they have to be built and served as
+// standalone modules. Paths are taken relative to the calling module, even
+// when passed as a plain string (which browsers resolve against the
+// document), since the worklet is a source file next to it.
+func RewriteWorkletRefs(code string, rewrite func(path string) string) string {
+	return workletRefRe.ReplaceAllStringFunc(code, func(match string) string {
+		m := workletRefRe.FindStringSubmatch(match)
+		path := m[2]
+		if path == "" {
+			path = m[3]
+		}
+		replacement := rewrite(path)
+		if replacement == "" {
+			return match
+		}
+		return strings.Replace(match, m[1], replacement, 1)
+	})
+}
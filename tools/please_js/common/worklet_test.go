@@ -0,0 +1,46 @@
+package common
+
+import "testing"
+
+func TestRewriteWorkletRefs(t *testing.T) {
+	rewrite := func(path string) string {
+		if path == "./skip.js" {
+			return ""
+		}
+		return `"/worklet/` + path + `"`
+	}
+	tests := []struct {
+		code string
+		want string
+	}{
+		{
+			`ctx.audioWorklet.addModule("./processor.js");`,
+			`ctx.audioWorklet.addModule("/worklet/./processor.js");`,
+		},
+		{
+			`CSS.paintWorklet.addModule(new URL('../paint/checker.ts', import.meta.url));`,
+			`CSS.paintWorklet.addModule("/worklet/../paint/checker.ts");`,
+		},
+		{
+			// Absolute and remote URLs are served as they are.
+			`ctx.audioWorklet.addModule("/static/processor.js"); w.addModule("https://cdn.example/p.js");`,
+			`ctx.audioWorklet.addModule("/static/processor.js"); w.addModule("https://cdn.example/p.js");`,
+		},
+		{
+			// An empty rewrite keeps the reference.
+			`ctx.audioWorklet.addModule("./skip.js");`,
+			`ctx.audioWorklet.addModule("./skip.js");`,
+		},
+	}
+	for _, tt := range tests {
+		if got := RewriteWorkletRefs(tt.code, rewrite); got != tt.want {
+			t.Errorf("RewriteWorkletRefs(%q)\n got %q\nwant %q", tt.code, got, tt.want)
+		}
+	}
+	if HasWorkletRefs(`console.log("addModule")`) {
+		t.Error("expected no worklet reference without an addModule call")
+	}
+	if !HasWorkletRefs(`ctx.audioWorklet.addModule("./p.js")`) {
+		t.Error("expected a worklet reference")
+	}
+}
@@ -0,0 +1,143 @@
+package dev
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"tools/please_js/common"
+)
+
+const (
+	// analyzeURLPath serves a treemap of the bundle's composition, redrawn
+	// on every rebuild.
+	analyzeURLPath = "/__analyze"
+	// analyzeDataURLPath serves the size tree it draws.
+	analyzeDataURLPath = "/__analyze.json"
+)
+
+// handleAnalyze serves the treemap page.
+func (s *devServer) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(analyzePage))
+}
+
+// handleAnalyzeData serves the size tree of the last successful build.
+func (s *devServer) handleAnalyzeData(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	metafile := s.metafile
+	s.mu.RUnlock()
+	if metafile == "" {
+		http.Error(w, "no build has succeeded yet", http.StatusServiceUnavailable)
+		return
+	}
+	tree, err := common.BundleTree(metafile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(tree)
+}
+
+// analyzePage draws the size tree from analyzeDataURLPath as a treemap,
+// nesting each output's directories and inputs in proportion to the bytes
+// they add to it. Clicking a box zooms into it, and the header zooms back
+// out. It refetches the tree on every rebuild the live reload stream
+// announces.
+const analyzePage = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Bundle analysis</title>
+<style>
+body { margin: 0; background: #181818; color: #e8e8e8; font: 12px/1.4 ui-monospace, Menlo, Consolas, monospace; }
+header { height: 32px; padding: 0 12px; display: flex; gap: 16px; align-items: center; }
+header a { color: #7cc4ff; cursor: pointer; }
+#info { color: #888; }
+#map { position: absolute; top: 32px; left: 0; right: 0; bottom: 0; }
+.n { position: absolute; box-sizing: border-box; overflow: hidden; padding: 1px 4px; border: 1px solid #181818; white-space: nowrap; text-overflow: ellipsis; cursor: pointer; }
+</style>
+</head>
+<body>
+<header><span id="path"></span><span id="info">loading…</span></header>
+<div id="map"></div>
+<script>
+const map = document.getElementById("map");
+const fmt = (b) => b >= 1048576 ? (b / 1048576).toFixed(1) + " MB" : b >= 1024 ? (b / 1024).toFixed(1) + " KB" : b + " B";
+let tree = null;
+let zoom = []; // names from the root to the node shown
+
+function box(node, x, y, w, h, depth, path) {
+  const el = document.createElement("div");
+  el.className = "n";
+  el.style.cssText = "left:" + x + "px;top:" + y + "px;width:" + w + "px;height:" + h + "px;background:hsl(" + ((210 + depth * 67) % 360) + ",40%," + (18 + depth * 7) + "%)";
+  el.title = path.join("/") + "\n" + fmt(node.bytes);
+  if (w > 48 && h > 14) el.textContent = node.name + " · " + fmt(node.bytes);
+  el.onclick = (e) => { e.stopPropagation(); if (node.children) { zoom = path; draw(); } };
+  map.appendChild(el);
+
+  const kids = node.children || [];
+  const head = 16;
+  if (!kids.length || w < 12 || h < head + 8) return;
+  const total = kids.reduce((t, c) => t + c.bytes, 0) || 1;
+  const ix = x + 2, iy = y + head, iw = w - 4, ih = h - head - 2;
+  let off = 0;
+  for (const c of kids) {
+    const f = c.bytes / total;
+    if (iw >= ih) {
+      box(c, ix + off, iy, iw * f, ih, depth + 1, path.concat(c.name));
+      off += iw * f;
+    } else {
+      box(c, ix, iy + off, iw, ih * f, depth + 1, path.concat(c.name));
+      off += ih * f;
+    }
+  }
+}
+
+function draw() {
+  map.innerHTML = "";
+  const crumbs = document.getElementById("path");
+  crumbs.innerHTML = "";
+  if (!tree) return;
+  let node = tree;
+  const shown = [];
+  for (const name of zoom) {
+    const child = (node.children || []).find((c) => c.name === name);
+    if (!child) break;
+    node = child;
+    shown.push(name);
+  }
+  zoom = shown;
+  [tree.name].concat(shown).forEach((name, i) => {
+    const a = document.createElement("a");
+    a.textContent = name;
+    a.onclick = () => { zoom = shown.slice(0, i); draw(); };
+    if (i) crumbs.append(" › ");
+    crumbs.append(a);
+  });
+  box(node, 0, 0, map.clientWidth, map.clientHeight, 0, shown);
+}
+
+async function load() {
+  const info = document.getElementById("info");
+  const res = await fetch("` + analyzeDataURLPath + `");
+  if (!res.ok) {
+    info.textContent = await res.text();
+    return;
+  }
+  tree = await res.json();
+  info.textContent = fmt(tree.bytes) + " in " + (tree.children || []).length + " outputs, updated " + new Date().toLocaleTimeString();
+  draw();
+}
+
+const es = new EventSource("/esbuild");
+es.addEventListener("change", load);
+es.addEventListener("css-update", load);
+window.addEventListener("resize", draw);
+load();
+</script>
+</body>
+</html>
+`
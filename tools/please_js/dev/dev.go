@@ -9,12 +9,10 @@ import (
 	"mime"
 	"net"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -27,31 +25,83 @@ import (
 
 // Args holds the arguments for the dev subcommand.
 type Args struct {
-	Entry          string
+	Entries        []string // entry points, all built by one esbuild context
 	ModuleConfig   string
+	ModuleAliases  []string // name=entry, resolving a module to another moduleconfig entry
 	Servedir       string
+	PublicDirs     []string // served at the root below the build outputs, and watched
+	WatchIgnore    []string // comma-separated globs of files whose changes are ignored
+	Host           string   // address to listen on; "" for all interfaces
 	Port           int
 	Format         string
 	Platform       string
 	Define         []string
 	Proxy          []string
+	ProxyConfig    string             // file of proxy routes (see common.LoadProxyConfig); "" for none
+	ProxyRules     []common.ProxyRule // routes from the project config, below ProxyConfig's and Proxy's
+	ProxyOptions   common.ProxyOptions
 	EnvFile        string
 	EnvPrefix      string
 	Tsconfig       string
 	TailwindBin    string
 	TailwindConfig string
+	Flavor         string
+	Rewrites       []string // history fallback rewrites, regex=file
+	NoFallback     []string // path prefixes that 404 rather than fall back to index.html
+	NoDotFallback  bool     // paths with a dot in their last segment 404 rather than fall back
+	HTTPS          bool
+	Cert           string        // TLS certificate to serve with HTTPS; "" for a generated one
+	Key            string        // key of Cert
+	H2C            bool          // also accept HTTP/2 without TLS, behind a proxy
+	Auth           string        // "user:pass" required to use the server; "" for none
+	CORS           bool          // set CORS headers allowing any origin
+	CORSOrigins    []string      // set CORS headers allowing only these origins
+	Headers        []string      // "Name: value" headers to set on every response
+	MockDir        string        // directory of mock API routes (see common.MockAPI); "" for none
+	Node           string        // Node.js binary, for JS mocks; "" for node on PATH
+	Throttle       time.Duration // latency added to each response
+	Bandwidth      string        // bandwidth responses are limited to, e.g. "1mbps"; "" for unlimited
+	Compress       bool          // serve responses gzip or brotli compressed
+	LogRequests    string        // off, summary or full; "" for full
+	LogFormat      string        // text or json; "" for text
+	AccessLog      string        // file every request is appended to; "" for none
+	Open           string        // path to open in the browser once the first build completes; "" not to
 }
 
 // liveReloadBanner is injected into the bundle to enable live reload via SSE.
 // Parses the SSE event data and only reloads when output files actually changed.
-// Debounced to collapse rapid rebuilds into a single reload.
-const liveReloadBanner = `(() => { const es = new EventSource("/esbuild"); let t; es.addEventListener("change", (e) => { try { const d = JSON.parse(e.data); if (!d.added.length && !d.removed.length && !d.updated.length) return; } catch {} clearTimeout(t); t = setTimeout(() => window.location.reload(), 200); }); es.addEventListener("css-update", () => { document.querySelectorAll('link[rel="stylesheet"]').forEach(link => { const url = new URL(link.href); url.searchParams.set('t', Date.now()); link.href = url.toString(); }); }); })();`
+// Debounced to collapse rapid rebuilds into a single reload. Each event is
+// logged to the console with its build ID and time, matching the server log.
+// A failed build shows errorOverlay instead, until the next successful one.
+// Only pages connect: a worker built as another entry point reloads with its
+// page.
+const liveReloadBanner = `(() => { if (typeof document === "undefined") return; const es = new EventSource("/esbuild"); let t; const log = (d, what) => console.info("[dev] #" + d.build + " " + d.time + " " + what); const overlay = ` + errorOverlay + `; es.addEventListener("build-error", (e) => { let d; try { d = JSON.parse(e.data); } catch { return; } log(d, "build failed"); overlay(d); }); es.addEventListener("change", (e) => { let d; overlay(null); try { d = JSON.parse(e.data); if (!d.added.length && !d.removed.length && !d.updated.length) return; } catch {} if (d) log(d, "rebuilt, reloading"); clearTimeout(t); t = setTimeout(() => window.location.reload(), 200); }); es.addEventListener("css-update", (e) => { let d; overlay(null); try { d = JSON.parse(e.data); log(d, "css updated"); } catch {} (` + swapStylesheets + `)(d ? d.updated : []); }); })();`
+
+// swapStylesheets is a function that reloads the page's stylesheets at the
+// given URL paths, or all of its own if none are linked, without reloading
+// the page. Each is replaced by a copy loading the new version, and removed
+// once that has loaded, so the page is never unstyled in between.
+// Stylesheets from other origins are left alone.
+const swapStylesheets = `(paths) => { const links = [...document.querySelectorAll('link[rel="stylesheet"]')].filter((l) => !l.dataset.stale && new URL(l.href).origin === location.origin); const hit = links.filter((l) => paths.includes(new URL(l.href).pathname)); (hit.length ? hit : links).forEach((link) => { const url = new URL(link.href); url.searchParams.set("t", Date.now()); const next = link.cloneNode(); next.href = url.toString(); next.onload = next.onerror = () => link.remove(); link.dataset.stale = "1"; link.after(next); }); }`
+
+// errorOverlay is a function that shows a failed build's errors and warnings
+// full-screen over the page, with where they are, or with null hides them.
+const errorOverlay = `(d) => { let el = document.getElementById("__plz_error_overlay"); if (!d) { if (el) el.remove(); return; } if (!el) { el = document.createElement("div"); el.id = "__plz_error_overlay"; el.style.cssText = "position:fixed;inset:0;z-index:2147483647;overflow:auto;padding:32px;background:rgba(24,24,24,.96);color:#e8e8e8;font:14px/1.5 ui-monospace,Menlo,Consolas,monospace"; document.body.appendChild(el); } const esc = (s) => String(s).replace(/[&<>"]/g, (c) => ({"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"})[c]); const msg = (m, color) => '<div style="margin-bottom:24px"><div style="white-space:pre-wrap;font-weight:bold;color:' + color + '">' + esc(m.text) + '</div>' + (m.file ? '<div style="color:#7cc4ff">' + esc(m.file + ":" + m.line + ":" + m.column) + '</div>' : '') + (m.lineText ? '<pre style="margin:4px 0 0;color:#aaa">' + esc(m.lineText) + '</pre>' : '') + '</div>'; el.innerHTML = '<div style="margin-bottom:24px;font-size:18px;color:#ff6b6b">Build #' + d.build + ' failed with ' + d.errors.length + (d.errors.length === 1 ? ' error' : ' errors') + '</div>' + d.errors.map((m) => msg(m, "#ff6b6b")).join("") + (d.warnings || []).map((m) => msg(m, "#ffc857")).join("") + '<div style="color:#888">This clears on the next successful build.</div>'; }`
 
 // isCSSFile returns true for .css and .css.map files.
 func isCSSFile(path string) bool {
 	return strings.HasSuffix(path, ".css") || strings.HasSuffix(path, ".css.map")
 }
 
+// entryDirs returns the directories of entry points, for Tailwind to scan.
+func entryDirs(entries []string) []string {
+	dirs := make([]string, len(entries))
+	for i, entry := range entries {
+		dirs[i] = filepath.Dir(entry)
+	}
+	return dirs
+}
+
 // formatSize formats a byte count as a human-readable string.
 func formatSize(bytes int) string {
 	if bytes >= 1024*1024 {
@@ -62,16 +112,51 @@ func formatSize(bytes int) string {
 
 // serverInfo holds serve details for the build timer plugin to print after the first build.
 type serverInfo struct {
-	port uint16
-	ips  []string
+	scheme string
+	host   string // host the machine itself reaches the server on; "" if none
+	port   uint16
+	ips    []string // network addresses other machines reach the server on
+	cert   string   // certificate served over HTTPS, for the browser to trust
+	open   string   // path to open in the browser after the first build; "" not to
+	origin string   // where to open it
+	// shortcuts is whether keyboard shortcuts are read from the terminal.
+	shortcuts bool
 }
 
 // sseEvent is the JSON payload sent to clients on rebuild.
 type sseEvent struct {
-	Added   []string `json:"added"`
-	Removed []string `json:"removed"`
-	Updated []string `json:"updated"`
-	CSSOnly bool     `json:"cssOnly"`
+	Added    []string       `json:"added"`
+	Removed  []string       `json:"removed"`
+	Updated  []string       `json:"updated"`
+	CSSOnly  bool           `json:"cssOnly"`
+	Build    int64          `json:"build"`              // build ID, as in the server log
+	Time     string         `json:"time"`               // when the build finished, HH:MM:SS.mmm
+	Errors   []buildMessage `json:"errors,omitempty"`   // set if the build failed
+	Warnings []buildMessage `json:"warnings,omitempty"` // those of a failed build
+}
+
+// buildMessage is an esbuild error or warning, as the error overlay shows it.
+type buildMessage struct {
+	Text     string `json:"text"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	LineText string `json:"lineText,omitempty"`
+}
+
+// buildMessages converts esbuild's messages for the error overlay.
+func buildMessages(msgs []api.Message) []buildMessage {
+	out := make([]buildMessage, len(msgs))
+	for i, m := range msgs {
+		out[i].Text = m.Text
+		if m.Location != nil {
+			out[i].File = m.Location.File
+			out[i].Line = m.Location.Line
+			out[i].Column = m.Location.Column
+			out[i].LineText = m.Location.LineText
+		}
+	}
+	return out
 }
 
 // devServer serves built output from memory and static files from disk,
@@ -80,67 +165,66 @@ type devServer struct {
 	mu          sync.RWMutex
 	outputFiles map[string][]byte // URL path ("/main.js") -> contents
 	fileHashes  map[string]string // URL path -> SHA-256 hex
+	metafile    string            // the last successful build's, for /__analyze
 
 	sseMu   sync.Mutex
 	clients map[chan sseEvent]struct{}
+	failed  *sseEvent // the last build's errors, if it failed; guarded by sseMu
 
-	outdir        string // absolute, for stripping OutputFile.Path prefix
-	servedir      string // absolute, for static file serving
-	proxies       map[string]*httputil.ReverseProxy
+	outdir        string              // absolute, for stripping OutputFile.Path prefix
+	servedir      string              // absolute, for static file serving
+	public        *common.PublicDirs  // nil without --public-dir
+	builds        common.BuildCounter // stamps builds and public file changes
+	status        common.BuildStatus
+	deps          int // modules in the moduleconfig, for /__status
+	proxies       map[string]*common.Proxy
 	proxyPrefixes []string // sorted longest-first for greedy matching
+	proxyHealth   *common.ProxyHealth
+	chaos         *common.Chaos
+	fallback      *common.HistoryFallback
+	auth          *common.BasicAuth // nil without --auth
+	cors          *common.CORS      // nil unless --cors or --cors-origin
+	headers       http.Header       // set on every response
+	mocks         *common.MockAPI   // nil without --mock-dir
+	throttle      *common.Throttle  // nil without --throttle or --bandwidth
+	requests      *common.RequestLog
+	compression   *common.Compression // nil without --compress
+	wsDialTimeout time.Duration       // for WebSocket connections to proxy targets
+	define        map[string]string   // for %NAME% placeholders in HTML
 }
 
-// parseProxies converts "prefix=target" strings into reverse proxy instances.
-// Each proxy is configured with Vite-equivalent defaults:
+// parseProxies converts "prefix=target" rules (see common.ParseProxyRule)
+// into reverse proxy instances, added to rules from a --proxy-config file;
+// a --proxy rule replaces a config rule for the same prefix. Unless a config
+// rule says otherwise, each proxy is configured with Vite-equivalent
+// defaults:
 //   - changeOrigin: Host header is rewritten to the target (so backends
 //     behind virtual hosts or CORS checks see the right origin)
 //   - secure=false: TLS certificate verification is skipped (dev servers
 //     commonly proxy to localhost HTTPS with self-signed certs)
+//   - ws: WebSocket upgrades are tunnelled to the target
 //   - All headers (including Cookie / Set-Cookie) are forwarded as-is
-func parseProxies(specs []string) (map[string]*httputil.ReverseProxy, []string) {
-	proxies := make(map[string]*httputil.ReverseProxy, len(specs))
-	var prefixes []string
+//
+// All proxies share one keep-alive connection pool, tuned by opts. Failed
+// requests get an error page naming the backend, and health tracks which
+// targets are up.
+func parseProxies(specs []string, rules []common.ProxyRule, opts common.ProxyOptions, health *common.ProxyHealth) (map[string]*common.Proxy, []string) {
 	for _, spec := range specs {
-		parts := strings.SplitN(spec, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		prefix := strings.TrimSpace(parts[0])
-		target := strings.TrimSpace(parts[1])
-		u, err := url.Parse(target)
+		rule, err := common.ParseProxyRule(spec)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "warning: invalid proxy target %q: %v\n", target, err)
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 			continue
 		}
-		proxy := httputil.NewSingleHostReverseProxy(u)
-
-		// changeOrigin: rewrite Host header to the target host so backends
-		// behind virtual hosts / CORS checks see the correct origin.
-		originalDirector := proxy.Director
-		proxy.Director = func(req *http.Request) {
-			originalDirector(req)
-			req.Host = u.Host
-		}
-
-		// secure=false: skip TLS verification for the proxy target.
-		proxy.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-
-		proxies[prefix] = proxy
-		prefixes = append(prefixes, prefix)
+		rules = append(rules, rule)
 	}
-	// Sort longest-first so /api/v2 matches before /api
-	sort.Slice(prefixes, func(i, j int) bool {
-		return len(prefixes[i]) > len(prefixes[j])
-	})
-	return proxies, prefixes
+	return common.NewProxies(rules, opts, health)
 }
 
-func newDevServer(outdir, servedir string, proxySpecs []string) *devServer {
+func newDevServer(outdir, servedir string, proxySpecs []string, proxyRules []common.ProxyRule, proxyOpts common.ProxyOptions) *devServer {
 	absOutdir, _ := filepath.Abs(outdir)
 	absServedir, _ := filepath.Abs(servedir)
-	proxies, proxyPrefixes := parseProxies(proxySpecs)
+	proxyHealth := common.NewProxyHealth()
+	proxies, proxyPrefixes := parseProxies(proxySpecs, proxyRules, proxyOpts, proxyHealth)
 	return &devServer{
 		outputFiles:   make(map[string][]byte),
 		fileHashes:    make(map[string]string),
@@ -149,66 +233,197 @@ func newDevServer(outdir, servedir string, proxySpecs []string) *devServer {
 		servedir:      absServedir,
 		proxies:       proxies,
 		proxyPrefixes: proxyPrefixes,
+		proxyHealth:   proxyHealth,
+		wsDialTimeout: proxyOpts.DialTimeout,
 	}
 }
 
 func (s *devServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	urlPath := r.URL.Path
+	// --header values go on every response, proxied ones included.
+	for name, values := range s.headers {
+		w.Header()[name] = values
+	}
 
-	// SSE endpoint
-	if urlPath == "/esbuild" {
+	// --auth guards everything. The live reload stream gets in on the
+	// session cookie set when the page logged in.
+	if !s.auth.Check(w, r) {
+		return
+	}
+
+	// SSE endpoint; a stream for the page's lifetime, so not logged
+	if r.URL.Path == "/esbuild" {
 		s.handleSSE(w, r)
 		return
 	}
 
-	// Proxy matching requests to backend services
+	start := time.Now()
+	lw := &common.LoggedResponse{ResponseWriter: w}
+	kind, detail := s.serve(lw, r)
+	if lw.Status == 0 {
+		// A request abandoned before any response, e.g. mid-throttle.
+		return
+	}
+	s.requests.Log(common.RequestEntry{
+		Time:   start,
+		Kind:   kind,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Status: lw.Status,
+		Bytes:  lw.Bytes,
+		Ms:     time.Since(start).Milliseconds(),
+		Detail: detail,
+	})
+}
+
+// serve answers a request, and returns what answered it for the request
+// log: "mock", "proxy" with the target, "fallback" with the page served, or
+// "req".
+func (s *devServer) serve(w http.ResponseWriter, r *http.Request) (string, string) {
+	urlPath := r.URL.Path
+
+	// Simulated slow network for everything else. WebSocket upgrades,
+	// which take over the connection, aren't slowed down.
+	upgrade := common.IsWebSocketUpgrade(r)
+	if !upgrade {
+		var ok bool
+		if w, ok = s.throttle.Wrap(w, r); !ok {
+			return "req", ""
+		}
+	}
+
+	// Bundle treemap
+	switch urlPath {
+	case analyzeURLPath:
+		s.handleAnalyze(w, r)
+		return "req", ""
+	case analyzeDataURLPath:
+		s.handleAnalyzeData(w, r)
+		return "req", ""
+	case common.StatusURLPath:
+		s.sseMu.Lock()
+		clients := len(s.clients)
+		s.sseMu.Unlock()
+		s.status.Serve(w, "dev", clients, s.deps)
+		return "req", ""
+	}
+
+	// Mock API routes, ahead of the proxy rules they stand in for
+	if s.mocks.Serve(w, r) {
+		return "mock", ""
+	}
+
+	// Proxy matching requests to backend services. Routes with ws off leave
+	// WebSocket upgrades to the routes after them.
 	for _, prefix := range s.proxyPrefixes {
-		if strings.HasPrefix(urlPath, prefix) {
-			fmt.Printf("  \033[2m[proxy] %s %s\033[0m\n", r.Method, urlPath)
-			s.proxies[prefix].ServeHTTP(w, r)
-			return
+		proxy := s.proxies[prefix]
+		if strings.HasPrefix(urlPath, prefix) && (proxy.Rule.WS || !upgrade) {
+			target := proxy.Rule.Target.String()
+			if s.chaos.Inject(w, r) {
+				return "proxy", target
+			}
+			if upgrade {
+				common.ServeWebSocket(w, r, proxy.ReverseProxy, s.wsDialTimeout, proxy.Rule.Secure)
+				return "proxy", target
+			}
+			proxy.ServeHTTP(w, r)
+			return "proxy", target
 		}
 	}
 
+	// CORS, for apps on other origins loading the bundle and assets.
+	// Proxied responses keep the backend's own headers.
+	if s.cors.Handle(w, r) {
+		return "req", ""
+	}
+
 	// Try built files from in-memory map
 	s.mu.RLock()
 	data, ok := s.outputFiles[urlPath]
+	hash := s.fileHashes[urlPath]
 	s.mu.RUnlock()
 	if ok {
+		// Outputs unchanged since the browser last fetched them cost it
+		// only a 304 on reload.
+		if common.ServeETag(w, r, hash) {
+			return "req", ""
+		}
 		ct := mime.TypeByExtension(filepath.Ext(urlPath))
 		if ct == "" {
 			ct = "application/octet-stream"
 		}
 		w.Header().Set("Content-Type", ct)
+		if s.compression.ServeBuilt(w, r, urlPath, hash) {
+			return "req", ""
+		}
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
 		w.Write(data)
-		fmt.Printf("  \033[2m[req] %s %s \u2192 200 (%dms)\033[0m\n",
-			r.Method, urlPath, time.Since(start).Milliseconds())
-		return
+		return "req", ""
+	}
+
+	// Static files and fallback pages are compressed as they're served.
+	w, done := s.compression.Wrap(w, r)
+	defer done()
+
+	// Try public directories
+	if file := s.public.Find(urlPath); file != "" {
+		http.ServeFile(w, r, file)
+		return "req", ""
 	}
 
 	// Try static file from servedir
 	filePath := filepath.Join(s.servedir, filepath.FromSlash(urlPath))
 	if info, err := os.Stat(filePath); err == nil && !info.IsDir() {
-		http.ServeFile(w, r, filePath)
-		fmt.Printf("  \033[2m[req] %s %s \u2192 200 (%dms)\033[0m\n",
-			r.Method, urlPath, time.Since(start).Milliseconds())
-		return
+		if strings.HasSuffix(filePath, ".html") {
+			s.serveHTML(w, r, filePath)
+		} else {
+			http.ServeFile(w, r, filePath)
+		}
+		return "req", ""
 	}
 
-	// SPA fallback — serve index.html
-	indexPath := filepath.Join(s.servedir, "index.html")
-	if _, err := os.Stat(indexPath); err == nil {
-		http.ServeFile(w, r, indexPath)
-		fmt.Printf("  \033[2m[req] %s %s \u2192 200 fallback (%dms)\033[0m\n",
-			r.Method, urlPath, time.Since(start).Milliseconds())
-		return
+	// SPA fallback — serve index.html, or the page a --rewrites rule names
+	if page := s.fallback.Resolve(urlPath); page != "" {
+		pagePath := filepath.Join(s.servedir, filepath.FromSlash(page))
+		if _, err := os.Stat(pagePath); err == nil {
+			if strings.HasSuffix(pagePath, ".html") {
+				s.serveHTML(w, r, pagePath)
+			} else {
+				http.ServeFile(w, r, pagePath)
+			}
+			return "fallback", page
+		}
 	}
 
 	http.NotFound(w, r)
-	fmt.Printf("  \033[2m[req] %s %s \u2192 404 (%dms)\033[0m\n",
-		r.Method, urlPath, time.Since(start).Milliseconds())
+	return "req", ""
+}
+
+// serveHTML serves an HTML file from servedir with its %NAME% env
+// placeholders filled in, as the production bundle's index.html has them.
+func (s *devServer) serveHTML(w http.ResponseWriter, r *http.Request, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	html := common.ReplaceEnvPlaceholders(string(data), s.define)
+	// Until a build succeeds there's no bundle to carry the live reload
+	// client, so the page gets it inline, to show the build's errors and
+	// reload once they're fixed.
+	s.mu.RLock()
+	built := len(s.outputFiles) > 0
+	s.mu.RUnlock()
+	if !built {
+		client := "<script>" + liveReloadBanner + "</script>"
+		if i := strings.Index(html, "</head>"); i >= 0 {
+			html = html[:i] + client + html[i:]
+		} else {
+			html = client + html
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(html))
 }
 
 func (s *devServer) handleSSE(w http.ResponseWriter, r *http.Request) {
@@ -221,12 +436,20 @@ func (s *devServer) handleSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if s.cors == nil {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else if s.cors.Handle(w, r) {
+		return
+	}
 	flusher.Flush()
 
+	// A page loaded while the build is broken shows its errors straight away.
 	ch := make(chan sseEvent, 1)
 	s.sseMu.Lock()
 	s.clients[ch] = struct{}{}
+	if s.failed != nil {
+		ch <- *s.failed
+	}
 	s.sseMu.Unlock()
 
 	defer func() {
@@ -245,7 +468,9 @@ func (s *devServer) handleSSE(w http.ResponseWriter, r *http.Request) {
 		case evt := <-ch:
 			data, _ := json.Marshal(evt)
 			eventType := "change"
-			if evt.CSSOnly {
+			if len(evt.Errors) > 0 {
+				eventType = "build-error"
+			} else if evt.CSSOnly {
 				eventType = "css-update"
 			}
 			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
@@ -258,7 +483,27 @@ func (s *devServer) handleSSE(w http.ResponseWriter, r *http.Request) {
 }
 
 // onBuildComplete updates the in-memory output map and broadcasts changes via SSE.
-func (s *devServer) onBuildComplete(result *api.BuildResult, newHashes map[string]string, changed bool) {
+// A failed build keeps serving the last good output, and broadcasts its
+// errors for the overlay instead.
+func (s *devServer) onBuildComplete(result *api.BuildResult, newHashes map[string]string, changed bool, stamp common.BuildStamp) {
+	if len(result.Errors) > 0 {
+		evt := sseEvent{
+			Build:    stamp.ID,
+			Time:     stamp.Clock(),
+			Errors:   buildMessages(result.Errors),
+			Warnings: buildMessages(result.Warnings),
+		}
+		s.sseMu.Lock()
+		s.failed = &evt
+		s.sseMu.Unlock()
+		s.broadcast(evt)
+		return
+	}
+	s.sseMu.Lock()
+	recovered := s.failed != nil
+	s.failed = nil
+	s.sseMu.Unlock()
+
 	// Build new output map, converting absolute paths to URL paths
 	newOutputFiles := make(map[string][]byte, len(result.OutputFiles))
 	for _, f := range result.OutputFiles {
@@ -281,18 +526,29 @@ func (s *devServer) onBuildComplete(result *api.BuildResult, newHashes map[strin
 		newURLHashes[urlPath] = hash
 	}
 
+	// Compress the outputs before serving them, so the page reloads with
+	// them compressed; until then the old outputs keep their compressed
+	// forms or are served as they are.
+	s.compression.Update(newOutputFiles, newURLHashes)
+
 	s.mu.Lock()
 	oldHashes := s.fileHashes
 	s.outputFiles = newOutputFiles
 	s.fileHashes = newURLHashes
+	s.metafile = result.Metafile
 	s.mu.Unlock()
 
+	// A build that fixes the last one's errors without changing the output
+	// still has to clear the overlay.
 	if !changed {
+		if recovered {
+			s.broadcast(sseEvent{Build: stamp.ID, Time: stamp.Clock()})
+		}
 		return
 	}
 
 	// Compute diff
-	var evt sseEvent
+	evt := sseEvent{Build: stamp.ID, Time: stamp.Clock()}
 	for p := range newURLHashes {
 		if _, ok := oldHashes[p]; !ok {
 			evt.Added = append(evt.Added, p)
@@ -307,21 +563,44 @@ func (s *devServer) onBuildComplete(result *api.BuildResult, newHashes map[strin
 	}
 
 	if len(evt.Added) == 0 && len(evt.Removed) == 0 && len(evt.Updated) == 0 {
+		if recovered {
+			s.broadcast(evt)
+		}
 		return
 	}
 
-	// Classify as CSS-only if every changed file ends in .css
-	cssOnly := true
-	for _, files := range [][]string{evt.Added, evt.Updated, evt.Removed} {
-		for _, f := range files {
-			if !isCSSFile(f) {
-				cssOnly = false
-			}
+	// Classify as CSS-only if every changed file ends in .css, for pages to
+	// swap their stylesheets in place. Adding or removing one changes what
+	// the page links, so that reloads it.
+	cssOnly := len(evt.Added) == 0 && len(evt.Removed) == 0
+	for _, f := range evt.Updated {
+		if !isCSSFile(f) {
+			cssOnly = false
 		}
 	}
 	evt.CSSOnly = cssOnly
+	s.broadcast(evt)
+}
+
+// onPublicChange tells pages files in the public directories changed, as a
+// build of its own: stylesheets alone are swapped in, anything else reloads.
+func (s *devServer) onPublicChange(changed []string) {
+	stamp := s.builds.Next()
+	evt := sseEvent{Build: stamp.ID, Time: stamp.Clock(), Updated: changed, CSSOnly: true}
+	for _, f := range changed {
+		if !isCSSFile(f) {
+			evt.CSSOnly = false
+		}
+	}
+	fmt.Printf("  \033[2m[public %s]\033[0m %d changed\n", stamp, len(changed))
+	for _, f := range changed {
+		fmt.Printf("    \033[33m\u0394 %s\033[0m\n", f)
+	}
+	s.broadcast(evt)
+}
 
-	// Broadcast to all SSE clients (non-blocking)
+// broadcast sends an event to all SSE clients (non-blocking).
+func (s *devServer) broadcast(evt sseEvent) {
 	s.sseMu.Lock()
 	for ch := range s.clients {
 		select {
@@ -351,6 +630,7 @@ func buildTimerPlugin(info *serverInfo, server *devServer) api.Plugin {
 			})
 
 			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				stamp := server.builds.Next()
 				mu.Lock()
 				elapsed := time.Since(buildStart)
 				first := isFirst
@@ -374,7 +654,9 @@ func buildTimerPlugin(info *serverInfo, server *devServer) api.Plugin {
 					}
 				}
 				prevHashes := lastFileHashes
-				lastFileHashes = newHashes
+				if len(result.Errors) == 0 {
+					lastFileHashes = newHashes
+				}
 				mu.Unlock()
 
 				ms := elapsed.Milliseconds()
@@ -409,14 +691,32 @@ func buildTimerPlugin(info *serverInfo, server *devServer) api.Plugin {
 						}
 
 						// URL block
-						fmt.Printf("\n  \033[36m➜\033[0m  \033[1mLocal:\033[0m   http://localhost:\033[1m%d\033[0m/\n", info.port)
+						fmt.Println()
+						if info.host != "" {
+							fmt.Printf("  \033[36m➜\033[0m  \033[1mLocal:\033[0m   %s://%s:\033[1m%d\033[0m/\n", info.scheme, common.URLHost(info.host), info.port)
+						}
 						for _, ip := range info.ips {
-							fmt.Printf("  \033[36m➜\033[0m  \033[2mNetwork: http://%s:%d/\033[0m\n", ip, info.port)
+							fmt.Printf("  \033[36m➜\033[0m  \033[2mNetwork: %s://%s:%d/\033[0m\n", info.scheme, common.URLHost(ip), info.port)
+						}
+						if info.cert != "" {
+							fmt.Printf("  \033[36m➜\033[0m  \033[2mCertificate: %s\033[0m\n", info.cert)
+						}
+						fmt.Printf("  \033[36m➜\033[0m  \033[2mAnalyze: %s%s\033[0m\n", info.origin, analyzeURLPath)
+						server.proxyHealth.PrintStatus()
+						server.chaos.PrintStatus()
+						if info.shortcuts {
+							fmt.Printf("  \033[36m➜\033[0m  \033[2mpress\033[0m \033[1mh + enter\033[0m \033[2mto show help\033[0m\n")
 						}
 						fmt.Println()
 					} else {
 						fmt.Printf("\n  \033[1;36mPLEASE_JS\033[0m  build failed with %d errors\n", len(result.Errors))
 					}
+					if info.open != "" {
+						url := common.OpenURL(info.origin, info.open)
+						if err := common.OpenBrowser(url); err != nil {
+							fmt.Fprintf(os.Stderr, "failed to open %s: %v\n", url, err)
+						}
+					}
 				} else {
 					// Watch rebuild
 					if len(result.Errors) == 0 && changed {
@@ -429,18 +729,21 @@ func buildTimerPlugin(info *serverInfo, server *devServer) api.Plugin {
 								}
 							}
 						}
+						for path := range prevHashes {
+							if _, ok := newHashes[path]; !ok {
+								rebuildCSSOnly = false
+							}
+						}
 						for path := range newHashes {
 							if _, ok := prevHashes[path]; !ok {
-								if !isCSSFile(path) {
-									rebuildCSSOnly = false
-								}
+								rebuildCSSOnly = false
 							}
 						}
 						cssTag := ""
 						if rebuildCSSOnly {
 							cssTag = " (css only)"
 						}
-						fmt.Printf("  \033[2m[rebuild]\033[0m \033[1m%d ms\033[0m%s \033[2m(%s, %d files)\033[0m\n", ms, cssTag, formatSize(totalSize), numFiles)
+						fmt.Printf("  \033[2m[rebuild %s]\033[0m \033[1m%d ms\033[0m%s \033[2m(%s, %d files)\033[0m\n", stamp, ms, cssTag, formatSize(totalSize), numFiles)
 						for path, hash := range newHashes {
 							if prev, ok := prevHashes[path]; ok && prev != hash {
 								fmt.Printf("    \033[33m\u0394 %s\033[0m\n", path)
@@ -452,12 +755,16 @@ func buildTimerPlugin(info *serverInfo, server *devServer) api.Plugin {
 							}
 						}
 					} else if len(result.Errors) == 0 && !changed {
-						fmt.Printf("  \033[2m[rebuild] %d ms (no change)\033[0m\n", ms)
+						fmt.Printf("  \033[2m[rebuild %s] %d ms (no change)\033[0m\n", stamp, ms)
+					} else {
+						fmt.Printf("  \033[31m[rebuild %s] failed with %d errors\033[0m\n", stamp, len(result.Errors))
 					}
 				}
 
 				// Update dev server with build output and broadcast changes
-				server.onBuildComplete(result, newHashes, changed)
+				server.status.Record(stamp, elapsed)
+				server.status.SetMessages("", common.StatusMessages(result.Errors), common.StatusMessages(result.Warnings))
+				server.onBuildComplete(result, newHashes, changed, stamp)
 
 				return api.OnEndResult{}, nil
 			})
@@ -491,6 +798,9 @@ func Run(args Args) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse moduleconfig: %w", err)
 	}
+	if err := common.ApplyModuleAliases(moduleMap, args.ModuleAliases); err != nil {
+		return err
+	}
 
 	port := args.Port
 	if port == 0 {
@@ -504,16 +814,79 @@ func Run(args Args) error {
 	}
 	outdir := servedir
 
-	server := newDevServer(outdir, servedir, args.Proxy)
+	proxyRules := args.ProxyRules
+	if args.ProxyConfig != "" {
+		configRules, err := common.LoadProxyConfig(args.ProxyConfig)
+		if err != nil {
+			return err
+		}
+		proxyRules = append(proxyRules, configRules...)
+	}
+	chaos, err := common.NewChaos(args.ProxyOptions.Chaos)
+	if err != nil {
+		return err
+	}
+	headers, err := common.ParseHeaders(args.Headers)
+	if err != nil {
+		return err
+	}
+	throttle, err := common.NewThrottle(args.Throttle, args.Bandwidth)
+	if err != nil {
+		return err
+	}
+	auth, err := common.NewBasicAuth(args.Auth)
+	if err != nil {
+		return err
+	}
+	public, err := common.NewPublicDirs(args.PublicDirs)
+	if err != nil {
+		return err
+	}
+	fallback, err := common.NewHistoryFallback(args.Rewrites, args.NoFallback, args.NoDotFallback)
+	if err != nil {
+		return err
+	}
+	requests, err := common.NewRequestLog(args.LogRequests, args.LogFormat, args.AccessLog, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer requests.Close()
+	server := newDevServer(outdir, servedir, args.Proxy, proxyRules, args.ProxyOptions)
+	server.chaos = chaos
+	server.fallback = fallback
+	server.auth = auth
+	server.public = public
+	server.deps = len(moduleMap)
+	public.Watch(common.NewWatchIgnore(args.WatchIgnore, "."), server.onPublicChange)
+	server.cors = common.NewCORS(args.CORS, args.CORSOrigins)
+	server.headers = headers
+	server.throttle = throttle
+	server.requests = requests
+	server.compression = common.NewCompression(args.Compress)
+	if args.MockDir != "" {
+		server.mocks = common.NewMockAPI(args.MockDir, args.Node)
+	}
 	info := &serverInfo{
-		port: uint16(port),
-		ips:  getLocalIPs(),
+		scheme: "http",
+		port:   uint16(port),
+		open:   args.Open,
+	}
+	info.host, info.ips = common.DevHosts(args.Host, getLocalIPs())
+	var tlsConfig *tls.Config
+	if args.HTTPS {
+		if tlsConfig, info.cert, err = common.DevTLSConfig(args.Cert, args.Key, info.ips); err != nil {
+			return err
+		}
+		info.scheme = "https"
+	}
+	info.origin = common.DevOrigin(info.scheme, args.Host, port)
+	if args.H2C && args.HTTPS {
+		return fmt.Errorf("--h2c is for serving HTTP/2 without TLS; --https already serves it")
 	}
 
 	plugins := []api.Plugin{
 		common.ModuleResolvePlugin(moduleMap, args.Platform),
 		common.RawImportPlugin(),
-		buildTimerPlugin(info, server),
 	}
 	// For browser builds, replace unresolved Node.js built-in imports with
 	// empty CJS modules. Registered AFTER ModuleResolvePlugin so npm polyfill
@@ -522,7 +895,8 @@ func Run(args Args) error {
 		plugins = append(plugins, common.NodeBuiltinEmptyPlugin())
 	}
 	if args.TailwindBin != "" {
-		plugins = append(plugins, common.TailwindPlugin(args.TailwindBin, args.TailwindConfig))
+		plugins = append(plugins, common.TailwindPlugin(args.TailwindBin, args.TailwindConfig, "",
+			append(entryDirs(args.Entries), common.LocalModuleDirs(moduleMap)...)))
 	}
 
 	format := common.ParseFormat(args.Format)
@@ -540,9 +914,15 @@ func Run(args Args) error {
 		}
 	}
 	common.MergeEnvDefines(define, "development")
+	server.define = define
+
+	resolveExtensions, err := common.ResolveExtensions(args.Platform, args.Flavor)
+	if err != nil {
+		return err
+	}
 
 	opts := api.BuildOptions{
-		EntryPoints: []string{args.Entry},
+		EntryPoints: args.Entries,
 		Outdir:      outdir,
 		Bundle:      true,
 		Write:       false,
@@ -556,40 +936,93 @@ func Run(args Args) error {
 		Banner: map[string]string{
 			"js": liveReloadBanner,
 		},
-		Define:    define,
-		Sourcemap: api.SourceMapLinked,
-		Metafile:  true,
+		Define:            define,
+		Sourcemap:         api.SourceMapLinked,
+		Metafile:          true,
+		ResolveExtensions: resolveExtensions,
 	}
 	if args.Tsconfig != "" {
 		opts.Tsconfig = args.Tsconfig
 	}
+	// Worklets and workers are built alongside the bundle with the plugins
+	// above. The build timer goes last, so the worker outputs are in the
+	// result by the time it serves them.
+	opts.Plugins = append([]api.Plugin{common.InMemoryWorkerPlugin(outdir, opts)}, opts.Plugins...)
+	opts.Plugins = append(opts.Plugins, buildTimerPlugin(info, server))
 	ctx, ctxErr := api.Context(opts)
 	if ctxErr != nil {
 		return fmt.Errorf("esbuild context creation failed: %v", ctxErr)
 	}
 
+	// Hold off serving until the proxy targets are up, if asked to, so the
+	// first page load doesn't hit a backend that's still starting.
+	if args.ProxyOptions.Wait > 0 {
+		server.proxyHealth.Wait(args.ProxyOptions.Wait)
+	} else {
+		server.proxyHealth.Check(time.Second)
+	}
+
 	// Start our HTTP server (replaces esbuild's ctx.Serve)
+	// HTTP/2 is negotiated over TLS; without it, only if asked for.
+	var handler http.Handler = server
+	if args.H2C {
+		handler = common.CleartextHTTP2(server)
+	}
 	httpServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: server,
+		Addr:      net.JoinHostPort(args.Host, strconv.Itoa(port)),
+		Handler:   handler,
+		TLSConfig: tlsConfig,
 	}
 	go func() {
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsConfig != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			fmt.Fprintf(os.Stderr, "HTTP server error: %v\n", err)
 			os.Exit(1)
 		}
 	}()
 
+	// Keyboard shortcuts, as in Vite. q shuts down as Ctrl+C does.
+	quit := make(chan struct{}, 1)
+	if info.shortcuts = shortcutsEnabled(); info.shortcuts {
+		go readShortcuts(os.Stdin, []shortcut{
+			{"r", "force a rebuild", func() {
+				fmt.Printf("  \033[2mrebuilding...\033[0m\n")
+				ctx.Rebuild()
+			}},
+			{"o", "open in the browser", func() {
+				url := common.OpenURL(info.origin, info.open)
+				if err := common.OpenBrowser(url); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to open %s: %v\n", url, err)
+				}
+			}},
+			{"c", "clear the console", func() { fmt.Print("\033[H\033[2J") }},
+			{"q", "quit", func() {
+				select {
+				case quit <- struct{}{}:
+				default:
+				}
+			}},
+		})
+	}
+
 	// Start watching for file changes — triggers initial build which
 	// prints the branding line and URL block via the build timer plugin.
 	if err := ctx.Watch(api.WatchOptions{}); err != nil {
 		return fmt.Errorf("esbuild watch failed: %v", err)
 	}
 
-	// Block until Ctrl+C
+	// Block until Ctrl+C or q
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	select {
+	case <-sigCh:
+	case <-quit:
+	}
 
 	fmt.Println("\nShutting down...")
 	ctx.Dispose()
@@ -0,0 +1,59 @@
+package dev
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// shortcut is a key the dev server acts on while it runs, typed then Enter,
+// as in Vite.
+type shortcut struct {
+	key    string
+	desc   string
+	action func()
+}
+
+// shortcutsEnabled reports whether to read shortcuts from stdin: only from
+// an interactive terminal, and not on CI, where nothing types them.
+func shortcutsEnabled() bool {
+	if os.Getenv("CI") != "" {
+		return false
+	}
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// readShortcuts runs shortcuts as their keys are entered on in, until it's
+// closed. h, or a key that isn't one, lists them.
+func readShortcuts(in io.Reader, shortcuts []shortcut) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		key := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if key == "" {
+			continue
+		}
+		found := false
+		for _, sc := range shortcuts {
+			if sc.key == key {
+				sc.action()
+				found = true
+				break
+			}
+		}
+		if !found {
+			printShortcuts(shortcuts)
+		}
+	}
+}
+
+// printShortcuts lists the shortcuts.
+func printShortcuts(shortcuts []shortcut) {
+	fmt.Printf("\n  \033[1mShortcuts\033[0m\n")
+	for _, sc := range shortcuts {
+		fmt.Printf("  \033[2mpress\033[0m \033[1m%s + enter\033[0m \033[2mto %s\033[0m\n", sc.key, sc.desc)
+	}
+	fmt.Printf("  \033[2mpress\033[0m \033[1mh + enter\033[0m \033[2mto show help\033[0m\n\n")
+}
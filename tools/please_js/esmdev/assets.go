@@ -1,6 +1,11 @@
 package esmdev
 
 import (
+	"encoding/base64"
+	"mime"
+	"net/url"
+	"path/filepath"
+
 	"github.com/evanw/esbuild/pkg/api"
 
 	"tools/please_js/common"
@@ -49,3 +54,27 @@ var textExts = func() map[string]bool {
 func isTextExt(ext string) bool {
 	return textExts[ext]
 }
+
+// importQueries are the Vite-style import suffixes served by handleQueryImport.
+var importQueries = []string{"raw", "url", "inline"}
+
+// importQuery returns the import query suffix (raw, url or inline) present
+// in a request's query string, or "" if there is none.
+func importQuery(query url.Values) string {
+	for _, q := range importQueries {
+		if query.Has(q) {
+			return q
+		}
+	}
+	return ""
+}
+
+// dataURL encodes file contents as a base64 data URL, using the file
+// extension to pick the MIME type.
+func dataURL(filePath string, data []byte) string {
+	mimeType := mime.TypeByExtension(filepath.Ext(filePath))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
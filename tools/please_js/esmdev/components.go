@@ -0,0 +1,147 @@
+package esmdev
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Component and hook detection for React Fast Refresh. The patterns run over
+// esbuild's transformed output rather than the source: esbuild's printer puts
+// every top-level statement at the start of a line and indents everything
+// nested, drops ordinary comments and escapes newlines in string literals, so a
+// declaration at column 0 is a top-level declaration. (Multi-line template
+// literals are the one place column-0 text isn't code.) It also lowers
+// default exports, TypeScript namespaces and JSX to plain bindings, IIFEs
+// and factory calls, which is what the patterns below match.
+var (
+	// function App(   export default function App(   async function* App(
+	topLevelFuncRe = regexp.MustCompile(`(?m)^(?:export (?:default )?)?(?:async )?function\*? ?([\w$]+)\(`)
+	// const App = <value>   export let App = <value>
+	topLevelBindingRe = regexp.MustCompile(`(?m)^(?:export )?(?:const|let|var) ([\w$]+) = (.*)$`)
+	// function () / (a, b) => / a => / memo( / React.forwardRef(
+	functionValueRe = regexp.MustCompile(`^(?:async )?(?:function\b|[\w$]+ =>|\(.*\) =>|(?:[\w$]+\.)?(?:memo|forwardRef|lazy|observer)\()`)
+	// "  card_default as default" in the trailing export { ... } clause
+	defaultExportRe = regexp.MustCompile(`(?m)^  ([\w$]+) as default,?$`)
+	// ((UI2) => { ... })(UI || (UI = {})); for a top-level namespace UI
+	namespaceRe = regexp.MustCompile(`(?ms)^\(\(([\w$]+)\) => \{$(.*?)^\}\)\(([\w$]+) \|\|`)
+	// function Button(   inside a namespace body
+	namespaceFuncRe = regexp.MustCompile(`(?m)^  (?:async )?function\*? ?([\w$]+)\(`)
+	// UI2.Button = Button;   inside a namespace body
+	namespaceMemberRe = regexp.MustCompile(`(?m)^  ([\w$]+)\.([\w$]+) = (.*)$`)
+	// createContext(   React.createContext(
+	contextValueRe = regexp.MustCompile(`^(?:[\w$]+\.)?createContext\(`)
+	// jsx(   jsxs(   jsxDEV(   React.createElement(
+	jsxCallRe = regexp.MustCompile(`\b(?:jsxs?|jsxDEV|createElement)\(`)
+)
+
+// isComponentName reports whether a binding name follows React's component
+// naming convention (leading capital letter).
+func isComponentName(name string) bool {
+	return name != "" && name[0] >= 'A' && name[0] <= 'Z'
+}
+
+// isHookName reports whether a binding name follows React's custom hook
+// naming convention ("use" followed by a capital letter).
+func isHookName(name string) bool {
+	return len(name) > 3 && strings.HasPrefix(name, "use") && name[3] >= 'A' && name[3] <= 'Z'
+}
+
+// topLevelFunctions returns the top-level function declarations and
+// function-valued bindings in code, in declaration order.
+func topLevelFunctions(code string) []string {
+	type decl struct {
+		pos  int
+		name string
+	}
+	var decls []decl
+	for _, m := range topLevelFuncRe.FindAllStringSubmatchIndex(code, -1) {
+		decls = append(decls, decl{m[0], code[m[2]:m[3]]})
+	}
+	for _, m := range topLevelBindingRe.FindAllStringSubmatchIndex(code, -1) {
+		if functionValueRe.MatchString(code[m[4]:m[5]]) {
+			decls = append(decls, decl{m[0], code[m[2]:m[3]]})
+		}
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].pos < decls[j].pos })
+
+	seen := map[string]bool{}
+	var names []string
+	for _, d := range decls {
+		if !seen[d.name] {
+			seen[d.name] = true
+			names = append(names, d.name)
+		}
+	}
+	return names
+}
+
+// detectComponents returns the names of likely React components in
+// esbuild-transformed JS, in declaration order. It recognises:
+//   - top-level functions and function-valued bindings with capitalised
+//     names, including memo()/forwardRef() wrappers
+//   - the default export, whatever esbuild named its binding, if it is a
+//     function and the module renders JSX
+//   - members of top-level TypeScript namespaces, returned as "NS.Member"
+//
+// esbuild's Go API exposes no syntax tree, and its metafile lists a module's
+// exports but not what they are, so this matches the printed output, as
+// described above. Beyond column 0 meaning top level, it relies on nesting
+// being indented two spaces per level, one statement per line, single
+// spaces around = and after keywords, the default export named on a line of
+// its own in a trailing export { ... } clause, and namespace members
+// assigned as "  NS2.Member = ...". An esbuild upgrade that changes any of
+// these needs the patterns updating; hmr_test.go covers each.
+func detectComponents(code string) []string {
+	var defaultExport string
+	if m := defaultExportRe.FindStringSubmatch(code); m != nil && jsxCallRe.MatchString(code) {
+		defaultExport = m[1]
+	}
+	var names []string
+	for _, name := range topLevelFunctions(code) {
+		if isComponentName(name) || name == defaultExport {
+			names = append(names, name)
+		}
+	}
+	for _, m := range namespaceRe.FindAllStringSubmatch(code, -1) {
+		param, body, ns := m[1], m[2], m[3]
+		funcs := map[string]bool{}
+		for _, f := range namespaceFuncRe.FindAllStringSubmatch(body, -1) {
+			funcs[f[1]] = true
+		}
+		for _, member := range namespaceMemberRe.FindAllStringSubmatch(body, -1) {
+			name, value := member[2], member[3]
+			if member[1] == param && isComponentName(name) && (funcs[strings.TrimSuffix(value, ";")] || functionValueRe.MatchString(value)) {
+				names = append(names, ns+"."+name)
+			}
+		}
+	}
+	return names
+}
+
+// detectHooks returns the names of custom hooks declared at the top level of
+// esbuild-transformed JS, in declaration order.
+func detectHooks(code string) []string {
+	var names []string
+	for _, name := range topLevelFunctions(code) {
+		if isHookName(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isHookModule reports whether esbuild-transformed JS declares custom hooks
+// or React contexts at the top level. Modules that do so without declaring
+// components can be hot-updated by re-importing the components using them.
+func isHookModule(code string) bool {
+	if len(detectHooks(code)) > 0 {
+		return true
+	}
+	for _, m := range topLevelBindingRe.FindAllStringSubmatch(code, -1) {
+		if contextValueRe.MatchString(m[2]) {
+			return true
+		}
+	}
+	return false
+}
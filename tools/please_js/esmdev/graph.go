@@ -0,0 +1,194 @@
+package esmdev
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// moduleGraph records which local source files import which, and a version
+// for every module that has been hot-updated. The zero value is ready to use.
+//
+// Browsers cache ES modules by URL, so re-importing a component picks up the
+// old instance of any hook module it imports. Importers of an updated module
+// are therefore served with "?t=<version>" appended to that import, which
+// makes the browser fetch and evaluate the new copy.
+type moduleGraph struct {
+	mu        sync.Mutex
+	imports   map[string][]string        // importer → local deps
+	importers map[string]map[string]bool // dep → importers
+	versions  map[string]int64           // module → last hot-update version
+}
+
+// setImports replaces the recorded local dependencies of importer.
+func (g *moduleGraph) setImports(importer string, deps []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.importers == nil {
+		g.imports = make(map[string][]string)
+		g.importers = make(map[string]map[string]bool)
+	}
+	for _, dep := range g.imports[importer] {
+		delete(g.importers[dep], importer)
+	}
+	g.imports[importer] = deps
+	for _, dep := range deps {
+		if g.importers[dep] == nil {
+			g.importers[dep] = make(map[string]bool)
+		}
+		g.importers[dep][importer] = true
+	}
+}
+
+// importersOf returns the modules that import path, sorted.
+func (g *moduleGraph) importersOf(path string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var result []string
+	for imp := range g.importers[path] {
+		result = append(result, imp)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// allImports returns a copy of every importer's recorded local deps.
+func (g *moduleGraph) allImports() map[string][]string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	result := make(map[string][]string, len(g.imports))
+	for importer, deps := range g.imports {
+		result[importer] = append([]string(nil), deps...)
+	}
+	return result
+}
+
+// remove drops a deleted module and its outgoing edges from the graph.
+func (g *moduleGraph) remove(path string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, dep := range g.imports[path] {
+		delete(g.importers[dep], path)
+	}
+	delete(g.imports, path)
+	delete(g.versions, path)
+}
+
+// bump records a new hot-update version for path.
+func (g *moduleGraph) bump(path string, version int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.versions == nil {
+		g.versions = make(map[string]int64)
+	}
+	g.versions[path] = version
+}
+
+// version returns the last hot-update version of path, or 0 if it has never
+// been hot-updated.
+func (g *moduleGraph) version(path string) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.versions[path]
+}
+
+// trackImports records the local imports of a transformed module in the
+// graph and appends "?t=<version>" to imports of hot-updated modules.
+// Bare specifiers (packages, local libraries) are resolved through the import
+// map and are never hot-updated, so they are left alone.
+func (s *esmServer) trackImports(resolved string, code []byte) []byte {
+	var deps []string
+	out := importSpecRe.ReplaceAllStringFunc(string(code), func(match string) string {
+		spec := importSpecRe.FindStringSubmatch(match)[1]
+		if strings.Contains(spec, "?") {
+			return match
+		}
+		var dep string
+		switch {
+		case strings.HasPrefix(spec, "."):
+			dep = resolveSourceFile(filepath.Dir(resolved), spec)
+		case strings.HasPrefix(spec, "/") && !strings.HasPrefix(spec, "/@"):
+			dep = resolveSourceFile(s.packageRoot, spec)
+			if dep == "" && s.packageRoot != s.sourceRoot {
+				dep = resolveSourceFile(s.sourceRoot, spec)
+			}
+		}
+		if dep == "" {
+			return match
+		}
+		deps = append(deps, dep)
+		s.watchPath(dep)
+		if v := s.graph.version(dep); v > 0 {
+			versioned := spec + "?t=" + strconv.FormatInt(v, 10)
+			return strings.Replace(match, spec, versioned, 1)
+		}
+		return match
+	})
+	s.graph.setImports(resolved, deps)
+	return []byte(out)
+}
+
+// propagateUpdate walks up the import graph from a changed hook or context
+// module to the nearest component modules, which can accept the update by
+// re-importing themselves. It bumps the version of every module on the way
+// and evicts their importers from the transform cache so the new versions
+// are picked up. Returns the URL paths of the component boundaries, or false
+// if some importer can't accept the update (the entry file, or a module that
+// is neither a component nor a hook/context module) and the page must reload.
+func (s *esmServer) propagateUpdate(path string, version int64) ([]string, bool) {
+	var boundaries []string
+	var chain []string
+	visited := map[string]bool{path: true}
+	queue := []string{path}
+
+	for len(queue) > 0 {
+		mod := queue[0]
+		queue = queue[1:]
+		chain = append(chain, mod)
+
+		importers := s.graph.importersOf(mod)
+		if len(importers) == 0 {
+			return nil, false
+		}
+		for _, imp := range importers {
+			if visited[imp] {
+				continue
+			}
+			visited[imp] = true
+			urlPath := s.urlPathFor(imp)
+			if urlPath == "" || urlPath == s.entryURLPath {
+				return nil, false
+			}
+			if isComp, ok := s.componentFiles.Load(imp); ok && isComp.(bool) {
+				boundaries = append(boundaries, urlPath)
+				continue
+			}
+			if isHook, ok := s.hookFiles.Load(imp); ok && isHook.(bool) {
+				queue = append(queue, imp)
+				continue
+			}
+			return nil, false
+		}
+	}
+
+	for _, mod := range chain {
+		s.graph.bump(mod, version)
+		for _, imp := range s.graph.importersOf(mod) {
+			s.transCache.Delete(imp)
+		}
+	}
+	sort.Strings(boundaries)
+	return boundaries, true
+}
+
+// urlPathFor maps an absolute source path to the URL the browser imports it
+// by, or "" if the file is outside packageRoot and all local libraries.
+func (s *esmServer) urlPathFor(path string) string {
+	rel, err := filepath.Rel(s.packageRoot, path)
+	if err == nil && !strings.HasPrefix(rel, "..") {
+		return "/" + filepath.ToSlash(rel)
+	}
+	return s.libURLPath(path)
+}
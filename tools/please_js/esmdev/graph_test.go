@@ -0,0 +1,203 @@
+package esmdev
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTrackImports(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/useCounter.ts": "export function useCounter() {}",
+		"src/theme.ts":      "export const theme = {};",
+		"src/App.tsx":       "",
+	})
+	srv := &esmServer{sourceRoot: dir, packageRoot: dir}
+	app := filepath.Join(dir, "src/App.tsx")
+	hook := filepath.Join(dir, "src/useCounter.ts")
+	theme := filepath.Join(dir, "src/theme.ts")
+
+	code := []byte(`import { useCounter } from "./useCounter";` + "\n" +
+		`import { theme } from "/src/theme.ts";` + "\n" +
+		`import React from "react";` + "\n" +
+		`import logo from "./logo.svg?url";` + "\n")
+
+	out := string(srv.trackImports(app, code))
+	if out != string(code) {
+		t.Errorf("expected code unchanged before any hot update, got:\n%s", out)
+	}
+	for _, dep := range []string{hook, theme} {
+		if got := srv.graph.importersOf(dep); len(got) != 1 || got[0] != app {
+			t.Errorf("importersOf(%s) = %v, want [%s]", filepath.Base(dep), got, app)
+		}
+	}
+
+	srv.graph.bump(hook, 42)
+	out = string(srv.trackImports(app, code))
+	if !strings.Contains(out, `from "./useCounter?t=42"`) {
+		t.Errorf("expected versioned hook import, got:\n%s", out)
+	}
+	if !strings.Contains(out, `from "/src/theme.ts"`) || !strings.Contains(out, `from "react"`) {
+		t.Errorf("expected other imports unchanged, got:\n%s", out)
+	}
+
+	// Re-tracking with fewer imports drops stale edges.
+	srv.trackImports(app, []byte(`import { theme } from "/src/theme.ts";`))
+	if got := srv.graph.importersOf(hook); len(got) != 0 {
+		t.Errorf("expected no importers of hook after re-track, got %v", got)
+	}
+}
+
+func TestPropagateUpdate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/main.tsx":      "",
+		"src/App.tsx":       "",
+		"src/Counter.tsx":   "",
+		"src/useCounter.ts": "",
+		"src/useStore.ts":   "",
+		"src/config.ts":     "",
+	})
+	path := func(name string) string { return filepath.Join(dir, "src", name) }
+
+	newServer := func() *esmServer {
+		srv := &esmServer{sourceRoot: dir, packageRoot: dir, entryURLPath: "/src/main.tsx"}
+		srv.componentFiles.Store(path("App.tsx"), true)
+		srv.componentFiles.Store(path("Counter.tsx"), true)
+		srv.hookFiles.Store(path("useCounter.ts"), true)
+		srv.hookFiles.Store(path("useStore.ts"), true)
+		srv.hookFiles.Store(path("config.ts"), false)
+		// main → App → Counter → useCounter → useStore
+		srv.graph.setImports(path("main.tsx"), []string{path("App.tsx")})
+		srv.graph.setImports(path("App.tsx"), []string{path("Counter.tsx"), path("useStore.ts")})
+		srv.graph.setImports(path("Counter.tsx"), []string{path("useCounter.ts")})
+		srv.graph.setImports(path("useCounter.ts"), []string{path("useStore.ts")})
+		return srv
+	}
+
+	t.Run("hook used by component", func(t *testing.T) {
+		srv := newServer()
+		got, ok := srv.propagateUpdate(path("useCounter.ts"), 7)
+		if !ok {
+			t.Fatal("expected update to be accepted")
+		}
+		if strings.Join(got, ",") != "/src/Counter.tsx" {
+			t.Errorf("boundaries = %v, want [/src/Counter.tsx]", got)
+		}
+		if v := srv.graph.version(path("useCounter.ts")); v != 7 {
+			t.Errorf("useCounter version = %d, want 7", v)
+		}
+	})
+
+	t.Run("hook chain reaches every component", func(t *testing.T) {
+		srv := newServer()
+		got, ok := srv.propagateUpdate(path("useStore.ts"), 8)
+		if !ok {
+			t.Fatal("expected update to be accepted")
+		}
+		if strings.Join(got, ",") != "/src/App.tsx,/src/Counter.tsx" {
+			t.Errorf("boundaries = %v, want [/src/App.tsx /src/Counter.tsx]", got)
+		}
+		for _, mod := range []string{"useStore.ts", "useCounter.ts"} {
+			if v := srv.graph.version(path(mod)); v != 8 {
+				t.Errorf("%s version = %d, want 8", mod, v)
+			}
+		}
+	})
+
+	t.Run("evicts importers from the transform cache", func(t *testing.T) {
+		srv := newServer()
+		srv.transCache.Store(path("Counter.tsx"), &transformEntry{})
+		srv.transCache.Store(path("App.tsx"), &transformEntry{})
+		srv.propagateUpdate(path("useCounter.ts"), 9)
+		if _, ok := srv.transCache.Load(path("Counter.tsx")); ok {
+			t.Error("expected Counter.tsx to be evicted")
+		}
+		if _, ok := srv.transCache.Load(path("App.tsx")); !ok {
+			t.Error("expected App.tsx to stay cached")
+		}
+	})
+
+	t.Run("plain module importer forces reload", func(t *testing.T) {
+		srv := newServer()
+		srv.graph.setImports(path("config.ts"), []string{path("useStore.ts")})
+		if _, ok := srv.propagateUpdate(path("useStore.ts"), 10); ok {
+			t.Error("expected full reload when a plain module imports the hook")
+		}
+		if v := srv.graph.version(path("useStore.ts")); v != 0 {
+			t.Errorf("expected no version bump on rejected update, got %d", v)
+		}
+	})
+
+	t.Run("entry importer forces reload", func(t *testing.T) {
+		srv := newServer()
+		srv.graph.setImports(path("main.tsx"), []string{path("App.tsx"), path("useStore.ts")})
+		if _, ok := srv.propagateUpdate(path("useStore.ts"), 11); ok {
+			t.Error("expected full reload when the entry imports the hook")
+		}
+	})
+
+	t.Run("unimported module forces reload", func(t *testing.T) {
+		srv := newServer()
+		if _, ok := srv.propagateUpdate(path("config.ts"), 12); ok {
+			t.Error("expected full reload for a module with no known importers")
+		}
+	})
+}
+
+func TestForgetModule(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/App.tsx":    "",
+		"src/Header.tsx": "",
+	})
+	srv := &esmServer{sourceRoot: dir, packageRoot: dir}
+	app := filepath.Join(dir, "src/App.tsx")
+	header := filepath.Join(dir, "src/Header.tsx")
+
+	srv.trackImports(app, []byte(`import { Header } from "./Header";`))
+	srv.trackImports(header, []byte(`import { App } from "./App";`))
+	srv.transCache.Store(app, &transformEntry{})
+	srv.transCache.Store(header, &transformEntry{})
+	srv.componentFiles.Store(header, true)
+	srv.graph.bump(header, 7)
+
+	importers := srv.forgetModule(header)
+	if len(importers) != 1 || importers[0] != app {
+		t.Errorf("forgetModule() = %v, want [%s]", importers, app)
+	}
+	for _, path := range []string{app, header} {
+		if _, ok := srv.transCache.Load(path); ok {
+			t.Errorf("expected %s evicted from transCache", filepath.Base(path))
+		}
+	}
+	if _, ok := srv.componentFiles.Load(header); ok {
+		t.Error("expected component classification dropped")
+	}
+	if srv.graph.version(header) != 0 {
+		t.Error("expected hot-update version dropped")
+	}
+	if got := srv.graph.importersOf(app); len(got) != 0 {
+		t.Errorf("expected deleted module's own imports dropped, got %v", got)
+	}
+	// Edges into the deleted module survive so a recreated file is seen as
+	// imported.
+	if got := srv.graph.importersOf(header); len(got) != 1 || got[0] != app {
+		t.Errorf("importersOf(Header) = %v, want [%s]", got, app)
+	}
+}
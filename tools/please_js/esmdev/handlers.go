@@ -29,7 +29,10 @@ func (s *esmServer) handleHTML(w http.ResponseWriter, r *http.Request, start tim
 		return
 	}
 
-	html := rewriteHTML(string(data), s.importMapJSON, s.hasRefresh, s.entryURLPath, s.sourceRoot, s.packageRoot)
+	// Placeholders are filled in before the import map and client scripts
+	// go in, so only the page's own markup is substituted.
+	html := common.ReplaceEnvPlaceholders(string(data), s.define)
+	html = rewriteHTML(html, s.importMapJSON, s.hasRefresh, s.entryURLPath, s.sourceRoot, s.packageRoot)
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -66,6 +69,7 @@ func (s *esmServer) handleSource(w http.ResponseWriter, r *http.Request, urlPath
 		http.NotFound(w, r)
 		return
 	}
+	s.watchPath(resolved)
 
 	if cached, ok := s.transCache.Load(resolved); ok {
 		entry := cached.(*transformEntry)
@@ -114,15 +118,23 @@ func (s *esmServer) handleSource(w http.ResponseWriter, r *http.Request, urlPath
 		w.WriteHeader(http.StatusOK) // Browser needs 200 to execute the error reporter
 		w.Write([]byte(errJS))
 		fmt.Printf("  \033[31m[error] %s %s: %s\033[0m\n", r.Method, urlPath, errMsg)
+		s.status.SetMessages(resolved, common.StatusMessages(result.Errors), nil)
 		return
 	}
+	s.status.SetMessages(resolved, nil, nil)
 
-	code := result.Code
+	code := rewriteWorkerRefs(result.Code, urlPath)
+
+	// Track local imports so hook/context edits can propagate to components.
+	if s.hasRefresh {
+		code = s.trackImports(resolved, code)
+	}
 
 	// Inject React Fast Refresh registration if enabled and not the entry file.
 	if s.hasRefresh && urlPath != s.entryURLPath {
 		components := detectComponents(string(code))
 		s.componentFiles.Store(resolved, len(components) > 0)
+		s.hookFiles.Store(resolved, len(components) == 0 && isHookModule(string(code)))
 		if len(components) > 0 {
 			code = injectRefreshRegistration(code, urlPath, components)
 		}
@@ -141,14 +153,9 @@ func (s *esmServer) handleSource(w http.ResponseWriter, r *http.Request, urlPath
 		r.Method, urlPath, time.Since(start).Milliseconds())
 }
 
-// handleLibSource serves local js_library source files via /@lib/ URLs.
-// Strips the /@lib/ prefix, finds the matching library by longest-prefix match,
-// resolves the file, and on-demand transforms it (same as handleSource).
-func (s *esmServer) handleLibSource(w http.ResponseWriter, r *http.Request, urlPath string, start time.Time) {
-	// Strip /@lib/ prefix: "/@lib/common/js/ui/Spinner.tsx" → "common/js/ui/Spinner.tsx"
-	specPath := strings.TrimPrefix(urlPath, "/@lib/")
-
-	// Find matching library by longest-prefix match
+// matchLocalLib finds the local library a /@lib/ specifier path belongs to
+// by longest-prefix match, returning its module name and directory.
+func (s *esmServer) matchLocalLib(specPath string) (string, string) {
 	bestLib := ""
 	bestDir := ""
 	for name, dir := range s.localLibs {
@@ -159,6 +166,17 @@ func (s *esmServer) handleLibSource(w http.ResponseWriter, r *http.Request, urlP
 			}
 		}
 	}
+	return bestLib, bestDir
+}
+
+// handleLibSource serves local js_library source files via /@lib/ URLs.
+// Strips the /@lib/ prefix, finds the matching library by longest-prefix match,
+// resolves the file, and on-demand transforms it (same as handleSource).
+func (s *esmServer) handleLibSource(w http.ResponseWriter, r *http.Request, urlPath string, start time.Time) {
+	// Strip /@lib/ prefix: "/@lib/common/js/ui/Spinner.tsx" → "common/js/ui/Spinner.tsx"
+	specPath := strings.TrimPrefix(urlPath, "/@lib/")
+
+	bestLib, bestDir := s.matchLocalLib(specPath)
 	if bestLib == "" {
 		http.NotFound(w, r)
 		fmt.Printf("  \033[2m[lib] %s %s → 404 no matching lib (%dms)\033[0m\n",
@@ -197,6 +215,7 @@ func (s *esmServer) handleLibSource(w http.ResponseWriter, r *http.Request, urlP
 		http.NotFound(w, r)
 		return
 	}
+	s.watchPath(resolved)
 
 	if cached, ok := s.transCache.Load(resolved); ok {
 		entry := cached.(*transformEntry)
@@ -243,15 +262,19 @@ func (s *esmServer) handleLibSource(w http.ResponseWriter, r *http.Request, urlP
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(errJS))
 		fmt.Printf("  \033[31m[error] %s %s: %s\033[0m\n", r.Method, urlPath, errMsg)
+		s.status.SetMessages(resolved, common.StatusMessages(result.Errors), nil)
 		return
 	}
+	s.status.SetMessages(resolved, nil, nil)
 
-	code := result.Code
+	code := rewriteWorkerRefs(result.Code, urlPath)
 
 	// Inject React Fast Refresh registration if enabled.
 	if s.hasRefresh {
+		code = s.trackImports(resolved, code)
 		components := detectComponents(string(code))
 		s.componentFiles.Store(resolved, len(components) > 0)
+		s.hookFiles.Store(resolved, len(components) == 0 && isHookModule(string(code)))
 		if len(components) > 0 {
 			code = injectRefreshRegistration(code, urlPath, components)
 		}
@@ -280,11 +303,12 @@ func (s *esmServer) handleCSSModule(w http.ResponseWriter, r *http.Request, urlP
 		http.NotFound(w, r)
 		return
 	}
+	s.watchPath(filePath)
 
 	cssContent := string(data)
 
-	// Process through Tailwind if configured and file has @tailwind directives
-	if s.tailwindBin != "" && strings.Contains(cssContent, "@tailwind") {
+	// Process through Tailwind if configured and file has Tailwind directives
+	if s.tailwindBin != "" && common.IsTailwindCSS(data) {
 		compiled, err := s.compileTailwind(filePath)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "  tailwind error: %v\n", err)
@@ -319,6 +343,7 @@ func (s *esmServer) handleTextModule(w http.ResponseWriter, r *http.Request, url
 		http.NotFound(w, r)
 		return
 	}
+	s.watchPath(filePath)
 	textJSON, _ := json.Marshal(string(data))
 	js := fmt.Sprintf("export default %s;\n", string(textJSON))
 	w.Header().Set("Content-Type", "application/javascript")
@@ -337,6 +362,52 @@ func (s *esmServer) handleAssetModule(w http.ResponseWriter, r *http.Request, ur
 		r.Method, urlPath, time.Since(start).Milliseconds())
 }
 
+// handleQueryImport serves Vite-style query imports as JS modules: ?raw
+// exports the file contents as a string, ?url exports the file's URL and
+// ?inline exports a data URL (or the compiled stylesheet text for CSS).
+func (s *esmServer) handleQueryImport(w http.ResponseWriter, r *http.Request, urlPath, query string, start time.Time) {
+	var js string
+	if query == "url" {
+		js = fmt.Sprintf(assetModuleTemplate, urlPath)
+	} else {
+		filePath := filepath.Join(s.packageRoot, filepath.FromSlash(urlPath))
+		if _, err := os.Stat(filePath); err != nil && s.packageRoot != s.sourceRoot {
+			filePath = filepath.Join(s.sourceRoot, filepath.FromSlash(urlPath))
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		s.watchPath(filePath)
+
+		var value string
+		switch {
+		case query == "raw":
+			value = string(data)
+		case filepath.Ext(filePath) == ".css":
+			value = string(data)
+			if s.tailwindBin != "" && common.IsTailwindCSS(data) {
+				if compiled, err := s.compileTailwind(filePath); err != nil {
+					fmt.Fprintf(os.Stderr, "  tailwind error: %v\n", err)
+				} else {
+					value = compiled
+				}
+			}
+		default:
+			value = dataURL(filePath, data)
+		}
+		valueJSON, _ := json.Marshal(value)
+		js = fmt.Sprintf("export default %s;\n", string(valueJSON))
+	}
+
+	w.Header().Set("Content-Type", "application/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(js))
+	fmt.Printf("  \033[2m[%s-module] %s %s → 200 (%dms)\033[0m\n",
+		query, r.Method, urlPath, time.Since(start).Milliseconds())
+}
+
 // handleDepOnDemand lazily bundles a dependency subpath that wasn't pre-bundled.
 // This handles requests resolved via prefix import map entries (e.g.,
 // "use-sync-external-store/shim/with-selector.js" → "/@deps/use-sync-external-store/shim/with-selector.js").
@@ -170,6 +170,62 @@ func TestHandleTextModule_NotFound(t *testing.T) {
 	}
 }
 
+func TestHandleQueryImport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"worker.ts": "export const n: number = 1;",
+		"logo.svg":  "<svg/>",
+		"theme.css": "body { color: red; }",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := &esmServer{
+		sourceRoot:  dir,
+		packageRoot: dir,
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		// ?raw on a source file must bypass the TS transform.
+		{"/worker.ts?raw", `export default "export const n: number = 1;";`},
+		{"/logo.svg?url", `export default "/logo.svg";`},
+		{"/logo.svg?inline", `export default "data:image/svg+xml;base64,PHN2Zy8+";`},
+		{"/theme.css?inline", `export default "body { color: red; }";`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/javascript" {
+				t.Errorf("expected Content-Type application/javascript, got %q", ct)
+			}
+			if body := strings.TrimSpace(rec.Body.String()); body != tt.want {
+				t.Errorf("body = %q, want %q", body, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/missing.txt?raw", nil)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", rec.Code)
+		}
+	})
+}
+
 // TestHandleDepOnDemand_CJSFunctionSubpath tests the runtime on-demand handler
 // for a CJS package subpath with `module.exports = function(){}`.
 func TestHandleDepOnDemand_CJSFunctionSubpath(t *testing.T) {
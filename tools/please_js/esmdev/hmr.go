@@ -4,48 +4,30 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
 	"time"
+
+	"tools/please_js/common"
 )
 
 // sseEvent is sent to clients when files change.
 type sseEvent struct {
-	Type  string   `json:"type"`
-	Files []string `json:"files,omitempty"`
-}
-
-// Component detection regexes for React Fast Refresh.
-var (
-	// function App(   or   export default function App(   or   export function App(
-	funcComponentRe = regexp.MustCompile(`(?m)^(?:export\s+(?:default\s+)?)?function\s+([A-Z][a-zA-Z0-9_]*)\s*\(`)
-	// const App =   or   export const App =   followed by arrow/function
-	constComponentRe = regexp.MustCompile(`(?m)^(?:export\s+)?(?:const|let|var)\s+([A-Z][a-zA-Z0-9_]*)\s*=`)
-)
-
-// detectComponents returns the names of likely React components in transformed JS.
-func detectComponents(code string) []string {
-	seen := map[string]bool{}
-	var names []string
-	for _, m := range funcComponentRe.FindAllStringSubmatch(code, -1) {
-		if !seen[m[1]] {
-			names = append(names, m[1])
-			seen[m[1]] = true
-		}
-	}
-	for _, m := range constComponentRe.FindAllStringSubmatch(code, -1) {
-		if !seen[m[1]] {
-			names = append(names, m[1])
-			seen[m[1]] = true
-		}
-	}
-	return names
+	Type    string   `json:"type"`
+	Files   []string `json:"files,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Build   int64    `json:"build,omitempty"` // update ID, as in the server log
+	Time    string   `json:"time,omitempty"`  // when the change was seen, HH:MM:SS.mmm
 }
 
 // injectRefreshRegistration wraps transformed JS code with React Fast Refresh
 // registration calls for the given component names.
+//
+// The preamble shifts every line of the original code down, so the inline
+// source map esbuild produced is offset by the preamble's line count and
+// re-emitted after the footer. Without this, breakpoints land several lines
+// away from the source line they were set on.
 func injectRefreshRegistration(code []byte, urlPath string, components []string) []byte {
 	var buf strings.Builder
 
@@ -59,10 +41,14 @@ func injectRefreshRegistration(code []byte, urlPath string, components []string)
 	buf.WriteString(fmt.Sprintf("%q", urlPath+" "))
 	buf.WriteString(" + id);\n")
 	buf.WriteString("window.$RefreshSig$ = window.__REACT_REFRESH__?.createSignatureFunctionForTransform || (() => (t) => t);\n")
+	preambleLines := strings.Count(buf.String(), "\n")
 
-	// Original code
-	buf.Write(code)
-	buf.WriteString("\n")
+	// Original code, with its inline source map held back until the end
+	body, sourceMap := splitInlineSourceMap(code)
+	buf.Write(body)
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		buf.WriteString("\n")
+	}
 
 	// Footer: register components, then restore hooks
 	for _, name := range components {
@@ -72,6 +58,12 @@ func injectRefreshRegistration(code []byte, urlPath string, components []string)
 	buf.WriteString("window.$RefreshSig$ = __prevSig;\n")
 	buf.WriteString("import.meta.hot?.accept();\n")
 
+	if sourceMap != nil {
+		if shifted, err := offsetSourceMap(sourceMap, preambleLines); err == nil {
+			buf.WriteString(inlineSourceMapComment(shifted))
+		}
+	}
+
 	return []byte(buf.String())
 }
 
@@ -87,7 +79,35 @@ func (s *esmServer) broadcast(evt sseEvent) {
 	s.sseMu.Unlock()
 }
 
+// announce logs an update and broadcasts it to clients, stamped with the
+// update it belongs to.
+func (s *esmServer) announce(stamp common.BuildStamp, evt sseEvent, detail string) {
+	evt.Build, evt.Time = stamp.ID, stamp.Clock()
+	fmt.Printf("  \033[2m[%s %s]\033[0m %s\n", evt.Type, stamp, detail)
+	s.status.Record(stamp, 0)
+	s.broadcast(evt)
+}
+
+// missingImportsSource is the BuildStatus source of the errors for imports
+// of deleted files.
+const missingImportsSource = "deleted imports"
+
+// handleStatus serves the server's state at common.StatusURLPath.
+func (s *esmServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.sseMu.Lock()
+	clients := len(s.clients)
+	s.sseMu.Unlock()
+	deps := len(s.depCache)
+	s.onDemandDeps.Range(func(_, _ any) bool {
+		deps++
+		return true
+	})
+	s.status.Serve(w, "esm-dev", clients, deps)
+}
+
 // watchFiles polls the source tree for changes and broadcasts SSE events.
+// The events from one poll share a stamp, so a browser's console lines up
+// with the server log.
 func (s *esmServer) watchFiles() {
 	mtimes := make(map[string]time.Time)
 
@@ -98,6 +118,10 @@ func (s *esmServer) watchFiles() {
 	defer ticker.Stop()
 
 	for range ticker.C {
+		// Files in directories first watched since the last poll are already
+		// served; seed their mtimes so they aren't reported as new.
+		s.watched.drainBaseline(mtimes)
+
 		newMtimes := make(map[string]time.Time)
 		s.walkSourceTree(newMtimes)
 
@@ -119,7 +143,7 @@ func (s *esmServer) watchFiles() {
 			if changed {
 				s.clearTailwindCache()
 				mtimes = newMtimes
-				s.broadcast(sseEvent{Type: "change"})
+				s.announce(s.builds.Next(), sseEvent{Type: "change"}, "reloading")
 			}
 			continue
 		}
@@ -128,19 +152,18 @@ func (s *esmServer) watchFiles() {
 		var hmrFiles []string
 		var cssFiles []string
 		needFullReload := false
+		tailwindContentChanged := false
+		changed := false
 
 		for path, newMt := range newMtimes {
 			if oldMt, ok := mtimes[path]; !ok || !oldMt.Equal(newMt) {
+				changed = true
 				s.transCache.Delete(path)
-
-				rel, err := filepath.Rel(s.packageRoot, path)
-				var relPath string
-				if err == nil && !strings.HasPrefix(rel, "..") {
-					relPath = "/" + filepath.ToSlash(rel)
-				} else {
-					// Check if this file is in a local library directory
-					relPath = s.libURLPath(path)
+				if s.isTailwindContent(path) {
+					tailwindContentChanged = true
 				}
+
+				relPath := s.urlPathFor(path)
 				if relPath == "" {
 					needFullReload = true
 					continue
@@ -148,6 +171,11 @@ func (s *esmServer) watchFiles() {
 				ext := filepath.Ext(path)
 
 				switch {
+				case !ok && relPath != s.entryURLPath && (isSourceFileExt(ext) || ext == ".css") &&
+					len(s.graph.importersOf(path)) == 0:
+					// A new module nothing imports yet (e.g. the new name of
+					// a renamed file) can't affect the page until an importer
+					// changes to use it.
 				case ext == ".css":
 					cssFiles = append(cssFiles, relPath)
 				case relPath == s.entryURLPath:
@@ -155,6 +183,14 @@ func (s *esmServer) watchFiles() {
 				case isSourceFileExt(ext):
 					if isComp, ok := s.componentFiles.Load(path); ok && isComp.(bool) {
 						hmrFiles = append(hmrFiles, relPath)
+					} else if isHook, ok := s.hookFiles.Load(path); ok && isHook.(bool) {
+						// Hook/context modules can't accept updates themselves;
+						// re-import the components that use them instead.
+						if boundaries, ok := s.propagateUpdate(path, newMt.UnixMilli()); ok {
+							hmrFiles = append(hmrFiles, boundaries...)
+						} else {
+							needFullReload = true
+						}
 					} else {
 						needFullReload = true
 					}
@@ -163,65 +199,113 @@ func (s *esmServer) watchFiles() {
 				}
 			}
 		}
-		// Check for deleted files
+		// Deleted files only break the page if something still imports them.
+		// Report those imports instead of reloading into a blank page; the
+		// overlay clears once the importers are fixed and hot-updated.
+		var missing []string
 		for path := range mtimes {
-			if _, ok := newMtimes[path]; !ok {
-				s.transCache.Delete(path)
-				s.componentFiles.Delete(path)
+			if _, ok := newMtimes[path]; ok {
+				continue
+			}
+			changed = true
+			if s.isTailwindContent(path) {
+				tailwindContentChanged = true
+			}
+			importers := s.forgetModule(path)
+			relPath := s.urlPathFor(path)
+			ext := filepath.Ext(path)
+			switch {
+			case relPath == "" || relPath == s.entryURLPath || !(isSourceFileExt(ext) || ext == ".css"):
 				needFullReload = true
+			default:
+				for _, imp := range importers {
+					// Importers deleted or edited in the same poll (e.g. a
+					// refactoring tool renaming the file and its imports)
+					// are reloaded or hot-updated anyway.
+					if newMt, ok := newMtimes[imp]; !ok || !newMt.Equal(mtimes[imp]) {
+						continue
+					}
+					missing = append(missing, fmt.Sprintf("%s imports %s, which was deleted", s.urlPathFor(imp), relPath))
+				}
 			}
 		}
+		sort.Strings(missing)
+
+		// New classes in content files only show up once Tailwind recompiles,
+		// so push every Tailwind stylesheet alongside the module update.
+		if tailwindContentChanged {
+			cssFiles = append(cssFiles, s.tailwindCSSURLs()...)
+		}
 
+		mtimes = newMtimes
+		var stamp common.BuildStamp
+		if needFullReload || len(missing) > 0 || len(hmrFiles) > 0 || len(cssFiles) > 0 {
+			stamp = s.builds.Next()
+		}
 		if needFullReload {
 			s.clearTailwindCache()
-			mtimes = newMtimes
-			s.broadcast(sseEvent{Type: "full-reload"})
-		} else if len(hmrFiles) > 0 || len(cssFiles) > 0 {
+			s.announce(stamp, sseEvent{Type: "full-reload"}, "reloading")
+			continue
+		}
+		if changed {
 			s.clearTailwindCache()
-			mtimes = newMtimes
-			if len(hmrFiles) > 0 {
-				s.broadcast(sseEvent{Type: "hmr-update", Files: hmrFiles})
+			// Missing imports stand until a change that leaves none.
+			var msgs []common.StatusMessage
+			for _, msg := range missing {
+				msgs = append(msgs, common.StatusMessage{Text: msg})
 			}
-			if len(cssFiles) > 0 {
-				s.broadcast(sseEvent{Type: "css-update", Files: cssFiles})
+			s.status.SetMessages(missingImportsSource, msgs, nil)
+		}
+		if len(missing) > 0 {
+			s.status.Record(stamp, 0)
+			for _, msg := range missing {
+				fmt.Printf("  \033[31m[error %s] %s\033[0m\n", stamp, msg)
 			}
+			s.broadcast(sseEvent{Type: "import-error", Message: strings.Join(missing, "\n"), Build: stamp.ID, Time: stamp.Clock()})
+		}
+		if len(hmrFiles) > 0 {
+			files := dedupe(hmrFiles)
+			s.announce(stamp, sseEvent{Type: "hmr-update", Files: files}, strings.Join(files, ", "))
+		}
+		if len(cssFiles) > 0 {
+			files := dedupe(cssFiles)
+			s.announce(stamp, sseEvent{Type: "css-update", Files: files}, strings.Join(files, ", "))
 		}
 	}
 }
 
-// walkSourceTree collects file mtimes, skipping hidden dirs, node_modules, plz-out.
-// Walks packageRoot (which may be a parent of sourceRoot) and local library directories.
-func (s *esmServer) walkSourceTree(mtimes map[string]time.Time) {
-	walkDir := func(root string) {
-		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
-			if info.IsDir() {
-				name := info.Name()
-				if strings.HasPrefix(name, ".") || name == "node_modules" || name == "plz-out" {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-
-			ext := filepath.Ext(path)
-			switch ext {
-			case ".js", ".jsx", ".ts", ".tsx", ".mjs", ".css", ".html", ".json":
-				mtimes[path] = info.ModTime()
-			}
-			return nil
-		})
+// forgetModule drops every cached trace of a deleted module: its transform,
+// component/hook classification, Tailwind output and graph edges. Importers
+// are evicted from the transform cache too, so their next request is
+// transformed afresh rather than served with imports of a file that no
+// longer exists. Returns those importers. The graph keeps the edges into the
+// deleted module, so a file recreated at the same path is found by them.
+func (s *esmServer) forgetModule(path string) []string {
+	s.status.SetMessages(path, nil, nil)
+	s.transCache.Delete(path)
+	s.componentFiles.Delete(path)
+	s.hookFiles.Delete(path)
+	s.tailwindCache.Delete(path)
+	s.tailwindFiles.Delete(path)
+	importers := s.graph.importersOf(path)
+	for _, imp := range importers {
+		s.transCache.Delete(imp)
 	}
+	s.graph.remove(path)
+	return importers
+}
 
-	walkDir(s.packageRoot)
-	for _, libDir := range s.localLibs {
-		// Skip if libDir is already under packageRoot (would be walked already)
-		if strings.HasPrefix(libDir, s.packageRoot+"/") {
-			continue
+// dedupe removes repeated entries from a list, keeping the first occurrence.
+func dedupe(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	var result []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
 		}
-		walkDir(libDir)
 	}
+	return result
 }
 
 // libURLPath returns the /@lib/ URL path for a file in a local library dir,
@@ -247,7 +331,11 @@ func (s *esmServer) handleSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if s.cors == nil {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else if s.cors.Handle(w, r) {
+		return
+	}
 	flusher.Flush()
 
 	ch := make(chan sseEvent, 1)
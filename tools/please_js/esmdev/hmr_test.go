@@ -3,6 +3,8 @@ package esmdev
 import (
 	"strings"
 	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
 )
 
 func TestDetectComponents(t *testing.T) {
@@ -56,6 +58,21 @@ func TestDetectComponents(t *testing.T) {
 			code: "function App() {}\nconst App = () => {};",
 			want: []string{"App"},
 		},
+		{
+			name: "constant not detected",
+			code: "export const API_URL = \"https://example.com\";",
+			want: nil,
+		},
+		{
+			name: "names inside strings and comments ignored",
+			code: "const s = \"function Fake() {}\";\n// function Other() {}\nconst t = `${\"}\"} function Tpl() {}`;",
+			want: nil,
+		},
+		{
+			name: "nested function not detected",
+			code: "function helper() {\n  function Inner() {}\n  return Inner;\n}",
+			want: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -73,6 +90,122 @@ func TestDetectComponents(t *testing.T) {
 	}
 }
 
+// TestDetectComponents_Transformed runs detection on real esbuild output,
+// which is what handleSource passes it.
+func TestDetectComponents_Transformed(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourcefile string
+		code       string
+		want       []string
+	}{
+		{
+			name:       "export default memo",
+			sourcefile: "/src/card.tsx",
+			code:       "import { memo } from \"react\";\nexport default memo(function Card() {\n  return <div/>;\n});",
+			want:       []string{"card_default"},
+		},
+		{
+			name:       "anonymous default export",
+			sourcefile: "/src/page.tsx",
+			code:       "export default () => <main/>;",
+			want:       []string{"page_default"},
+		},
+		{
+			name:       "anonymous default export function",
+			sourcefile: "/src/about.tsx",
+			code:       "export default function () {\n  return <section/>;\n}",
+			want:       []string{"about_default"},
+		},
+		{
+			name:       "default export without JSX not detected",
+			sourcefile: "/src/util.ts",
+			code:       "export default function () {\n  return 42;\n}",
+			want:       nil,
+		},
+		{
+			name:       "forwardRef",
+			sourcefile: "/src/Input.tsx",
+			code:       "import { forwardRef } from \"react\";\nexport const Input = forwardRef<HTMLInputElement>((props, ref) => <input ref={ref} {...props}/>);",
+			want:       []string{"Input"},
+		},
+		{
+			name:       "namespace members",
+			sourcefile: "/src/ui.tsx",
+			code:       "export namespace UI {\n  export function Button() { return <button/>; }\n  export const Label = () => <span/>;\n  export const SIZE = 3;\n}",
+			want:       []string{"UI.Button", "UI.Label"},
+		},
+		{
+			name:       "namespace parameter renamed to avoid a clash",
+			sourcefile: "/src/ui.tsx",
+			code:       "const UI2 = 1;\nnamespace UI {\n  export function Card() { return <div>{UI2}</div>; }\n}",
+			want:       []string{"UI.Card"},
+		},
+		{
+			name:       "regex literal with braces",
+			sourcefile: "/src/App.tsx",
+			code:       "const re = /[{(]/g;\nexport function App() { return <div>{re.source}</div>; }",
+			want:       []string{"App"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := api.LoaderTSX
+			if strings.HasSuffix(tt.sourcefile, ".ts") {
+				loader = api.LoaderTS
+			}
+			result := api.Transform(tt.code, api.TransformOptions{
+				Loader:     loader,
+				Format:     api.FormatESModule,
+				JSX:        api.JSXAutomatic,
+				Sourcefile: tt.sourcefile,
+			})
+			if len(result.Errors) > 0 {
+				t.Fatalf("transform failed: %v", result.Errors[0].Text)
+			}
+			got := detectComponents(string(result.Code))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("detectComponents() = %v, want %v\ncode:\n%s", got, tt.want, result.Code)
+			}
+		})
+	}
+}
+
+func TestDetectHooks(t *testing.T) {
+	code := "import { useState } from \"react\";\n" +
+		"export function useCounter() { return useState(0); }\n" +
+		"export const useToggle = (initial) => useState(initial);\n" +
+		"const user = useUser();\n" +
+		"function helper() { function useInner() {} }\n" +
+		"export const useless = 1;"
+	got := detectHooks(code)
+	want := []string{"useCounter", "useToggle"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("detectHooks() = %v, want %v", got, want)
+	}
+}
+
+func TestIsHookModule(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"custom hook", "export function useAuth() { return useContext(AuthContext); }", true},
+		{"context", "import { createContext } from \"react\";\nexport const AuthContext = createContext(null);", true},
+		{"React.createContext", "export const Theme = React.createContext(\"light\");", true},
+		{"plain module", "export const API_URL = \"/api\";\nexport function fetchUser() {}", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isHookModule(tt.code); got != tt.want {
+				t.Errorf("isHookModule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestInjectRefreshRegistration(t *testing.T) {
 	original := []byte("const App = () => <div>hello</div>;")
 	urlPath := "/src/App.tsx"
@@ -113,3 +246,21 @@ func TestInjectRefreshRegistration(t *testing.T) {
 		}
 	})
 }
+
+func TestAnnounceStampsEvents(t *testing.T) {
+	s := &esmServer{clients: make(map[chan sseEvent]struct{})}
+	ch := make(chan sseEvent, 2)
+	s.clients[ch] = struct{}{}
+
+	first := s.builds.Next()
+	s.announce(first, sseEvent{Type: "hmr-update", Files: []string{"/App.tsx"}}, "/App.tsx")
+	s.announce(s.builds.Next(), sseEvent{Type: "full-reload"}, "reloading")
+
+	evt := <-ch
+	if evt.Build != 1 || evt.Time != first.Clock() || evt.Files[0] != "/App.tsx" {
+		t.Errorf("expected the first update stamped #1 at %s, got %+v", first.Clock(), evt)
+	}
+	if evt := <-ch; evt.Build != 2 || evt.Type != "full-reload" {
+		t.Errorf("expected the second update stamped #2, got %+v", evt)
+	}
+}
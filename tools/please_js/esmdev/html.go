@@ -13,7 +13,9 @@ const liveReloadScript = `<script type="module">
 (() => {
   const es = new EventSource("/__esm_dev_sse");
   let t;
-  es.addEventListener("change", () => {
+  es.addEventListener("change", (e) => {
+    const { build, time } = JSON.parse(e.data);
+    console.info("[esm-dev] #" + build + " " + time + " reloading");
     clearTimeout(t);
     t = setTimeout(() => location.reload(), 100);
   });
@@ -44,38 +46,75 @@ window.__ESM_HMR__ = {
   _modules: new Map(),
 };
 
+const overlay = {
+  el: null,
+  show(message) {
+    if (!overlay.el) {
+      overlay.el = document.createElement("pre");
+      overlay.el.style.cssText = "position:fixed;inset:0;z-index:2147483647;margin:0;padding:24px;" +
+        "background:rgba(24,24,27,.92);color:#fca5a5;font:14px/1.5 ui-monospace,monospace;white-space:pre-wrap;overflow:auto";
+      document.body.appendChild(overlay.el);
+    }
+    overlay.el.textContent = "[esm-dev] " + message;
+  },
+  clear() {
+    overlay.el?.remove();
+    overlay.el = null;
+  },
+};
+
 const es = new EventSource("/__esm_dev_sse");
 
+// Prefixes console lines with the update's ID and time, as the server logs it.
+const stamp = (d) => "#" + d.build + " " + d.time + " ";
+
+es.addEventListener("import-error", (e) => {
+  const d = JSON.parse(e.data);
+  console.error("[esm-dev] " + stamp(d) + d.message);
+  overlay.show(d.message);
+});
+
 es.addEventListener("hmr-update", async (e) => {
-  const { files } = JSON.parse(e.data);
+  const d = JSON.parse(e.data);
+  const { files } = d;
   let didUpdate = false;
   for (const file of files) {
     try {
       await import(file + "?t=" + Date.now());
       didUpdate = true;
     } catch (err) {
-      console.error("[hmr] Failed to update " + file, err);
+      console.error("[hmr] " + stamp(d) + "Failed to update " + file, err);
+      // While an import is known to be broken, reloading would only leave
+      // a blank page; keep the overlay up until the importer is fixed.
+      if (overlay.el) {
+        overlay.show(overlay.el.textContent.replace(/^\[esm-dev\] /, "") + "\n\n" + err.message);
+        return;
+      }
       location.reload();
       return;
     }
   }
+  overlay.clear();
   if (didUpdate && window.__REACT_REFRESH__) {
     window.__REACT_REFRESH__.performReactRefresh();
   }
+  console.info("[hmr] " + stamp(d) + "updated " + files.join(", "));
 });
 
 es.addEventListener("css-update", async (e) => {
-  const { files } = JSON.parse(e.data);
-  for (const file of files) {
+  const d = JSON.parse(e.data);
+  for (const file of d.files) {
     try {
       await import(file + "?t=" + Date.now());
     } catch (err) {
-      console.warn("[hmr] CSS update failed for " + file, err);
+      console.warn("[hmr] " + stamp(d) + "CSS update failed for " + file, err);
     }
   }
+  console.info("[hmr] " + stamp(d) + "updated " + d.files.join(", "));
 });
 
-es.addEventListener("full-reload", () => {
+es.addEventListener("full-reload", (e) => {
+  console.info("[hmr] " + stamp(JSON.parse(e.data)) + "reloading");
   location.reload();
 });
 </script>`
@@ -136,15 +175,7 @@ func rewriteHTML(html string, importMapJSON []byte, hasRefresh bool, entryURLPat
 	}
 
 	// Inject import map and live reload / HMR script before </head>
-	var clientScript string
-	if hasRefresh {
-		clientScript = refreshInitScript + "\n" + hmrClientScript
-	} else {
-		clientScript = liveReloadScript
-	}
-	globalsPolyfill := buildGlobalsPolyfill(importMapJSON)
-	injection := fmt.Sprintf(`<script type="importmap">%s</script>
-%s%s`, string(importMapJSON), globalsPolyfill, clientScript)
+	injection := headScripts(importMapJSON, hasRefresh, "")
 
 	if idx := strings.Index(html, "</head>"); idx >= 0 {
 		html = html[:idx] + injection + "\n" + html[idx:]
@@ -157,6 +188,25 @@ func rewriteHTML(html string, importMapJSON []byte, hasRefresh bool, entryURLPat
 	return html
 }
 
+// headScripts returns the import map and client scripts a page needs in its
+// <head>. origin is prefixed to the dev server's own URLs, for pages served
+// from another origin; it's "" for pages esm-dev serves itself.
+func headScripts(importMapJSON []byte, hasRefresh bool, origin string) string {
+	var clientScript string
+	if hasRefresh {
+		clientScript = refreshInitScript + "\n" + hmrClientScript
+	} else {
+		clientScript = liveReloadScript
+	}
+	if origin != "" {
+		clientScript = strings.ReplaceAll(clientScript, `"/__esm_dev_sse"`, `"`+origin+`/__esm_dev_sse"`)
+		clientScript = strings.ReplaceAll(clientScript, `import(file + `, `import("`+origin+`" + file + `)
+	}
+	globalsPolyfill := buildGlobalsPolyfill(importMapJSON)
+	return fmt.Sprintf(`<script type="importmap">%s</script>
+%s%s`, string(importMapJSON), globalsPolyfill, clientScript)
+}
+
 // buildGlobalsPolyfill generates a module script that sets up Node.js globals
 // (global, process, Buffer) for packages that reference them as bare globals.
 // It checks the import map to conditionally include Buffer polyfill only when
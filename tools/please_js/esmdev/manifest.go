@@ -0,0 +1,132 @@
+package esmdev
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// manifestURLPath serves the dev manifest, for backends that render the
+// page themselves.
+const manifestURLPath = "/__esm_dev_manifest"
+
+// devManifest tells a backend rendering its own HTML (Django, Rails) how to
+// load the app from esm-dev: the page embeds Head at the end of its <head>
+// and Body at the end of its <body>, or builds its own tags from ImportMap
+// and Entry. All URLs are absolute, as the page is served from another
+// origin.
+type devManifest struct {
+	Origin    string                     `json:"origin"`
+	Entry     string                     `json:"entry"`
+	ImportMap map[string]json.RawMessage `json:"importMap"`
+	Head      string                     `json:"head"`
+	Body      string                     `json:"body"`
+}
+
+// manifest returns the dev manifest for the server reached at origin, e.g.
+// "http://localhost:3000".
+func (s *esmServer) manifest(origin string) ([]byte, error) {
+	importMap, err := absoluteImportMap(s.importMapJSON, origin)
+	if err != nil {
+		return nil, err
+	}
+	importMapJSON, err := json.Marshal(importMap)
+	if err != nil {
+		return nil, err
+	}
+	entry := origin + s.entryURLPath
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// The snippets are meant to be read and pasted as they are.
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(devManifest{
+		Origin:    origin,
+		Entry:     entry,
+		ImportMap: importMap,
+		Head:      headScripts(importMapJSON, s.hasRefresh, origin),
+		Body:      fmt.Sprintf(`<script type="module" src="%s"></script>`, entry),
+	})
+	return buf.Bytes(), err
+}
+
+// absoluteImportMap prefixes origin to the root-relative URLs of an import
+// map's imports and scopes, so they resolve against the dev server from a
+// page on another origin.
+func absoluteImportMap(importMapJSON []byte, origin string) (map[string]json.RawMessage, error) {
+	importMap := map[string]json.RawMessage{}
+	if len(importMapJSON) > 0 {
+		if err := json.Unmarshal(importMapJSON, &importMap); err != nil {
+			return nil, fmt.Errorf("invalid import map: %w", err)
+		}
+	}
+	absolute := func(url string) string {
+		if strings.HasPrefix(url, "/") && !strings.HasPrefix(url, "//") {
+			return origin + url
+		}
+		return url
+	}
+	absoluteAll := func(specifiers map[string]string) map[string]string {
+		result := make(map[string]string, len(specifiers))
+		for specifier, url := range specifiers {
+			result[specifier] = absolute(url)
+		}
+		return result
+	}
+
+	var imports map[string]string
+	if raw, ok := importMap["imports"]; ok {
+		if err := json.Unmarshal(raw, &imports); err != nil {
+			return nil, fmt.Errorf("invalid import map imports: %w", err)
+		}
+	}
+	data, err := json.Marshal(absoluteAll(imports))
+	if err != nil {
+		return nil, err
+	}
+	importMap["imports"] = data
+
+	if raw, ok := importMap["scopes"]; ok {
+		var scopes map[string]map[string]string
+		if err := json.Unmarshal(raw, &scopes); err != nil {
+			return nil, fmt.Errorf("invalid import map scopes: %w", err)
+		}
+		result := make(map[string]map[string]string, len(scopes))
+		for scope, specifiers := range scopes {
+			result[absolute(scope)] = absoluteAll(specifiers)
+		}
+		if importMap["scopes"], err = json.Marshal(result); err != nil {
+			return nil, err
+		}
+	}
+	return importMap, nil
+}
+
+// handleManifest serves the dev manifest, with URLs on the host the request
+// reached the server by.
+func (s *esmServer) handleManifest(w http.ResponseWriter, r *http.Request) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	data, err := s.manifest(scheme + "://" + r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// writeManifest writes the dev manifest to path for the server at origin.
+func (s *esmServer) writeManifest(path, origin string) error {
+	data, err := s.manifest(origin)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,64 @@
+package esmdev
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	srv := &esmServer{
+		importMapJSON: []byte(`{"imports":{"react":"/@deps/react.js","cdn":"https://cdn.example/cdn.js"},"scopes":{"/@deps/":{"scheduler":"/@deps/scheduler.js"}}}`),
+		entryURLPath:  "/src/main.tsx",
+		hasRefresh:    true,
+	}
+	req := httptest.NewRequest("GET", manifestURLPath, nil)
+	req.Host = "localhost:3000"
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected CORS to be allowed, got %q", got)
+	}
+
+	var m struct {
+		Origin    string
+		Entry     string
+		ImportMap struct {
+			Imports map[string]string
+			Scopes  map[string]map[string]string
+		}
+		Head string
+		Body string
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Origin != "http://localhost:3000" || m.Entry != "http://localhost:3000/src/main.tsx" {
+		t.Errorf("unexpected origin %q and entry %q", m.Origin, m.Entry)
+	}
+	if m.ImportMap.Imports["react"] != "http://localhost:3000/@deps/react.js" {
+		t.Errorf("expected root-relative imports to be made absolute, got %q", m.ImportMap.Imports["react"])
+	}
+	if m.ImportMap.Imports["cdn"] != "https://cdn.example/cdn.js" {
+		t.Errorf("expected absolute imports to be kept, got %q", m.ImportMap.Imports["cdn"])
+	}
+	if m.ImportMap.Scopes["http://localhost:3000/@deps/"]["scheduler"] != "http://localhost:3000/@deps/scheduler.js" {
+		t.Errorf("expected scopes to be made absolute, got %v", m.ImportMap.Scopes)
+	}
+	for _, want := range []string{
+		`"http://localhost:3000/@deps/react.js"`,
+		`new EventSource("http://localhost:3000/__esm_dev_sse")`,
+		`import("http://localhost:3000" + file + "?t="`,
+	} {
+		if !strings.Contains(m.Head, want) {
+			t.Errorf("expected head to contain %s, got:\n%s", want, m.Head)
+		}
+	}
+	if m.Body != `<script type="module" src="http://localhost:3000/src/main.tsx"></script>` {
+		t.Errorf("unexpected body %q", m.Body)
+	}
+}
@@ -18,12 +18,16 @@ import (
 
 // prebundleCacheKey computes a hash key based on the moduleconfig content
 // and the set of used imports. The cache is invalidated when either changes.
-func prebundleCacheKey(moduleConfigPath string, usedImports map[string]bool) string {
+func prebundleCacheKey(moduleConfigPath string, moduleAliases []string, usedImports map[string]bool) string {
 	h := sha256.New()
 	// Hash moduleconfig content — changes when any dep is added/removed/updated
 	if data, err := os.ReadFile(moduleConfigPath); err == nil {
 		h.Write(data)
 	}
+	// Hash module aliases — they change which package a name bundles
+	for _, alias := range moduleAliases {
+		h.Write([]byte(alias + "\n"))
+	}
 	// Hash used imports — changes when source code adds/removes an import
 	var specs []string
 	for spec := range usedImports {
@@ -120,12 +124,16 @@ func LoadPrebundleDir(dir string) (map[string][]byte, []byte, error) {
 
 // PrebundleAll runs the full pre-bundle pipeline for all npm dependencies
 // and writes the output to outDir. This is used by the "prebundle" subcommand
-// at build time so Please can cache the result.
-func PrebundleAll(moduleConfigPath, outDir string) error {
+// at build time so Please can cache the result. moduleAliases point modules
+// at other moduleconfig entries, as for bundle's --module-alias.
+func PrebundleAll(moduleConfigPath, outDir string, moduleAliases []string) error {
 	moduleMap, err := common.ParseModuleConfig(moduleConfigPath)
 	if err != nil {
 		return fmt.Errorf("failed to parse moduleconfig: %w", err)
 	}
+	if err := common.ApplyModuleAliases(moduleMap, moduleAliases); err != nil {
+		return err
+	}
 
 	define := make(map[string]string)
 	common.MergeEnvDefines(define, "development")
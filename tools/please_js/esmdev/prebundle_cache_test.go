@@ -23,31 +23,39 @@ func TestPrebundleCacheKey(t *testing.T) {
 	importsB := map[string]bool{"react": true, "vue": true}
 
 	t.Run("same inputs produce same key", func(t *testing.T) {
-		key1 := prebundleCacheKey(mc1, importsA)
-		key2 := prebundleCacheKey(mc1, importsA)
+		key1 := prebundleCacheKey(mc1, nil, importsA)
+		key2 := prebundleCacheKey(mc1, nil, importsA)
 		if key1 != key2 {
 			t.Errorf("same inputs gave different keys: %q vs %q", key1, key2)
 		}
 	})
 
 	t.Run("different imports produce different key", func(t *testing.T) {
-		key1 := prebundleCacheKey(mc1, importsA)
-		key2 := prebundleCacheKey(mc1, importsB)
+		key1 := prebundleCacheKey(mc1, nil, importsA)
+		key2 := prebundleCacheKey(mc1, nil, importsB)
 		if key1 == key2 {
 			t.Errorf("different imports gave same key: %q", key1)
 		}
 	})
 
 	t.Run("different moduleconfig produces different key", func(t *testing.T) {
-		key1 := prebundleCacheKey(mc1, importsA)
-		key2 := prebundleCacheKey(mc2, importsA)
+		key1 := prebundleCacheKey(mc1, nil, importsA)
+		key2 := prebundleCacheKey(mc2, nil, importsA)
 		if key1 == key2 {
 			t.Errorf("different moduleconfigs gave same key: %q", key1)
 		}
 	})
 
+	t.Run("different module aliases produce different key", func(t *testing.T) {
+		key1 := prebundleCacheKey(mc1, nil, importsA)
+		key2 := prebundleCacheKey(mc1, []string{"react=react18"}, importsA)
+		if key1 == key2 {
+			t.Errorf("different module aliases gave same key: %q", key1)
+		}
+	})
+
 	t.Run("key is 16 hex characters", func(t *testing.T) {
-		key := prebundleCacheKey(mc1, importsA)
+		key := prebundleCacheKey(mc1, nil, importsA)
 		if len(key) != 16 {
 			t.Errorf("expected key length 16, got %d (%q)", len(key), key)
 		}
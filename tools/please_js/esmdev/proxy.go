@@ -1,46 +1,24 @@
 package esmdev
 
 import (
-	"crypto/tls"
 	"fmt"
-	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
-	"sort"
-	"strings"
+
+	"tools/please_js/common"
 )
 
-// parseProxies converts "prefix=target" strings into reverse proxy instances.
-func parseProxies(specs []string) (map[string]*httputil.ReverseProxy, []string) {
-	proxies := make(map[string]*httputil.ReverseProxy, len(specs))
-	var prefixes []string
+// parseProxies converts "prefix=target" rules (see common.ParseProxyRule)
+// into reverse proxy instances, added to rules from a --proxy-config file.
+// A --proxy rule replaces a config rule for the same prefix.
+// All proxies share one transport, tuned by opts, and report to health.
+func parseProxies(specs []string, rules []common.ProxyRule, opts common.ProxyOptions, health *common.ProxyHealth) (map[string]*common.Proxy, []string) {
 	for _, spec := range specs {
-		parts := strings.SplitN(spec, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		prefix := strings.TrimSpace(parts[0])
-		target := strings.TrimSpace(parts[1])
-		u, err := url.Parse(target)
+		rule, err := common.ParseProxyRule(spec)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "warning: invalid proxy target %q: %v\n", target, err)
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 			continue
 		}
-		proxy := httputil.NewSingleHostReverseProxy(u)
-		originalDirector := proxy.Director
-		proxy.Director = func(req *http.Request) {
-			originalDirector(req)
-			req.Host = u.Host
-		}
-		proxy.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		proxies[prefix] = proxy
-		prefixes = append(prefixes, prefix)
+		rules = append(rules, rule)
 	}
-	sort.Slice(prefixes, func(i, j int) bool {
-		return len(prefixes[i]) > len(prefixes[j])
-	})
-	return proxies, prefixes
+	return common.NewProxies(rules, opts, health)
 }
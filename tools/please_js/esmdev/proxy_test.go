@@ -2,11 +2,13 @@ package esmdev
 
 import (
 	"testing"
+
+	"tools/please_js/common"
 )
 
 func TestParseProxies(t *testing.T) {
 	t.Run("single proxy", func(t *testing.T) {
-		proxies, prefixes := parseProxies([]string{"/api=http://localhost:8080"})
+		proxies, prefixes := parseProxies([]string{"/api=http://localhost:8080"}, nil, common.ProxyOptions{}, common.NewProxyHealth())
 
 		if len(prefixes) != 1 || prefixes[0] != "/api" {
 			t.Fatalf("expected prefixes [/api], got %v", prefixes)
@@ -21,7 +23,7 @@ func TestParseProxies(t *testing.T) {
 			"/api=http://localhost:8080",
 			"/api/v2/admin=http://localhost:9090",
 			"/api/v2=http://localhost:8081",
-		})
+		}, nil, common.ProxyOptions{}, common.NewProxyHealth())
 
 		if len(prefixes) != 3 {
 			t.Fatalf("expected 3 prefixes, got %d", len(prefixes))
@@ -42,7 +44,7 @@ func TestParseProxies(t *testing.T) {
 	})
 
 	t.Run("invalid spec skipped", func(t *testing.T) {
-		proxies, prefixes := parseProxies([]string{"no-equals-sign"})
+		proxies, prefixes := parseProxies([]string{"no-equals-sign"}, nil, common.ProxyOptions{}, common.NewProxyHealth())
 
 		if len(prefixes) != 0 {
 			t.Errorf("expected no prefixes, got %v", prefixes)
@@ -52,8 +54,23 @@ func TestParseProxies(t *testing.T) {
 		}
 	})
 
+	t.Run("flag overrides config rule", func(t *testing.T) {
+		config, err := common.ParseProxyRule("/api=http://localhost:8080;rewrite=/api->/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxies, prefixes := parseProxies([]string{"/api=http://localhost:9090"}, []common.ProxyRule{config}, common.ProxyOptions{}, common.NewProxyHealth())
+
+		if len(prefixes) != 1 {
+			t.Fatalf("expected one prefix, got %v", prefixes)
+		}
+		if got := proxies["/api"].Rule.Target.Host; got != "localhost:9090" {
+			t.Errorf("expected the --proxy rule's target, got %s", got)
+		}
+	})
+
 	t.Run("empty specs", func(t *testing.T) {
-		proxies, prefixes := parseProxies([]string{})
+		proxies, prefixes := parseProxies([]string{}, nil, common.ProxyOptions{}, common.NewProxyHealth())
 
 		if len(proxies) != 0 {
 			t.Errorf("expected empty map, got %d entries", len(proxies))
@@ -1,15 +1,17 @@
 package esmdev
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
-	"net/http/httputil"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -22,17 +24,34 @@ import (
 type Args struct {
 	Entry          string
 	ModuleConfig   string
+	ModuleAliases  []string // name=entry, resolving a module to another moduleconfig entry
 	Servedir       string
+	Host           string // address to listen on; "" for all interfaces
 	Port           int
 	Tsconfig       string
 	Define         []string
 	Proxy          []string
+	ProxyConfig    string             // file of proxy routes (see common.LoadProxyConfig); "" for none
+	ProxyRules     []common.ProxyRule // routes from the project config, below ProxyConfig's and Proxy's
+	ProxyOptions   common.ProxyOptions
 	EnvFile        string
 	EnvPrefix      string
 	PrebundleDir   string // path to pre-bundled deps dir (skips runtime prebundle)
 	Root           string // package root for source file resolution
 	TailwindBin    string
 	TailwindConfig string
+	WatchGlobs     []string // extra files to watch, relative to Root
+	WatchIgnore    []string // comma-separated globs of files whose changes are ignored, relative to Root
+	Manifest       string   // where to write the dev manifest for backend-rendered pages
+	SnapshotOnExit string   // where to save the server's state on exit, and restore it from on start
+	HTTPS          bool
+	Cert           string   // TLS certificate to serve with HTTPS; "" for a generated one
+	Key            string   // key of Cert
+	H2C            bool     // also accept HTTP/2 without TLS, behind a proxy
+	Auth           string   // "user:pass" required to use the server; "" for none
+	CORS           bool     // set CORS headers allowing any origin
+	CORSOrigins    []string // set CORS headers allowing only these origins
+	Open           string   // path to open in the browser once the server is ready; "" not to
 }
 
 // esmServer serves individual ES modules with on-demand transformation.
@@ -47,37 +66,88 @@ type esmServer struct {
 	importMapJSON  []byte
 	clients        map[chan sseEvent]struct{}
 	sseMu          sync.Mutex
-	proxies        map[string]*httputil.ReverseProxy
+	builds         common.BuildCounter // stamps each update pushed to clients
+	status         common.BuildStatus  // updates, and the transform errors standing
+	proxies        map[string]*common.Proxy
 	proxyPrefixes  []string
+	proxyHealth    *common.ProxyHealth
+	chaos          *common.Chaos
+	auth           *common.BasicAuth // nil without --auth
+	cors           *common.CORS      // nil unless --cors or --cors-origin
+	wsDialTimeout  time.Duration     // for WebSocket connections to proxy targets
 	define         map[string]string
 	tsconfig       string
 	hasRefresh     bool     // true if react-refresh found in pre-bundled deps
 	entryURLPath   string   // entry file URL path (e.g., "/main.jsx") — skip HMR for this
 	componentFiles sync.Map // abs path → bool (true if last transform found components)
+	hookFiles      sync.Map // abs path → bool (true if last transform found only hooks/contexts)
+	graph          moduleGraph
 	tailwindBin    string
 	tailwindConfig string
 	tailwindCache  sync.Map // abs CSS path → *tailwindEntry
+	tailwindFiles  sync.Map // abs CSS path → true once compiled through Tailwind
+	tailwindGlobs  []*regexp.Regexp // Tailwind content globs, matched against abs paths
+	watched        watchSet    // directories polled for changes, grown as modules are served
+	watchGlobs     []watchGlob // extra globs polled regardless of the import graph
 }
 
 func (s *esmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	urlPath := r.URL.Path
 
+	// --auth guards everything. The HMR stream gets in on the session
+	// cookie set when the page logged in.
+	if !s.auth.Check(w, r) {
+		return
+	}
+
 	// 1. SSE endpoint
 	if urlPath == "/__esm_dev_sse" {
 		s.handleSSE(w, r)
 		return
 	}
 
-	// 2. Proxy matching
+	// 1a. Status, for tooling to poll
+	if urlPath == common.StatusURLPath {
+		s.handleStatus(w, r)
+		return
+	}
+
+	// 2. Proxy matching. Routes with ws off leave WebSocket upgrades to
+	// the routes after them.
+	upgrade := common.IsWebSocketUpgrade(r)
 	for _, prefix := range s.proxyPrefixes {
-		if strings.HasPrefix(urlPath, prefix) {
+		proxy := s.proxies[prefix]
+		if strings.HasPrefix(urlPath, prefix) && (proxy.Rule.WS || !upgrade) {
 			fmt.Printf("  \033[2m[proxy] %s %s\033[0m\n", r.Method, urlPath)
-			s.proxies[prefix].ServeHTTP(w, r)
+			if s.chaos.Inject(w, r) {
+				return
+			}
+			if upgrade {
+				common.ServeWebSocket(w, r, proxy.ReverseProxy, s.wsDialTimeout, proxy.Rule.Secure)
+				return
+			}
+			proxy.ServeHTTP(w, r)
 			return
 		}
 	}
 
+	// Pages rendered by a backend on another origin load their modules from
+	// here (see the dev manifest), and module scripts are fetched with CORS,
+	// so any origin may unless --cors or --cors-origin say otherwise.
+	// Proxied responses keep the backend's own headers.
+	if s.cors == nil {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else if s.cors.Handle(w, r) {
+		return
+	}
+
+	// 2a. Dev manifest
+	if urlPath == manifestURLPath {
+		s.handleManifest(w, r)
+		return
+	}
+
 	// 3. Pre-bundled deps
 	if strings.HasPrefix(urlPath, "/@deps/") {
 		if data, ok := s.depCache[urlPath]; ok {
@@ -91,6 +161,12 @@ func (s *esmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 3a. Worklet and worker modules, bundled standalone
+	if strings.HasPrefix(urlPath, workletURLPrefix+"/") || strings.HasPrefix(urlPath, workerURLPrefix+"/") {
+		s.handleWorker(w, r, urlPath, start)
+		return
+	}
+
 	// 4. Local library source files (js_library with module_name)
 	if strings.HasPrefix(urlPath, "/@lib/") {
 		s.handleLibSource(w, r, urlPath, start)
@@ -103,6 +179,13 @@ func (s *esmServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 5a. Vite-style import suffixes (?raw, ?url, ?inline) — checked before
+	// extension-based routing so e.g. `./worker.ts?raw` returns source text.
+	if query := importQuery(r.URL.Query()); query != "" {
+		s.handleQueryImport(w, r, urlPath, query, start)
+		return
+	}
+
 	// 6. JS/TS/JSX/TSX source files — on-demand transform
 	ext := filepath.Ext(urlPath)
 	isSourceExt := ext == ".js" || ext == ".jsx" || ext == ".ts" || ext == ".tsx" || ext == ".mjs"
@@ -169,6 +252,9 @@ func Run(args Args) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse moduleconfig: %w", err)
 	}
+	if err := common.ApplyModuleAliases(moduleMap, args.ModuleAliases); err != nil {
+		return err
+	}
 
 	port := args.Port
 	if port == 0 {
@@ -212,6 +298,19 @@ func Run(args Args) error {
 		if err != nil {
 			return fmt.Errorf("failed to load prebundle dir %s: %w", args.PrebundleDir, err)
 		}
+		// Deps are pre-bundled under their own names, so aliases point the
+		// import map at their entries' bundles.
+		if len(args.ModuleAliases) > 0 {
+			var im common.ImportMap
+			json.Unmarshal(importMapJSON, &im)
+			if im.Imports == nil {
+				im.Imports = make(map[string]string)
+			}
+			if err := common.AliasImportMap(im.Imports, args.ModuleAliases); err != nil {
+				return err
+			}
+			importMapJSON, _ = json.Marshal(im)
+		}
 		var imData struct{ Imports map[string]string }
 		json.Unmarshal(importMapJSON, &imData)
 		fmt.Printf("  \033[2mLoaded %d deps from prebundle dir in %dms\033[0m\n",
@@ -224,7 +323,7 @@ func Run(args Args) error {
 			usedImports["react-refresh"] = true
 		}
 
-		cacheKey := prebundleCacheKey(args.ModuleConfig, usedImports)
+		cacheKey := prebundleCacheKey(args.ModuleConfig, args.ModuleAliases, usedImports)
 		cacheDir := filepath.Join(".esm-dev-cache", cacheKey)
 
 		if dc, im, loadErr := loadPrebundleCache(cacheDir); loadErr == nil {
@@ -301,7 +400,24 @@ func Run(args Args) error {
 	}
 
 	// Parse proxies
-	proxies, proxyPrefixes := parseProxies(args.Proxy)
+	proxyRules := args.ProxyRules
+	if args.ProxyConfig != "" {
+		configRules, err := common.LoadProxyConfig(args.ProxyConfig)
+		if err != nil {
+			return err
+		}
+		proxyRules = append(proxyRules, configRules...)
+	}
+	proxyHealth := common.NewProxyHealth()
+	proxies, proxyPrefixes := parseProxies(args.Proxy, proxyRules, args.ProxyOptions, proxyHealth)
+	chaos, err := common.NewChaos(args.ProxyOptions.Chaos)
+	if err != nil {
+		return err
+	}
+	auth, err := common.NewBasicAuth(args.Auth)
+	if err != nil {
+		return err
+	}
 
 	// Detect react-refresh in pre-bundled deps
 	hasRefresh := false
@@ -327,6 +443,11 @@ func Run(args Args) error {
 		clients:        make(map[chan sseEvent]struct{}),
 		proxies:        proxies,
 		proxyPrefixes:  proxyPrefixes,
+		proxyHealth:    proxyHealth,
+		chaos:          chaos,
+		auth:           auth,
+		cors:           common.NewCORS(args.CORS, args.CORSOrigins),
+		wsDialTimeout:  args.ProxyOptions.DialTimeout,
 		define:         define,
 		tsconfig:       args.Tsconfig,
 		hasRefresh:     hasRefresh,
@@ -334,15 +455,79 @@ func Run(args Args) error {
 		tailwindBin:    args.TailwindBin,
 		tailwindConfig: args.TailwindConfig,
 	}
+	if args.TailwindBin != "" && args.TailwindConfig != "" {
+		server.tailwindGlobs = common.ParseTailwindContent(args.TailwindConfig)
+	}
+
+	// Seed the watcher with the entry, the servedir (HTML) and config files;
+	// everything else is added as it's served or imported. Tailwind content
+	// globs are always watched since they drive CSS regeneration.
+	server.watched.ignore = common.NewWatchIgnore(args.WatchIgnore, absPackageRoot)
+	server.watchPath(absEntry)
+	server.watched.add(absServedir)
+	if args.Tsconfig != "" {
+		absTsconfig, _ := filepath.Abs(args.Tsconfig)
+		server.watchPath(absTsconfig)
+	}
+	for _, glob := range args.WatchGlobs {
+		server.watchGlobs = append(server.watchGlobs, newWatchGlob(glob, absPackageRoot))
+	}
+	if args.TailwindBin != "" && args.TailwindConfig != "" {
+		absConfig, _ := filepath.Abs(args.TailwindConfig)
+		server.watchPath(absConfig)
+		for _, glob := range common.TailwindContentGlobs(args.TailwindConfig) {
+			server.watchGlobs = append(server.watchGlobs, newWatchGlob(glob, ""))
+		}
+	}
+
+	// Restore what the last run learned before the watcher's first scan, so
+	// the modules it knew about are watched from the start.
+	var depsKey string
+	if args.SnapshotOnExit != "" {
+		depsKey = snapshotDepsKey(args.ModuleConfig, importMapJSON, define)
+		restored, err := server.restoreSnapshot(args.SnapshotOnExit, depsKey)
+		if err != nil {
+			fmt.Printf("  \033[33mIgnoring snapshot: %v\033[0m\n", err)
+		} else if restored > 0 {
+			fmt.Printf("  \033[2mRestored %d modules from %s\033[0m\n", restored, args.SnapshotOnExit)
+		}
+	}
+
+	// The server's ready to serve modules; updates are recorded from here.
+	server.status.Record(common.BuildStamp{Time: time.Now()}, time.Since(prebundleStart))
 
 	// Start file watcher
 	go server.watchFiles()
 
+	// Hold off serving until the proxy targets are up, if asked to, so the
+	// first page load doesn't hit a backend that's still starting.
+	if args.ProxyOptions.Wait > 0 {
+		proxyHealth.Wait(args.ProxyOptions.Wait)
+	} else {
+		proxyHealth.Check(time.Second)
+	}
+
+	// Serve HTTPS, for secure-context APIs, with the given certificate or
+	// a generated one for this machine's addresses.
+	localHost, ips := common.DevHosts(args.Host, getLocalIPs())
+	scheme := "http"
+	var tlsConfig *tls.Config
+	var certPath string
+	if args.HTTPS {
+		if tlsConfig, certPath, err = common.DevTLSConfig(args.Cert, args.Key, ips); err != nil {
+			return err
+		}
+		scheme = "https"
+	}
+	if args.H2C && args.HTTPS {
+		return fmt.Errorf("--h2c is for serving HTTP/2 without TLS; --https already serves it")
+	}
+
 	// Start HTTP server — try successive ports if the configured one is in use.
 	var listener net.Listener
 	actualPort := port
 	for attempts := 0; attempts < 20; attempts++ {
-		ln, listenErr := net.Listen("tcp", fmt.Sprintf(":%d", actualPort))
+		ln, listenErr := net.Listen("tcp", net.JoinHostPort(args.Host, strconv.Itoa(actualPort)))
 		if listenErr == nil {
 			listener = ln
 			break
@@ -357,9 +542,27 @@ func Run(args Args) error {
 		return fmt.Errorf("no available port found (tried %d–%d)", port, actualPort-1)
 	}
 
-	httpServer := &http.Server{Handler: server}
+	origin := common.DevOrigin(scheme, args.Host, actualPort)
+	if args.Manifest != "" {
+		if err := server.writeManifest(args.Manifest, origin); err != nil {
+			return fmt.Errorf("failed to write manifest: %w", err)
+		}
+	}
+
+	// HTTP/2 is negotiated over TLS; without it, only if asked for.
+	var handler http.Handler = server
+	if args.H2C {
+		handler = common.CleartextHTTP2(server)
+	}
+	httpServer := &http.Server{Handler: handler, TLSConfig: tlsConfig}
 	go func() {
-		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsConfig != nil {
+			err = httpServer.ServeTLS(listener, "", "")
+		} else {
+			err = httpServer.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			fmt.Fprintf(os.Stderr, "HTTP server error: %v\n", err)
 			os.Exit(1)
 		}
@@ -370,11 +573,25 @@ func Run(args Args) error {
 	if hasRefresh {
 		fmt.Printf("  \033[1;35mHMR\033[0m  React Fast Refresh enabled\n")
 	}
-	fmt.Printf("\n  \033[36m➜\033[0m  \033[1mLocal:\033[0m   http://localhost:\033[1m%d\033[0m/\n", actualPort)
-	for _, ip := range getLocalIPs() {
-		fmt.Printf("  \033[36m➜\033[0m  \033[2mNetwork: http://%s:%d/\033[0m\n", ip, actualPort)
+	fmt.Println()
+	if localHost != "" {
+		fmt.Printf("  \033[36m➜\033[0m  \033[1mLocal:\033[0m   %s://%s:\033[1m%d\033[0m/\n", scheme, common.URLHost(localHost), actualPort)
+	}
+	for _, ip := range ips {
+		fmt.Printf("  \033[36m➜\033[0m  \033[2mNetwork: %s://%s:%d/\033[0m\n", scheme, common.URLHost(ip), actualPort)
 	}
+	if certPath != "" {
+		fmt.Printf("  \033[36m➜\033[0m  \033[2mCertificate: %s\033[0m\n", certPath)
+	}
+	proxyHealth.PrintStatus()
+	chaos.PrintStatus()
 	fmt.Println()
+	if args.Open != "" {
+		url := common.OpenURL(origin, args.Open)
+		if err := common.OpenBrowser(url); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open %s: %v\n", url, err)
+		}
+	}
 
 	// Block until Ctrl+C
 	sigCh := make(chan os.Signal, 1)
@@ -383,6 +600,11 @@ func Run(args Args) error {
 
 	fmt.Println("\nShutting down...")
 	httpServer.Close()
+	if args.SnapshotOnExit != "" {
+		if err := server.saveSnapshot(args.SnapshotOnExit, depsKey); err != nil {
+			return fmt.Errorf("failed to save snapshot: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,148 @@
+package esmdev
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// devSnapshot is what the server learns by serving modules: the import
+// graph, which modules are components or hooks, and the deps bundled on
+// demand. --snapshot-on-exit saves it on shutdown and restores it on the
+// next start, so a page left open across a restart keeps getting hot
+// updates instead of reloading on every change until its modules have been
+// served again.
+type devSnapshot struct {
+	Modules map[string]snapshotModule `json:"modules"`
+	// DepsKey identifies the dependencies OnDemandDeps were bundled from;
+	// they're only restored if it still matches.
+	DepsKey      string            `json:"depsKey"`
+	OnDemandDeps map[string][]byte `json:"onDemandDeps,omitempty"`
+}
+
+// snapshotModule is a source file's entry in a devSnapshot. ModTime is the
+// file's mtime when it was saved; a file changed since is left out when
+// restoring, as what was learned from it may no longer hold.
+type snapshotModule struct {
+	ModTime   time.Time `json:"modTime"`
+	Imports   []string  `json:"imports,omitempty"`
+	Component bool      `json:"component,omitempty"`
+	Hook      bool      `json:"hook,omitempty"`
+	Version   int64     `json:"version,omitempty"`
+}
+
+// snapshotDepsKey hashes what on-demand deps are bundled from: the
+// moduleconfig, the import map and the defines.
+func snapshotDepsKey(moduleConfigPath string, importMapJSON []byte, define map[string]string) string {
+	h := sha256.New()
+	if data, err := os.ReadFile(moduleConfigPath); err == nil {
+		h.Write(data)
+	}
+	h.Write(importMapJSON)
+	keys := make([]string, 0, len(define))
+	for k := range define {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "\n%s=%s", k, define[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// saveSnapshot writes the server's state to path.
+func (s *esmServer) saveSnapshot(path, depsKey string) error {
+	snap := devSnapshot{
+		Modules:      map[string]snapshotModule{},
+		DepsKey:      depsKey,
+		OnDemandDeps: map[string][]byte{},
+	}
+	module := func(file string) (snapshotModule, bool) {
+		if m, ok := snap.Modules[file]; ok {
+			return m, true
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return snapshotModule{}, false
+		}
+		return snapshotModule{ModTime: info.ModTime(), Version: s.graph.version(file)}, true
+	}
+	for importer, deps := range s.graph.allImports() {
+		if m, ok := module(importer); ok {
+			m.Imports = deps
+			snap.Modules[importer] = m
+		}
+	}
+	s.componentFiles.Range(func(key, value any) bool {
+		if m, ok := module(key.(string)); ok {
+			m.Component = value.(bool)
+			snap.Modules[key.(string)] = m
+		}
+		return true
+	})
+	s.hookFiles.Range(func(key, value any) bool {
+		if m, ok := module(key.(string)); ok {
+			m.Hook = value.(bool)
+			snap.Modules[key.(string)] = m
+		}
+		return true
+	})
+	s.onDemandDeps.Range(func(key, value any) bool {
+		snap.OnDemandDeps[key.(string)] = value.([]byte)
+		return true
+	})
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// restoreSnapshot loads the state saved at path, skipping modules changed
+// since and on-demand deps bundled from other dependencies. A missing
+// snapshot restores nothing. Returns the number of modules restored.
+func (s *esmServer) restoreSnapshot(path, depsKey string) (int, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var snap devSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+
+	restored := 0
+	for file, m := range snap.Modules {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(m.ModTime) {
+			continue
+		}
+		if m.Imports != nil {
+			s.graph.setImports(file, m.Imports)
+			for _, dep := range m.Imports {
+				s.watchPath(dep)
+			}
+		}
+		s.componentFiles.Store(file, m.Component)
+		s.hookFiles.Store(file, m.Hook)
+		if m.Version > 0 {
+			s.graph.bump(file, m.Version)
+		}
+		s.watchPath(file)
+		restored++
+	}
+	if snap.DepsKey == depsKey {
+		for urlPath, code := range snap.OnDemandDeps {
+			s.onDemandDeps.Store(urlPath, code)
+		}
+	}
+	return restored, nil
+}
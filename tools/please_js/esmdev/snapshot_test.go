@@ -0,0 +1,112 @@
+package esmdev
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	app := filepath.Join(dir, "App.tsx")
+	hook := filepath.Join(dir, "useCount.ts")
+	changed := filepath.Join(dir, "Changed.tsx")
+	for _, f := range []string{app, hook, changed} {
+		os.WriteFile(f, []byte("export {};"), 0644)
+	}
+
+	srv := &esmServer{packageRoot: dir, sourceRoot: dir}
+	srv.graph.setImports(app, []string{hook, changed})
+	srv.graph.bump(hook, 42)
+	srv.componentFiles.Store(app, true)
+	srv.hookFiles.Store(app, false)
+	srv.componentFiles.Store(hook, false)
+	srv.hookFiles.Store(hook, true)
+	srv.componentFiles.Store(changed, true)
+	srv.onDemandDeps.Store("/@deps/lodash/get.js", []byte("export default 1;"))
+
+	snapshot := filepath.Join(dir, "snapshot.json")
+	if err := srv.saveSnapshot(snapshot, "deps1"); err != nil {
+		t.Fatal(err)
+	}
+	// Edited while the server was down.
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(changed, later, later)
+
+	restored := &esmServer{packageRoot: dir, sourceRoot: dir}
+	n, err := restored.restoreSnapshot(snapshot, "deps1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 modules restored, got %d", n)
+	}
+	if got := restored.graph.importersOf(hook); len(got) != 1 || got[0] != app {
+		t.Errorf("expected App to import the hook, got %v", got)
+	}
+	if v := restored.graph.version(hook); v != 42 {
+		t.Errorf("expected the hook's hot-update version to be restored, got %d", v)
+	}
+	if isHook, ok := restored.hookFiles.Load(hook); !ok || !isHook.(bool) {
+		t.Error("expected the hook module to be restored as a hook")
+	}
+	if isComp, ok := restored.componentFiles.Load(app); !ok || !isComp.(bool) {
+		t.Error("expected App to be restored as a component")
+	}
+	if _, ok := restored.componentFiles.Load(changed); ok {
+		t.Error("expected the module changed since the snapshot to be left out")
+	}
+	if _, ok := restored.watched.dirs.Load(dir); !ok {
+		t.Error("expected restored modules to be watched")
+	}
+
+	// Boundaries are found without the modules being served again.
+	boundaries, ok := restored.propagateUpdate(hook, 43)
+	if !ok || len(boundaries) != 1 || boundaries[0] != "/App.tsx" {
+		t.Errorf("expected a hot update through App.tsx, got %v, %v", boundaries, ok)
+	}
+	if _, ok := restored.onDemandDeps.Load("/@deps/lodash/get.js"); !ok {
+		t.Error("expected on-demand deps to be restored")
+	}
+}
+
+func TestSnapshotDepsKeyMismatch(t *testing.T) {
+	dir := t.TempDir()
+	srv := &esmServer{}
+	srv.onDemandDeps.Store("/@deps/lodash/get.js", []byte("export default 1;"))
+	snapshot := filepath.Join(dir, "snapshot.json")
+	if err := srv.saveSnapshot(snapshot, "deps1"); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &esmServer{}
+	if _, err := restored.restoreSnapshot(snapshot, "deps2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restored.onDemandDeps.Load("/@deps/lodash/get.js"); ok {
+		t.Error("expected on-demand deps bundled from other deps to be dropped")
+	}
+}
+
+func TestSnapshotMissing(t *testing.T) {
+	srv := &esmServer{}
+	n, err := srv.restoreSnapshot(filepath.Join(t.TempDir(), "missing.json"), "")
+	if err != nil || n != 0 {
+		t.Errorf("expected a missing snapshot to restore nothing, got %d, %v", n, err)
+	}
+}
+
+func TestSnapshotDepsKey(t *testing.T) {
+	importMap := []byte(`{"imports":{"react":"/@deps/react.js"}}`)
+	a := snapshotDepsKey("", importMap, map[string]string{"A": "1", "B": "2"})
+	if b := snapshotDepsKey("", importMap, map[string]string{"B": "2", "A": "1"}); a != b {
+		t.Errorf("expected the key not to depend on define order, got %s and %s", a, b)
+	}
+	if b := snapshotDepsKey("", importMap, map[string]string{"A": "1", "B": "3"}); a == b {
+		t.Error("expected the key to change with the defines")
+	}
+	if b := snapshotDepsKey("", []byte(`{}`), map[string]string{"A": "1", "B": "2"}); a == b {
+		t.Error("expected the key to change with the import map")
+	}
+}
@@ -0,0 +1,59 @@
+package esmdev
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// inlineSourceMapPrefix is the comment esbuild appends when Sourcemap is
+// api.SourceMapInline. The base64-encoded map runs to the end of the line.
+const inlineSourceMapPrefix = "//# sourceMappingURL=data:application/json;base64,"
+
+// splitInlineSourceMap separates transformed code from its trailing inline
+// source map comment. Returns the code without the comment and the decoded
+// map JSON, or the original code and nil if no inline map is present.
+func splitInlineSourceMap(code []byte) ([]byte, []byte) {
+	idx := bytes.LastIndex(code, []byte(inlineSourceMapPrefix))
+	if idx < 0 {
+		return code, nil
+	}
+	encoded := bytes.TrimSpace(code[idx+len(inlineSourceMapPrefix):])
+	decoded, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return code, nil
+	}
+	return code[:idx], decoded
+}
+
+// offsetSourceMap shifts every mapping in a source map down by the given
+// number of generated lines. In the mappings string each ";" starts a new
+// generated line and all other fields are relative to the previous segment,
+// so prepending empty lines is the only change needed.
+func offsetSourceMap(mapJSON []byte, lines int) ([]byte, error) {
+	if lines <= 0 {
+		return mapJSON, nil
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(mapJSON, &m); err != nil {
+		return nil, err
+	}
+	var mappings string
+	if raw, ok := m["mappings"]; ok {
+		if err := json.Unmarshal(raw, &mappings); err != nil {
+			return nil, err
+		}
+	}
+	shifted, err := json.Marshal(strings.Repeat(";", lines) + mappings)
+	if err != nil {
+		return nil, err
+	}
+	m["mappings"] = shifted
+	return json.Marshal(m)
+}
+
+// inlineSourceMapComment encodes a source map as an inline sourceMappingURL comment.
+func inlineSourceMapComment(mapJSON []byte) string {
+	return inlineSourceMapPrefix + base64.StdEncoding.EncodeToString(mapJSON) + "\n"
+}
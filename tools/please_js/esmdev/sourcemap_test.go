@@ -0,0 +1,123 @@
+package esmdev
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestSplitInlineSourceMap(t *testing.T) {
+	t.Run("no source map", func(t *testing.T) {
+		code := []byte("const a = 1;\n")
+		body, sm := splitInlineSourceMap(code)
+		if sm != nil {
+			t.Errorf("expected nil map, got %s", sm)
+		}
+		if string(body) != string(code) {
+			t.Errorf("expected code unchanged, got %q", body)
+		}
+	})
+
+	t.Run("invalid base64 left alone", func(t *testing.T) {
+		code := []byte("const a = 1;\n" + inlineSourceMapPrefix + "!!!\n")
+		body, sm := splitInlineSourceMap(code)
+		if sm != nil {
+			t.Errorf("expected nil map for invalid base64, got %s", sm)
+		}
+		if string(body) != string(code) {
+			t.Errorf("expected code unchanged, got %q", body)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		mapJSON := []byte(`{"version":3,"mappings":"AAAA"}`)
+		code := []byte("const a = 1;\n" + inlineSourceMapComment(mapJSON))
+		body, sm := splitInlineSourceMap(code)
+		if string(body) != "const a = 1;\n" {
+			t.Errorf("body = %q", body)
+		}
+		if string(sm) != string(mapJSON) {
+			t.Errorf("map = %s, want %s", sm, mapJSON)
+		}
+	})
+}
+
+func TestOffsetSourceMap(t *testing.T) {
+	out, err := offsetSourceMap([]byte(`{"version":3,"sources":["a.ts"],"mappings":"AAAA;AACA"}`), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		Version  int      `json:"version"`
+		Sources  []string `json:"sources"`
+		Mappings string   `json:"mappings"`
+	}
+	if err := json.Unmarshal(out, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Mappings != ";;;AAAA;AACA" {
+		t.Errorf("mappings = %q, want %q", m.Mappings, ";;;AAAA;AACA")
+	}
+	if m.Version != 3 || len(m.Sources) != 1 || m.Sources[0] != "a.ts" {
+		t.Errorf("other fields not preserved: %+v", m)
+	}
+}
+
+func TestInjectRefreshRegistration_OffsetsSourceMap(t *testing.T) {
+	result := api.Transform("export function App() {\n  return <div/>;\n}\n", api.TransformOptions{
+		Loader:     api.LoaderTSX,
+		Format:     api.FormatESModule,
+		JSX:        api.JSXAutomatic,
+		Sourcemap:  api.SourceMapInline,
+		Sourcefile: "/App.tsx",
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("transform failed: %v", result.Errors[0].Text)
+	}
+	_, origMap := splitInlineSourceMap(result.Code)
+	if origMap == nil {
+		t.Fatal("expected inline source map in transform output")
+	}
+	var orig struct{ Mappings string }
+	json.Unmarshal(origMap, &orig)
+
+	out := injectRefreshRegistration(result.Code, "/App.tsx", []string{"App"})
+
+	// The inline map must be the last thing in the file so browsers pick it up.
+	trimmed := strings.TrimRight(string(out), "\n")
+	lastLine := trimmed[strings.LastIndex(trimmed, "\n")+1:]
+	if !strings.HasPrefix(lastLine, inlineSourceMapPrefix) {
+		t.Fatalf("expected source map comment on last line, got %q", lastLine)
+	}
+	if strings.Count(string(out), inlineSourceMapPrefix) != 1 {
+		t.Error("expected exactly one inline source map comment")
+	}
+
+	_, newMap := splitInlineSourceMap(out)
+	var shifted struct{ Mappings string }
+	if err := json.Unmarshal(newMap, &shifted); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every original line must be shifted by exactly the number of lines the
+	// "function App" line moved between the original and wrapped output.
+	findLine := func(code string) int {
+		for i, line := range strings.Split(code, "\n") {
+			if strings.Contains(line, "function App") {
+				return i
+			}
+		}
+		t.Fatal("function App not found")
+		return -1
+	}
+	shift := findLine(string(out)) - findLine(string(result.Code))
+	if shift <= 0 {
+		t.Fatalf("expected preamble to shift code down, got shift %d", shift)
+	}
+	want := strings.Repeat(";", shift) + orig.Mappings
+	if shifted.Mappings != want {
+		t.Errorf("mappings = %q, want %q", shifted.Mappings, want)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"time"
+
+	"tools/please_js/common"
 )
 
 // tailwindEntry caches compiled Tailwind CSS output keyed by source file path.
@@ -15,7 +17,8 @@ type tailwindEntry struct {
 	modTime time.Time // mtime of the source CSS file at compile time
 }
 
-// compileTailwind runs the Tailwind CLI binary on a CSS file and caches the result.
+// compileTailwind runs the Tailwind CLI binary on a CSS file (v3 or v4) and
+// caches the result.
 // The cache is invalidated when the source CSS file's mtime changes.
 func (s *esmServer) compileTailwind(cssPath string) (string, error) {
 	info, err := os.Stat(cssPath)
@@ -31,15 +34,11 @@ func (s *esmServer) compileTailwind(cssPath string) (string, error) {
 		}
 	}
 
-	cmdArgs := []string{"--input", cssPath}
-	if s.tailwindConfig != "" {
-		cmdArgs = append(cmdArgs, "--config", filepath.Base(s.tailwindConfig))
-	}
-
-	cmd := exec.Command(s.tailwindBin, cmdArgs...)
-	if s.tailwindConfig != "" {
-		cmd.Dir = filepath.Dir(s.tailwindConfig)
+	css, err := os.ReadFile(cssPath)
+	if err != nil {
+		return "", err
 	}
+	cmd := common.TailwindCommand(s.tailwindBin, s.tailwindConfig, cssPath, css, s.tailwindSourceDirs())
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -53,9 +52,20 @@ func (s *esmServer) compileTailwind(cssPath string) (string, error) {
 		css:     result,
 		modTime: info.ModTime(),
 	})
+	s.tailwindFiles.Store(cssPath, true)
 	return result, nil
 }
 
+// tailwindSourceDirs returns the directories Tailwind v4 scans for class
+// names: the package root and every local library it serves.
+func (s *esmServer) tailwindSourceDirs() []string {
+	dirs := []string{s.packageRoot}
+	for _, dir := range s.localLibs {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
 // clearTailwindCache removes all entries from the tailwind cache.
 // Called on any source file change since new Tailwind classes may have been added.
 func (s *esmServer) clearTailwindCache() {
@@ -67,3 +77,37 @@ func (s *esmServer) clearTailwindCache() {
 		return true
 	})
 }
+
+// isTailwindContent reports whether a changed file may add or remove Tailwind
+// classes. Without parsed content globs, every source and HTML file counts.
+func (s *esmServer) isTailwindContent(path string) bool {
+	if s.tailwindBin == "" {
+		return false
+	}
+	if len(s.tailwindGlobs) == 0 {
+		ext := filepath.Ext(path)
+		return isSourceFileExt(ext) || ext == ".html"
+	}
+	slashed := filepath.ToSlash(path)
+	for _, re := range s.tailwindGlobs {
+		if re.MatchString(slashed) {
+			return true
+		}
+	}
+	return false
+}
+
+// tailwindCSSURLs returns the URL paths of every CSS file compiled through
+// Tailwind so far, sorted. These are pushed as css-updates when a content
+// file changes, so new classes apply without editing the stylesheet.
+func (s *esmServer) tailwindCSSURLs() []string {
+	var urls []string
+	s.tailwindFiles.Range(func(key, _ any) bool {
+		if u := s.urlPathFor(key.(string)); u != "" {
+			urls = append(urls, u)
+		}
+		return true
+	})
+	sort.Strings(urls)
+	return urls
+}
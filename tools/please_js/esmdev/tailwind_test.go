@@ -0,0 +1,73 @@
+package esmdev
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tools/please_js/common"
+)
+
+func TestIsTailwindContent(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "tailwind.config.js")
+	os.WriteFile(config, []byte(`module.exports = {
+  content: [
+    "./index.html",
+    './src/**/*.{js,tsx}',
+    "!./src/**/*.test.tsx",
+  ],
+  theme: { extend: {} },
+};
+`), 0644)
+
+	srv := &esmServer{tailwindBin: "tailwindcss", tailwindGlobs: common.ParseTailwindContent(config)}
+	if len(srv.tailwindGlobs) != 3 {
+		t.Fatalf("expected 3 patterns (negation skipped), got %d", len(srv.tailwindGlobs))
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"index.html", true},
+		{"src/App.tsx", true},
+		{"src/components/deep/Button.tsx", true},
+		{"src/main.js", true},
+		{"src/types.ts", false},
+		{"other/App.tsx", false},
+		{"about.html", false},
+	}
+	for _, tt := range tests {
+		if got := srv.isTailwindContent(filepath.Join(dir, tt.path)); got != tt.want {
+			t.Errorf("isTailwindContent(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIsTailwindContent_NoGlobs(t *testing.T) {
+	srv := &esmServer{}
+	if srv.isTailwindContent("/src/App.tsx") {
+		t.Error("expected false without tailwind configured")
+	}
+	srv.tailwindBin = "tailwindcss"
+	if !srv.isTailwindContent("/src/App.tsx") || !srv.isTailwindContent("/index.html") {
+		t.Error("expected source and HTML files to count as content when no globs were parsed")
+	}
+	if srv.isTailwindContent("/data.json") {
+		t.Error("expected JSON not to count as content")
+	}
+}
+
+func TestTailwindCSSURLs(t *testing.T) {
+	srv := &esmServer{packageRoot: "/repo/app"}
+	srv.tailwindFiles.Store("/repo/app/styles/main.css", true)
+	srv.tailwindFiles.Store("/repo/app/admin.css", true)
+	srv.tailwindFiles.Store("/elsewhere/x.css", true)
+	got := srv.tailwindCSSURLs()
+	want := []string{"/admin.css", "/styles/main.css"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("tailwindCSSURLs() = %v, want %v", got, want)
+	}
+}
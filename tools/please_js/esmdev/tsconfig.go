@@ -4,73 +4,10 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
-)
-
-// trailingCommaRe matches trailing commas before closing braces/brackets.
-var trailingCommaRe = regexp.MustCompile(`,\s*([}\]])`)
-
-// stripJSONC removes comments and trailing commas from JSONC content (as used
-// in tsconfig.json). Handles // line comments, /* */ block comments, and
-// trailing commas before } or ]. String contents are preserved.
-func stripJSONC(data []byte) []byte {
-	var result []byte
-	i := 0
-	inString := false
-
-	for i < len(data) {
-		if inString {
-			if data[i] == '\\' && i+1 < len(data) {
-				result = append(result, data[i], data[i+1])
-				i += 2
-				continue
-			}
-			if data[i] == '"' {
-				inString = false
-			}
-			result = append(result, data[i])
-			i++
-			continue
-		}
-
-		// Not in string
-		if data[i] == '"' {
-			inString = true
-			result = append(result, data[i])
-			i++
-			continue
-		}
-
-		// Line comment
-		if i+1 < len(data) && data[i] == '/' && data[i+1] == '/' {
-			for i < len(data) && data[i] != '\n' {
-				i++
-			}
-			continue
-		}
 
-		// Block comment
-		if i+1 < len(data) && data[i] == '/' && data[i+1] == '*' {
-			i += 2
-			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
-				i++
-			}
-			if i+1 < len(data) {
-				i += 2
-			}
-			continue
-		}
-
-		result = append(result, data[i])
-		i++
-	}
-
-	// Remove trailing commas before } and ]
-	result = trailingCommaRe.ReplaceAll(result, []byte("$1"))
-
-	return result
-}
+	"tools/please_js/common"
+)
 
 // parseTsconfigPaths reads a tsconfig.json and returns import map entries for
 // path aliases. Wildcard entries like "@/*": ["./src/*"] produce prefix mappings
@@ -84,7 +21,7 @@ func parseTsconfigPaths(tsconfigPath, packageRoot string) map[string]string {
 	}
 
 	// tsconfig.json supports JSONC (comments + trailing commas)
-	clean := stripJSONC(data)
+	clean := common.StripJSONC(data)
 
 	var tsconfig struct {
 		CompilerOptions struct {
@@ -27,6 +27,10 @@ func isSourceFileExt(ext string) bool {
 	return false
 }
 
+// sourceExts are the extensions tried for a source file. Browser variants
+// (util.browser.ts) come first, as they do in bundled builds.
+var sourceExts = []string{".browser.ts", ".browser.tsx", ".browser.js", ".browser.jsx", ".ts", ".tsx", ".js", ".jsx"}
+
 // resolveSourceFile finds the actual file for a URL path, trying various extensions.
 func resolveSourceFile(sourceRoot, urlPath string) string {
 	// Direct path
@@ -35,13 +39,11 @@ func resolveSourceFile(sourceRoot, urlPath string) string {
 		return full
 	}
 
-	exts := []string{".ts", ".tsx", ".js", ".jsx"}
-
 	// If the path has an extension like .js, try replacing it with .ts/.tsx/.jsx
 	// This handles <script src="/main.js"> when the actual file is main.tsx
 	if curExt := filepath.Ext(full); curExt != "" {
 		base := strings.TrimSuffix(full, curExt)
-		for _, ext := range exts {
+		for _, ext := range sourceExts {
 			if ext == curExt {
 				continue
 			}
@@ -53,7 +55,7 @@ func resolveSourceFile(sourceRoot, urlPath string) string {
 	}
 
 	// Try adding extensions to the path as-is (for extensionless paths)
-	for _, ext := range exts {
+	for _, ext := range sourceExts {
 		candidate := full + ext
 		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
 			return candidate
@@ -61,7 +63,7 @@ func resolveSourceFile(sourceRoot, urlPath string) string {
 	}
 
 	// Try index files
-	for _, ext := range exts {
+	for _, ext := range sourceExts {
 		candidate := filepath.Join(full, "index"+ext)
 		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
 			return candidate
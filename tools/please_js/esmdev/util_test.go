@@ -141,6 +141,24 @@ func TestResolveSourceFile(t *testing.T) {
 		}
 	})
 
+	t.Run("browser variant preferred", func(t *testing.T) {
+		storage := filepath.Join(dir, "storage.ts")
+		storageBrowser := filepath.Join(dir, "storage.browser.ts")
+		for _, f := range []string{storage, storageBrowser, filepath.Join(dir, "storage.node.ts")} {
+			if err := os.WriteFile(f, []byte("export {}"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, path := range []string{"/storage", "/storage.js"} {
+			if got := resolveSourceFile(dir, path); got != storageBrowser {
+				t.Errorf("resolveSourceFile(dir, %s) = %q, want %q", path, got, storageBrowser)
+			}
+		}
+		if got := resolveSourceFile(dir, "/storage.ts"); got != storage {
+			t.Errorf("resolveSourceFile(dir, /storage.ts) = %q, want %q", got, storage)
+		}
+	})
+
 	t.Run("not found returns empty", func(t *testing.T) {
 		got := resolveSourceFile(dir, "/nonexistent")
 		if got != "" {
@@ -0,0 +1,145 @@
+package esmdev
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"tools/please_js/common"
+)
+
+// watchedExts are the file types polled in watched directories.
+var watchedExts = map[string]bool{
+	".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".mjs": true,
+	".css": true, ".html": true, ".json": true,
+}
+
+// watchSet is the set of directories the file watcher polls. Rather than
+// walking the whole package root, which gets slower as a monorepo grows, it
+// starts from the entry and servedir and grows as modules are served or
+// imported, so polling cost tracks the app's import graph. The zero value is
+// ready to use.
+type watchSet struct {
+	dirs     sync.Map            // abs dir → true
+	ignore   *common.WatchIgnore // files never polled, from --watch-ignore
+	mu       sync.Mutex
+	baseline map[string]time.Time // mtimes of files in dirs added since the last poll
+}
+
+// add starts watching dir. The first time a directory is added its current
+// file mtimes are recorded as a baseline, so files that were already there
+// aren't reported as new on the next poll.
+func (w *watchSet) add(dir string) {
+	if _, loaded := w.dirs.LoadOrStore(dir, true); loaded {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.baseline == nil {
+		w.baseline = make(map[string]time.Time)
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if e.IsDir() || !watchedExts[filepath.Ext(e.Name())] || w.ignore.Match(path) {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			w.baseline[path] = info.ModTime()
+		}
+	}
+}
+
+// drainBaseline merges baseline mtimes recorded since the last poll into
+// mtimes, without overwriting files the watcher already knows about.
+func (w *watchSet) drainBaseline(mtimes map[string]time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for path, mt := range w.baseline {
+		if _, ok := mtimes[path]; !ok {
+			mtimes[path] = mt
+		}
+	}
+	w.baseline = nil
+}
+
+// watchGlob is an extra set of files to watch: a glob compiled to regexes,
+// walked from its static base directory.
+type watchGlob struct {
+	base     string
+	patterns []*regexp.Regexp
+}
+
+// newWatchGlob compiles a glob, resolving relative globs against root.
+func newWatchGlob(glob, root string) watchGlob {
+	if !filepath.IsAbs(glob) {
+		glob = filepath.Join(root, glob)
+	}
+	glob = filepath.ToSlash(glob)
+	return watchGlob{
+		base:     filepath.FromSlash(common.GlobBase(glob)),
+		patterns: common.CompileGlob(glob),
+	}
+}
+
+// watchPath starts watching the directory containing path.
+func (s *esmServer) watchPath(path string) {
+	if path != "" {
+		s.watched.add(filepath.Dir(path))
+	}
+}
+
+// walkSourceTree collects mtimes for files in watched directories and files
+// matching the extra watch globs, skipping hidden dirs, node_modules and
+// plz-out, and files --watch-ignore matches.
+func (s *esmServer) walkSourceTree(mtimes map[string]time.Time) {
+	s.watched.dirs.Range(func(key, _ any) bool {
+		dir := key.(string)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return true
+		}
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			if e.IsDir() || !watchedExts[filepath.Ext(e.Name())] || s.watched.ignore.Match(path) {
+				continue
+			}
+			if info, err := e.Info(); err == nil {
+				mtimes[path] = info.ModTime()
+			}
+		}
+		return true
+	})
+
+	for _, g := range s.watchGlobs {
+		filepath.Walk(g.base, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				name := info.Name()
+				if path != g.base && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "plz-out") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if s.watched.ignore.Match(path) {
+				return nil
+			}
+			slashed := filepath.ToSlash(path)
+			for _, re := range g.patterns {
+				if re.MatchString(slashed) {
+					mtimes[path] = info.ModTime()
+					break
+				}
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package esmdev
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"tools/please_js/common"
+)
+
+func TestWalkSourceTree_OnlyWatchedDirs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/main.tsx":               "",
+		"src/nested/Button.tsx":      "",
+		"other/huge/Unrelated.tsx":   "",
+		"styles/theme.css":           "",
+		"styles/deep/extra.css":      "",
+		"styles/deep/.hidden/x.css":  "",
+		"styles/deep/notes.txt":      "",
+		"styles/node_modules/y.css":  "",
+		"styles/deep/node_modules/a": "",
+	})
+	srv := &esmServer{sourceRoot: dir, packageRoot: dir}
+	srv.watchPath(filepath.Join(dir, "src/main.tsx"))
+	srv.watchGlobs = []watchGlob{newWatchGlob("styles/**/*.css", dir)}
+
+	mtimes := make(map[string]time.Time)
+	srv.walkSourceTree(mtimes)
+
+	var got []string
+	for path := range mtimes {
+		rel, _ := filepath.Rel(dir, path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	want := []string{"src/main.tsx", "styles/deep/extra.css", "styles/theme.css"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("walkSourceTree() = %v, want %v", got, want)
+	}
+}
+
+func TestWatchSet_BaselineSuppressesNewFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"lib/util.ts": "", "lib/README.md": ""})
+	util := filepath.Join(dir, "lib/util.ts")
+
+	var w watchSet
+	w.add(filepath.Join(dir, "lib"))
+	w.add(filepath.Join(dir, "lib")) // second add is a no-op
+
+	mtimes := make(map[string]time.Time)
+	w.drainBaseline(mtimes)
+	if _, ok := mtimes[util]; !ok || len(mtimes) != 1 {
+		t.Errorf("expected baseline to contain only util.ts, got %v", mtimes)
+	}
+
+	// Known files keep the watcher's own mtime.
+	known := time.Unix(1, 0)
+	mtimes[util] = known
+	w.add(filepath.Join(dir, "lib2"))
+	w.drainBaseline(mtimes)
+	if !mtimes[util].Equal(known) {
+		t.Error("drainBaseline overwrote an existing mtime")
+	}
+}
+
+func TestHandleSource_WatchesServedAndImportedDirs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/App.tsx":            `import { useThing } from "../hooks/useThing";` + "\nexport function App() { useThing(); return null; }\n",
+		"hooks/useThing.ts":      "export function useThing() {}\n",
+		"unrelated/Elsewhere.ts": "",
+	})
+	srv := &esmServer{sourceRoot: dir, packageRoot: dir, hasRefresh: true, entryURLPath: "/src/main.tsx"}
+
+	rec := httptest.NewRecorder()
+	srv.handleSource(rec, httptest.NewRequest("GET", "/src/App.tsx", nil), "/src/App.tsx", time.Now())
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	for _, d := range []string{"src", "hooks"} {
+		if _, ok := srv.watched.dirs.Load(filepath.Join(dir, d)); !ok {
+			t.Errorf("expected %s to be watched", d)
+		}
+	}
+	if _, ok := srv.watched.dirs.Load(filepath.Join(dir, "unrelated")); ok {
+		t.Error("expected unrelated dir not to be watched")
+	}
+}
+
+func TestWalkSourceTree_WatchIgnore(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/Button.tsx":               "",
+		"src/Button.stories.tsx":       "",
+		"src/__tests__/Button.test.ts": "",
+		"styles/theme.css":             "",
+		"styles/print.draft.css":       "",
+	})
+	srv := &esmServer{sourceRoot: dir, packageRoot: dir}
+	srv.watched.ignore = common.NewWatchIgnore([]string{"**/*.stories.tsx,**/__tests__/**", "**/*.draft.css"}, dir)
+	srv.watched.add(filepath.Join(dir, "src"))
+	srv.watched.add(filepath.Join(dir, "src/__tests__"))
+	srv.watchGlobs = []watchGlob{newWatchGlob("styles/**/*.css", dir)}
+
+	baseline := make(map[string]time.Time)
+	srv.watched.drainBaseline(baseline)
+	if _, ok := baseline[filepath.Join(dir, "src/Button.stories.tsx")]; ok || len(baseline) != 1 {
+		t.Errorf("expected the baseline to leave out ignored files, got %v", baseline)
+	}
+
+	mtimes := make(map[string]time.Time)
+	srv.walkSourceTree(mtimes)
+	var got []string
+	for path := range mtimes {
+		rel, _ := filepath.Rel(dir, path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	want := []string{"src/Button.tsx", "styles/theme.css"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("walkSourceTree() = %v, want %v", got, want)
+	}
+}
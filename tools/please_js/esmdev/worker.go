@@ -0,0 +1,134 @@
+package esmdev
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"tools/please_js/common"
+)
+
+// workletURLPrefix and workerURLPrefix serve worklet and worker modules
+// bundled with their dependencies: "/@worklet/src/processor.ts" is
+// src/processor.ts and everything it imports. Worklet and worker scopes
+// don't see the page's import map, so the per-file serving used for the
+// app's own modules can't resolve their bare imports. Classic workers, which
+// can't use import statements, are requested with "?classic" and served as
+// a single script.
+const (
+	workletURLPrefix = "/@worklet"
+	workerURLPrefix  = "/@worker"
+)
+
+// rewriteWorkerRefs points the addModule() calls and the workers created in
+// a served module at /@worklet/ and /@worker/ URLs, resolving their paths
+// against the module's own URL.
+func rewriteWorkerRefs(code []byte, urlPath string) []byte {
+	src := string(code)
+	hasWorklets, hasWorkers := common.HasWorkletRefs(src), common.HasWorkerRefs(src)
+	if !hasWorklets && !hasWorkers {
+		return code
+	}
+	if hasWorklets {
+		src = common.RewriteWorkletRefs(src, func(ref string) string {
+			return strconv.Quote(workletURLPrefix + path.Join(path.Dir(urlPath), ref))
+		})
+	}
+	if hasWorkers {
+		src = common.RewriteWorkerRefs(src, func(ref string, module bool) string {
+			url := workerURLPrefix + path.Join(path.Dir(urlPath), ref)
+			if !module {
+				url += "?classic"
+			}
+			return strconv.Quote(url)
+		})
+	}
+	return []byte(src)
+}
+
+// handleWorker bundles a worklet or worker module on demand: as an ES module,
+// or as an IIFE for a classic worker. The result isn't cached: workers are
+// small, and rebuilding picks up edits to any of their imports.
+func (s *esmServer) handleWorker(w http.ResponseWriter, r *http.Request, urlPath string, start time.Time) {
+	srcPath, ok := strings.CutPrefix(urlPath, workletURLPrefix)
+	if !ok {
+		srcPath = strings.TrimPrefix(urlPath, workerURLPrefix)
+	}
+	format := api.FormatESModule
+	if r.URL.Query().Has("classic") {
+		format = api.FormatIIFE
+	}
+	var resolved string
+	if specPath, ok := strings.CutPrefix(srcPath, "/@lib/"); ok {
+		if lib, dir := s.matchLocalLib(specPath); lib != "" {
+			resolved = resolveSourceFile(dir, "/"+strings.TrimPrefix(strings.TrimPrefix(specPath, lib), "/"))
+		}
+	} else {
+		resolved = resolveSourceFile(s.packageRoot, srcPath)
+		if resolved == "" && s.packageRoot != s.sourceRoot {
+			resolved = resolveSourceFile(s.sourceRoot, srcPath)
+		}
+	}
+	if resolved == "" {
+		http.NotFound(w, r)
+		fmt.Printf("  \033[2m[worker] %s %s → 404 (%dms)\033[0m\n",
+			r.Method, urlPath, time.Since(start).Milliseconds())
+		return
+	}
+
+	resolveExtensions, _ := common.ResolveExtensions("browser", "")
+	result := api.Build(api.BuildOptions{
+		EntryPoints:       []string{resolved},
+		Bundle:            true,
+		Write:             false,
+		Format:            format,
+		Platform:          api.PlatformBrowser,
+		Target:            api.ESNext,
+		JSX:               api.JSXAutomatic,
+		Sourcemap:         api.SourceMapInline,
+		Tsconfig:          s.tsconfig,
+		Define:            s.define,
+		LogLevel:          api.LogLevelSilent,
+		Metafile:          true,
+		ResolveExtensions: resolveExtensions,
+		Plugins: []api.Plugin{
+			common.ModuleResolvePlugin(s.moduleMap, "browser"),
+			common.RawImportPlugin(),
+			common.NodeBuiltinEmptyPlugin(s.moduleMap),
+		},
+	})
+	if len(result.Errors) > 0 || len(result.OutputFiles) == 0 {
+		errMsg := "no output"
+		if len(result.Errors) > 0 {
+			errMsg = result.Errors[0].Text
+		}
+		http.Error(w, errMsg, http.StatusInternalServerError)
+		fmt.Printf("  \033[1;31m[worker] %s %s → 500 %s (%dms)\033[0m\n",
+			r.Method, urlPath, errMsg, time.Since(start).Milliseconds())
+		return
+	}
+	// Watch everything the worker imports, not just its own directory.
+	var meta struct {
+		Inputs map[string]json.RawMessage `json:"inputs"`
+	}
+	json.Unmarshal([]byte(result.Metafile), &meta)
+	for input := range meta.Inputs {
+		if abs, err := filepath.Abs(input); err == nil && !strings.Contains(abs, "node_modules") {
+			s.watchPath(abs)
+		}
+	}
+	s.watchPath(resolved)
+
+	w.Header().Set("Content-Type", "application/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(result.OutputFiles[0].Contents)
+	fmt.Printf("  \033[2m[worker] %s %s → 200 (%dms)\033[0m\n",
+		r.Method, urlPath, time.Since(start).Milliseconds())
+}
@@ -0,0 +1,90 @@
+package esmdev
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkletServing(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(srcDir, "audio"), 0755)
+	os.WriteFile(filepath.Join(srcDir, "main.ts"),
+		[]byte(`await ctx.audioWorklet.addModule(new URL("./audio/processor.ts", import.meta.url));`+"\n"), 0644)
+	os.WriteFile(filepath.Join(srcDir, "audio", "processor.ts"),
+		[]byte(`import { gain } from "./dsp";`+"\n"+`registerProcessor("p", class { process() { return gain > 0; } });`+"\n"), 0644)
+	os.WriteFile(filepath.Join(srcDir, "audio", "dsp.ts"),
+		[]byte(`export const gain: number = 2;`+"\n"), 0644)
+
+	srv := &esmServer{sourceRoot: dir, packageRoot: dir}
+
+	rec := httptest.NewRecorder()
+	srv.handleSource(rec, httptest.NewRequest("GET", "/src/main.ts", nil), "/src/main.ts", time.Now())
+	if body := rec.Body.String(); !strings.Contains(body, `addModule("/@worklet/src/audio/processor.ts")`) {
+		t.Fatalf("expected the worklet reference rewritten, got:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/@worklet/src/audio/processor.ts", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "gain = 2") || strings.Contains(body, `from "./dsp"`) {
+		t.Errorf("expected the worklet bundled with its imports, got:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/@worklet/src/missing.ts", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing worklet, got %d", rec.Code)
+	}
+}
+
+func TestWorkerServing(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	os.MkdirAll(srcDir, 0755)
+	os.WriteFile(filepath.Join(srcDir, "main.ts"), []byte(
+		`new Worker(new URL("./worker.ts", import.meta.url), { type: "module" });`+"\n"+
+			`new SharedWorker(new URL("./worker.ts", import.meta.url));`+"\n"), 0644)
+	os.WriteFile(filepath.Join(srcDir, "worker.ts"),
+		[]byte(`import { n } from "./shared";`+"\n"+`export const doubled: number = n * 2;`+"\n"+`postMessage(doubled);`+"\n"), 0644)
+	os.WriteFile(filepath.Join(srcDir, "shared.ts"),
+		[]byte(`export const n: number = 21;`+"\n"), 0644)
+
+	srv := &esmServer{sourceRoot: dir, packageRoot: dir}
+
+	rec := httptest.NewRecorder()
+	srv.handleSource(rec, httptest.NewRequest("GET", "/src/main.ts", nil), "/src/main.ts", time.Now())
+	body := rec.Body.String()
+	if !strings.Contains(body, `new Worker("/@worker/src/worker.ts", { type: "module" })`) {
+		t.Errorf("expected the module worker URL rewritten, got:\n%s", body)
+	}
+	if !strings.Contains(body, `new SharedWorker("/@worker/src/worker.ts?classic")`) {
+		t.Errorf("expected the classic shared worker URL rewritten, got:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/@worker/src/worker.ts", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "export {") || strings.Contains(body, `from "./shared"`) {
+		t.Errorf("expected the module worker bundled as an ES module, got:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/@worker/src/worker.ts?classic", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if body := rec.Body.String(); strings.Contains(body, "export {") || !strings.Contains(body, "(() => {") {
+		t.Errorf("expected the classic worker bundled as a script, got:\n%s", body)
+	}
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/thought-machine/go-flags"
 
 	"tools/please_js/bundle"
+	"tools/please_js/common"
 	"tools/please_js/dev"
 	"tools/please_js/esmdev"
 	"tools/please_js/resolve"
@@ -17,80 +21,220 @@ var opts = struct {
 	Usage string
 
 	Bundle struct {
-		Entry          string   `short:"e" long:"entry" required:"true" description:"Entry point file"`
-		Out            string   `short:"o" long:"out" description:"Output file"`
-		OutDir         string   `long:"out-dir" description:"Output directory (for code splitting)"`
-		ModuleConfig   string   `short:"m" long:"moduleconfig" description:"Aggregated moduleconfig file"`
-		Format         string   `short:"f" long:"format" default:"esm" description:"Output format: esm, cjs, iife"`
-		Platform       string   `short:"p" long:"platform" default:"browser" description:"Target platform: browser, node"`
-		Target         string   `short:"t" long:"target" default:"esnext" description:"Target ES version"`
-		External       []string `long:"external" description:"External packages to exclude from bundle"`
-		Tsconfig       string   `long:"tsconfig" description:"Path to tsconfig.json (for JSX settings, paths, etc.)"`
-		Define         []string `long:"define" description:"Define substitutions (key=value)"`
-		Minify         bool     `long:"minify" description:"Minify output (syntax, whitespace, identifiers)"`
-		Splitting      bool     `long:"splitting" description:"Enable code splitting (requires ESM format)"`
-		HTML           bool     `long:"html" description:"Generate index.html with module scripts and preload hints"`
-		EnvFile        string   `long:"env-file" description:"Base .env file path for auto-discovery"`
-		EnvPrefix      string   `long:"env-prefix" default:"PLZ_" description:"Prefix filter for .env variables"`
-		TailwindBin    string   `long:"tailwind-bin" description:"Path to Tailwind CSS binary"`
-		TailwindConfig string   `long:"tailwind-config" description:"Path to tailwind.config.js"`
+		Entry                string   `short:"e" long:"entry" required:"true" description:"Entry point file"`
+		Out                  string   `short:"o" long:"out" description:"Output file"`
+		OutDir               string   `long:"out-dir" description:"Output directory (for code splitting)"`
+		ModuleConfig         string   `short:"m" long:"moduleconfig" description:"Aggregated moduleconfig file"`
+		ModuleAliases        []string `long:"module-alias" description:"Resolve a module to another moduleconfig entry: name=entry, e.g. react=react18 for an npm alias of another version (repeatable)"`
+		Config               string   `long:"config" description:"please_js.config.json of defaults for --define, --module-alias, --env-prefix and loaders; flags win over it"`
+		Format               string   `short:"f" long:"format" default:"esm" description:"Output format: esm, cjs, iife"`
+		Platform             string   `short:"p" long:"platform" default:"browser" description:"Target platform: browser, node"`
+		Target               string   `short:"t" long:"target" default:"esnext" description:"Target ES version"`
+		External             []string `long:"external" description:"External packages to exclude from bundle"`
+		ImportMap            string   `long:"importmap" description:"Browser import map JSON; every specifier it maps is left external (and embedded in --html output)"`
+		RemoteImports        bool     `long:"remote-imports" description:"Bundle https:// imports and npm: specifiers instead of leaving them external"`
+		RemoteCacheDir       string   `long:"remote-cache-dir" description:"Directory remote imports are cached in by content hash (default: the user cache directory)"`
+		RemoteLock           string   `long:"remote-lock" description:"JSON file pinning each remote import URL to its sha256 integrity; new URLs are added"`
+		RemoteLocked         bool     `long:"remote-locked" description:"Fail on remote imports not pinned in --remote-lock instead of adding them"`
+		RemoteOffline        bool     `long:"remote-offline" description:"Never download remote imports; serve them from --remote-cache-dir only (implies --remote-locked)"`
+		NpmCDN               string   `long:"npm-cdn" default:"https://esm.sh" description:"Base URL npm: specifiers are fetched from"`
+		Tsconfig             string   `long:"tsconfig" description:"Path to tsconfig.json (for JSX settings, paths, etc.)"`
+		Define               []string `long:"define" description:"Define substitutions (key=value)"`
+		Minify               bool     `long:"minify" description:"Minify output (syntax, whitespace, identifiers)"`
+		MinifyWith           string   `long:"minify-with" default:"esbuild" description:"Minifier used by --minify: esbuild, or terser as a pass over esbuild's output"`
+		TerserBin            string   `long:"terser-bin" description:"Path to the terser CLI, for --minify-with terser"`
+		TerserConfig         string   `long:"terser-config" description:"terser options JSON (compress, mangle, format, ...) passed as --config-file; default: --compress --mangle"`
+		Node                 string   `long:"node" description:"Path to Node.js binary used to run --terser-bin and --prerender (default for --prerender: node on PATH)"`
+		Splitting            bool     `long:"splitting" description:"Enable code splitting (requires ESM format)"`
+		HTML                 bool     `long:"html" description:"Generate index.html with module scripts and preload hints"`
+		HTMLTemplate         string   `long:"html-template" description:"With --html, HTML file to inject the module scripts and preload hints into instead of a bare page"`
+		Preconnect           []string `long:"preconnect" description:"With --html, origin to open a connection to early (repeatable)"`
+		DNSPrefetch          []string `long:"dns-prefetch" description:"With --html, origin to resolve early (repeatable)"`
+		Preload              []string `long:"preload" description:"With --html, preload font and image outputs matching this glob, e.g. *.woff2 (repeatable)"`
+		FetchPriority        []string `long:"fetch-priority" description:"With --html, fetchpriority for outputs matching a glob (pattern=high|low|auto, e.g. chunk-*.js=low; repeatable)"`
+		InlineCSS            string   `long:"inline-css" default:"none" description:"With --html, how much CSS to inline into index.html: critical, all, none"`
+		EnvFile              string   `long:"env-file" description:"Base .env file path for auto-discovery"`
+		EnvPrefix            string   `long:"env-prefix" description:"Prefix filter for .env variables (default: the --config's envPrefix, or PLZ_)"`
+		Mode                 string   `long:"mode" default:"production" description:"Build mode: selects .env.<mode> files and sets import.meta.env.MODE"`
+		TailwindBin          string   `long:"tailwind-bin" description:"Path to Tailwind CSS binary"`
+		TailwindConfig       string   `long:"tailwind-config" description:"Path to tailwind.config.js"`
+		AssetInlineLimit     int      `long:"asset-inline-limit" description:"Inline file assets at or below this many bytes as data URLs (0 disables)"`
+		AssetInlineOverrides []string `long:"asset-inline-ext" description:"Per-extension inline limit override (ext=bytes, e.g. .svg=8192)"`
+		NodeExecutable       bool     `long:"node-executable" description:"Add a node shebang, mark the output executable and write a package.json (requires --platform node)"`
+		CacheDir             string   `long:"cache-dir" description:"Directory for persistent build caches; unchanged builds are restored without running esbuild"`
+		ExtractMessages      string   `long:"extract-messages" description:"Write a catalog of translatable messages found in the bundled sources to this JSON file"`
+		MessageFunctions     []string `long:"message-function" default:"t" description:"Translation function names scanned by --extract-messages (repeatable)"`
+		UnusedFiles          string   `long:"unused-files" description:"Write the source files under --unused-root that the bundle never loads to this file"`
+		UnusedRoots          []string `long:"unused-root" description:"Directory scanned for --unused-files (repeatable; default: the entry point's directory)"`
+		UnusedIgnores        []string `long:"unused-ignore" description:"Glob, relative to each root, of files to leave out of --unused-files (repeatable)"`
+		ViteManifest         string   `long:"vite-manifest" description:"Write a Vite-compatible manifest.json of the bundle's outputs to this file"`
+		ViteManifestRoot     string   `long:"vite-manifest-root" description:"Directory --vite-manifest and SSR manifest source keys are relative to (default: the working directory)"`
+		OutputManifest       string   `long:"output-manifest" description:"Write the sha256 and size of every output file to this file (outputs must be in its directory)"`
+		SSREntry             string   `long:"ssr-entry" description:"Also bundle this server entry point for Node, sharing the client's define and env (requires --splitting)"`
+		SSROutDir            string   `long:"ssr-out-dir" description:"Output directory for the --ssr-entry bundle and its ssr-manifest.json"`
+		Prerender            []string `long:"prerender" description:"With --html, render these routes (comma-separated, e.g. /,/about) to <route>/index.html by running the app in jsdom under Node (repeatable)"`
+		Flavor               string   `long:"flavor" description:"Build flavor: resolve ./file to file.<flavor>.ts (or .tsx, .js, ...) before file.ts"`
 	} `command:"bundle" alias:"b" description:"Bundle JavaScript/TypeScript using esbuild"`
 
 	Transpile struct {
-		OutDir string `short:"o" long:"out-dir" required:"true" description:"Output directory for transpiled files"`
-		Args   struct {
+		OutDir     string   `short:"o" long:"out-dir" required:"true" description:"Output directory for transpiled files"`
+		Sourcemap  string   `long:"sourcemap" default:"inline" description:"Source maps for transpiled files: inline, or external to write <name>.js.map alongside"`
+		Formats    string   `long:"formats" default:"esm" description:"Comma-separated module formats: esm, cjs. Several are written to <format>/ directories as .mjs and .cjs"`
+		EntryPoint string   `long:"entry-point" default:"index.js" description:"Library entry point, for the package.json written with several --formats"`
+		Tsconfig   string   `long:"tsconfig" description:"Path to tsconfig.json whose path aliases are rewritten to relative imports"`
+		Root       string   `long:"root" description:"Mirror the sources' directories under this root in the output, rather than writing every file directly into --out-dir"`
+		Target     string   `long:"target" default:"esnext" description:"Comma-separated syntax targets to down-level to: an ES version (es2018) and/or runtime versions (node14)"`
+		Loaders    []string `long:"loader" description:"Override the loader for an extension: .ext=js|jsx|ts|tsx|copy (repeatable)"`
+		Dts        bool     `long:"dts" description:"Also emit .d.ts declarations for TypeScript sources with tsc, laid out as the sources are under their common directory"`
+		TscBin     string   `long:"tsc-bin" description:"Path to the tsc CLI, for --dts"`
+		Node       string   `long:"node" description:"Path to Node.js binary used to run --tsc-bin"`
+		Watch      bool     `long:"watch" description:"Keep running and re-transpile files as they change"`
+		Args       struct {
 			Sources []string `positional-arg-name:"sources" description:"Source files to transpile"`
 		} `positional-args:"true"`
 	} `command:"transpile" alias:"t" description:"Transpile individual files (TS->JS, JSX->JS) without bundling"`
 
 	Resolve struct {
-		Lockfile       string `short:"l" long:"lockfile" required:"true" description:"Path to package-lock.json"`
-		Out            string `short:"o" long:"out" required:"true" description:"Output directory for generated BUILD files"`
-		NoDev          bool   `long:"no-dev" description:"Exclude dev dependencies"`
-		SubincludePath string `long:"subinclude-path" default:"///js//build_defs:js" description:"Subinclude path for generated BUILD files"`
-	} `command:"resolve" alias:"r" description:"Generate npm_module BUILD files from package-lock.json"`
+		Lockfile       []string `short:"l" long:"lockfile" required:"true" description:"Path to package-lock.json, pnpm-lock.yaml, yarn.lock or bun.lock (repeatable, to merge several projects' into one subrepo; the first's versions go at the top level)"`
+		LockfileFormat string   `long:"lockfile-format" description:"Lockfile format: npm, pnpm, yarn or bun (default: detected from the file name)"`
+		Out            string   `short:"o" long:"out" required:"true" description:"Output directory for generated BUILD files"`
+		NoDev          bool     `long:"no-dev" description:"Exclude dev dependencies"`
+		SubincludePath string   `long:"subinclude-path" default:"///js//build_defs:js" description:"Subinclude path for generated BUILD files"`
+		Workspaces     []string `long:"workspace" description:"In-repo target for a workspace or local (file:) package: name=//path:target (repeatable; default: the directory's default target, or for a tarball the target named after the file)"`
+		PatchesDir     string   `long:"patches-dir" description:"Directory of patch-package patches (<pkg>+<version>.patch) to attach to the npm_module rules they name"`
+		PackageJSON    string   `long:"package-json" description:"package.json whose overrides, resolutions or pnpm.overrides to apply to the lockfile's versions"`
+		Only           []string `long:"only" description:"Only generate these packages and what they depend on: names, which may contain wildcards, e.g. react,@tanstack/* (repeatable or comma-separated)"`
+		Exclude        []string `long:"exclude" description:"Don't generate these packages, by name or wildcard; packages depending on them lose the dependency (repeatable or comma-separated)"`
+		Registry       string   `long:"registry" description:"npm registry mirror to fetch packages from instead of registry.npmjs.org, e.g. https://npm.internal.example.com"`
+		Npmrc          string   `long:"npmrc" description:".npmrc whose registry, @scope:registry and _authToken settings to apply. Tokens must be given as ${VARIABLE}"`
+		Visibility     []string `long:"visibility" description:"Visibility of the generated rules: PUBLIC, or labels in the repo, e.g. //app/... (repeatable or comma-separated; default: PUBLIC)"`
+		Labels         []string `long:"labels" description:"Labels to add to the generated rules, e.g. third_party (repeatable or comma-separated)"`
+		Flat           string   `long:"flat" optional:"yes" optional-value:"all" choice:"all" choice:"scope" description:"Write every package's rules into the root BUILD file, or with --flat=scope one BUILD file per scope, instead of a directory per package"`
+		Types          string   `long:"types" choice:"label" choice:"group" description:"Label @types packages npm:types, or with --types=group also put them all in the types directory with a types filegroup depending on them all"`
+		RegistryMeta   string   `long:"registry-meta" description:"Directory of cached npm registry metadata (<name>.json) to read licenses and install scripts the lockfile doesn't record, and package sizes, from"`
+		ForbidScripts  bool     `long:"forbid-scripts" description:"Fail if any package has preinstall, install or postinstall scripts, which the build doesn't run"`
+		StrictPeers    bool     `long:"strict-peers" description:"Fail if a package's peer dependency is missing or outside its range, instead of warning"`
+		LicenseAllow   []string `long:"license-allowlist" description:"License a dependency may have, e.g. MIT; fail if one has another (repeatable or comma-separated)"`
+		LicenseDeny    []string `long:"license-denylist" description:"License no dependency may have, e.g. GPL-3.0 (repeatable or comma-separated)"`
+		Graph          string   `long:"graph" description:"Write the resolved dependency graph, including version-conflict targets and the edges removed to break cycles, to this file: Graphviz DOT if it ends in .dot, JSON if .json"`
+		CycleStrategy  string   `long:"cycle-strategy" choice:"prefer-peer" choice:"prefer-dev" choice:"report" description:"Which edge of a circular dependency to remove: a peer dependency or one onto a dev-only package if there is one, or report to fail listing the cycles (default: the edge closing the cycle)"`
+		CycleReport    string   `long:"cycle-report" description:"File to write the edges removed to break circular dependencies to, as JSON"`
+		DedupeReport   string   `long:"dedupe-report" description:"File to write the packages that exist at more than one version to, with the packages that pin them and their size: JSON if it ends in .json, text otherwise"`
+		Audit          bool     `long:"audit" description:"Check every package for known vulnerabilities in OSV, and report them"`
+		AuditDB        string   `long:"audit-db" description:"Offline OSV database to audit against instead of the OSV API: a JSON file of vulnerabilities or a zip of them, such as OSV's npm all.zip"`
+		AuditLevel     string   `long:"audit-level" choice:"low" choice:"moderate" choice:"high" choice:"critical" description:"Fail if the audit finds a vulnerability of this severity or higher"`
+		Incremental    bool     `long:"incremental" description:"Update an existing output directory, rewriting only the BUILD files that changed and removing those of packages no longer in the lockfile"`
+		Check          bool     `long:"check" description:"Check that the output directory is what would be generated, without writing it; fail with a summary of the files that differ if not"`
+		DryRun         bool     `long:"dry-run" description:"Print the packages, versions and version-conflict targets regenerating the output directory would add, change and remove, without writing anything"`
+	} `command:"resolve" alias:"r" description:"Generate npm_module BUILD files from package-lock.json, pnpm-lock.yaml, yarn.lock or bun.lock"`
 
 	Dev struct {
-		Entry          string `short:"e" long:"entry" required:"true" description:"Entry point file"`
-		ModuleConfig   string `short:"m" long:"moduleconfig" description:"Aggregated moduleconfig file"`
-		Servedir       string `short:"s" long:"servedir" default:"." description:"Directory to serve static files from"`
-		Port           int    `short:"p" long:"port" default:"8080" description:"HTTP port"`
-		Format         string `short:"f" long:"format" default:"esm" description:"Output format: esm, cjs, iife"`
-		Platform       string `long:"platform" default:"browser" description:"Target platform: browser, node"`
-		Tsconfig       string `long:"tsconfig" description:"Path to tsconfig.json (for JSX settings, paths, etc.)"`
-		Define         []string `long:"define" description:"Define substitutions (key=value)"`
-		Proxy          []string `long:"proxy" description:"Proxy rules (prefix=target)"`
-		EnvFile        string   `long:"env-file" description:"Base .env file path for auto-discovery"`
-		EnvPrefix      string   `long:"env-prefix" default:"PLZ_" description:"Prefix filter for .env variables"`
-		TailwindBin    string   `long:"tailwind-bin" description:"Path to Tailwind CSS binary"`
-		TailwindConfig string   `long:"tailwind-config" description:"Path to tailwind.config.js"`
+		Entry             []string      `short:"e" long:"entry" required:"true" description:"Entry point file (repeatable; all are built and served together)"`
+		ModuleConfig      string        `short:"m" long:"moduleconfig" description:"Aggregated moduleconfig file"`
+		ModuleAliases     []string      `long:"module-alias" description:"Resolve a module to another moduleconfig entry: name=entry (repeatable)"`
+		Config            string        `long:"config" description:"please_js.config.json of defaults for --define, --proxy, --module-alias, --env-prefix and loaders; flags win over it"`
+		Servedir          string        `short:"s" long:"servedir" default:"." description:"Directory to serve static files from"`
+		PublicDir         []string      `long:"public-dir" description:"Directory whose files are served at the root, below the build outputs, and reload the page when they change (repeatable)"`
+		WatchIgnore       []string      `long:"watch-ignore" description:"Comma-separated globs of files whose changes don't reload the page, e.g. \"**/*.stories.tsx,**/__tests__/**\" (repeatable)"`
+		Host              string        `long:"host" description:"Address to listen on, e.g. 127.0.0.1 to keep the server off the network (default: all interfaces)"`
+		Port              int           `short:"p" long:"port" default:"8080" description:"HTTP port"`
+		Format            string        `short:"f" long:"format" default:"esm" description:"Output format: esm, cjs, iife"`
+		Platform          string        `long:"platform" default:"browser" description:"Target platform: browser, node"`
+		Tsconfig          string        `long:"tsconfig" description:"Path to tsconfig.json (for JSX settings, paths, etc.)"`
+		Define            []string      `long:"define" description:"Define substitutions (key=value)"`
+		Proxy             []string      `long:"proxy" description:"Proxy rules (prefix=target[;rewrite=from->to][;header=Name:value])"`
+		ProxyConfig       string        `long:"proxy-config" description:"JSON file of proxy routes, each with target, rewrite, changeOrigin, secure, ws and headers options"`
+		ProxyMaxIdle      int           `long:"proxy-max-idle" default:"32" description:"Idle keep-alive connections kept per proxy target"`
+		ProxyMaxConns     int           `long:"proxy-max-conns" description:"Maximum connections per proxy target (0 = unlimited)"`
+		ProxyIdleTimeout  time.Duration `long:"proxy-idle-timeout" default:"90s" description:"How long idle proxy connections are kept open"`
+		ProxyDialTimeout  time.Duration `long:"proxy-dial-timeout" default:"10s" description:"Timeout for connecting to a proxy target"`
+		ProxyTimeout      time.Duration `long:"proxy-timeout" description:"Timeout waiting for a proxy target's response headers (0 = none)"`
+		ProxyRetry        time.Duration `long:"proxy-retry" description:"Keep retrying refused proxy connections for this long, e.g. while the backend starts (0 = off)"`
+		ProxyWait         time.Duration `long:"proxy-wait" description:"Wait up to this long at startup for proxy targets to accept connections (0 = don't wait)"`
+		ProxyCookieDomain []string      `long:"proxy-cookie-domain" description:"Rewrite the Domain of cookies set by proxy targets: from=to, or a bare value for any domain (empty drops the attribute; repeatable)"`
+		ProxyCookiePath   []string      `long:"proxy-cookie-path" description:"Rewrite the Path of cookies set by proxy targets: from=to, or a bare value for any path (empty drops the attribute; repeatable)"`
+		Chaos             []string      `long:"chaos" description:"Delay or fail proxied requests under a prefix, e.g. /api/orders=500ms,5%error or /api=100ms-2s,10%503 (repeatable)"`
+		EnvFile           string        `long:"env-file" description:"Base .env file path for auto-discovery"`
+		EnvPrefix         string        `long:"env-prefix" description:"Prefix filter for .env variables (default: the --config's envPrefix, or PLZ_)"`
+		TailwindBin       string        `long:"tailwind-bin" description:"Path to Tailwind CSS binary"`
+		TailwindConfig    string        `long:"tailwind-config" description:"Path to tailwind.config.js"`
+		Flavor            string        `long:"flavor" description:"Build flavor: resolve ./file to file.<flavor>.ts (or .tsx, .js, ...) before file.ts"`
+		Rewrites          []string      `long:"rewrites" description:"Serve a page other than index.html for unmatched paths matching a regex, e.g. ^/admin=admin.html (repeatable)"`
+		NoFallback        []string      `long:"no-fallback" description:"Answer unmatched paths under this prefix, e.g. /api, with a 404 rather than index.html (repeatable)"`
+		NoDotFallback     bool          `long:"no-dot-fallback" description:"Answer unmatched paths with a dot in their last segment, like missing assets, with a 404 rather than index.html"`
+		HTTPS             bool          `long:"https" description:"Serve over HTTPS, with a self-signed certificate generated on first run and cached unless --cert and --key are given"`
+		Cert              string        `long:"cert" description:"TLS certificate to serve with --https"`
+		Key               string        `long:"key" description:"Private key of --cert"`
+		H2C               bool          `long:"h2c" description:"Also accept HTTP/2 without TLS (h2c), for a dev server behind a proxy that terminates TLS"`
+		Auth              string        `long:"auth" description:"Require this user:pass, with HTTP basic auth, to use the server, for one exposed on a shared or VPN network"`
+		CORS              bool          `long:"cors" description:"Set CORS headers allowing any origin on every response the server answers itself, and answer preflights"`
+		CORSOrigin        []string      `long:"cors-origin" description:"Like --cors, but only allow this origin, e.g. http://localhost:4000, with credentials (repeatable)"`
+		Header            []string      `long:"header" description:"Set a header on every response, e.g. \"Cross-Origin-Opener-Policy: same-origin\" (repeatable)"`
+		MockDir           string        `long:"mock-dir" description:"Directory of .json and .js files defining mock API routes, served before the proxy rules"`
+		Node              string        `long:"node" description:"Path to Node.js binary used to run .js mocks (default: node on PATH)"`
+		Throttle          time.Duration `long:"throttle" description:"Add this much latency to each response, e.g. 500ms, to simulate a slow network"`
+		Bandwidth         string        `long:"bandwidth" description:"Limit responses to this bandwidth, e.g. 1mbps or 512kbps, to simulate a slow network"`
+		Compress          bool          `long:"compress" description:"Serve responses gzip or brotli compressed, as Accept-Encoding asks, so transfer sizes match production's"`
+		LogRequests       string        `long:"log-requests" default:"full" choice:"off" choice:"summary" choice:"full" description:"Print a line per request (full), only failed requests and a periodic count of the rest (summary), or nothing (off)"`
+		LogFormat         string        `long:"log-format" default:"text" choice:"text" choice:"json" description:"Print request logs as text or as JSON lines"`
+		AccessLog         string        `long:"access-log" description:"Append every request to this file, in --log-format, whatever --log-requests is"`
+		Open              string        `long:"open" optional:"yes" optional-value:"/" description:"Open the default browser at the served URL, or at this path under it, once the first build completes"`
 	} `command:"dev" alias:"d" description:"Start dev server with live reload using esbuild"`
 
 	EsmDev struct {
-		Entry          string   `short:"e" long:"entry" required:"true" description:"Entry point file"`
-		ModuleConfig   string   `short:"m" long:"moduleconfig" description:"Aggregated moduleconfig file"`
-		Servedir       string   `short:"s" long:"servedir" default:"." description:"Directory to serve static files from"`
-		Port           int      `short:"p" long:"port" default:"3000" description:"HTTP port"`
-		Tsconfig       string   `long:"tsconfig" description:"Path to tsconfig.json"`
-		Define         []string `long:"define" description:"Define substitutions (key=value)"`
-		Proxy          []string `long:"proxy" description:"Proxy rules (prefix=target)"`
-		EnvFile        string   `long:"env-file" description:"Base .env file path for auto-discovery"`
-		EnvPrefix      string   `long:"env-prefix" default:"PLZ_" description:"Prefix filter for .env variables"`
-		PrebundleDir   string   `long:"prebundle-dir" description:"Path to pre-bundled deps directory (skips runtime prebundle)"`
-		Root           string   `long:"root" description:"Package root directory for source file resolution"`
-		TailwindBin    string   `long:"tailwind-bin" description:"Path to Tailwind CSS binary"`
-		TailwindConfig string   `long:"tailwind-config" description:"Path to tailwind.config.js"`
+		Entry             string        `short:"e" long:"entry" required:"true" description:"Entry point file"`
+		ModuleConfig      string        `short:"m" long:"moduleconfig" description:"Aggregated moduleconfig file"`
+		ModuleAliases     []string      `long:"module-alias" description:"Resolve a module to another moduleconfig entry: name=entry (repeatable)"`
+		Config            string        `long:"config" description:"please_js.config.json of defaults for --define, --proxy, --module-alias, --env-prefix and loaders; flags win over it"`
+		Servedir          string        `short:"s" long:"servedir" default:"." description:"Directory to serve static files from"`
+		Host              string        `long:"host" description:"Address to listen on, e.g. 127.0.0.1 to keep the server off the network (default: all interfaces)"`
+		Port              int           `short:"p" long:"port" default:"3000" description:"HTTP port"`
+		Tsconfig          string        `long:"tsconfig" description:"Path to tsconfig.json"`
+		Define            []string      `long:"define" description:"Define substitutions (key=value)"`
+		Proxy             []string      `long:"proxy" description:"Proxy rules (prefix=target[;rewrite=from->to][;header=Name:value])"`
+		ProxyConfig       string        `long:"proxy-config" description:"JSON file of proxy routes, each with target, rewrite, changeOrigin, secure, ws and headers options"`
+		ProxyMaxIdle      int           `long:"proxy-max-idle" default:"32" description:"Idle keep-alive connections kept per proxy target"`
+		ProxyMaxConns     int           `long:"proxy-max-conns" description:"Maximum connections per proxy target (0 = unlimited)"`
+		ProxyIdleTimeout  time.Duration `long:"proxy-idle-timeout" default:"90s" description:"How long idle proxy connections are kept open"`
+		ProxyDialTimeout  time.Duration `long:"proxy-dial-timeout" default:"10s" description:"Timeout for connecting to a proxy target"`
+		ProxyTimeout      time.Duration `long:"proxy-timeout" description:"Timeout waiting for a proxy target's response headers (0 = none)"`
+		ProxyRetry        time.Duration `long:"proxy-retry" description:"Keep retrying refused proxy connections for this long, e.g. while the backend starts (0 = off)"`
+		ProxyWait         time.Duration `long:"proxy-wait" description:"Wait up to this long at startup for proxy targets to accept connections (0 = don't wait)"`
+		ProxyCookieDomain []string      `long:"proxy-cookie-domain" description:"Rewrite the Domain of cookies set by proxy targets: from=to, or a bare value for any domain (empty drops the attribute; repeatable)"`
+		ProxyCookiePath   []string      `long:"proxy-cookie-path" description:"Rewrite the Path of cookies set by proxy targets: from=to, or a bare value for any path (empty drops the attribute; repeatable)"`
+		Chaos             []string      `long:"chaos" description:"Delay or fail proxied requests under a prefix, e.g. /api/orders=500ms,5%error or /api=100ms-2s,10%503 (repeatable)"`
+		EnvFile           string        `long:"env-file" description:"Base .env file path for auto-discovery"`
+		EnvPrefix         string        `long:"env-prefix" description:"Prefix filter for .env variables (default: the --config's envPrefix, or PLZ_)"`
+		PrebundleDir      string        `long:"prebundle-dir" description:"Path to pre-bundled deps directory (skips runtime prebundle)"`
+		Root              string        `long:"root" description:"Package root directory for source file resolution"`
+		TailwindBin       string        `long:"tailwind-bin" description:"Path to Tailwind CSS binary"`
+		TailwindConfig    string        `long:"tailwind-config" description:"Path to tailwind.config.js"`
+		WatchGlobs        []string      `long:"watch" description:"Extra glob to watch for changes, relative to --root (repeatable)"`
+		WatchIgnore       []string      `long:"watch-ignore" description:"Comma-separated globs, relative to --root, of files whose changes are ignored, e.g. \"**/*.stories.tsx,**/__tests__/**\" (repeatable)"`
+		Manifest          string        `long:"manifest" description:"Write the import map, entry URL and script tags to this JSON file, for backend-rendered pages (also served at /__esm_dev_manifest)"`
+		SnapshotOnExit    string        `long:"snapshot-on-exit" description:"Save the import graph, component map and on-demand deps to this file on exit, and restore them from it on start"`
+		HTTPS             bool          `long:"https" description:"Serve over HTTPS, with a self-signed certificate generated on first run and cached unless --cert and --key are given"`
+		Cert              string        `long:"cert" description:"TLS certificate to serve with --https"`
+		Key               string        `long:"key" description:"Private key of --cert"`
+		H2C               bool          `long:"h2c" description:"Also accept HTTP/2 without TLS (h2c), for a dev server behind a proxy that terminates TLS"`
+		Auth              string        `long:"auth" description:"Require this user:pass, with HTTP basic auth, to use the server, for one exposed on a shared or VPN network"`
+		CORS              bool          `long:"cors" description:"Set CORS headers allowing any origin on every response the server answers itself, and answer preflights"`
+		CORSOrigin        []string      `long:"cors-origin" description:"Like --cors, but only allow this origin, e.g. http://localhost:4000, with credentials (repeatable)"`
+		Open              string        `long:"open" optional:"yes" optional-value:"/" description:"Open the default browser at the served URL, or at this path under it, once the server is ready"`
 	} `command:"esm-dev" description:"Start ESM dev server with native import maps"`
 
 	Prebundle struct {
-		ModuleConfig string `short:"m" long:"moduleconfig" required:"true" description:"Aggregated moduleconfig file"`
-		Out          string `short:"o" long:"out" required:"true" description:"Output directory for pre-bundled deps"`
+		ModuleConfig   string   `short:"m" long:"moduleconfig" required:"true" description:"Aggregated moduleconfig file"`
+		Out            string   `short:"o" long:"out" required:"true" description:"Output directory for pre-bundled deps"`
+		ModuleAliases  []string `long:"module-alias" description:"Resolve a module to another moduleconfig entry: name=entry (repeatable)"`
+		OutputManifest bool     `long:"output-manifest" description:"Also write outputs.sha256 listing the sha256 and size of every output file"`
 	} `command:"prebundle" description:"Pre-bundle all npm dependencies for ESM dev server"`
 
 	PrebundlePkg struct {
-		ModuleConfig string `short:"m" long:"moduleconfig" required:"true" description:"Moduleconfig for a single package"`
-		Out          string `short:"o" long:"out" required:"true" description:"Output directory for pre-bundled package"`
-		Node         string `long:"node" description:"Path to Node.js binary for CJS export detection"`
+		ModuleConfig   string `short:"m" long:"moduleconfig" required:"true" description:"Moduleconfig for a single package"`
+		Out            string `short:"o" long:"out" required:"true" description:"Output directory for pre-bundled package"`
+		Node           string `long:"node" description:"Path to Node.js binary for CJS export detection"`
+		OutputManifest bool   `long:"output-manifest" description:"Also write outputs.sha256 listing the sha256 and size of every output file"`
 	} `command:"prebundle-pkg" description:"Pre-bundle a single npm package for ESM dev server"`
 
 	MergeImportmaps struct {
@@ -101,6 +245,24 @@ var opts = struct {
 			Files []string `positional-arg-name:"files" description:"importmap.json files to merge"`
 		} `positional-args:"true"`
 	} `command:"merge-importmaps" description:"Merge multiple importmap.json files into one"`
+
+	VerifyOutputs struct {
+		SizeOnly bool `long:"size-only" description:"Only check that each file exists with the recorded size, without hashing"`
+		Args     struct {
+			Manifests []string `positional-arg-name:"manifests" required:"1" description:"outputs.sha256 files to verify"`
+		} `positional-args:"true"`
+	} `command:"verify-outputs" description:"Check output files against the outputs.sha256 manifests listing them"`
+
+	VerifyIntegrity struct {
+		Integrity string `long:"integrity" required:"true" description:"Subresource Integrity string, as lockfiles record, e.g. sha512-..."`
+		Args      struct {
+			File string `positional-arg-name:"file" required:"true" description:"File to verify"`
+		} `positional-args:"true"`
+	} `command:"verify-integrity" description:"Check a downloaded file against a lockfile integrity hash"`
+
+	EnvTypes struct {
+		Out string `short:"o" long:"out" default:"please-js-env.d.ts" description:"Output .d.ts path"`
+	} `command:"env-types" description:"Write TypeScript declarations for import.meta.env and non-code imports"`
 }{
 	Usage: `
 please_js is the companion tool for the JavaScript/TypeScript Please build rules.
@@ -119,24 +281,60 @@ It provides these main operations:
 
 var subCommands = map[string]func() int{
 	"bundle": func() int {
+		config := loadProjectConfig(opts.Bundle.Config)
 		if err := bundle.Run(bundle.Args{
-			Entry:          opts.Bundle.Entry,
-			Out:            opts.Bundle.Out,
-			OutDir:         opts.Bundle.OutDir,
-			ModuleConfig:   opts.Bundle.ModuleConfig,
-			Format:         opts.Bundle.Format,
-			Platform:       opts.Bundle.Platform,
-			Target:         opts.Bundle.Target,
-			External:       opts.Bundle.External,
-			Define:         opts.Bundle.Define,
-			Minify:         opts.Bundle.Minify,
-			Splitting:      opts.Bundle.Splitting,
-			HTML:           opts.Bundle.HTML,
-			EnvFile:        opts.Bundle.EnvFile,
-			EnvPrefix:      opts.Bundle.EnvPrefix,
-			Tsconfig:       opts.Bundle.Tsconfig,
-			TailwindBin:    opts.Bundle.TailwindBin,
-			TailwindConfig: opts.Bundle.TailwindConfig,
+			Entry:                opts.Bundle.Entry,
+			Out:                  opts.Bundle.Out,
+			OutDir:               opts.Bundle.OutDir,
+			ModuleConfig:         opts.Bundle.ModuleConfig,
+			ModuleAliases:        config.ModuleAliases(opts.Bundle.ModuleAliases),
+			Format:               opts.Bundle.Format,
+			Platform:             opts.Bundle.Platform,
+			Target:               opts.Bundle.Target,
+			External:             opts.Bundle.External,
+			ImportMap:            opts.Bundle.ImportMap,
+			RemoteImports:        opts.Bundle.RemoteImports,
+			RemoteCacheDir:       opts.Bundle.RemoteCacheDir,
+			RemoteLock:           opts.Bundle.RemoteLock,
+			RemoteLocked:         opts.Bundle.RemoteLocked,
+			RemoteOffline:        opts.Bundle.RemoteOffline,
+			NpmCDN:               opts.Bundle.NpmCDN,
+			Define:               config.Defines(opts.Bundle.Define),
+			Minify:               opts.Bundle.Minify,
+			MinifyWith:           opts.Bundle.MinifyWith,
+			TerserBin:            opts.Bundle.TerserBin,
+			TerserConfig:         opts.Bundle.TerserConfig,
+			Node:                 opts.Bundle.Node,
+			Splitting:            opts.Bundle.Splitting,
+			HTML:                 opts.Bundle.HTML,
+			HTMLTemplate:         opts.Bundle.HTMLTemplate,
+			Preconnect:           opts.Bundle.Preconnect,
+			DNSPrefetch:          opts.Bundle.DNSPrefetch,
+			Preload:              opts.Bundle.Preload,
+			FetchPriority:        opts.Bundle.FetchPriority,
+			InlineCSS:            opts.Bundle.InlineCSS,
+			EnvFile:              opts.Bundle.EnvFile,
+			EnvPrefix:            config.EnvPrefixOr(opts.Bundle.EnvPrefix),
+			Mode:                 opts.Bundle.Mode,
+			Tsconfig:             opts.Bundle.Tsconfig,
+			TailwindBin:          opts.Bundle.TailwindBin,
+			TailwindConfig:       opts.Bundle.TailwindConfig,
+			AssetInlineLimit:     opts.Bundle.AssetInlineLimit,
+			AssetInlineOverrides: opts.Bundle.AssetInlineOverrides,
+			NodeExecutable:       opts.Bundle.NodeExecutable,
+			CacheDir:             opts.Bundle.CacheDir,
+			ExtractMessages:      opts.Bundle.ExtractMessages,
+			MessageFunctions:     opts.Bundle.MessageFunctions,
+			UnusedFiles:          opts.Bundle.UnusedFiles,
+			UnusedRoots:          opts.Bundle.UnusedRoots,
+			UnusedIgnores:        opts.Bundle.UnusedIgnores,
+			ViteManifest:         opts.Bundle.ViteManifest,
+			ViteManifestRoot:     opts.Bundle.ViteManifestRoot,
+			OutputManifest:       opts.Bundle.OutputManifest,
+			SSREntry:             opts.Bundle.SSREntry,
+			SSROutDir:            opts.Bundle.SSROutDir,
+			Prerender:            opts.Bundle.Prerender,
+			Flavor:               opts.Bundle.Flavor,
 		}); err != nil {
 			log.Fatal(err)
 		}
@@ -144,8 +342,19 @@ var subCommands = map[string]func() int{
 	},
 	"transpile": func() int {
 		if err := transpile.Run(transpile.Args{
-			OutDir: opts.Transpile.OutDir,
-			Srcs:   opts.Transpile.Args.Sources,
+			OutDir:     opts.Transpile.OutDir,
+			Srcs:       opts.Transpile.Args.Sources,
+			Sourcemap:  opts.Transpile.Sourcemap,
+			Formats:    strings.Split(opts.Transpile.Formats, ","),
+			EntryPoint: opts.Transpile.EntryPoint,
+			Tsconfig:   opts.Transpile.Tsconfig,
+			Root:       opts.Transpile.Root,
+			Target:     opts.Transpile.Target,
+			Loaders:    opts.Transpile.Loaders,
+			Dts:        opts.Transpile.Dts,
+			TscBin:     opts.Transpile.TscBin,
+			Node:       opts.Transpile.Node,
+			Watch:      opts.Transpile.Watch,
 		}); err != nil {
 			log.Fatal(err)
 		}
@@ -153,65 +362,179 @@ var subCommands = map[string]func() int{
 	},
 	"resolve": func() int {
 		if err := resolve.Run(resolve.Args{
-			Lockfile:       opts.Resolve.Lockfile,
+			Lockfiles:      opts.Resolve.Lockfile,
+			LockfileFormat: opts.Resolve.LockfileFormat,
 			Out:            opts.Resolve.Out,
 			NoDev:          opts.Resolve.NoDev,
 			SubincludePath: opts.Resolve.SubincludePath,
+			Workspaces:     opts.Resolve.Workspaces,
+			PatchesDir:     opts.Resolve.PatchesDir,
+			PackageJSON:    opts.Resolve.PackageJSON,
+			Incremental:    opts.Resolve.Incremental,
+			Check:          opts.Resolve.Check,
+			DryRun:         opts.Resolve.DryRun,
+			Only:           opts.Resolve.Only,
+			Exclude:        opts.Resolve.Exclude,
+			Registry:       opts.Resolve.Registry,
+			Npmrc:          opts.Resolve.Npmrc,
+			Visibility:     opts.Resolve.Visibility,
+			Labels:         opts.Resolve.Labels,
+			Flat:           opts.Resolve.Flat,
+			Types:          opts.Resolve.Types,
+			RegistryMeta:   opts.Resolve.RegistryMeta,
+			ForbidScripts:  opts.Resolve.ForbidScripts,
+			StrictPeers:    opts.Resolve.StrictPeers,
+			LicenseAllow:   opts.Resolve.LicenseAllow,
+			LicenseDeny:    opts.Resolve.LicenseDeny,
+			Graph:          opts.Resolve.Graph,
+			CycleStrategy:  opts.Resolve.CycleStrategy,
+			CycleReport:    opts.Resolve.CycleReport,
+			DedupeReport:   opts.Resolve.DedupeReport,
+			Audit:          opts.Resolve.Audit || opts.Resolve.AuditDB != "" || opts.Resolve.AuditLevel != "",
+			AuditDB:        opts.Resolve.AuditDB,
+			AuditLevel:     opts.Resolve.AuditLevel,
 		}); err != nil {
 			log.Fatal(err)
 		}
 		return 0
 	},
 	"dev": func() int {
+		config := loadProjectConfig(opts.Dev.Config)
+		proxyRules, err := config.ProxyRules()
+		if err != nil {
+			log.Fatal(err)
+		}
 		if err := dev.Run(dev.Args{
-			Entry:          opts.Dev.Entry,
-			ModuleConfig:   opts.Dev.ModuleConfig,
-			Servedir:       opts.Dev.Servedir,
-			Port:           opts.Dev.Port,
-			Format:         opts.Dev.Format,
-			Platform:       opts.Dev.Platform,
-			Define:         opts.Dev.Define,
-			Proxy:          opts.Dev.Proxy,
+			Entries:       opts.Dev.Entry,
+			ModuleConfig:  opts.Dev.ModuleConfig,
+			ModuleAliases: config.ModuleAliases(opts.Dev.ModuleAliases),
+			Servedir:      opts.Dev.Servedir,
+			PublicDirs:    opts.Dev.PublicDir,
+			WatchIgnore:   opts.Dev.WatchIgnore,
+			Host:          opts.Dev.Host,
+			Port:          opts.Dev.Port,
+			Format:        opts.Dev.Format,
+			Platform:      opts.Dev.Platform,
+			Define:        config.Defines(opts.Dev.Define),
+			Proxy:         opts.Dev.Proxy,
+			ProxyConfig:   opts.Dev.ProxyConfig,
+			ProxyRules:    proxyRules,
+			ProxyOptions: common.ProxyOptions{
+				MaxIdleConns:        opts.Dev.ProxyMaxIdle,
+				MaxConns:            opts.Dev.ProxyMaxConns,
+				IdleTimeout:         opts.Dev.ProxyIdleTimeout,
+				DialTimeout:         opts.Dev.ProxyDialTimeout,
+				ResponseTimeout:     opts.Dev.ProxyTimeout,
+				RetryRefused:        opts.Dev.ProxyRetry,
+				Wait:                opts.Dev.ProxyWait,
+				CookieDomainRewrite: common.ParseCookieRewrites(opts.Dev.ProxyCookieDomain),
+				CookiePathRewrite:   common.ParseCookieRewrites(opts.Dev.ProxyCookiePath),
+				Chaos:               opts.Dev.Chaos,
+			},
 			EnvFile:        opts.Dev.EnvFile,
-			EnvPrefix:      opts.Dev.EnvPrefix,
+			EnvPrefix:      config.EnvPrefixOr(opts.Dev.EnvPrefix),
 			Tsconfig:       opts.Dev.Tsconfig,
 			TailwindBin:    opts.Dev.TailwindBin,
 			TailwindConfig: opts.Dev.TailwindConfig,
+			Flavor:         opts.Dev.Flavor,
+			Rewrites:       opts.Dev.Rewrites,
+			NoFallback:     opts.Dev.NoFallback,
+			NoDotFallback:  opts.Dev.NoDotFallback,
+			HTTPS:          opts.Dev.HTTPS || opts.Dev.Cert != "",
+			Cert:           opts.Dev.Cert,
+			Key:            opts.Dev.Key,
+			H2C:            opts.Dev.H2C,
+			Auth:           opts.Dev.Auth,
+			CORS:           opts.Dev.CORS,
+			CORSOrigins:    opts.Dev.CORSOrigin,
+			Headers:        opts.Dev.Header,
+			MockDir:        opts.Dev.MockDir,
+			Node:           opts.Dev.Node,
+			Throttle:       opts.Dev.Throttle,
+			Bandwidth:      opts.Dev.Bandwidth,
+			Compress:       opts.Dev.Compress,
+			LogRequests:    opts.Dev.LogRequests,
+			LogFormat:      opts.Dev.LogFormat,
+			AccessLog:      opts.Dev.AccessLog,
+			Open:           opts.Dev.Open,
 		}); err != nil {
 			log.Fatal(err)
 		}
 		return 0
 	},
 	"esm-dev": func() int {
+		config := loadProjectConfig(opts.EsmDev.Config)
+		proxyRules, err := config.ProxyRules()
+		if err != nil {
+			log.Fatal(err)
+		}
 		if err := esmdev.Run(esmdev.Args{
-			Entry:          opts.EsmDev.Entry,
-			ModuleConfig:   opts.EsmDev.ModuleConfig,
-			Servedir:       opts.EsmDev.Servedir,
-			Port:           opts.EsmDev.Port,
-			Tsconfig:       opts.EsmDev.Tsconfig,
-			Define:         opts.EsmDev.Define,
-			Proxy:          opts.EsmDev.Proxy,
+			Entry:         opts.EsmDev.Entry,
+			ModuleConfig:  opts.EsmDev.ModuleConfig,
+			ModuleAliases: config.ModuleAliases(opts.EsmDev.ModuleAliases),
+			Servedir:      opts.EsmDev.Servedir,
+			Host:          opts.EsmDev.Host,
+			Port:          opts.EsmDev.Port,
+			Tsconfig:      opts.EsmDev.Tsconfig,
+			Define:        config.Defines(opts.EsmDev.Define),
+			Proxy:         opts.EsmDev.Proxy,
+			ProxyConfig:   opts.EsmDev.ProxyConfig,
+			ProxyRules:    proxyRules,
+			ProxyOptions: common.ProxyOptions{
+				MaxIdleConns:        opts.EsmDev.ProxyMaxIdle,
+				MaxConns:            opts.EsmDev.ProxyMaxConns,
+				IdleTimeout:         opts.EsmDev.ProxyIdleTimeout,
+				DialTimeout:         opts.EsmDev.ProxyDialTimeout,
+				ResponseTimeout:     opts.EsmDev.ProxyTimeout,
+				RetryRefused:        opts.EsmDev.ProxyRetry,
+				Wait:                opts.EsmDev.ProxyWait,
+				CookieDomainRewrite: common.ParseCookieRewrites(opts.EsmDev.ProxyCookieDomain),
+				CookiePathRewrite:   common.ParseCookieRewrites(opts.EsmDev.ProxyCookiePath),
+				Chaos:               opts.EsmDev.Chaos,
+			},
 			EnvFile:        opts.EsmDev.EnvFile,
-			EnvPrefix:      opts.EsmDev.EnvPrefix,
+			EnvPrefix:      config.EnvPrefixOr(opts.EsmDev.EnvPrefix),
 			PrebundleDir:   opts.EsmDev.PrebundleDir,
 			Root:           opts.EsmDev.Root,
 			TailwindBin:    opts.EsmDev.TailwindBin,
 			TailwindConfig: opts.EsmDev.TailwindConfig,
+			WatchGlobs:     opts.EsmDev.WatchGlobs,
+			WatchIgnore:    opts.EsmDev.WatchIgnore,
+			Manifest:       opts.EsmDev.Manifest,
+			SnapshotOnExit: opts.EsmDev.SnapshotOnExit,
+			HTTPS:          opts.EsmDev.HTTPS || opts.EsmDev.Cert != "",
+			Cert:           opts.EsmDev.Cert,
+			Key:            opts.EsmDev.Key,
+			H2C:            opts.EsmDev.H2C,
+			Auth:           opts.EsmDev.Auth,
+			CORS:           opts.EsmDev.CORS,
+			CORSOrigins:    opts.EsmDev.CORSOrigin,
+			Open:           opts.EsmDev.Open,
 		}); err != nil {
 			log.Fatal(err)
 		}
 		return 0
 	},
 	"prebundle": func() int {
-		if err := esmdev.PrebundleAll(opts.Prebundle.ModuleConfig, opts.Prebundle.Out); err != nil {
+		if err := esmdev.PrebundleAll(opts.Prebundle.ModuleConfig, opts.Prebundle.Out, opts.Prebundle.ModuleAliases); err != nil {
 			log.Fatal(err)
 		}
+		if opts.Prebundle.OutputManifest {
+			if err := common.WriteOutputManifestDir(filepath.Join(opts.Prebundle.Out, "outputs.sha256")); err != nil {
+				log.Fatal(err)
+			}
+		}
 		return 0
 	},
 	"prebundle-pkg": func() int {
 		if err := esmdev.PrebundlePkg(opts.PrebundlePkg.ModuleConfig, opts.PrebundlePkg.Out, opts.PrebundlePkg.Node); err != nil {
 			log.Fatal(err)
 		}
+		if opts.PrebundlePkg.OutputManifest {
+			if err := common.WriteOutputManifestDir(filepath.Join(opts.PrebundlePkg.Out, "outputs.sha256")); err != nil {
+				log.Fatal(err)
+			}
+		}
 		return 0
 	},
 	"merge-importmaps": func() int {
@@ -221,6 +544,38 @@ var subCommands = map[string]func() int{
 		}
 		return 0
 	},
+	"verify-outputs": func() int {
+		for _, manifest := range opts.VerifyOutputs.Args.Manifests {
+			if err := common.VerifyOutputManifest(manifest, opts.VerifyOutputs.SizeOnly); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return 0
+	},
+	"verify-integrity": func() int {
+		if err := common.VerifyIntegrity(opts.VerifyIntegrity.Args.File, opts.VerifyIntegrity.Integrity); err != nil {
+			log.Fatal(err)
+		}
+		return 0
+	},
+	"env-types": func() int {
+		if err := os.WriteFile(opts.EnvTypes.Out, []byte(common.EnvTypes()), 0644); err != nil {
+			log.Fatal(err)
+		}
+		return 0
+	},
+}
+
+// loadProjectConfig reads a --config file and applies its loaders, or exits.
+func loadProjectConfig(path string) *common.ProjectConfig {
+	config, err := common.LoadProjectConfig(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := config.ApplyLoaders(); err != nil {
+		log.Fatal(err)
+	}
+	return config
 }
 
 func main() {
@@ -0,0 +1,332 @@
+package resolve
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// osvAPI is the OSV vulnerability database's API.
+const osvAPI = "https://api.osv.dev"
+
+// osvBatchSize is how many packages the API takes in one query.
+const osvBatchSize = 1000
+
+// osvVuln is an OSV vulnerability record, with the fields an audit reads.
+type osvVuln struct {
+	ID               string        `json:"id"`
+	Summary          string        `json:"summary"`
+	Affected         []osvAffected `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"` // GitHub advisories: LOW, MODERATE, HIGH or CRITICAL
+	} `json:"database_specific"`
+}
+
+// osvAffected is a package a vulnerability affects, and at which versions.
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string              `json:"type"`
+		Events []map[string]string `json:"events"` // introduced, fixed or last_affected
+	} `json:"ranges"`
+	Versions []string `json:"versions"`
+}
+
+// severity returns the vulnerability's severity, "low" to "critical", or
+// "unknown" if it doesn't give one.
+func (v osvVuln) severity() string {
+	if s := strings.ToLower(v.DatabaseSpecific.Severity); s != "" {
+		return s
+	}
+	return "unknown"
+}
+
+// auditLevels ranks severities, as npm audit's --audit-level does.
+// Vulnerabilities without one, such as malicious packages, rank highest.
+var auditLevels = map[string]int{"low": 1, "moderate": 2, "medium": 2, "high": 3, "critical": 4, "unknown": 5}
+
+// auditFinding is a vulnerability in a version of a package.
+type auditFinding struct {
+	pkg  packageRef
+	vuln osvVuln
+}
+
+// audit looks up the vulnerabilities in each package, in the offline OSV
+// database db, a JSON file or a zip of them as OSV exports them, or else
+// from the OSV API.
+func audit(refs []packageRef, db string) ([]auditFinding, error) {
+	if db != "" {
+		vulns, err := readOSVDatabase(db)
+		if err != nil {
+			return nil, err
+		}
+		byName := map[string][]osvVuln{}
+		for _, v := range vulns {
+			for _, a := range v.Affected {
+				if a.Package.Ecosystem == "npm" {
+					byName[a.Package.Name] = append(byName[a.Package.Name], v)
+				}
+			}
+		}
+		var findings []auditFinding
+		for _, ref := range refs {
+			seen := map[string]bool{}
+			for _, v := range byName[ref.name] {
+				if !seen[v.ID] && osvAffects(v, ref) {
+					seen[v.ID] = true
+					findings = append(findings, auditFinding{ref, v})
+				}
+			}
+		}
+		return findings, nil
+	}
+	return queryOSV(&http.Client{Timeout: 60 * time.Second}, refs)
+}
+
+// queryOSV asks the OSV API which vulnerabilities affect each package, then
+// fetches each vulnerability's record for its summary and severity.
+func queryOSV(client *http.Client, refs []packageRef) ([]auditFinding, error) {
+	type query struct {
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		Version string `json:"version"`
+	}
+	var ids [][]string
+	for start := 0; start < len(refs); start += osvBatchSize {
+		batch := refs[start:min(start+osvBatchSize, len(refs))]
+		var req struct {
+			Queries []query `json:"queries"`
+		}
+		for _, ref := range batch {
+			var q query
+			q.Package.Name, q.Package.Ecosystem, q.Version = ref.name, "npm", ref.version
+			req.Queries = append(req.Queries, q)
+		}
+		body, _ := json.Marshal(req)
+		var resp struct {
+			Results []struct {
+				Vulns []struct {
+					ID string `json:"id"`
+				} `json:"vulns"`
+			} `json:"results"`
+		}
+		if err := osvRequest(client, http.MethodPost, osvAPI+"/v1/querybatch", body, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.Results) != len(batch) {
+			return nil, fmt.Errorf("OSV returned %d results for %d packages", len(resp.Results), len(batch))
+		}
+		for _, result := range resp.Results {
+			var vulnIDs []string
+			for _, v := range result.Vulns {
+				vulnIDs = append(vulnIDs, v.ID)
+			}
+			ids = append(ids, vulnIDs)
+		}
+	}
+
+	vulns := map[string]osvVuln{}
+	var findings []auditFinding
+	for i, ref := range refs {
+		for _, id := range ids[i] {
+			v, ok := vulns[id]
+			if !ok {
+				if err := osvRequest(client, http.MethodGet, osvAPI+"/v1/vulns/"+id, nil, &v); err != nil {
+					return nil, err
+				}
+				vulns[id] = v
+			}
+			findings = append(findings, auditFinding{ref, v})
+		}
+	}
+	return findings, nil
+}
+
+// osvRequest makes a request of the OSV API, decoding its JSON response.
+func osvRequest(client *http.Client, method, url string, body []byte, out any) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query OSV: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to query OSV: %s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse OSV response from %s: %w", url, err)
+	}
+	return nil
+}
+
+// readOSVDatabase reads an offline OSV database: a zip of vulnerability
+// records, as https://osv-vulnerabilities.storage.googleapis.com/npm/all.zip
+// is, or a JSON file of one record or a list of them.
+func readOSVDatabase(path string) ([]osvVuln, error) {
+	if filepath.Ext(path) != ".zip" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit database: %w", err)
+		}
+		return parseOSVRecords(path, data)
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit database: %w", err)
+	}
+	defer r.Close()
+	var vulns []osvVuln
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".json") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in audit database: %w", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in audit database: %w", f.Name, err)
+		}
+		records, err := parseOSVRecords(f.Name, data)
+		if err != nil {
+			return nil, err
+		}
+		vulns = append(vulns, records...)
+	}
+	return vulns, nil
+}
+
+// parseOSVRecords parses a JSON vulnerability record, or a list of them.
+func parseOSVRecords(name string, data []byte) ([]osvVuln, error) {
+	var vulns []osvVuln
+	if err := json.Unmarshal(data, &vulns); err == nil {
+		return vulns, nil
+	}
+	var v osvVuln
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return []osvVuln{v}, nil
+}
+
+// osvAffects reports whether a vulnerability affects a version of a
+// package: it's listed, or in a SEMVER range, whose events are in order.
+func osvAffects(v osvVuln, ref packageRef) bool {
+	version, n, ok := parsePartialVersion(ref.version)
+	for _, a := range v.Affected {
+		if a.Package.Ecosystem != "npm" || a.Package.Name != ref.name {
+			continue
+		}
+		for _, listed := range a.Versions {
+			if listed == ref.version {
+				return true
+			}
+		}
+		if !ok || n < 3 {
+			continue
+		}
+		for _, r := range a.Ranges {
+			if r.Type != "SEMVER" {
+				continue
+			}
+			affected := false
+			for _, event := range r.Events {
+				for kind, at := range event {
+					bound, _, _ := parsePartialVersion(at)
+					switch cmp := compareVersions(version, bound); kind {
+					case "introduced":
+						if cmp >= 0 {
+							affected = true
+						}
+					case "fixed":
+						if cmp >= 0 {
+							affected = false
+						}
+					case "last_affected":
+						if cmp > 0 {
+							affected = false
+						}
+					}
+				}
+			}
+			if affected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// auditReport prints a report of an audit's findings, and returns an error
+// if any are at or above level, "" for none to fail on.
+func auditReport(w io.Writer, findings []auditFinding, audited int, level string) error {
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.pkg.name != b.pkg.name {
+			return a.pkg.name < b.pkg.name
+		}
+		if a.pkg.version != b.pkg.version {
+			return a.pkg.version < b.pkg.version
+		}
+		return a.vuln.ID < b.vuln.ID
+	})
+	if len(findings) == 0 {
+		fmt.Fprintf(w, "Audited %d packages: no known vulnerabilities\n", audited)
+		return nil
+	}
+	fmt.Fprintf(w, "Audited %d packages: %d known vulnerabilities\n", audited, len(findings))
+	failing := 0
+	for _, f := range findings {
+		fmt.Fprintf(w, "  %s@%s: %s (%s) %s\n", f.pkg.name, f.pkg.version, f.vuln.ID, f.vuln.severity(), f.vuln.Summary)
+		if level != "" && auditLevels[f.vuln.severity()] >= auditLevels[level] {
+			failing++
+		}
+	}
+	if failing > 0 {
+		return fmt.Errorf("audit found %d vulnerabilities at or above %s severity", failing, level)
+	}
+	return nil
+}
+
+// auditRefs returns the packages an audit checks: every npm package in the
+// subrepo, once each.
+func auditRefs(packages []resolvedPackage, ctargets []conflictTarget) []packageRef {
+	seen := map[packageRef]bool{}
+	var refs []packageRef
+	add := func(ref packageRef) {
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	for _, pkg := range packages {
+		if pkg.Workspace == "" {
+			add(packageRef{name: pkg.effectivePkgName(), version: pkg.Version})
+		}
+	}
+	for _, ct := range ctargets {
+		add(packageRef{name: ct.PkgName, version: ct.Version})
+	}
+	return refs
+}
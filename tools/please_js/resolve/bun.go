@@ -0,0 +1,204 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tools/please_js/common"
+)
+
+// bunLock is Bun's text lockfile, bun.lock. Packages are keyed by where
+// bun installs them ("debug/ms" is node_modules/debug/node_modules/ms),
+// and each is an array: ["name@version", registry, metadata, integrity].
+type bunLock struct {
+	LockfileVersion int                          `json:"lockfileVersion"`
+	Workspaces      map[string]bunWorkspace      `json:"workspaces"`
+	Packages        map[string][]json.RawMessage `json:"packages"`
+}
+
+// bunWorkspace is the root project or one of its workspaces.
+type bunWorkspace struct {
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+}
+
+// bunPackageMeta is the metadata element of a package entry.
+type bunPackageMeta struct {
+	Dependencies         map[string]string `json:"dependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+	OptionalPeers        []string          `json:"optionalPeers"`
+	OS                   bunStrings        `json:"os"`
+	CPU                  bunStrings        `json:"cpu"`
+}
+
+// bunStrings is an os or cpu field, which bun writes as a string when
+// there's one value and a list when there are several.
+type bunStrings []string
+
+func (s *bunStrings) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*s = []string{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(s))
+}
+
+// isBunLockfile reports whether path is named like a bun lockfile.
+func isBunLockfile(path string) bool {
+	return filepath.Base(path) == "bun.lock"
+}
+
+// parseBunLockfile reads a bun.lock into the package-lock.json layout the
+// rest of resolve works on. bun records where it installs each package,
+// so the layout is bun's own. bun doesn't mark dev packages; those only
+// reachable from devDependencies are marked here. The binary bun.lockb
+// isn't supported: `bun install --save-text-lockfile` writes bun.lock.
+func parseBunLockfile(path string) (*packageLock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+	var lock bunLock
+	if err := json.Unmarshal(common.StripJSONC(data), &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile: %w", err)
+	}
+	if lock.LockfileVersion != 0 && lock.LockfileVersion != 1 {
+		return nil, fmt.Errorf("unsupported bun lockfile version %d (expected 0 or 1)", lock.LockfileVersion)
+	}
+
+	result := &packageLock{LockfileVersion: 3, Packages: map[string]packageInfo{"": {}}}
+	workspaceKeys := map[string]string{} // workspace path → package key
+	for key, entry := range lock.Packages {
+		var descriptor string
+		if len(entry) == 0 || json.Unmarshal(entry[0], &descriptor) != nil {
+			return nil, fmt.Errorf("invalid entry for package %s", key)
+		}
+		name, version := splitYarnDescriptor(descriptor)
+		if ws, ok := strings.CutPrefix(version, "workspace:"); ok {
+			workspaceKeys[ws] = key
+			continue
+		}
+		// Registry packages have a version and four elements; git, file and
+		// tarball dependencies don't, and have no registry package.
+		if len(entry) != 4 || strings.Contains(version, ":") {
+			continue
+		}
+		var meta bunPackageMeta
+		var integrity string
+		if err := json.Unmarshal(entry[2], &meta); err != nil {
+			return nil, fmt.Errorf("invalid metadata for package %s: %w", key, err)
+		}
+		json.Unmarshal(entry[3], &integrity)
+
+		info := packageInfo{
+			Version:          version,
+			Resolved:         registryTarball(name, version),
+			Integrity:        integrity,
+			Dependencies:     map[string]string{},
+			PeerDependencies: meta.PeerDependencies,
+			OS:               meta.OS,
+			CPU:              meta.CPU,
+		}
+		for dep, rng := range meta.Dependencies {
+			info.Dependencies[dep] = rng
+		}
+		for dep, rng := range meta.OptionalDependencies {
+			info.Dependencies[dep] = rng
+		}
+		for _, dep := range meta.OptionalPeers {
+			if info.PeerDependenciesMeta == nil {
+				info.PeerDependenciesMeta = map[string]peerDepMeta{}
+			}
+			info.PeerDependenciesMeta[dep] = peerDepMeta{Optional: true}
+		}
+		result.Packages[bunLockfilePath(key)] = info
+	}
+
+	// Mark as prod everything reachable from the workspaces' production
+	// dependencies, resolving each as Node would: from the node_modules
+	// nearest the importer outwards.
+	prod := map[string]bool{}
+	var walk func(from, dep string)
+	walk = func(from, dep string) {
+		path := resolveBunDep(result.Packages, from, dep)
+		if path == "" || prod[path] {
+			return
+		}
+		prod[path] = true
+		info := result.Packages[path]
+		for d := range info.Dependencies {
+			walk(path, d)
+		}
+		for d := range info.PeerDependencies {
+			walk(path, d)
+		}
+	}
+	wsPaths := make([]string, 0, len(lock.Workspaces))
+	for ws := range lock.Workspaces {
+		wsPaths = append(wsPaths, ws)
+	}
+	sort.Strings(wsPaths)
+	for _, ws := range wsPaths {
+		w := lock.Workspaces[ws]
+		from := ""
+		if key, ok := workspaceKeys[ws]; ok {
+			from = bunLockfilePath(key)
+		}
+		for _, deps := range []map[string]string{w.Dependencies, w.OptionalDependencies, w.PeerDependencies} {
+			for dep := range deps {
+				walk(from, dep)
+			}
+		}
+	}
+	for path, info := range result.Packages {
+		if path != "" && !prod[path] {
+			info.Dev = true
+			result.Packages[path] = info
+		}
+	}
+	return result, nil
+}
+
+// bunLockfilePath converts a bun package key such as "@babel/core/semver"
+// to its package-lock.json path, node_modules/@babel/core/node_modules/semver.
+func bunLockfilePath(key string) string {
+	var names []string
+	parts := strings.Split(key, "/")
+	for i := 0; i < len(parts); i++ {
+		if strings.HasPrefix(parts[i], "@") && i+1 < len(parts) {
+			names = append(names, parts[i]+"/"+parts[i+1])
+			i++
+		} else {
+			names = append(names, parts[i])
+		}
+	}
+	return "node_modules/" + strings.Join(names, "/node_modules/")
+}
+
+// resolveBunDep returns the lockfile path dep resolves to when imported from
+// the package at path from ("" for the project root), or "" if it isn't
+// installed.
+func resolveBunDep(pkgs map[string]packageInfo, from, dep string) string {
+	for dir := from; dir != ""; {
+		if candidate := dir + "/node_modules/" + dep; pkgs[candidate].Version != "" {
+			return candidate
+		}
+		i := strings.LastIndex(dir, "/node_modules/")
+		if i < 0 {
+			break
+		}
+		dir = dir[:i]
+	}
+	if candidate := "node_modules/" + dep; pkgs[candidate].Version != "" {
+		return candidate
+	}
+	return ""
+}
@@ -1,8 +1,11 @@
 package resolve
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"slices"
 	"sort"
 	"strings"
 
@@ -11,13 +14,25 @@ import (
 
 // resolvedPackage is the processed form we use for BUILD file generation.
 type resolvedPackage struct {
-	Name       string            // npm package name or alias (e.g., "react", "my-ms")
-	RealName   string            // real npm package name if aliased (e.g., "ms"); empty if not aliased
-	Version    string
-	Resolved   string            // tarball URL
-	Deps       []string          // dependency package names (mapped to subrepo targets)
-	Dev        bool              // true if this is a dev-only package
-	NestedDeps map[string]string // import_name -> subrepo target for version-conflict deps
+	Name         string              // npm package name or alias (e.g., "react", "my-ms")
+	RealName     string              // real npm package name if aliased (e.g., "ms"); empty if not aliased
+	Version      string
+	Resolved     string              // tarball URL
+	Integrity    string              // lockfile integrity hash (e.g., "sha512-..."); empty if not recorded
+	License      string              // SPDX license expression (e.g., "MIT"); empty if not known
+	Deps         []string            // dependency package names (mapped to subrepo targets)
+	Dev          bool                // true if this is a dev-only package
+	NestedDeps   map[string]string   // import_name -> subrepo target for version-conflict deps
+	PlatformDeps map[string][]string // platform (e.g., "linux_amd64") -> deps only installed there
+	Workspace    string              // in-repo target standing in for a workspace package; empty for npm packages
+	Source       packageSource       // where to fetch the package from; zero for the npm registry
+	Patches      []packagePatch      // patch-package patches to apply, in order
+	Overrides    []string            // notes of the versions package.json overrides substituted
+	Bundled      []string            // dependencies shipped inside its tarball, so not depended on
+	HasScripts   bool                // has install scripts, which aren't run
+	Scripts      []string            // which install scripts, e.g. "postinstall", if known
+	Peers        map[string]string   // name -> range of its non-optional peer dependencies
+	Bins         map[string]string   // command name -> script, relative to the package, of its bin entries
 }
 
 // targetName returns the Please target name for this package.
@@ -38,11 +53,21 @@ func (p resolvedPackage) effectivePkgName() string {
 // conflictTarget represents an additional npm_module target for a specific
 // version of a package that conflicts with the top-level version.
 type conflictTarget struct {
-	Dir        string   // flat subrepo directory path (e.g., "zod", "types_react")
-	TargetName string   // version-qualified name (e.g., "zod_v4_3_6")
-	PkgName    string   // real npm package name
-	Version    string
-	Deps       []string // dependency package names
+	Dir          string              // subrepo directory of its BUILD file (e.g., "zod", "types_react")
+	TargetName   string              // version-qualified name (e.g., "zod_v4_3_6")
+	PkgName      string              // real npm package name
+	Version      string
+	Integrity    string
+	License      string
+	Deps         []string            // dependency package names
+	PlatformDeps map[string][]string // platform -> deps only installed there
+	Source       packageSource
+	Patches      []packagePatch
+	Bundled      []string
+	HasScripts   bool
+	Scripts      []string
+	Peers        map[string]string
+	Parents      []string // packages it's nested under, by name
 }
 
 // parentConflict records a version conflict between a nested package
@@ -53,13 +78,42 @@ type parentConflict struct {
 	Version    string
 }
 
-// breakCycles detects and removes back-edges in the dependency graph via DFS,
-// ensuring the resulting graph is a DAG suitable for Please's build system.
+// cycleEdge is a dependency breakCycles removed, between packages by name
+// or version-conflict targets by target name.
+type cycleEdge struct {
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Reason string   `json:"reason"` // "peer" or "dev" if the strategy chose it as one, else "back-edge"
+	Cycle  []string `json:"cycle"`  // the cycle it broke, starting at To and ending at From
+}
+
+// cycleStrategies are the edge kinds each --cycle-strategy prefers to
+// remove from a cycle, in order, before the edge that closes it. "report"
+// breaks cycles as the default does, but fails listing them.
+var cycleStrategies = map[string][]string{
+	"":            nil,
+	"prefer-peer": {"peer", "dev"},
+	"prefer-dev":  {"dev", "peer"},
+	"report":      nil,
+}
+
+// breakCycles detects and removes edges in the dependency graph until it's
+// a DAG suitable for Please's build system, one from each cycle a DFS finds.
+// By default that's the back-edge closing the cycle; a strategy prefers
+// peer dependency edges, or edges onto dev-only packages, in the cycle.
 // It operates on a unified graph containing both regular packages and
 // version-conflict targets so that cycles spanning both are detected.
-func breakCycles(packages []resolvedPackage, ctargets []conflictTarget) {
+// It returns the edges it removed.
+func breakCycles(packages []resolvedPackage, ctargets []conflictTarget, strategy string) ([]cycleEdge, error) {
+	prefer, ok := cycleStrategies[strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown cycle strategy %q (expected prefer-peer, prefer-dev or report)", strategy)
+	}
+
 	// Build unified adjacency list over both regular packages and conflict targets.
 	adj := make(map[string][]string)
+	dev := make(map[string]bool)
+	peers := make(map[string]map[string]string)
 
 	// Track which edges from regular packages come from NestedDeps.
 	// nestedEdgeKey[pkgName][conflictTargetName] = importName
@@ -80,6 +134,8 @@ func breakCycles(packages []resolvedPackage, ctargets []conflictTarget) {
 			nestedEdgeKey[pkg.Name][targetName] = importName
 		}
 		adj[pkg.Name] = edges
+		dev[pkg.Name] = pkg.Dev
+		peers[pkg.Name] = pkg.Peers
 	}
 
 	// Add conflict target nodes.
@@ -89,6 +145,7 @@ func breakCycles(packages []resolvedPackage, ctargets []conflictTarget) {
 			edges = append(edges, dep)
 		}
 		adj[ct.TargetName] = edges
+		peers[ct.TargetName] = ct.Peers
 	}
 
 	// Sort all node keys for deterministic traversal.
@@ -98,36 +155,84 @@ func breakCycles(packages []resolvedPackage, ctargets []conflictTarget) {
 	}
 	sort.Strings(allNodes)
 
-	// DFS coloring: 0=white, 1=gray (in stack), 2=black (done)
-	color := make(map[string]int, len(allNodes))
-
-	var dfs func(name string)
-	dfs = func(name string) {
-		color[name] = 1
-		var kept []string
-		for _, dep := range adj[name] {
-			if _, inGraph := adj[dep]; !inGraph {
-				// Keep edges to nodes outside the graph (e.g. filtered-out packages).
-				kept = append(kept, dep)
-				continue
-			}
-			if color[dep] == 1 {
-				log.Printf("warning: breaking circular dependency: %s -> %s", name, dep)
-				continue
+	// findCycle returns the nodes of the first cycle a DFS finds, the last
+	// depending on the first, or nil if there are none.
+	findCycle := func() []string {
+		// DFS coloring: 0=white, 1=gray (in stack), 2=black (done)
+		color := make(map[string]int, len(allNodes))
+		var stack, cycle []string
+		var dfs func(name string) bool
+		dfs = func(name string) bool {
+			color[name] = 1
+			stack = append(stack, name)
+			for _, dep := range adj[name] {
+				if _, inGraph := adj[dep]; !inGraph {
+					// Edges to nodes outside the graph (e.g. filtered-out packages) can't cycle.
+					continue
+				}
+				if color[dep] == 1 {
+					cycle = append([]string{}, stack[slices.Index(stack, dep):]...)
+					return true
+				}
+				if color[dep] == 0 && dfs(dep) {
+					return true
+				}
 			}
-			kept = append(kept, dep)
-			if color[dep] == 0 {
-				dfs(dep)
+			stack = stack[:len(stack)-1]
+			color[name] = 2
+			return false
+		}
+		for _, node := range allNodes {
+			if color[node] == 0 && dfs(node) {
+				return cycle
 			}
 		}
-		adj[name] = kept
-		color[name] = 2
+		return nil
 	}
 
-	for _, node := range allNodes {
-		if color[node] == 0 {
-			dfs(node)
+	// edgeKind returns what kind of edge a dependency is, as strategies
+	// know them.
+	edgeKind := func(from, to string) string {
+		if _, ok := peers[from][to]; ok {
+			return "peer"
 		}
+		if dev[to] {
+			return "dev"
+		}
+		return ""
+	}
+
+	var removed []cycleEdge
+	for cycle := findCycle(); cycle != nil; cycle = findCycle() {
+		// The edge closing the cycle, unless the strategy prefers another,
+		// looking back from it.
+		n := len(cycle)
+		edge := cycleEdge{From: cycle[n-1], To: cycle[0], Reason: "back-edge"}
+	prefer:
+		for _, kind := range prefer {
+			for i := n - 1; i >= 0; i-- {
+				from, to := cycle[i], cycle[(i+1)%n]
+				if edgeKind(from, to) == kind {
+					edge = cycleEdge{From: from, To: to, Reason: kind}
+					break prefer
+				}
+			}
+		}
+		i := slices.Index(cycle, edge.To)
+		edge.Cycle = append(append([]string{}, cycle[i:]...), cycle[:i]...)
+		if strategy != "report" {
+			log.Printf("warning: breaking circular dependency: %s -> %s", edge.From, edge.To)
+		}
+		j := slices.Index(adj[edge.From], edge.To)
+		adj[edge.From] = slices.Delete(adj[edge.From], j, j+1)
+		removed = append(removed, edge)
+	}
+	if strategy == "report" && len(removed) > 0 {
+		lines := make([]string, len(removed))
+		for i, e := range removed {
+			lines[i] = strings.Join(append(e.Cycle, e.To), " -> ")
+		}
+		return removed, fmt.Errorf("circular dependencies:\n  %s", strings.Join(lines, "\n  "))
 	}
 
 	// Write back pruned edges to regular packages.
@@ -156,6 +261,22 @@ func breakCycles(packages []resolvedPackage, ctargets []conflictTarget) {
 		}
 		ctargets[i].Deps = deps
 	}
+	return removed, nil
+}
+
+// writeCycleReport writes the edges breakCycles removed to path as JSON.
+func writeCycleReport(path string, removed []cycleEdge) error {
+	if removed == nil {
+		removed = []cycleEdge{}
+	}
+	data, err := json.MarshalIndent(removed, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write cycle report: %w", err)
+	}
+	return nil
 }
 
 // extractTargetName extracts the target name from a subrepo target label
@@ -171,10 +292,15 @@ func extractTargetName(label string) string {
 // collectPackages extracts top-level packages from the lockfile and detects
 // version conflicts. It returns regular packages (including promoted nested-only
 // packages) and version-conflict targets for packages that exist at multiple versions.
-func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage, []conflictTarget) {
+// Workspace packages map to the in-repo targets in workspaces, by name, or
+// else to the default target of their directory; local tarballs map to the
+// targets in workspaces too, or else the target named after the file.
+// Version-conflict targets are labelled as l places them.
+func collectPackages(pkgs map[string]packageInfo, noDev bool, workspaces map[string]string, l layout) ([]resolvedPackage, []conflictTarget) {
 	// Phase 1: Build set of top-level package names and their versions
 	topLevel := make(map[string]bool)
 	topLevelVersions := make(map[string]string)
+	topLevelInfos := make(map[string]packageInfo)
 	for path, info := range pkgs {
 		if path == "" || common.IsNestedPackage(path) {
 			continue
@@ -185,6 +311,7 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 		}
 		topLevel[name] = true
 		topLevelVersions[name] = info.Version
+		topLevelInfos[name] = info
 	}
 
 	// Phase 2: Promote nested-only packages (exist ONLY as nested, no top-level)
@@ -194,7 +321,7 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 			continue
 		}
 		name := common.ExtractPackageName(path)
-		if name == "" || topLevel[name] {
+		if name == "" || topLevel[name] || pkgs[path].InBundle {
 			continue
 		}
 		if _, already := promoted[name]; already {
@@ -202,6 +329,7 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 		}
 		promoted[name] = path
 		topLevel[name] = true
+		topLevelInfos[name] = pkgs[path]
 	}
 
 	// Phase 3: Detect version conflicts (nested package version differs from top-level)
@@ -216,8 +344,9 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 		if name == "" {
 			continue
 		}
-		// Skip promoted packages (they're treated as top-level)
-		if promoted[name] == path {
+		// Skip promoted packages (they're treated as top-level), and
+		// bundled ones, which come inside their parent's tarball.
+		if promoted[name] == path || info.Link || info.InBundle {
 			continue
 		}
 		// Only detect conflicts where a different top-level version exists
@@ -225,7 +354,7 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 		if !exists || info.Version == topVer {
 			continue
 		}
-		if info.Resolved == "" {
+		if _, ok := packageSourceOf(name, info.Resolved, workspaces); !ok {
 			continue
 		}
 
@@ -254,7 +383,29 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 			parentNestedDeps[c.ParentName] = make(map[string]string)
 		}
 		targetName := common.VersionedTargetName(c.DepName, c.Version)
-		parentNestedDeps[c.ParentName][c.DepName] = fmt.Sprintf("//%s:%s", common.FlattenPkgName(c.DepName), targetName)
+		parentNestedDeps[c.ParentName][c.DepName] = l.label(c.DepName, targetName)
+	}
+
+	// Platform-specific packages, such as esbuild's native binaries, are
+	// depended on only on the platforms their os and cpu fields allow, and
+	// not at all if those are platforms Please doesn't build on.
+	splitPlatformDeps := func(deps []string) ([]string, map[string][]string) {
+		var always []string
+		var platformDeps map[string][]string
+		for _, dep := range deps {
+			platforms, restricted := packagePlatforms(topLevelInfos[dep])
+			if !restricted {
+				always = append(always, dep)
+				continue
+			}
+			for _, platform := range platforms {
+				if platformDeps == nil {
+					platformDeps = make(map[string][]string)
+				}
+				platformDeps[platform] = append(platformDeps[platform], dep)
+			}
+		}
+		return always, platformDeps
 	}
 
 	// Phase 4: Build resolvedPackage entries
@@ -279,25 +430,32 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 			continue
 		}
 
-		if info.Resolved == "" {
+		if info.Link {
+			label, ok := workspaces[name]
+			if !ok {
+				label = "//" + info.Resolved
+			}
+			result = append(result, resolvedPackage{Name: name, Dev: info.Dev, Workspace: label})
 			continue
 		}
 
-		var deps []string
-		for dep := range info.Dependencies {
-			if topLevel[dep] {
-				deps = append(deps, dep)
+		source, ok := packageSourceOf(name, info.Resolved, workspaces)
+		if !ok {
+			if info.Resolved != "" {
+				log.Printf("warning: skipping %s: don't know how to fetch %s", name, info.Resolved)
 			}
+			continue
 		}
-		for dep := range info.PeerDependencies {
-			if meta, ok := info.PeerDependenciesMeta[dep]; ok && meta.Optional {
-				continue
-			}
+
+		deps := nonBundledDeps(info, topLevel)
+		peers := requiredPeers(info)
+		for dep := range peers {
 			if topLevel[dep] {
 				deps = append(deps, dep)
 			}
 		}
 		sort.Strings(deps)
+		deps, platformDeps := splitPlatformDeps(deps)
 
 		var realName string
 		if rn := common.ExtractRealPackageName(info.Resolved); rn != "" && rn != name {
@@ -305,12 +463,20 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 		}
 
 		pkg := resolvedPackage{
-			Name:     name,
-			RealName: realName,
-			Version:  info.Version,
-			Resolved: info.Resolved,
-			Deps:     deps,
-			Dev:      info.Dev,
+			Name:         name,
+			RealName:     realName,
+			Version:      info.Version,
+			Resolved:     info.Resolved,
+			Integrity:    info.Integrity,
+			License:      string(info.License),
+			Deps:         deps,
+			Dev:          info.Dev,
+			PlatformDeps: platformDeps,
+			Source:       source,
+			Bundled:      info.Bundled,
+			HasScripts:   info.HasInstallScript,
+			Peers:        peers,
+			Bins:         info.Bin,
 		}
 
 		if nd, ok := parentNestedDeps[name]; ok {
@@ -326,6 +492,13 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 
 	// Phase 5: Build conflict targets
 	var ctargets []conflictTarget
+	parents := make(map[string][]string) // name@version -> packages it's nested under
+	for _, c := range conflicts {
+		key := c.DepName + "@" + c.Version
+		if !slices.Contains(parents[key], c.ParentName) {
+			parents[key] = append(parents[key], c.ParentName)
+		}
+	}
 	seen := make(map[string]bool)
 	for _, c := range conflicts {
 		key := c.DepName + "@" + c.Version
@@ -333,23 +506,29 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 			continue
 		}
 		seen[key] = true
+		sort.Strings(parents[key])
 
 		info := conflictVersionInfos[c.DepName][c.Version]
+		source, _ := packageSourceOf(c.DepName, info.Resolved, workspaces)
 
-		var deps []string
-		for dep := range info.Dependencies {
-			if topLevel[dep] {
-				deps = append(deps, dep)
-			}
-		}
+		deps := nonBundledDeps(info, topLevel)
 		sort.Strings(deps)
+		deps, platformDeps := splitPlatformDeps(deps)
 
 		ctargets = append(ctargets, conflictTarget{
-			Dir:        common.FlattenPkgName(c.DepName),
-			TargetName: common.VersionedTargetName(c.DepName, c.Version),
-			PkgName:    c.DepName,
-			Version:    c.Version,
-			Deps:       deps,
+			Dir:          l.dir(c.DepName),
+			TargetName:   common.VersionedTargetName(c.DepName, c.Version),
+			PkgName:      c.DepName,
+			Version:      c.Version,
+			Integrity:    info.Integrity,
+			License:      string(info.License),
+			Deps:         deps,
+			PlatformDeps: platformDeps,
+			Source:       source,
+			Bundled:      info.Bundled,
+			HasScripts:   info.HasInstallScript,
+			Peers:        requiredPeers(info),
+			Parents:      parents[key],
 		})
 	}
 
@@ -362,3 +541,34 @@ func collectPackages(pkgs map[string]packageInfo, noDev bool) ([]resolvedPackage
 
 	return result, ctargets
 }
+
+// nonBundledDeps returns the dependencies and optional dependencies of a
+// package that are in the subrepo, except those it bundles: it imports
+// those from inside its own tarball, whatever version is at the top level.
+func nonBundledDeps(info packageInfo, topLevel map[string]bool) []string {
+	var deps []string
+	for _, section := range []map[string]string{info.Dependencies, info.OptionalDependencies} {
+		for dep := range section {
+			if topLevel[dep] && !slices.Contains(info.Bundled, dep) {
+				deps = append(deps, dep)
+			}
+		}
+	}
+	return deps
+}
+
+// requiredPeers returns the peer dependencies of a package that aren't
+// optional, by name, with their ranges.
+func requiredPeers(info packageInfo) map[string]string {
+	var peers map[string]string
+	for dep, rng := range info.PeerDependencies {
+		if meta, ok := info.PeerDependenciesMeta[dep]; ok && meta.Optional {
+			continue
+		}
+		if peers == nil {
+			peers = make(map[string]string)
+		}
+		peers[dep] = rng
+	}
+	return peers
+}
@@ -0,0 +1,133 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dedupeEntry is a package that exists at more than one version: the
+// top-level one and those version-conflict targets are generated for.
+type dedupeEntry struct {
+	Name       string          `json:"name"`
+	Version    string          `json:"version"` // top-level version; "" if only nested ones were generated
+	Duplicates []dedupeVersion `json:"duplicates"`
+	Bytes      int64           `json:"bytes"` // unpacked size of the duplicates; 0 if unknown
+}
+
+// dedupeVersion is one of the other versions of a package, and the
+// packages that pin it by depending on it.
+type dedupeVersion struct {
+	Version string   `json:"version"`
+	Parents []string `json:"parents"`
+	Bytes   int64    `json:"bytes"` // unpacked size; 0 if unknown
+}
+
+// size returns the unpacked size the registry gives a version of a
+// package, or 0 if the cache doesn't have it.
+func (m *registryMeta) size(name, version string) (int64, error) {
+	doc, err := m.doc(name)
+	if err != nil || doc == nil {
+		return 0, err
+	}
+	return doc.Versions[version].Dist.UnpackedSize, nil
+}
+
+// dedupeReport lists the packages that exist at more than one version,
+// costliest first. Sizes are read from meta, if it isn't nil.
+func dedupeReport(meta *registryMeta, packages []resolvedPackage, ctargets []conflictTarget) ([]dedupeEntry, error) {
+	topLevel := make(map[string]string)
+	for _, pkg := range packages {
+		if pkg.Workspace == "" {
+			topLevel[pkg.effectivePkgName()] = pkg.Version
+		}
+	}
+	byName := make(map[string]*dedupeEntry)
+	for _, ct := range ctargets {
+		e, ok := byName[ct.PkgName]
+		if !ok {
+			e = &dedupeEntry{Name: ct.PkgName, Version: topLevel[ct.PkgName]}
+			byName[ct.PkgName] = e
+		}
+		v := dedupeVersion{Version: ct.Version, Parents: ct.Parents}
+		if meta != nil {
+			size, err := meta.size(ct.PkgName, ct.Version)
+			if err != nil {
+				return nil, err
+			}
+			v.Bytes = size
+		}
+		e.Duplicates = append(e.Duplicates, v)
+		e.Bytes += v.Bytes
+	}
+	entries := make([]dedupeEntry, 0, len(byName))
+	for _, e := range byName {
+		sort.Slice(e.Duplicates, func(i, j int) bool { return e.Duplicates[i].Version < e.Duplicates[j].Version })
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Bytes != entries[j].Bytes {
+			return entries[i].Bytes > entries[j].Bytes
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// writeDedupeReport writes the duplicated packages to path, as JSON if it
+// ends in .json or as text otherwise.
+func writeDedupeReport(path string, entries []dedupeEntry) error {
+	var data []byte
+	if filepath.Ext(path) == ".json" {
+		var err error
+		if data, err = json.MarshalIndent(entries, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		data = []byte(formatDedupeReport(entries))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write dedupe report: %w", err)
+	}
+	return nil
+}
+
+// formatDedupeReport formats the duplicated packages as text, one line
+// per package followed by one per duplicate version.
+func formatDedupeReport(entries []dedupeEntry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		n := len(e.Duplicates)
+		if e.Version != "" {
+			n++
+		}
+		fmt.Fprintf(&b, "%s: %d versions", e.Name, n)
+		if e.Bytes > 0 {
+			fmt.Fprintf(&b, ", %s duplicated", formatSize(e.Bytes))
+		}
+		if e.Version != "" {
+			fmt.Fprintf(&b, " (%s at the top level)", e.Version)
+		}
+		b.WriteString("\n")
+		for _, v := range e.Duplicates {
+			fmt.Fprintf(&b, "  %s", v.Version)
+			if v.Bytes > 0 {
+				fmt.Fprintf(&b, ": %s", formatSize(v.Bytes))
+			}
+			fmt.Fprintf(&b, ", nested under %s\n", strings.Join(v.Parents, ", "))
+		}
+	}
+	return b.String()
+}
+
+// formatSize formats a byte count as a human-readable string.
+func formatSize(bytes int64) string {
+	if bytes >= 1024*1024 {
+		return fmt.Sprintf("%.1fMB", float64(bytes)/(1024*1024))
+	}
+	return fmt.Sprintf("%.0fKB", float64(bytes)/1024)
+}
@@ -0,0 +1,160 @@
+package resolve
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"tools/please_js/common"
+)
+
+// moduleRule is an npm_module rule read back from a generated BUILD file.
+type moduleRule struct {
+	Label    string // //dir:name
+	Name     string // npm package name, as it's imported
+	Version  string
+	Conflict bool   // a version-conflict target
+	Text     string // the formatted rule, to tell whether anything else changed
+}
+
+// readModules reads the npm_module rules in the BUILD files under dir,
+// keyed by label. A dir that doesn't exist has none.
+func readModules(dir string) (map[string]moduleRule, error) {
+	rules := make(map[string]moduleRule)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return rules, nil
+	}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != "BUILD" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		f, err := build.ParseBuild(path, data)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			rel = ""
+		}
+		for _, stmt := range f.Stmt {
+			call, ok := stmt.(*build.CallExpr)
+			if !ok {
+				continue
+			}
+			if ident, ok := call.X.(*build.Ident); !ok || ident.Name != "npm_module" {
+				continue
+			}
+			args := stringArgs(call)
+			r := moduleRule{
+				Label:   fmt.Sprintf("//%s:%s", filepath.ToSlash(rel), args["name"]),
+				Version: args["version"],
+				Text:    build.FormatString(call),
+			}
+			r.Conflict = strings.HasSuffix(args["name"], common.VersionedTargetName("", r.Version))
+			switch {
+			case args["import_name"] != "" && !r.Conflict:
+				r.Name = args["import_name"]
+			case args["pkg_name"] != "":
+				r.Name = args["pkg_name"]
+			default:
+				r.Name = args["name"]
+			}
+			rules[r.Label] = r
+		}
+		return nil
+	})
+	return rules, err
+}
+
+// stringArgs returns the string-valued keyword arguments of a call.
+func stringArgs(call *build.CallExpr) map[string]string {
+	args := make(map[string]string)
+	for _, arg := range call.List {
+		assign, ok := arg.(*build.AssignExpr)
+		if !ok {
+			continue
+		}
+		key, ok := assign.LHS.(*build.Ident)
+		if !ok {
+			continue
+		}
+		if value, ok := assign.RHS.(*build.StringExpr); ok {
+			args[key.Name] = value.Value
+		}
+	}
+	return args
+}
+
+// dryRunReport compares src, which holds freshly generated output, with
+// dst, and writes a summary of the packages that regenerating dst would
+// add, change and remove, then of the other files that would change.
+func dryRunReport(w io.Writer, src, dst string) error {
+	before, err := readModules(dst)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dst, err)
+	}
+	after, err := readModules(src)
+	if err != nil {
+		return fmt.Errorf("failed to read the generated rules: %w", err)
+	}
+	describe := func(r moduleRule) string {
+		s := r.Name + "@" + r.Version
+		if r.Conflict {
+			s += " (version-conflict target " + r.Label + ")"
+		}
+		return s
+	}
+	var added, changed, removed []string
+	for label, r := range after {
+		old, ok := before[label]
+		switch {
+		case !ok:
+			added = append(added, describe(r))
+		case old.Version != r.Version:
+			changed = append(changed, fmt.Sprintf("%s: %s -> %s", r.Name, old.Version, r.Version))
+		case old.Text != r.Text:
+			changed = append(changed, describe(r)+": rule changed")
+		}
+	}
+	for label, r := range before {
+		if _, ok := after[label]; !ok {
+			removed = append(removed, describe(r))
+		}
+	}
+	diff, err := diffDir(src, dst)
+	if err != nil {
+		return fmt.Errorf("failed to compare with %s: %w", dst, err)
+	}
+	if diff.empty() {
+		fmt.Fprintf(w, "%s is up to date\n", dst)
+		return nil
+	}
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{{"Added", added}, {"Changed", changed}, {"Removed", removed}} {
+		if len(section.lines) == 0 {
+			continue
+		}
+		sort.Strings(section.lines)
+		fmt.Fprintf(w, "%s:\n", section.title)
+		for _, line := range section.lines {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	fmt.Fprintf(w, "Would add %d packages, change %d and remove %d; %d files would be written and %d removed in %s\n",
+		len(added), len(changed), len(removed), len(diff.added)+len(diff.changed), len(diff.removed), dst)
+	return nil
+}
@@ -0,0 +1,235 @@
+package resolve
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// packageFilter selects the packages to generate: those matching only, or
+// the project's direct dependencies if it's empty, and what they depend on,
+// except those matching exclude. Patterns are package names, which may
+// contain wildcards: "react", "@tanstack/*".
+type packageFilter struct {
+	only    []string
+	exclude []string
+}
+
+// newPackageFilter makes a filter from lists of patterns, each of which may
+// also be comma-separated.
+func newPackageFilter(only, exclude []string) (packageFilter, error) {
+	split := func(lists []string) ([]string, error) {
+		var patterns []string
+		for _, list := range lists {
+			for _, pattern := range strings.Split(list, ",") {
+				if pattern = strings.TrimSpace(pattern); pattern == "" {
+					continue
+				}
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("invalid package pattern %q: %w", pattern, err)
+				}
+				patterns = append(patterns, pattern)
+			}
+		}
+		return patterns, nil
+	}
+	var f packageFilter
+	var err error
+	if f.only, err = split(only); err != nil {
+		return f, err
+	}
+	f.exclude, err = split(exclude)
+	return f, err
+}
+
+// enabled reports whether the filter leaves anything out.
+func (f packageFilter) enabled() bool {
+	return len(f.only) > 0 || len(f.exclude) > 0
+}
+
+// matches reports whether a package name matches any of patterns.
+func matches(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// directDependencies returns the names of the root project's dependencies
+// of every kind, or nil if the lockfile doesn't record them.
+func directDependencies(root packageInfo) []string {
+	var names []string
+	for _, deps := range []map[string]string{root.Dependencies, root.DevDependencies, root.OptionalDependencies, root.PeerDependencies} {
+		for name := range deps {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apply returns the packages and version-conflict targets the filter
+// selects. Without only, selection starts from direct and the packages
+// nothing depends on, such as workspace members, so packages that only
+// excluded ones depend on are left out with them. If direct is empty, as
+// when the lockfile has no root entry, it starts from every package.
+// Dependencies on packages the filter leaves out are dropped.
+func (f packageFilter) apply(packages []resolvedPackage, ctargets []conflictTarget, direct []string) ([]resolvedPackage, []conflictTarget) {
+	byName := make(map[string]int, len(packages))
+	for i, pkg := range packages {
+		byName[pkg.Name] = i
+	}
+	byTarget := make(map[string]int, len(ctargets))
+	for i, ct := range ctargets {
+		byTarget[ct.TargetName] = i
+	}
+	for _, pattern := range f.only {
+		found := false
+		for _, pkg := range packages {
+			if ok, _ := path.Match(pattern, pkg.Name); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Printf("warning: --only %s matches no package in the lockfile", pattern)
+		}
+	}
+
+	// Walk what the selected packages depend on, never into excluded ones.
+	keepPkg := map[string]bool{}
+	keepTarget := map[string]bool{}
+	var queue []string
+	visit := func(name string) {
+		if _, ok := byName[name]; ok && !keepPkg[name] && !matches(f.exclude, name) {
+			keepPkg[name] = true
+			queue = append(queue, name)
+		}
+	}
+	switch {
+	case len(f.only) > 0:
+		for _, pkg := range packages {
+			if matches(f.only, pkg.Name) {
+				visit(pkg.Name)
+			}
+		}
+	case len(direct) > 0:
+		for _, name := range direct {
+			visit(name)
+		}
+		dependedOn := map[string]bool{}
+		for _, pkg := range packages {
+			for _, dep := range pkg.Deps {
+				dependedOn[dep] = true
+			}
+			for _, deps := range pkg.PlatformDeps {
+				for _, dep := range deps {
+					dependedOn[dep] = true
+				}
+			}
+		}
+		for _, ct := range ctargets {
+			for _, dep := range ct.Deps {
+				dependedOn[dep] = true
+			}
+			for _, deps := range ct.PlatformDeps {
+				for _, dep := range deps {
+					dependedOn[dep] = true
+				}
+			}
+		}
+		for _, pkg := range packages {
+			if !dependedOn[pkg.Name] {
+				visit(pkg.Name)
+			}
+		}
+	default:
+		for _, pkg := range packages {
+			visit(pkg.Name)
+		}
+	}
+	for len(queue) > 0 {
+		pkg := packages[byName[queue[0]]]
+		queue = queue[1:]
+		for _, dep := range pkg.Deps {
+			visit(dep)
+		}
+		for _, deps := range pkg.PlatformDeps {
+			for _, dep := range deps {
+				visit(dep)
+			}
+		}
+		for _, label := range pkg.NestedDeps {
+			target := extractTargetName(label)
+			i, ok := byTarget[target]
+			if !ok || keepTarget[target] || matches(f.exclude, ctargets[i].PkgName) {
+				continue
+			}
+			keepTarget[target] = true
+			for _, dep := range ctargets[i].Deps {
+				visit(dep)
+			}
+			for _, deps := range ctargets[i].PlatformDeps {
+				for _, dep := range deps {
+					visit(dep)
+				}
+			}
+		}
+	}
+
+	keptDeps := func(deps []string) []string {
+		var kept []string
+		for _, dep := range deps {
+			if keepPkg[dep] {
+				kept = append(kept, dep)
+			}
+		}
+		return kept
+	}
+	keptPlatformDeps := func(platformDeps map[string][]string) map[string][]string {
+		var kept map[string][]string
+		for platform, deps := range platformDeps {
+			if deps = keptDeps(deps); len(deps) > 0 {
+				if kept == nil {
+					kept = map[string][]string{}
+				}
+				kept[platform] = deps
+			}
+		}
+		return kept
+	}
+
+	var result []resolvedPackage
+	for _, pkg := range packages {
+		if !keepPkg[pkg.Name] {
+			continue
+		}
+		pkg.Deps = keptDeps(pkg.Deps)
+		pkg.PlatformDeps = keptPlatformDeps(pkg.PlatformDeps)
+		var nested map[string]string
+		for importName, label := range pkg.NestedDeps {
+			if keepTarget[extractTargetName(label)] {
+				if nested == nil {
+					nested = map[string]string{}
+				}
+				nested[importName] = label
+			}
+		}
+		pkg.NestedDeps = nested
+		result = append(result, pkg)
+	}
+	var kept []conflictTarget
+	for _, ct := range ctargets {
+		if !keepTarget[ct.TargetName] {
+			continue
+		}
+		ct.Deps = keptDeps(ct.Deps)
+		ct.PlatformDeps = keptPlatformDeps(ct.PlatformDeps)
+		kept = append(kept, ct)
+	}
+	return result, kept
+}
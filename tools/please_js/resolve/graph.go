@@ -0,0 +1,161 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// graphNode is a target in the dependency graph.
+type graphNode struct {
+	ID      string `json:"id"` // subrepo label, e.g. "//react"
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Kind    string `json:"kind"` // "package", "conflict" (a version-conflict target) or "workspace"
+	Dev     bool   `json:"dev,omitempty"`
+}
+
+// graphEdge is a dependency between two targets.
+type graphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// "dep", "nested" (on a version-conflict target), "platform" (only on
+	// Platforms) or "cycle" (removed to break a circular dependency).
+	Kind      string   `json:"kind"`
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// depGraph is the resolved dependency graph.
+type depGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// buildGraph assembles the dependency graph of the generated targets,
+// labelled as l places them, including the edges breakCycles removed.
+func buildGraph(packages []resolvedPackage, ctargets []conflictTarget, removed []cycleEdge, l layout) depGraph {
+	var g depGraph
+	// breakCycles knows packages by name and conflict targets by target name.
+	labels := map[string]string{}
+	addDeps := func(from string, deps []string, nested map[string]string, platformDeps map[string][]string) {
+		for _, dep := range deps {
+			g.Edges = append(g.Edges, graphEdge{From: from, To: l.depLabel(dep), Kind: "dep"})
+		}
+		for _, label := range nested {
+			g.Edges = append(g.Edges, graphEdge{From: from, To: label, Kind: "nested"})
+		}
+		platforms := map[string][]string{}
+		for platform, deps := range platformDeps {
+			for _, dep := range deps {
+				platforms[dep] = append(platforms[dep], platform)
+			}
+		}
+		for dep, list := range platforms {
+			sort.Strings(list)
+			g.Edges = append(g.Edges, graphEdge{From: from, To: l.depLabel(dep), Kind: "platform", Platforms: list})
+		}
+	}
+	for _, pkg := range packages {
+		id := l.depLabel(pkg.Name)
+		labels[pkg.Name] = id
+		if pkg.Workspace != "" {
+			g.Nodes = append(g.Nodes, graphNode{ID: id, Name: pkg.Name, Kind: "workspace", Dev: pkg.Dev})
+			continue
+		}
+		g.Nodes = append(g.Nodes, graphNode{ID: id, Name: pkg.effectivePkgName(), Version: pkg.Version, Kind: "package", Dev: pkg.Dev})
+		addDeps(id, pkg.Deps, pkg.NestedDeps, pkg.PlatformDeps)
+	}
+	for _, ct := range ctargets {
+		id := l.label(ct.PkgName, ct.TargetName)
+		labels[ct.TargetName] = id
+		g.Nodes = append(g.Nodes, graphNode{ID: id, Name: ct.PkgName, Version: ct.Version, Kind: "conflict"})
+		addDeps(id, ct.Deps, nil, ct.PlatformDeps)
+	}
+	for _, e := range removed {
+		g.Edges = append(g.Edges, graphEdge{From: labels[e.From], To: labels[e.To], Kind: "cycle"})
+	}
+
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Kind < b.Kind
+	})
+	return g
+}
+
+// writeGraph writes the dependency graph to path, as Graphviz DOT if it
+// ends in .dot or JSON if it ends in .json.
+func writeGraph(path string, g depGraph) error {
+	var data []byte
+	switch filepath.Ext(path) {
+	case ".json":
+		var err error
+		if data, err = json.MarshalIndent(g, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	case ".dot":
+		data = []byte(formatDOT(g))
+	default:
+		return fmt.Errorf("unknown graph format for %s (expected .dot or .json)", path)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+	return nil
+}
+
+// formatDOT formats the dependency graph as Graphviz DOT. Version-conflict
+// targets are boxes and workspace packages are folders; edges to conflict
+// targets are bold, platform-specific ones dotted and those removed to
+// break cycles dashed red.
+func formatDOT(g depGraph) string {
+	var b strings.Builder
+	b.WriteString("digraph deps {\n")
+	for _, n := range g.Nodes {
+		label := n.Name
+		if n.Version != "" {
+			label += "@" + n.Version
+		}
+		attrs := []string{"label=" + dotQuote(label)}
+		switch n.Kind {
+		case "conflict":
+			attrs = append(attrs, "shape=box")
+		case "workspace":
+			attrs = append(attrs, "shape=folder")
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		var attrs []string
+		switch e.Kind {
+		case "nested":
+			attrs = []string{"style=bold"}
+		case "platform":
+			attrs = []string{"style=dotted", "label=" + dotQuote(strings.Join(e.Platforms, " "))}
+		case "cycle":
+			attrs = []string{"style=dashed", "color=red"}
+		}
+		fmt.Fprintf(&b, "  %s -> %s", dotQuote(e.From), dotQuote(e.To))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote quotes a DOT ID.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
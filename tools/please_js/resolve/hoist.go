@@ -0,0 +1,148 @@
+package resolve
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"tools/please_js/common"
+)
+
+// lockedPackage is one package version in a pnpm or yarn lockfile. pnpm
+// records a package once per set of peer dependencies; those are merged.
+type lockedPackage struct {
+	name    string // real npm package name
+	version string
+	info    packageInfo
+	deps    map[string]packageRef // import name → resolved package
+}
+
+// packageRef is a dependency as the lockfile resolves it: the real package
+// name (which differs from the import name for npm aliases) and version.
+type packageRef struct {
+	name    string
+	version string
+}
+
+func (r packageRef) key() string {
+	return r.name + "@" + r.version
+}
+
+// rootDep is a direct dependency of the project or one of its workspaces.
+type rootDep struct {
+	name string
+	ref  packageRef
+}
+
+// hoist lays packages out as npm would in package-lock.json, for lockfiles
+// that don't record a node_modules layout: the direct dependencies go at
+// the top level, then, breadth-first, the first version of each transitive
+// dependency seen. A package depending on another version of a top-level
+// one gets that version nested under it. Packages only reachable from
+// devRoots are marked dev.
+func hoist(packages map[string]*lockedPackage, prodRoots, devRoots []rootDep) *packageLock {
+	type node struct {
+		path string // lockfile path, "" for packages not given one
+		ref  packageRef
+	}
+	topLevel := map[string]packageRef{}
+	paths := map[string]packageRef{}
+	visited := map[string]bool{}
+	var queue []node
+	for _, root := range append(prodRoots, devRoots...) {
+		if _, ok := topLevel[root.name]; ok {
+			continue
+		}
+		topLevel[root.name] = root.ref
+		path := "node_modules/" + root.name
+		paths[path] = root.ref
+		queue = append(queue, node{path, root.ref})
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if visited[n.path+"\x00"+n.ref.key()] {
+			continue
+		}
+		visited[n.path+"\x00"+n.ref.key()] = true
+		pkg := packages[n.ref.key()]
+		if pkg == nil {
+			continue
+		}
+		deps := make([]string, 0, len(pkg.deps))
+		for dep := range pkg.deps {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			ref := pkg.deps[dep]
+			top, ok := topLevel[dep]
+			switch {
+			case !ok:
+				topLevel[dep] = ref
+				path := "node_modules/" + dep
+				paths[path] = ref
+				queue = append(queue, node{path, ref})
+			case top != ref && n.path != "" && !common.IsNestedPackage(n.path):
+				// Nested under top-level packages only, which is as deep as
+				// collectPackages looks for version conflicts.
+				path := n.path + "/node_modules/" + dep
+				paths[path] = ref
+				queue = append(queue, node{"", ref})
+			case top != ref:
+				queue = append(queue, node{"", ref})
+			}
+		}
+	}
+
+	prod := map[string]bool{}
+	var walk func(ref packageRef)
+	walk = func(ref packageRef) {
+		if prod[ref.key()] {
+			return
+		}
+		prod[ref.key()] = true
+		if pkg := packages[ref.key()]; pkg != nil {
+			for _, dep := range pkg.deps {
+				walk(dep)
+			}
+		}
+	}
+	for _, root := range prodRoots {
+		walk(root.ref)
+	}
+
+	lock := &packageLock{LockfileVersion: 3, Packages: map[string]packageInfo{"": {}}}
+	for path, ref := range paths {
+		pkg := packages[ref.key()]
+		if pkg == nil {
+			continue
+		}
+		info := pkg.info
+		if info.Version == "" {
+			info.Version = pkg.version
+		}
+		info.Dependencies = make(map[string]string, len(pkg.deps))
+		for dep, depRef := range pkg.deps {
+			info.Dependencies[dep] = depRef.version
+		}
+		info.Dev = !prod[ref.key()]
+		lock.Packages[path] = info
+	}
+	return lock
+}
+
+// registryTarball returns the npm registry URL of a package's tarball.
+func registryTarball(name, version string) string {
+	return "https://registry.npmjs.org/" + registryTarballPath(name, version)
+}
+
+// registryTarballPath returns the path of a package's tarball on a registry.
+// "@babel/core", "7.24.0" → "@babel/core/-/core-7.24.0.tgz"
+func registryTarballPath(name, version string) string {
+	base := name
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		base = name[i+1:]
+	}
+	return fmt.Sprintf("%s/-/%s-%s.tgz", name, base, version)
+}
@@ -0,0 +1,131 @@
+package resolve
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// dirDiff is how one directory differs from another: the files, relative
+// to the directories, it has that the other doesn't, those they both have
+// with different contents, and the files and directories only the other
+// has.
+type dirDiff struct {
+	added, changed, removed []string
+}
+
+// empty reports whether the directories are the same.
+func (d dirDiff) empty() bool {
+	return len(d.added) == 0 && len(d.changed) == 0 && len(d.removed) == 0
+}
+
+// diffDir compares src, which holds freshly generated output, with dst.
+// A directory only dst has is removed as a whole.
+func diffDir(src, dst string) (dirDiff, error) {
+	var diff dirDiff
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		existing, err := os.ReadFile(filepath.Join(dst, rel))
+		if os.IsNotExist(err) {
+			diff.added = append(diff.added, rel)
+		} else if err != nil {
+			return err
+		} else if !bytes.Equal(existing, data) {
+			diff.changed = append(diff.changed, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return diff, err
+	}
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		return diff, nil
+	}
+	err = filepath.WalkDir(dst, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dst, path)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(filepath.Join(src, rel)); os.IsNotExist(err) {
+			diff.removed = append(diff.removed, rel)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	return diff, err
+}
+
+// syncDir makes dst match src, which holds freshly generated output, writing
+// only the files whose contents differ and removing the files and
+// directories src doesn't have. It returns how many files it wrote and
+// removed.
+func syncDir(src, dst string) (written, removed int, err error) {
+	diff, err := diffDir(src, dst)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to update %s: %w", dst, err)
+	}
+	for _, rel := range append(diff.added, diff.changed...) {
+		data, err := os.ReadFile(filepath.Join(src, rel))
+		if err != nil {
+			return written, removed, fmt.Errorf("failed to update %s: %w", dst, err)
+		}
+		target := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return written, removed, fmt.Errorf("failed to update %s: %w", dst, err)
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return written, removed, fmt.Errorf("failed to update %s: %w", dst, err)
+		}
+		written++
+	}
+	for _, rel := range diff.removed {
+		if err := os.RemoveAll(filepath.Join(dst, rel)); err != nil {
+			return written, removed, fmt.Errorf("failed to remove %s: %w", filepath.Join(dst, rel), err)
+		}
+		removed++
+	}
+	return written, removed, nil
+}
+
+// checkDir compares src, which holds freshly generated output, with dst,
+// failing with a summary of each file that differs if they aren't the
+// same.
+func checkDir(w io.Writer, src, dst string) error {
+	diff, err := diffDir(src, dst)
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", dst, err)
+	}
+	if diff.empty() {
+		fmt.Fprintf(w, "%s is up to date\n", dst)
+		return nil
+	}
+	fmt.Fprintf(w, "%s is out of date:\n", dst)
+	for _, rel := range diff.changed {
+		fmt.Fprintf(w, "  changed: %s\n", filepath.ToSlash(rel))
+	}
+	for _, rel := range diff.added {
+		fmt.Fprintf(w, "  missing: %s\n", filepath.ToSlash(rel))
+	}
+	for _, rel := range diff.removed {
+		fmt.Fprintf(w, "  stale:   %s\n", filepath.ToSlash(rel))
+	}
+	n := len(diff.changed) + len(diff.added) + len(diff.removed)
+	return fmt.Errorf("%s differs from the lockfile's rules in %d files; regenerate it with --incremental", dst, n)
+}
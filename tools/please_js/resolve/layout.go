@@ -0,0 +1,79 @@
+package resolve
+
+import (
+	"fmt"
+	"strings"
+
+	"tools/please_js/common"
+)
+
+// layout says which BUILD file in the subrepo each package's rules go in.
+type layout struct {
+	flat  string // "", "all" or "scope", as --flat gives it
+	types bool   // @types packages all in //types, whatever flat says
+}
+
+const (
+	layoutPackage = ""      // a directory per package: //react, //babel_core
+	layoutFlat    = "all"   // every rule in the root BUILD file: //:react, //:babel_core
+	layoutScope   = "scope" // a directory per scope, the rest at the root: //:react, //babel:babel_core
+)
+
+// typesDir is the directory @types packages are grouped in.
+const typesDir = "types"
+
+// parseLayout parses the --flat option, "" for a directory per package,
+// "all" or "scope", and the --types option, which with "group" puts
+// @types packages together.
+func parseLayout(flat, types string) (layout, error) {
+	switch flat {
+	case layoutPackage, layoutFlat, layoutScope:
+	default:
+		return layout{}, fmt.Errorf("invalid --flat %q (expected all or scope)", flat)
+	}
+	switch types {
+	case "", "label", "group":
+	default:
+		return layout{}, fmt.Errorf("invalid --types %q (expected label or group)", types)
+	}
+	return layout{flat: flat, types: types == "group"}, nil
+}
+
+// dir returns the subrepo directory whose BUILD file holds a package's rules.
+func (l layout) dir(name string) string {
+	if l.types && isTypesPackage(name) {
+		return typesDir
+	}
+	switch l.flat {
+	case layoutFlat:
+		return ""
+	case layoutScope:
+		if scope, _, ok := strings.Cut(name, "/"); ok && strings.HasPrefix(scope, "@") {
+			return common.FlattenPkgName(scope)
+		}
+		return ""
+	}
+	return common.FlattenPkgName(name)
+}
+
+// label returns the subrepo label of one of a package's targets.
+// "react", "react" → "//react", or in the flat layout "//:react".
+func (l layout) label(name, target string) string {
+	dir := l.dir(name)
+	if l.flat == layoutPackage && target == dir {
+		return common.DepTarget(name)
+	}
+	return fmt.Sprintf("//%s:%s", dir, target)
+}
+
+// depLabel returns the label of a package's main target.
+func (l layout) depLabel(name string) string {
+	return l.label(name, common.FlattenPkgName(name))
+}
+
+// isTypesPackage reports whether a package is one of DefinitelyTyped's
+// type declarations, @types/react, which only the TypeScript compiler
+// needs.
+func isTypesPackage(name string) bool {
+	return strings.HasPrefix(name, "@types/")
+}
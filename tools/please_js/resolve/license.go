@@ -0,0 +1,310 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// licenseField is a package's license as package.json and lockfiles record
+// it: an SPDX expression such as "MIT" or "(MIT OR Apache-2.0)", or in older
+// packages {"type": "MIT"}, or a list of those, any of which applies.
+type licenseField string
+
+func (l *licenseField) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = licenseField(s)
+		return nil
+	}
+	var obj struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &obj); err == nil {
+		*l = licenseField(obj.Type)
+		return nil
+	}
+	var list []licenseField
+	if err := json.Unmarshal(data, &list); err != nil {
+		// Not worth failing the lockfile over.
+		return nil
+	}
+	var alternatives []string
+	for _, item := range list {
+		if item != "" {
+			alternatives = append(alternatives, string(item))
+		}
+	}
+	if len(alternatives) > 1 {
+		*l = licenseField("(" + strings.Join(alternatives, " OR ") + ")")
+	} else if len(alternatives) == 1 {
+		*l = licenseField(alternatives[0])
+	}
+	return nil
+}
+
+// registryMeta reads licenses, install scripts and sizes from a cache of npm
+// registry metadata: the documents https://registry.npmjs.org/<name>
+// serves, saved as <dir>/<name>.json (<dir>/@babel/core.json for scoped
+// packages).
+type registryMeta struct {
+	dir  string
+	docs map[string]*registryDoc
+}
+
+// registryDoc holds the fields of a registry document licenses, install
+// scripts and sizes are read from.
+type registryDoc struct {
+	License  licenseField `json:"license"`
+	Licenses licenseField `json:"licenses"`
+	Versions map[string]struct {
+		License          licenseField      `json:"license"`
+		Licenses         licenseField      `json:"licenses"`
+		Scripts          map[string]string `json:"scripts"`
+		HasInstallScript bool              `json:"hasInstallScript"`
+		Dist             struct {
+			UnpackedSize int64 `json:"unpackedSize"`
+		} `json:"dist"`
+	} `json:"versions"`
+}
+
+// doc returns the cached registry document of a package, or nil if the
+// cache doesn't have it.
+func (m *registryMeta) doc(name string) (*registryDoc, error) {
+	doc, ok := m.docs[name]
+	if !ok {
+		data, err := os.ReadFile(filepath.Join(m.dir, filepath.FromSlash(name)+".json"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read registry metadata for %s: %w", name, err)
+		}
+		if err == nil {
+			doc = &registryDoc{}
+			if err := json.Unmarshal(data, doc); err != nil {
+				return nil, fmt.Errorf("failed to parse registry metadata for %s: %w", name, err)
+			}
+		}
+		m.docs[name] = doc
+	}
+	return doc, nil
+}
+
+// license returns the license the registry gives a version of a package,
+// or "" if the cache doesn't have it.
+func (m *registryMeta) license(name, version string) (string, error) {
+	doc, err := m.doc(name)
+	if err != nil || doc == nil {
+		return "", err
+	}
+	if v, ok := doc.Versions[version]; ok {
+		if v.License != "" {
+			return string(v.License), nil
+		}
+		if v.Licenses != "" {
+			return string(v.Licenses), nil
+		}
+	}
+	if doc.License != "" {
+		return string(doc.License), nil
+	}
+	return string(doc.Licenses), nil
+}
+
+// fillLicenses looks up the licenses of the packages the lockfile didn't
+// record one for in the registry metadata cache.
+func fillLicenses(meta *registryMeta, packages []resolvedPackage, ctargets []conflictTarget) error {
+	for i, pkg := range packages {
+		if pkg.Workspace != "" || pkg.License != "" {
+			continue
+		}
+		license, err := meta.license(pkg.effectivePkgName(), pkg.Version)
+		if err != nil {
+			return err
+		}
+		packages[i].License = license
+	}
+	for i, ct := range ctargets {
+		if ct.License != "" {
+			continue
+		}
+		license, err := meta.license(ct.PkgName, ct.Version)
+		if err != nil {
+			return err
+		}
+		ctargets[i].License = license
+	}
+	return nil
+}
+
+// licensePolicy is the licenses dependencies may have. A license expression
+// is permitted if some choice of its alternatives uses only licenses that
+// are allowed, if there's an allowlist, and none that are denied. Without
+// an allowlist, packages whose license isn't known are permitted.
+type licensePolicy struct {
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+// newLicensePolicy makes a policy from lists of license IDs, each of which
+// may also be comma-separated.
+func newLicensePolicy(allow, deny []string) licensePolicy {
+	set := func(lists []string) map[string]bool {
+		m := map[string]bool{}
+		for _, list := range lists {
+			for _, id := range strings.Split(list, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					m[strings.ToLower(id)] = true
+				}
+			}
+		}
+		return m
+	}
+	return licensePolicy{allow: set(allow), deny: set(deny)}
+}
+
+// enabled reports whether the policy restricts anything.
+func (p licensePolicy) enabled() bool {
+	return len(p.allow) > 0 || len(p.deny) > 0
+}
+
+// permits reports whether the policy permits a license expression.
+func (p licensePolicy) permits(expr string) bool {
+	if strings.TrimSpace(expr) == "" {
+		return len(p.allow) == 0
+	}
+	return evalLicense(expr, func(id string) bool {
+		id = strings.ToLower(id)
+		return (len(p.allow) == 0 || p.allow[id]) && !p.deny[id]
+	})
+}
+
+// checkLicenses returns an error listing the packages whose licenses the
+// policy doesn't permit.
+func checkLicenses(policy licensePolicy, packages []resolvedPackage, ctargets []conflictTarget) error {
+	var violations []string
+	check := func(name, version, license string) {
+		if policy.permits(license) {
+			return
+		}
+		if license == "" {
+			license = "no license recorded; pass --registry-meta to look it up"
+		}
+		violations = append(violations, fmt.Sprintf("%s@%s: %s", name, version, license))
+	}
+	for _, pkg := range packages {
+		if pkg.Workspace == "" {
+			check(pkg.effectivePkgName(), pkg.Version, pkg.License)
+		}
+	}
+	for _, ct := range ctargets {
+		check(ct.PkgName, ct.Version, ct.License)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	sort.Strings(violations)
+	return fmt.Errorf("dependencies with licenses the policy doesn't permit:\n  %s", strings.Join(violations, "\n  "))
+}
+
+// licenseList returns a license expression as npm_module's licences, which
+// Please accepts if any of them is accepted: the alternatives of an
+// expression that only has alternatives, such as "(MIT OR Apache-2.0)", or
+// else the expression as it is.
+func licenseList(expr string) []string {
+	if expr == "" {
+		return nil
+	}
+	// Parenthesised alternatives flatten, "((MIT OR ISC) OR BSD-2-Clause)".
+	var ids []string
+	ors := 0
+	for _, token := range licenseTokens(expr) {
+		switch token {
+		case "(", ")":
+		case "OR":
+			ors++
+		case "AND", "WITH":
+			return []string{expr}
+		default:
+			ids = append(ids, token)
+		}
+	}
+	if ors == 0 || len(ids) != ors+1 {
+		return []string{expr}
+	}
+	return ids
+}
+
+// licenseTokens splits an SPDX expression into its license IDs, operators
+// and parentheses.
+func licenseTokens(expr string) []string {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	var tokens []string
+	for _, token := range strings.Fields(expr) {
+		switch upper := strings.ToUpper(token); upper {
+		case "AND", "OR", "WITH":
+			tokens = append(tokens, upper)
+		default:
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// evalLicense evaluates an SPDX expression, with AND binding tighter than
+// OR, reporting whether it's satisfied given which license IDs are
+// permitted. A license WITH an exception counts as the license. An
+// expression that doesn't parse is taken as a single ID.
+func evalLicense(expr string, permitted func(id string) bool) bool {
+	tokens := licenseTokens(expr)
+	i := 0
+	var parseOr func() (bool, bool)
+	parseTerm := func() (bool, bool) {
+		if i >= len(tokens) {
+			return false, false
+		}
+		token := tokens[i]
+		i++
+		switch token {
+		case "(":
+			value, ok := parseOr()
+			if !ok || i >= len(tokens) || tokens[i] != ")" {
+				return false, false
+			}
+			i++
+			return value, true
+		case ")", "AND", "OR", "WITH":
+			return false, false
+		}
+		if i < len(tokens) && tokens[i] == "WITH" {
+			i += 2
+		}
+		return permitted(strings.TrimSuffix(token, "+")), true
+	}
+	parseAnd := func() (bool, bool) {
+		value, ok := parseTerm()
+		for ok && i < len(tokens) && tokens[i] == "AND" {
+			i++
+			var next bool
+			next, ok = parseTerm()
+			value = value && next
+		}
+		return value, ok
+	}
+	parseOr = func() (bool, bool) {
+		value, ok := parseAnd()
+		for ok && i < len(tokens) && tokens[i] == "OR" {
+			i++
+			var next bool
+			next, ok = parseAnd()
+			value = value || next
+		}
+		return value, ok
+	}
+	value, ok := parseOr()
+	if !ok || i != len(tokens) {
+		return permitted(strings.TrimSpace(expr))
+	}
+	return value
+}
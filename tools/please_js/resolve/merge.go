@@ -0,0 +1,158 @@
+package resolve
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"tools/please_js/common"
+)
+
+// readLockfiles reads the lockfiles of one or more projects, such as the
+// apps of a monorepo, into one package set. Where they have a package at
+// different versions, the first lockfile's is at the top level and the
+// others' are nested under their dependents, as version conflicts within a
+// lockfile are; those conflicts are reported to w.
+func readLockfiles(w io.Writer, paths []string, format string) (*packageLock, error) {
+	var merged *packageLock
+	var conflicts []string
+	origins := map[string]string{} // lockfile path -> the lockfile it came from
+	for _, path := range paths {
+		lock, err := readLockfile(path, format)
+		if err != nil {
+			if len(paths) > 1 {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			return nil, err
+		}
+		resolveInRepoPaths(lock, path)
+		if merged == nil {
+			merged = lock
+			for pkgPath := range lock.Packages {
+				origins[pkgPath] = path
+			}
+			continue
+		}
+		conflicts = append(conflicts, mergeLockfile(merged, lock, path, origins)...)
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		fmt.Fprintf(w, "%d packages have different versions in different lockfiles; the first lockfile's is used at the top level:\n  %s\n", len(conflicts), strings.Join(conflicts, "\n  "))
+	}
+	return merged, nil
+}
+
+// resolveInRepoPaths makes the workspace directories and file: dependencies
+// of a lockfile, which are relative to it, relative to the repo root, which
+// its path is given from, as labels are. file: directories are linked, as
+// workspaces are.
+func resolveInRepoPaths(lock *packageLock, lockfile string) {
+	inRepo := func(rel string) string {
+		return filepath.ToSlash(filepath.Join(filepath.Dir(lockfile), rel))
+	}
+	for path, info := range lock.Packages {
+		if file, ok := strings.CutPrefix(info.Resolved, "file:"); ok {
+			if isTarballPath(file) {
+				info.Resolved = "file:" + inRepo(file)
+			} else {
+				info.Link = true
+				info.Resolved = file
+			}
+		}
+		if info.Link {
+			info.Resolved = inRepo(info.Resolved)
+		}
+		lock.Packages[path] = info
+	}
+}
+
+// mergeLockfile adds the packages of another lockfile to merged, returning
+// a note of each package it has at another version than merged does. A
+// package is dev-only if it is in every lockfile that has it. The packages
+// nested under one of the lockfile's top-level packages go wherever it does.
+func mergeLockfile(merged, lock *packageLock, lockfile string, origins map[string]string) []string {
+	paths := make([]string, 0, len(lock.Packages))
+	for path := range lock.Packages {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	var notes []string
+	var displaced []string // top-level paths whose version merged has another of
+	for _, path := range paths {
+		info := lock.Packages[path]
+		existing, ok := merged.Packages[path]
+		if common.IsNestedPackage(path) && slices.Contains(displaced, topLevelPath(path)) {
+			// Nested under a displaced package; moved with it below.
+			continue
+		}
+		switch {
+		case !ok:
+			merged.Packages[path] = info
+			origins[path] = lockfile
+		case existing.Version == info.Version && existing.Resolved == info.Resolved:
+			existing.Dev = existing.Dev && info.Dev
+			merged.Packages[path] = existing
+		default:
+			name := strings.TrimPrefix(path, "node_modules/")
+			notes = append(notes, fmt.Sprintf("%s: %s (%s), %s (%s)", name, existing.Version, origins[path], info.Version, lockfile))
+			if !common.IsNestedPackage(path) {
+				displaced = append(displaced, path)
+			}
+		}
+	}
+	// The lockfile's top-level packages that depend on a displaced one get
+	// their version of it nested under them, unless merged has another
+	// version of them too.
+	for _, path := range displaced {
+		name := common.ExtractPackageName(path)
+		info := lock.Packages[path]
+		for _, parent := range paths {
+			parentInfo := lock.Packages[parent]
+			if common.IsNestedPackage(parent) || !dependsOn(parentInfo, name) {
+				continue
+			}
+			nested := parent + "/node_modules/" + name
+			if _, ok := lock.Packages[nested]; ok {
+				continue
+			}
+			if _, ok := merged.Packages[nested]; ok || merged.Packages[parent].Version != parentInfo.Version {
+				continue
+			}
+			merged.Packages[nested] = info
+			origins[nested] = lockfile
+			for _, sub := range paths {
+				if rest, ok := strings.CutPrefix(sub, path+"/node_modules/"); ok {
+					merged.Packages[nested+"/node_modules/"+rest] = lock.Packages[sub]
+					origins[nested+"/node_modules/"+rest] = lockfile
+				}
+			}
+		}
+	}
+	return notes
+}
+
+// topLevelPath returns the lockfile path of the top-level package a nested
+// one is under.
+// "node_modules/porto/node_modules/zod" → "node_modules/porto"
+func topLevelPath(path string) string {
+	if idx := strings.Index(path, "/node_modules/"); idx >= 0 {
+		return path[:idx]
+	}
+	return path
+}
+
+// dependsOn reports whether a package depends on another by name, in any
+// way.
+func dependsOn(info packageInfo, name string) bool {
+	for _, section := range []map[string]string{info.Dependencies, info.OptionalDependencies, info.PeerDependencies} {
+		if _, ok := section[name]; ok {
+			return true
+		}
+	}
+	return false
+}
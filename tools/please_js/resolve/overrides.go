@@ -0,0 +1,380 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tools/please_js/common"
+)
+
+// packageOverride forces the version of a package, as package.json's
+// overrides (npm), resolutions (yarn) and pnpm.overrides do.
+type packageOverride struct {
+	field  string // the package.json field it's from, e.g. "overrides"
+	parent string // the package it's overridden under; "" for everywhere
+	name   string
+	from   string // the versions it overrides; "" for any
+	spec   string // the version or range it forces
+}
+
+// packageManifest holds the package.json fields overrides are read from.
+type packageManifest struct {
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+	Overrides            map[string]any    `json:"overrides"`
+	Resolutions          map[string]string `json:"resolutions"`
+	Pnpm                 struct {
+		Overrides map[string]string `json:"overrides"`
+	} `json:"pnpm"`
+}
+
+// readOverrides reads the overrides in a package.json.
+func readOverrides(path string) ([]packageOverride, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	var manifest packageManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var overrides []packageOverride
+	// "$name" refers to the version the project itself depends on.
+	rootSpec := func(spec string) string {
+		name, ok := strings.CutPrefix(spec, "$")
+		if !ok {
+			return spec
+		}
+		for _, deps := range []map[string]string{manifest.Dependencies, manifest.DevDependencies, manifest.OptionalDependencies, manifest.PeerDependencies} {
+			if s, ok := deps[name]; ok {
+				return s
+			}
+		}
+		return ""
+	}
+	var addNpm func(parent string, m map[string]any)
+	addNpm = func(parent string, m map[string]any) {
+		for key, value := range m {
+			if key == "." {
+				continue
+			}
+			name, from := splitOverrideKey(key)
+			// A package's own override is "." in an object of overrides
+			// under it.
+			spec, _ := value.(string)
+			nested, _ := value.(map[string]any)
+			if nested != nil {
+				spec, _ = nested["."].(string)
+			}
+			if spec = rootSpec(spec); spec != "" {
+				overrides = append(overrides, packageOverride{field: "overrides", parent: parent, name: name, from: from, spec: spec})
+			}
+			if nested != nil {
+				addNpm(name, nested)
+			}
+		}
+	}
+	addNpm("", manifest.Overrides)
+
+	// yarn: "ms", "**/ms", "debug/ms" or "debug/**/ms".
+	for key, spec := range manifest.Resolutions {
+		var names []string
+		segments := strings.Split(key, "/")
+		for i := 0; i < len(segments); i++ {
+			switch {
+			case segments[i] == "**":
+			case strings.HasPrefix(segments[i], "@") && i+1 < len(segments):
+				names = append(names, segments[i]+"/"+segments[i+1])
+				i++
+			default:
+				names = append(names, segments[i])
+			}
+		}
+		spec, ok := overrideSpec(spec)
+		if !ok || len(names) == 0 {
+			continue
+		}
+		o := packageOverride{field: "resolutions", name: names[len(names)-1], spec: spec}
+		if len(names) > 1 {
+			o.parent, _ = splitOverrideKey(names[len(names)-2])
+		}
+		overrides = append(overrides, o)
+	}
+
+	// pnpm: "ms", "ms@<2" or "debug>ms".
+	for key, spec := range manifest.Pnpm.Overrides {
+		spec, ok := overrideSpec(rootSpec(spec))
+		if !ok {
+			continue
+		}
+		o := packageOverride{field: "pnpm.overrides", spec: spec}
+		if parent, child, ok := strings.Cut(key, ">"); ok {
+			o.parent, _ = splitOverrideKey(parent)
+			key = child
+		}
+		o.name, o.from = splitOverrideKey(key)
+		overrides = append(overrides, o)
+	}
+
+	// Overrides under a parent apply before those for everywhere.
+	sort.Slice(overrides, func(i, j int) bool {
+		a, b := overrides[i], overrides[j]
+		if (a.parent == "") != (b.parent == "") {
+			return a.parent != ""
+		}
+		if a.field != b.field {
+			return a.field < b.field
+		}
+		if a.parent != b.parent {
+			return a.parent < b.parent
+		}
+		return a.name+"@"+a.from < b.name+"@"+b.from
+	})
+	return overrides, nil
+}
+
+// splitOverrideKey splits an override's key, "ms" or "ms@<2.1.3", into the
+// package name and the versions it overrides.
+func splitOverrideKey(key string) (name, from string) {
+	if key == "" {
+		return "", ""
+	}
+	return splitYarnDescriptor(key)
+}
+
+// overrideSpec returns the version or range an override forces, dropping an
+// "npm:" protocol. Overrides to anything else, such as a git repository or
+// pnpm's "-" for removing a dependency, aren't applied.
+func overrideSpec(spec string) (string, bool) {
+	spec = strings.TrimPrefix(spec, "npm:")
+	if spec == "" || spec == "-" || strings.ContainsAny(spec, ":/") {
+		return "", false
+	}
+	return spec, true
+}
+
+// applyOverrides substitutes each package in the lockfile whose version an
+// override doesn't allow with a version of it in the lockfile that it does,
+// as a lockfile from before the override was added needs. It returns notes
+// of the substitutions, by the name of the package whose target records
+// them: the overridden package, or for a nested one, the package it's
+// nested under.
+func applyOverrides(pkgs map[string]packageInfo, overrides []packageOverride) map[string][]string {
+	if len(overrides) == 0 {
+		return nil
+	}
+	// Replacements are taken from the lockfile as it was. Top-level paths
+	// sort first, so the top-level version is preferred.
+	original := make(map[string]packageInfo, len(pkgs))
+	var paths []string
+	for path, info := range pkgs {
+		original[path] = info
+		if path != "" && !info.Link {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if ni, nj := common.IsNestedPackage(paths[i]), common.IsNestedPackage(paths[j]); ni != nj {
+			return nj
+		}
+		return paths[i] < paths[j]
+	})
+	byName := map[string][]string{}
+	for _, path := range paths {
+		name := common.ExtractPackageName(path)
+		byName[name] = append(byName[name], path)
+	}
+
+	notes := map[string][]string{}
+	for _, path := range paths {
+		name := common.ExtractPackageName(path)
+		info := original[path]
+		parent := ""
+		if common.IsNestedPackage(path) {
+			parent = common.ExtractPackageName(common.ExtractParentPackagePath(path))
+		}
+		for _, o := range overrides {
+			if o.name != name || (o.parent != "" && o.parent != parent) || (o.from != "" && !satisfiesRange(info.Version, o.from)) {
+				continue
+			}
+			if satisfiesRange(info.Version, o.spec) {
+				break
+			}
+			var replacement *packageInfo
+			for _, candidate := range byName[name] {
+				if c := original[candidate]; satisfiesRange(c.Version, o.spec) {
+					replacement = &c
+					break
+				}
+			}
+			if replacement == nil {
+				log.Printf("warning: %s forces %s to %s, but the lockfile has no matching version; %s is left at %s", o.field, name, o.spec, path, info.Version)
+				break
+			}
+			replacement.Dev = info.Dev
+			pkgs[path] = *replacement
+			owner := name
+			if parent != "" {
+				owner = parent
+			}
+			notes[owner] = append(notes[owner], fmt.Sprintf("%s %s overridden to %s by package.json %s", name, info.Version, replacement.Version, o.field))
+			break
+		}
+	}
+	return notes
+}
+
+// satisfiesRange reports whether a version satisfies an npm semver range,
+// such as "^1.2.0", "~1.2", ">=1.0.0 <2", "1.x" or "1 || 2". Prereleases
+// satisfy only a range naming them exactly.
+func satisfiesRange(version, rng string) bool {
+	rng = strings.TrimSpace(rng)
+	if version == rng || strings.TrimPrefix(rng, "=") == version {
+		return true
+	}
+	v, n, ok := parsePartialVersion(version)
+	if !ok || n < 3 || strings.ContainsAny(version, "-+") {
+		return false
+	}
+	for _, alt := range strings.Split(rng, "||") {
+		fields := strings.Fields(alt)
+		// A hyphen range, "1.2.3 - 2.3.4".
+		if len(fields) == 3 && fields[1] == "-" {
+			fields = []string{">=" + fields[0], "<=" + fields[2]}
+		}
+		// An operator written apart from its version, ">= 16.8".
+		for i := 0; i < len(fields)-1; i++ {
+			if strings.Trim(fields[i], "<>=^~") == "" {
+				fields = slices.Replace(fields, i, i+2, fields[i]+fields[i+1])
+			}
+		}
+		matched := true
+		for _, field := range fields {
+			if !satisfiesComparator(v, field) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// satisfiesComparator reports whether v satisfies one comparator of a range.
+func satisfiesComparator(v [3]int, comparator string) bool {
+	var op string
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(comparator, prefix) {
+			op = prefix
+			break
+		}
+	}
+	c, n, ok := parsePartialVersion(comparator[len(op):])
+	if !ok {
+		return false
+	}
+	// The version after the last one a partial version (1.2, 1) covers.
+	next := c
+	if n > 0 {
+		next[n-1]++
+		for i := n; i < 3; i++ {
+			next[i] = 0
+		}
+	}
+	switch op {
+	case "^":
+		if n == 0 {
+			return true
+		}
+		// Bumps the first non-zero part, or the last given if all are zero.
+		i := 0
+		for i < n-1 && c[i] == 0 {
+			i++
+		}
+		upper := [3]int{}
+		copy(upper[:i], c[:i])
+		upper[i] = c[i] + 1
+		return compareVersions(v, c) >= 0 && compareVersions(v, upper) < 0
+	case "~":
+		if n == 0 {
+			return true
+		}
+		upper := [3]int{c[0] + 1, 0, 0}
+		if n > 1 {
+			upper = [3]int{c[0], c[1] + 1, 0}
+		}
+		return compareVersions(v, c) >= 0 && compareVersions(v, upper) < 0
+	case ">=":
+		return compareVersions(v, c) >= 0
+	case ">":
+		if n < 3 {
+			return n == 0 || compareVersions(v, next) >= 0
+		}
+		return compareVersions(v, c) > 0
+	case "<":
+		return n > 0 && compareVersions(v, c) < 0
+	case "<=":
+		if n < 3 {
+			return n == 0 || compareVersions(v, next) < 0
+		}
+		return compareVersions(v, c) <= 0
+	case "", "=":
+		if n == 3 {
+			return compareVersions(v, c) == 0
+		}
+		return n == 0 || compareVersions(v, c) >= 0 && compareVersions(v, next) < 0
+	}
+	return false
+}
+
+// parsePartialVersion parses a version that may leave out parts or give
+// them as x or *, "1.2.x" or "1", returning how many parts it gives.
+func parsePartialVersion(s string) (v [3]int, n int, ok bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	if s == "" {
+		return v, 0, true
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, 0, false
+	}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		num, err := strconv.Atoi(part)
+		if err != nil || num < 0 {
+			return v, 0, false
+		}
+		v[i] = num
+		n = i + 1
+	}
+	return v, n, true
+}
+
+// compareVersions compares two versions part by part.
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
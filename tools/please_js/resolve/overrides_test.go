@@ -0,0 +1,68 @@
+package resolve
+
+import "testing"
+
+func TestSatisfiesRange(t *testing.T) {
+	tests := []struct {
+		version, rng string
+		want         bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "=1.2.3", true},
+		{"1.2.4", "1.2.3", false},
+		// Caret
+		{"1.9.0", "^1.2.3", true},
+		{"2.0.0", "^1.2.3", false},
+		{"1.2.2", "^1.2.3", false},
+		{"0.2.5", "^0.2.3", true},
+		{"0.3.0", "^0.2.3", false},
+		{"0.0.3", "^0.0.3", true},
+		{"0.0.4", "^0.0.3", false},
+		{"1.5.0", "^1", true},
+		// Tilde
+		{"1.2.9", "~1.2.3", true},
+		{"1.3.0", "~1.2.3", false},
+		{"1.9.0", "~1", true},
+		{"2.0.0", "~1", false},
+		// Comparators and their spacing
+		{"18.2.0", ">=16.8.0", true},
+		{"18.2.0", ">= 16.8", true},
+		{"18.2.0", ">= 16.8.0 < 19", true},
+		{"19.0.0", ">= 16.8.0 < 19", false},
+		{"18.2.0", ">=16.8.0 <19", true},
+		{"18.2.0", "> 18.1", true},
+		{"18.1.5", "> 18.1", false},
+		{"18.1.5", "<= 18.1", true},
+		{"18.2.0", "<= 18.1", false},
+		{"18.2.0", "^ 18.0.0", true},
+		// Hyphen ranges
+		{"1.5.0", "1.2.3 - 2.3.4", true},
+		{"2.3.4", "1.2.3 - 2.3.4", true},
+		{"2.3.5", "1.2.3 - 2.3.4", false},
+		// Alternatives
+		{"17.0.2", "^16.8 || ^17.0 || ^18.0", true},
+		{"19.0.0", "^16.8 || ^17.0 || ^18.0", false},
+		{"18.2.0", ">= 16 < 17 || >= 18", true},
+		// X-ranges
+		{"1.2.7", "1.2.x", true},
+		{"1.3.0", "1.2.x", false},
+		{"1.9.9", "1.x", true},
+		{"1.9.9", "1", true},
+		{"2.0.0", "1.X", false},
+		{"5.0.0", "*", true},
+		{"5.0.0", "", true},
+		{"17.0.0", "17.x || 18.x", true},
+		// Prereleases satisfy only a range naming them
+		{"19.0.0-rc.1", "^18.0.0 || ^19.0.0", false},
+		{"19.0.0-rc.1", "19.0.0-rc.1", true},
+		{"19.0.0-rc.1", ">=18", false},
+		// Not versions
+		{"latest", "^1.0.0", false},
+		{"1.2.3", "not-a-range", false},
+	}
+	for _, tt := range tests {
+		if got := satisfiesRange(tt.version, tt.rng); got != tt.want {
+			t.Errorf("satisfiesRange(%q, %q) = %v, want %v", tt.version, tt.rng, got, tt.want)
+		}
+	}
+}
@@ -4,12 +4,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"tools/please_js/common"
 )
 
 // packageLock represents the top-level structure of package-lock.json (v3).
 type packageLock struct {
 	LockfileVersion int                    `json:"lockfileVersion"`
 	Packages        map[string]packageInfo `json:"packages"`
+	// Dependencies is the tree lockfile version 1 has instead of packages.
+	Dependencies map[string]v1Dependency `json:"dependencies"`
+}
+
+// v1Dependency is a package in a version 1 lockfile's dependency tree.
+type v1Dependency struct {
+	Version      string                  `json:"version"` // "npm:ms@2.1.3" for an alias
+	Resolved     string                  `json:"resolved"`
+	Integrity    string                  `json:"integrity"`
+	Requires     map[string]string       `json:"requires"`
+	Dependencies map[string]v1Dependency `json:"dependencies"` // nested under this package
+	Dev          bool                    `json:"dev"`
+	Optional     bool                    `json:"optional"`
+	Bundled      bool                    `json:"bundled"` // shipped inside the tarball of the package it's nested under
 }
 
 // peerDepMeta holds metadata for a single peer dependency entry.
@@ -23,12 +42,50 @@ type packageInfo struct {
 	Resolved             string                 `json:"resolved"`
 	Integrity            string                 `json:"integrity"`
 	Dependencies         map[string]string      `json:"dependencies"`
+	DevDependencies      map[string]string      `json:"devDependencies"` // only recorded for the root project, ""
+	OptionalDependencies map[string]string      `json:"optionalDependencies"`
 	PeerDependencies     map[string]string      `json:"peerDependencies"`
 	PeerDependenciesMeta map[string]peerDepMeta `json:"peerDependenciesMeta"`
 	Dev                  bool                   `json:"dev"`
 	Optional             bool                   `json:"optional"`
+	Link                 bool                   `json:"link"` // a workspace, with Resolved its directory
 	OS                   []string               `json:"os"`
 	CPU                  []string               `json:"cpu"`
+	License              licenseField           `json:"license"`
+	Bin                  map[string]string      `json:"bin"`              // command name -> script, relative to the package
+	HasInstallScript     bool                   `json:"hasInstallScript"` // has preinstall, install or postinstall scripts
+	InBundle             bool                   `json:"inBundle"`         // shipped inside the tarball of the package it's nested under
+	// Bundled is the names of the dependencies the package ships inside its
+	// tarball, its bundleDependencies, which it imports from there.
+	Bundled []string `json:"-"`
+}
+
+// readLockfile parses a lockfile in the given format: "npm" for
+// package-lock.json, "pnpm" for pnpm-lock.yaml, "yarn" for yarn.lock, "bun"
+// for bun.lock, or "" to tell from the file name.
+func readLockfile(path, format string) (*packageLock, error) {
+	switch format {
+	case "":
+		if isPnpmLockfile(path) {
+			return parsePnpmLockfile(path)
+		}
+		if isYarnLockfile(path) {
+			return parseYarnLockfile(path)
+		}
+		if isBunLockfile(path) {
+			return parseBunLockfile(path)
+		}
+		return parseLockfile(path)
+	case "npm":
+		return parseLockfile(path)
+	case "pnpm":
+		return parsePnpmLockfile(path)
+	case "yarn":
+		return parseYarnLockfile(path)
+	case "bun":
+		return parseBunLockfile(path)
+	}
+	return nil, fmt.Errorf("unknown lockfile format %q (expected npm, pnpm, yarn or bun)", format)
 }
 
 // parseLockfile reads and parses a package-lock.json file.
@@ -43,9 +100,69 @@ func parseLockfile(path string) (*packageLock, error) {
 		return nil, fmt.Errorf("failed to parse lockfile: %w", err)
 	}
 
-	if lock.LockfileVersion != 3 && lock.LockfileVersion != 2 {
-		return nil, fmt.Errorf("unsupported lockfile version %d (expected 2 or 3)", lock.LockfileVersion)
+	switch lock.LockfileVersion {
+	case 1:
+		lock.Packages = map[string]packageInfo{"": {}}
+		addV1Packages(lock.Packages, "", lock.Dependencies)
+	case 2, 3:
+		// Workspace links usually say link: true, but a relative resolved
+		// path without a version is a directory too.
+		for path, info := range lock.Packages {
+			if !info.Link && info.Version == "" && info.Resolved != "" && !strings.Contains(info.Resolved, "://") {
+				info.Link = true
+				lock.Packages[path] = info
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported lockfile version %d (expected 1, 2 or 3)", lock.LockfileVersion)
 	}
+	markBundled(lock.Packages)
 
 	return &lock, nil
 }
+
+// addV1Packages adds a version 1 dependency tree to packages, under the
+// node_modules paths later versions key them by.
+func addV1Packages(packages map[string]packageInfo, parent string, deps map[string]v1Dependency) {
+	for name, dep := range deps {
+		path := "node_modules/" + name
+		if parent != "" {
+			path = parent + "/node_modules/" + name
+		}
+		version := dep.Version
+		if alias, ok := strings.CutPrefix(version, "npm:"); ok {
+			version = alias[strings.LastIndex(alias, "@")+1:]
+		}
+		packages[path] = packageInfo{
+			Version:      version,
+			Resolved:     dep.Resolved,
+			Integrity:    dep.Integrity,
+			Dependencies: dep.Requires,
+			Dev:          dep.Dev,
+			Optional:     dep.Optional,
+			InBundle:     dep.Bundled,
+		}
+		addV1Packages(packages, path, dep.Dependencies)
+	}
+}
+
+// markBundled records each package's bundled dependencies, the packages
+// nested under it that are in its bundle, and theirs, as its own.
+func markBundled(packages map[string]packageInfo) {
+	for path, info := range packages {
+		if !info.InBundle || !common.IsNestedPackage(path) {
+			continue
+		}
+		parent := common.ExtractParentPackagePath(path)
+		for packages[parent].InBundle && common.IsNestedPackage(parent) {
+			parent = common.ExtractParentPackagePath(parent)
+		}
+		owner := packages[parent]
+		name := common.ExtractPackageName(path)
+		if !slices.Contains(owner.Bundled, name) {
+			owner.Bundled = append(owner.Bundled, name)
+			sort.Strings(owner.Bundled)
+			packages[parent] = owner
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package resolve
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// packagePatch is a patch-package patch, named for the package it patches:
+// "lodash+4.17.21.patch", "@babel+core+7.23.0.patch", or for a package
+// nested under another, "react-dom++scheduler+0.23.0.patch". A sequence
+// number and description may follow the version, "lodash+4.17.21+001+fix.patch".
+type packagePatch struct {
+	file    string // path of the patch file
+	path    string // node_modules path it patches, e.g. "node_modules/lodash"
+	name    string // package name, as installed under node_modules
+	version string
+}
+
+// readPatches reads the patch-package patches in dir, sorted by file name
+// so that numbered patches apply in order. A missing dir has none.
+func readPatches(dir string) ([]packagePatch, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read patches: %w", err)
+	}
+	var patches []packagePatch
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".patch") {
+			continue
+		}
+		patch, ok := parsePatchName(entry.Name())
+		if !ok {
+			return nil, fmt.Errorf("invalid patch file name %s: expected <package>+<version>.patch", entry.Name())
+		}
+		patch.file = filepath.Join(dir, entry.Name())
+		patches = append(patches, patch)
+	}
+	sort.Slice(patches, func(i, j int) bool { return patches[i].file < patches[j].file })
+	return patches, nil
+}
+
+// parsePatchName parses a patch file's name into the package it patches.
+func parsePatchName(file string) (packagePatch, bool) {
+	base := strings.TrimSuffix(strings.TrimSuffix(file, ".patch"), ".dev")
+	segments := strings.Split(base, "++")
+	var names []string
+	var version string
+	for i, segment := range segments {
+		parts := strings.Split(segment, "+")
+		name, rest := parts[0], parts[1:]
+		if strings.HasPrefix(name, "@") && len(rest) > 0 {
+			name, rest = name+"/"+rest[0], rest[1:]
+		}
+		if name == "" {
+			return packagePatch{}, false
+		}
+		names = append(names, name)
+		if i == len(segments)-1 {
+			if len(rest) == 0 || rest[0] == "" {
+				return packagePatch{}, false
+			}
+			version = rest[0]
+		}
+	}
+	return packagePatch{
+		path:    "node_modules/" + strings.Join(names, "/node_modules/"),
+		name:    names[len(names)-1],
+		version: version,
+	}, true
+}
+
+// writePatch copies a patch into dir, rewriting its paths, which
+// patch-package makes relative to the project ("a/node_modules/lodash/fp.js"),
+// to be relative to the package ("a/fp.js") for npm_module to apply.
+func writePatch(dir string, patch packagePatch) (string, error) {
+	data, err := os.ReadFile(patch.file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read patch: %w", err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ") {
+			line = strings.ReplaceAll(line, "a/"+patch.path+"/", "a/")
+			lines[i] = strings.ReplaceAll(line, "b/"+patch.path+"/", "b/")
+		}
+	}
+	name := filepath.Base(patch.file)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "")), 0644); err != nil {
+		return "", err
+	}
+	return name, nil
+}
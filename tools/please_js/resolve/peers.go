@@ -0,0 +1,55 @@
+package resolve
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// checkPeers reports the peer dependencies the subrepo doesn't satisfy: a
+// package's peers resolve to the top-level version, which must be in the
+// package's range. Peers on workspace packages, whose versions aren't
+// known, and ranges that aren't versions, such as workspace:*, are taken
+// as satisfied. With strict set, it returns an error
+// listing them instead.
+func checkPeers(w io.Writer, packages []resolvedPackage, ctargets []conflictTarget, strict bool) error {
+	versions := make(map[string]string, len(packages))
+	workspaces := map[string]bool{}
+	for _, pkg := range packages {
+		if pkg.Workspace != "" {
+			workspaces[pkg.Name] = true
+		}
+		versions[pkg.Name] = pkg.Version
+	}
+	var problems []string
+	check := func(name, version string, peers map[string]string) {
+		for peer, rng := range peers {
+			if workspaces[peer] || strings.Contains(rng, ":") {
+				continue
+			}
+			have, ok := versions[peer]
+			switch {
+			case !ok:
+				problems = append(problems, fmt.Sprintf("%s@%s wants %s@%s: not in the subrepo", name, version, peer, rng))
+			case !satisfiesRange(have, rng):
+				problems = append(problems, fmt.Sprintf("%s@%s wants %s@%s: has %s", name, version, peer, rng, have))
+			}
+		}
+	}
+	for _, pkg := range packages {
+		check(pkg.effectivePkgName(), pkg.Version, pkg.Peers)
+	}
+	for _, ct := range ctargets {
+		check(ct.PkgName, ct.Version, ct.Peers)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	if strict {
+		return fmt.Errorf("unsatisfied peer dependencies:\n  %s", strings.Join(problems, "\n  "))
+	}
+	fmt.Fprintf(w, "warning: %d unsatisfied peer dependencies:\n  %s\n", len(problems), strings.Join(problems, "\n  "))
+	return nil
+}
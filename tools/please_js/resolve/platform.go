@@ -0,0 +1,56 @@
+package resolve
+
+import "strings"
+
+// pleasePlatforms are the platforms platform-specific packages are resolved
+// for, named as npm_module names them: CONFIG.OS + "_" + CONFIG.ARCH.
+var pleasePlatforms = []struct{ os, arch string }{
+	{"darwin", "amd64"},
+	{"darwin", "arm64"},
+	{"freebsd", "amd64"},
+	{"freebsd", "arm64"},
+	{"linux", "386"},
+	{"linux", "amd64"},
+	{"linux", "arm"},
+	{"linux", "arm64"},
+}
+
+// npmCPUs maps Go architecture names, as Please uses, to npm's cpu names.
+var npmCPUs = map[string]string{
+	"386":   "ia32",
+	"amd64": "x64",
+	"arm":   "arm",
+	"arm64": "arm64",
+}
+
+// packagePlatforms returns the platforms a package's os and cpu fields allow
+// it to install on, such as ["linux_amd64"]. restricted is false for
+// packages that install everywhere, which are depended on unconditionally.
+func packagePlatforms(info packageInfo) (platforms []string, restricted bool) {
+	if len(info.OS) == 0 && len(info.CPU) == 0 {
+		return nil, false
+	}
+	for _, p := range pleasePlatforms {
+		if npmAllows(info.OS, p.os) && npmAllows(info.CPU, npmCPUs[p.arch]) {
+			platforms = append(platforms, p.os+"_"+p.arch)
+		}
+	}
+	return platforms, len(platforms) < len(pleasePlatforms)
+}
+
+// npmAllows reports whether value passes an npm os or cpu list, as npm
+// checks it: "!name" entries exclude name, and if there are any others,
+// value must be one of them.
+func npmAllows(list []string, value string) bool {
+	allowed, allowList := false, false
+	for _, v := range list {
+		if v == "!"+value {
+			return false
+		}
+		if !strings.HasPrefix(v, "!") {
+			allowList = true
+			allowed = allowed || v == value
+		}
+	}
+	return allowed || !allowList
+}
@@ -0,0 +1,272 @@
+package resolve
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// isPnpmLockfile reports whether path is named like a pnpm lockfile.
+func isPnpmLockfile(path string) bool {
+	ext := filepath.Ext(path)
+	return filepath.Base(path) == "pnpm-lock.yaml" || ext == ".yaml" || ext == ".yml"
+}
+
+// parsePnpmLockfile reads a pnpm-lock.yaml (lockfile version 6 or 9) into
+// the package-lock.json layout the rest of resolve works on. pnpm doesn't
+// hoist, so the importers' dependencies are hoisted as npm would. The
+// directories importers link to (link:../ui) are linked at the top level,
+// as npm links workspaces.
+func parsePnpmLockfile(path string) (*packageLock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	version := yamlString(doc["lockfileVersion"])
+	major, _, _ := strings.Cut(version, ".")
+	var importers []pnpmImporter
+	switch major {
+	case "6":
+		importers = []pnpmImporter{{".", doc}}
+		if ws := yamlMap(doc["importers"]); len(ws) > 0 {
+			importers = pnpmImporters(ws)
+		}
+	case "9":
+		importers = pnpmImporters(yamlMap(doc["importers"]))
+	default:
+		return nil, fmt.Errorf("unsupported pnpm lockfile version %q (expected 6 or 9)", version)
+	}
+
+	packages := map[string]*lockedPackage{}
+	addVariant := func(key string, fields map[string]any) {
+		ref, ok := parsePnpmKey(key)
+		if !ok {
+			return
+		}
+		pkg := packages[ref.key()]
+		if pkg == nil {
+			pkg = &lockedPackage{name: ref.name, version: ref.version, deps: map[string]packageRef{}}
+			packages[ref.key()] = pkg
+		}
+		if resolution := yamlMap(fields["resolution"]); resolution != nil {
+			pkg.info.Integrity = yamlString(resolution["integrity"])
+			pkg.info.Resolved = yamlString(resolution["tarball"])
+			switch yamlString(resolution["type"]) {
+			case "git":
+				pkg.info.Resolved = "git+" + yamlString(resolution["repo"]) + "#" + yamlString(resolution["commit"])
+			case "directory":
+				pkg.info.Link = true
+				pkg.info.Resolved = yamlString(resolution["directory"])
+			}
+			// Registry packages are recorded by integrity alone.
+			if pkg.info.Resolved == "" && pkg.info.Integrity != "" {
+				pkg.info.Resolved = registryTarball(ref.name, ref.version)
+			}
+		}
+		// Git, tarball and local packages are keyed by where they're from,
+		// and give their version separately.
+		if version := yamlString(fields["version"]); version != "" {
+			pkg.info.Version = version
+		}
+		if peers := yamlMap(fields["peerDependencies"]); peers != nil {
+			pkg.info.PeerDependencies = yamlStrings(peers)
+		}
+		for dep, meta := range yamlMap(fields["peerDependenciesMeta"]) {
+			if pkg.info.PeerDependenciesMeta == nil {
+				pkg.info.PeerDependenciesMeta = map[string]peerDepMeta{}
+			}
+			pkg.info.PeerDependenciesMeta[dep] = peerDepMeta{Optional: yamlString(yamlMap(meta)["optional"]) == "true"}
+		}
+		if yamlString(fields["optional"]) == "true" {
+			pkg.info.Optional = true
+		}
+		// Version 6 says which packages have install scripts.
+		if yamlString(fields["requiresBuild"]) == "true" {
+			pkg.info.HasInstallScript = true
+		}
+		pkg.info.OS = append(pkg.info.OS, yamlList(fields["os"])...)
+		pkg.info.CPU = append(pkg.info.CPU, yamlList(fields["cpu"])...)
+		pkg.info.Bundled = append(pkg.info.Bundled, yamlList(fields["bundledDependencies"])...)
+		for _, section := range []string{"dependencies", "optionalDependencies"} {
+			for dep, value := range yamlMap(fields[section]) {
+				// The first variant's resolution wins, as keys are sorted.
+				if _, ok := pkg.deps[dep]; ok || slices.Contains(pkg.info.Bundled, dep) {
+					continue
+				}
+				if ref, ok := parsePnpmRef(dep, yamlString(value)); ok {
+					pkg.deps[dep] = ref
+				}
+			}
+		}
+	}
+	// Version 9 keeps each package's metadata in packages and the
+	// dependencies of each peer variant in snapshots; version 6 has both in
+	// packages, keyed with a leading slash.
+	for _, section := range []string{"packages", "snapshots"} {
+		entries := yamlMap(doc[section])
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			addVariant(strings.TrimPrefix(key, "/"), yamlMap(entries[key]))
+		}
+	}
+
+	// Direct dependencies, production ones first so that a package that's
+	// both keeps its production version at the top level.
+	var prodRoots, devRoots []rootDep
+	var links []pnpmLink
+	for _, importer := range importers {
+		for _, section := range []string{"dependencies", "optionalDependencies", "devDependencies"} {
+			deps := yamlMap(importer.fields[section])
+			names := make([]string, 0, len(deps))
+			for name := range deps {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				value := deps[name]
+				// Version 9 and 6.1 give {specifier, version}, 6.0 the version.
+				if m := yamlMap(value); m != nil {
+					value = m["version"]
+				}
+				if dir, ok := strings.CutPrefix(yamlString(value), "link:"); ok {
+					links = append(links, pnpmLink{name, filepath.ToSlash(filepath.Join(importer.dir, dir)), section == "devDependencies"})
+					continue
+				}
+				ref, ok := parsePnpmRef(name, yamlString(value))
+				if !ok {
+					continue
+				}
+				if section == "devDependencies" {
+					devRoots = append(devRoots, rootDep{name, ref})
+				} else {
+					prodRoots = append(prodRoots, rootDep{name, ref})
+				}
+			}
+		}
+	}
+
+	lock := hoist(packages, prodRoots, devRoots)
+	for _, link := range links {
+		key := "node_modules/" + link.name
+		if info, ok := lock.Packages[key]; ok {
+			// Linked from both dependencies and devDependencies.
+			if info.Link && !link.dev {
+				info.Dev = false
+				lock.Packages[key] = info
+			}
+			continue
+		}
+		lock.Packages[key] = packageInfo{Link: true, Resolved: link.dir, Dev: link.dev}
+	}
+	return lock, nil
+}
+
+// pnpmImporter is a project in the lockfile, the root or a workspace
+// package, in dir relative to the lockfile.
+type pnpmImporter struct {
+	dir    string
+	fields map[string]any
+}
+
+// pnpmLink is a dependency an importer links to a directory.
+type pnpmLink struct {
+	name string
+	dir  string // relative to the lockfile
+	dev  bool
+}
+
+// pnpmImporters returns the importers of a workspace lockfile, the root
+// first and the rest sorted by path.
+func pnpmImporters(importers map[string]any) []pnpmImporter {
+	paths := make([]string, 0, len(importers))
+	for path := range importers {
+		if path != "." {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	if _, ok := importers["."]; ok {
+		paths = append([]string{"."}, paths...)
+	}
+	result := make([]pnpmImporter, 0, len(paths))
+	for _, path := range paths {
+		result = append(result, pnpmImporter{path, yamlMap(importers[path])})
+	}
+	return result
+}
+
+// parsePnpmKey parses a package key such as "react-dom@18.2.0(react@18.2.0)"
+// or "@babel/core@7.23.0" into the package it names, dropping the peer
+// dependency suffix.
+func parsePnpmKey(key string) (packageRef, bool) {
+	if i := strings.Index(key, "("); i >= 0 {
+		key = key[:i]
+	}
+	if key == "" {
+		return packageRef{}, false
+	}
+	// The first @ after a scope's, as the version may be a URL with its own.
+	i := strings.Index(key[1:], "@") + 1
+	if i <= 0 {
+		return packageRef{}, false
+	}
+	return packageRef{name: key[:i], version: key[i+1:]}, true
+}
+
+// parsePnpmRef parses the version pnpm resolved a dependency to: a version,
+// possibly with a peer suffix, a URL or file: path for git, tarball and
+// local packages, or "<name>@<version>" for an npm alias. Links have no
+// package and are skipped.
+func parsePnpmRef(name, version string) (packageRef, bool) {
+	if version == "" || strings.HasPrefix(version, "link:") {
+		return packageRef{}, false
+	}
+	if i := strings.Index(version, "("); i >= 0 {
+		version = version[:i]
+	}
+	// An alias names the package before its first @; a URL has a scheme
+	// there instead.
+	if i := strings.Index(version[1:], "@") + 1; i > 0 && !strings.Contains(version[:i], ":") {
+		return parsePnpmKey(version)
+	}
+	return packageRef{name: name, version: version}, true
+}
+
+func yamlMap(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func yamlString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func yamlList(v any) []string {
+	items, _ := v.([]any)
+	var result []string
+	for _, item := range items {
+		result = append(result, yamlString(item))
+	}
+	return result
+}
+
+func yamlStrings(m map[string]any) map[string]string {
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = yamlString(v)
+	}
+	return result
+}
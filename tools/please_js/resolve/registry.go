@@ -0,0 +1,183 @@
+package resolve
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultRegistry is the npm registry, which npm_module fetches from itself.
+const defaultRegistry = "https://registry.npmjs.org"
+
+// registryConfig says which registry each package is fetched from, and with
+// which credentials, as .npmrc's registry, @scope:registry and
+// //host/:_authToken settings do.
+type registryConfig struct {
+	registry string            // registry for unscoped packages, without a trailing slash
+	scopes   map[string]string // scope, e.g. "@corp", -> its registry
+	// Registry URLs without their scheme, "//npm.corp.com/", -> the
+	// environment variable holding their token.
+	tokens map[string]string
+}
+
+// envVar matches a whole .npmrc value that's an environment variable, ${NPM_TOKEN}.
+var envVar = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// parseRegistry validates a registry URL, returning it without a trailing
+// slash.
+func parseRegistry(registry string) (string, error) {
+	u, err := url.Parse(registry)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", fmt.Errorf("invalid registry %q: must be an http(s) URL", registry)
+	}
+	return strings.TrimSuffix(registry, "/"), nil
+}
+
+// newRegistryConfig makes the registry configuration from an .npmrc file,
+// "" for none, and a registry for unscoped packages that overrides its
+// registry setting, "" for none.
+func newRegistryConfig(npmrc, registry string) (registryConfig, error) {
+	cfg := registryConfig{registry: defaultRegistry, scopes: map[string]string{}, tokens: map[string]string{}}
+	if npmrc != "" {
+		if err := cfg.readNpmrc(npmrc); err != nil {
+			return cfg, err
+		}
+	}
+	if registry != "" {
+		var err error
+		if cfg.registry, err = parseRegistry(registry); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// readNpmrc reads the registry and auth settings of an .npmrc file. Tokens
+// must come from environment variables, _authToken=${NPM_TOKEN}: the
+// variable is passed to the rules that fetch from the registry, so the
+// token itself is never written into a BUILD file.
+func (cfg *registryConfig) readNpmrc(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read .npmrc: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"'`)
+		switch {
+		case key == "registry":
+			if cfg.registry, err = parseRegistry(os.ExpandEnv(value)); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+		case strings.HasPrefix(key, "@") && strings.HasSuffix(key, ":registry"):
+			registry, err := parseRegistry(os.ExpandEnv(value))
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			cfg.scopes[strings.TrimSuffix(key, ":registry")] = registry
+		case strings.HasPrefix(key, "//"):
+			prefix, setting, _ := strings.Cut(key, ":")
+			if !strings.HasSuffix(prefix, "/") {
+				prefix += "/"
+			}
+			switch setting {
+			case "_authToken":
+				m := envVar.FindStringSubmatch(value)
+				if m == nil {
+					log.Printf("warning: %s:%d: ignoring the _authToken for %s: give it as ${VARIABLE}, not in the file", path, n, prefix)
+					continue
+				}
+				cfg.tokens[prefix] = m[1]
+			case "_auth", "_password", "username", "certfile", "keyfile":
+				log.Printf("warning: %s:%d: ignoring %s for %s: only _authToken is supported", path, n, setting, prefix)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read .npmrc: %w", err)
+	}
+	return nil
+}
+
+// enabled reports whether the configuration fetches anything other than
+// public packages from the npm registry.
+func (cfg registryConfig) enabled() bool {
+	return cfg.registry != defaultRegistry || len(cfg.scopes) > 0 || len(cfg.tokens) > 0
+}
+
+// registryFor returns the registry a package is fetched from.
+func (cfg registryConfig) registryFor(name string) string {
+	if scope, _, ok := strings.Cut(name, "/"); ok && strings.HasPrefix(scope, "@") {
+		if registry, ok := cfg.scopes[scope]; ok {
+			return registry
+		}
+	}
+	return cfg.registry
+}
+
+// tokenEnv returns the environment variable holding the token to fetch a
+// URL with: that of the longest registry URL it's under, "" for none.
+func (cfg registryConfig) tokenEnv(tarball string) string {
+	_, rest, ok := strings.Cut(tarball, ":")
+	if !ok {
+		return ""
+	}
+	prefixes := make([]string, 0, len(cfg.tokens))
+	for prefix := range cfg.tokens {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(rest, prefix) {
+			return cfg.tokens[prefix]
+		}
+	}
+	return ""
+}
+
+// source returns where to fetch a package from, given where its lockfile
+// entry says to: packages from the npm registry are fetched from their
+// registry instead, at the same path under its URL,
+// https://npm.corp.com/@corp/ui/-/ui-1.0.0.tgz, with its token. Public
+// packages from the npm registry are left to npm_module.
+func (cfg registryConfig) source(name, version string, source packageSource) packageSource {
+	if source == (packageSource{}) {
+		registry := cfg.registryFor(name)
+		tarball := registry + "/" + registryTarballPath(name, version)
+		if registry == defaultRegistry && cfg.tokenEnv(tarball) == "" {
+			return source
+		}
+		source.URL = tarball
+	}
+	if source.URL != "" {
+		source.TokenEnv = cfg.tokenEnv(source.URL)
+	}
+	return source
+}
+
+// applyRegistries sets where each package is fetched from, and with which
+// token.
+func applyRegistries(cfg registryConfig, packages []resolvedPackage, ctargets []conflictTarget) {
+	for i, pkg := range packages {
+		if pkg.Workspace == "" {
+			packages[i].Source = cfg.source(pkg.effectivePkgName(), pkg.Version, pkg.Source)
+		}
+	}
+	for i, ct := range ctargets {
+		ctargets[i].Source = cfg.source(ct.PkgName, ct.Version, ct.Source)
+	}
+}
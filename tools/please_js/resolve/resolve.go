@@ -2,53 +2,275 @@ package resolve
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 // Args holds the arguments for the resolve subcommand.
 type Args struct {
-	Lockfile       string
+	Lockfiles      []string // merged into one subrepo, the first's versions at the top level
+	LockfileFormat string   // "npm", "pnpm", "yarn", "bun", or "" to detect from the file name
 	Out            string
 	NoDev          bool
 	SubincludePath string
+	Workspaces     []string // name=label: the in-repo target for a workspace package
+	PatchesDir     string   // directory of patch-package patches; "" for none
+	PackageJSON    string   // package.json whose overrides/resolutions to apply; "" for none
+	Incremental    bool     // update an existing Out, rewriting only the files that changed
+	Check          bool     // compare an existing Out with what would be generated, failing if they differ
+	DryRun         bool     // print what regenerating Out would add, change and remove, without writing anything
+	Only           []string // packages to generate, with what they depend on; empty for all
+	Exclude        []string // packages not to generate
+	Registry       string   // npm registry mirror to fetch packages from; "" for the npm registry
+	Npmrc          string   // .npmrc whose registry, scoped registry and auth token settings to apply; "" for none
+	Visibility     []string // visibility of the generated rules; empty for PUBLIC
+	Labels         []string // labels to add to the generated rules
+	Flat           string   // "all" or "scope" to put packages' rules in fewer BUILD files; "" for one per package
+	Types          string   // "label" to label @types packages npm:types, "group" to also put them together in //types; "" for neither
+	RegistryMeta   string   // directory of cached registry metadata to read licenses, install scripts and sizes from; "" for none
+	ForbidScripts  bool     // fail if any package has install scripts
+	StrictPeers    bool     // fail if any peer dependency isn't satisfied, rather than warning
+	LicenseAllow   []string // licenses dependencies may have; empty for any
+	LicenseDeny    []string // licenses dependencies may not have
+	Graph          string   // file to write the dependency graph to, .dot or .json; "" for none
+	CycleStrategy  string   // which edge of each dependency cycle to remove, or "report" to fail; "" to remove the back-edge
+	CycleReport    string   // file to write the removed cycle edges to as JSON; "" for none
+	DedupeReport   string   // file to write the packages at more than one version to, as text or .json; "" for none
+	Audit          bool     // check the packages for known vulnerabilities
+	AuditDB        string   // offline OSV database to audit against; "" for the OSV API
+	AuditLevel     string   // severity at or above which a vulnerability fails resolution; "" for none
 }
 
 // Run executes the resolve subcommand.
 func Run(args Args) error {
-	lock, err := parseLockfile(args.Lockfile)
+	l, err := parseLayout(args.Flat, args.Types)
 	if err != nil {
 		return err
 	}
+	filter, err := newPackageFilter(args.Only, args.Exclude)
+	if err != nil {
+		return err
+	}
+	visibility, err := parseVisibility(args.Visibility)
+	if err != nil {
+		return err
+	}
+	registries, err := newRegistryConfig(args.Npmrc, args.Registry)
+	if err != nil {
+		return err
+	}
+	lock, err := readLockfiles(os.Stderr, args.Lockfiles, args.LockfileFormat)
+	if err != nil {
+		return err
+	}
+	var patches []packagePatch
+	if args.PatchesDir != "" {
+		if patches, err = readPatches(args.PatchesDir); err != nil {
+			return err
+		}
+	}
+	var overrideNotes map[string][]string
+	if args.PackageJSON != "" {
+		overrides, err := readOverrides(args.PackageJSON)
+		if err != nil {
+			return err
+		}
+		overrideNotes = applyOverrides(lock.Packages, overrides)
+	}
+	workspaces := make(map[string]string, len(args.Workspaces))
+	for _, ws := range args.Workspaces {
+		name, label, ok := strings.Cut(ws, "=")
+		if !ok || name == "" || !strings.HasPrefix(label, "//") {
+			return fmt.Errorf("invalid workspace %q: must be name=//path:target", ws)
+		}
+		workspaces[name] = label
+	}
 
 	// Collect all top-level packages (skip root, nested, dev) and version-conflict targets
-	packages, conflictTargets := collectPackages(lock.Packages, args.NoDev)
-	breakCycles(packages, conflictTargets)
+	packages, conflictTargets := collectPackages(lock.Packages, args.NoDev, workspaces, l)
+	if filter.enabled() {
+		packages, conflictTargets = filter.apply(packages, conflictTargets, directDependencies(lock.Packages[""]))
+	}
+	if err := checkPeers(os.Stderr, packages, conflictTargets, args.StrictPeers); err != nil {
+		return err
+	}
+	cycles, err := breakCycles(packages, conflictTargets, args.CycleStrategy)
+	if err != nil {
+		if args.CycleReport != "" && cycles != nil {
+			if err := writeCycleReport(args.CycleReport, cycles); err != nil {
+				log.Printf("warning: %v", err)
+			}
+		}
+		return err
+	}
+	if registries.enabled() {
+		applyRegistries(registries, packages, conflictTargets)
+	}
+	attachPatches(patches, packages, conflictTargets)
+	for i, pkg := range packages {
+		packages[i].Overrides = overrideNotes[pkg.Name]
+	}
+	var meta *registryMeta
+	if args.RegistryMeta != "" {
+		meta = &registryMeta{dir: args.RegistryMeta, docs: map[string]*registryDoc{}}
+		if err := fillLicenses(meta, packages, conflictTargets); err != nil {
+			return err
+		}
+		if err := fillScripts(meta, packages, conflictTargets); err != nil {
+			return err
+		}
+	}
+	if err := scriptsReport(os.Stderr, packages, conflictTargets, args.ForbidScripts); err != nil {
+		return err
+	}
+	if policy := newLicensePolicy(args.LicenseAllow, args.LicenseDeny); policy.enabled() {
+		if err := checkLicenses(policy, packages, conflictTargets); err != nil {
+			return err
+		}
+	}
+	if args.Audit {
+		refs := auditRefs(packages, conflictTargets)
+		findings, err := audit(refs, args.AuditDB)
+		if err != nil {
+			return err
+		}
+		if err := auditReport(os.Stderr, findings, len(refs), args.AuditLevel); err != nil {
+			return err
+		}
+	}
 
-	// Generate output directory
-	if err := os.MkdirAll(args.Out, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	// Generate output directory. An incremental run generates into a
+	// temporary one, then updates the existing one from it; a check or a
+	// dry run compares the existing one with it instead.
+	out := args.Out
+	readOnly := args.Check || args.DryRun
+	switch {
+	case readOnly:
+		tmp, err := os.MkdirTemp("", "resolve-")
+		if err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		out = tmp
+	case args.Incremental:
+		if err := os.MkdirAll(args.Out, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		tmp, err := os.MkdirTemp(filepath.Dir(args.Out), ".resolve-")
+		if err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		out = tmp
+	default:
+		if err := os.MkdirAll(args.Out, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
 	}
 
 	// Write .plzconfig with plugin declaration
-	if err := writePlzConfig(args.Out); err != nil {
+	if err := writePlzConfig(out); err != nil {
 		return fmt.Errorf("failed to write .plzconfig: %w", err)
 	}
 
 	// Generate BUILD files with explicit subinclude
+	files := newBuildFiles(out, args.SubincludePath, l, visibility, splitList(args.Labels), args.Types != "")
 	for _, pkg := range packages {
-		if err := writeBuildFile(args.Out, pkg, args.SubincludePath); err != nil {
+		if pkg.Workspace != "" {
+			if err := files.addWorkspace(pkg); err != nil {
+				return fmt.Errorf("failed to write BUILD for %s: %w", pkg.Name, err)
+			}
+			continue
+		}
+		if err := files.addPackage(pkg); err != nil {
 			return fmt.Errorf("failed to write BUILD for %s: %w", pkg.Name, err)
 		}
 	}
 
-	// Append version-conflict targets to existing BUILD files
+	// Add version-conflict targets alongside their packages' main ones
 	for _, ct := range conflictTargets {
-		if err := appendConflictTarget(args.Out, ct); err != nil {
+		if err := files.addConflictTarget(ct); err != nil {
 			return fmt.Errorf("failed to write conflict target %s: %w", ct.TargetName, err)
 		}
 	}
+	if l.types {
+		if err := files.addTypesGroup(packages); err != nil {
+			return fmt.Errorf("failed to write the types group: %w", err)
+		}
+	}
+	if err := files.write(); err != nil {
+		return fmt.Errorf("failed to write BUILD files: %w", err)
+	}
 
 	total := len(packages) + len(conflictTargets)
 	fmt.Fprintf(os.Stderr, "Generated %d npm_module rules (%d version-conflict targets)\n", total, len(conflictTargets))
+	// Reports written into the output directory are generated with it, so
+	// they're checked or updated too. A check or dry run writes no others.
+	reports := []struct {
+		path  string
+		write func(path string) error
+	}{
+		{args.Graph, func(path string) error { return writeGraph(path, buildGraph(packages, conflictTargets, cycles, l)) }},
+		{args.CycleReport, func(path string) error { return writeCycleReport(path, cycles) }},
+		{args.DedupeReport, func(path string) error {
+			entries, err := dedupeReport(meta, packages, conflictTargets)
+			if err != nil {
+				return err
+			}
+			return writeDedupeReport(path, entries)
+		}},
+	}
+	for _, report := range reports {
+		if report.path == "" {
+			continue
+		}
+		path := report.path
+		if rel, err := filepath.Rel(args.Out, path); err == nil && filepath.IsLocal(rel) {
+			path = filepath.Join(out, rel)
+		}
+		if path != report.path || !readOnly {
+			if err := report.write(path); err != nil {
+				return err
+			}
+		}
+	}
+	if args.DryRun {
+		return dryRunReport(os.Stdout, out, args.Out)
+	}
+	if args.Check {
+		return checkDir(os.Stderr, out, args.Out)
+	}
+	if args.Incremental {
+		written, removed, err := syncDir(out, args.Out)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Updated %d files, removed %d\n", written, removed)
+	}
 	return nil
 }
+
+// attachPatches attaches each patch to the packages at the version it
+// names, whichever package they're nested under.
+func attachPatches(patches []packagePatch, packages []resolvedPackage, ctargets []conflictTarget) {
+	for _, patch := range patches {
+		matched := false
+		for i, pkg := range packages {
+			if pkg.Workspace == "" && pkg.Name == patch.name && pkg.Version == patch.version {
+				packages[i].Patches = append(packages[i].Patches, patch)
+				matched = true
+			}
+		}
+		for i, ct := range ctargets {
+			if ct.PkgName == patch.name && ct.Version == patch.version {
+				ctargets[i].Patches = append(ctargets[i].Patches, patch)
+				matched = true
+			}
+		}
+		if !matched {
+			log.Printf("warning: %s patches %s@%s, which isn't in the lockfile", filepath.Base(patch.file), patch.name, patch.version)
+		}
+	}
+}
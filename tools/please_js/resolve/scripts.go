@@ -0,0 +1,93 @@
+package resolve
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// installScripts are the lifecycle scripts npm runs when it installs a
+// package, which npm_module doesn't.
+var installScripts = []string{"preinstall", "install", "postinstall"}
+
+// scripts returns the install scripts the registry gives a version of a
+// package, and whether it has any: npm also runs node-gyp for a package
+// with a binding.gyp, which the registry records as hasInstallScript
+// without a script.
+func (m *registryMeta) scripts(name, version string) ([]string, bool, error) {
+	doc, err := m.doc(name)
+	if err != nil || doc == nil {
+		return nil, false, err
+	}
+	v, ok := doc.Versions[version]
+	if !ok {
+		return nil, false, nil
+	}
+	var scripts []string
+	for _, script := range installScripts {
+		if v.Scripts[script] != "" {
+			scripts = append(scripts, script)
+		}
+	}
+	return scripts, len(scripts) > 0 || v.HasInstallScript, nil
+}
+
+// fillScripts looks up which install scripts the packages have in the
+// registry metadata cache, for those the lockfile doesn't say have some
+// too.
+func fillScripts(meta *registryMeta, packages []resolvedPackage, ctargets []conflictTarget) error {
+	for i, pkg := range packages {
+		if pkg.Workspace != "" {
+			continue
+		}
+		scripts, has, err := meta.scripts(pkg.effectivePkgName(), pkg.Version)
+		if err != nil {
+			return err
+		}
+		packages[i].Scripts = scripts
+		packages[i].HasScripts = pkg.HasScripts || has
+	}
+	for i, ct := range ctargets {
+		scripts, has, err := meta.scripts(ct.PkgName, ct.Version)
+		if err != nil {
+			return err
+		}
+		ctargets[i].Scripts = scripts
+		ctargets[i].HasScripts = ct.HasScripts || has
+	}
+	return nil
+}
+
+// scriptsReport writes a summary of the packages with install scripts,
+// which the build doesn't run, returning an error listing them if forbid
+// is set.
+func scriptsReport(w io.Writer, packages []resolvedPackage, ctargets []conflictTarget, forbid bool) error {
+	var lines []string
+	add := func(name, version string, scripts []string) {
+		line := name + "@" + version
+		if len(scripts) > 0 {
+			line += ": " + strings.Join(scripts, ", ")
+		}
+		lines = append(lines, line)
+	}
+	for _, pkg := range packages {
+		if pkg.HasScripts {
+			add(pkg.effectivePkgName(), pkg.Version, pkg.Scripts)
+		}
+	}
+	for _, ct := range ctargets {
+		if ct.HasScripts {
+			add(ct.PkgName, ct.Version, ct.Scripts)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	sort.Strings(lines)
+	if forbid {
+		return fmt.Errorf("dependencies with install scripts, which aren't run:\n  %s", strings.Join(lines, "\n  "))
+	}
+	fmt.Fprintf(w, "%d packages have install scripts, which aren't run (labelled npm:has-scripts):\n  %s\n", len(lines), strings.Join(lines, "\n  "))
+	return nil
+}
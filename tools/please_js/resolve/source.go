@@ -0,0 +1,84 @@
+package resolve
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// packageSource is where a package not fetched from the npm registry comes
+// from. At most one of URL, Src and GitRepo is set.
+type packageSource struct {
+	URL      string // tarball URL
+	TokenEnv string // environment variable holding the token to fetch URL with; "" for none
+	Src      string // in-repo target providing the tarball
+	GitRepo  string // git repository, checked out at Revision
+	Revision string
+}
+
+// gitTarballs give the URL of a commit's tarball on the git hosts that serve
+// them, which npm downloads instead of cloning.
+var gitTarballs = map[string]func(repo, commit string) string{
+	"github.com": func(repo, commit string) string {
+		return "https://codeload.github.com/" + repo + "/tar.gz/" + commit
+	},
+	"gitlab.com": func(repo, commit string) string {
+		return "https://gitlab.com/" + repo + "/-/archive/" + commit + "/" + path.Base(repo) + "-" + commit + ".tar.gz"
+	},
+	"bitbucket.org": func(repo, commit string) string {
+		return "https://bitbucket.org/" + repo + "/get/" + commit + ".tar.gz"
+	},
+}
+
+// packageSourceOf works out where to fetch a package from its lockfile
+// resolved field. Registry tarballs, from the npm registry or a mirror of
+// it, give the zero packageSource: npm_module fetches those itself. Local
+// tarballs ("file:vendor/foo-1.0.0.tgz") map to the target named in
+// localTargets, or else the target named after the file in its directory,
+// //vendor:foo-1.0.0. ok is false for a source there's no way to fetch.
+func packageSourceOf(name, resolved string, localTargets map[string]string) (packageSource, bool) {
+	if file, ok := strings.CutPrefix(resolved, "file:"); ok {
+		if label, ok := localTargets[name]; ok {
+			return packageSource{Src: label}, true
+		}
+		dir := path.Dir(file)
+		if dir == "." {
+			dir = ""
+		}
+		target := strings.TrimSuffix(strings.TrimSuffix(path.Base(file), ".tgz"), ".tar.gz")
+		return packageSource{Src: "//" + dir + ":" + target}, true
+	}
+	if strings.HasPrefix(resolved, "git+") || strings.HasPrefix(resolved, "git://") {
+		repo, commit, _ := strings.Cut(strings.TrimPrefix(resolved, "git+"), "#")
+		u, err := url.Parse(repo)
+		if err != nil || commit == "" {
+			return packageSource{}, false
+		}
+		if tarball, ok := gitTarballs[u.Hostname()]; ok {
+			return packageSource{URL: tarball(strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"), commit)}, true
+		}
+		return packageSource{GitRepo: repo, Revision: commit}, true
+	}
+	if strings.HasPrefix(resolved, "https://") || strings.HasPrefix(resolved, "http://") {
+		// yarn appends the tarball's sha1 as a fragment.
+		tarball, _, _ := strings.Cut(resolved, "#")
+		if isRegistryTarball(tarball) {
+			return packageSource{}, true
+		}
+		return packageSource{URL: tarball}, true
+	}
+	return packageSource{}, false
+}
+
+// isRegistryTarball reports whether a URL is laid out as registry tarballs
+// are, .../<name>/-/<base>-<version>.tgz.
+func isRegistryTarball(tarball string) bool {
+	i := strings.LastIndex(tarball, "/-/")
+	return i >= 0 && !strings.Contains(tarball[i+3:], "/") && strings.HasSuffix(tarball, ".tgz")
+}
+
+// isTarballPath reports whether a file: dependency names a tarball rather
+// than a directory.
+func isTarballPath(file string) bool {
+	return strings.HasSuffix(file, ".tgz") || strings.HasSuffix(file, ".tar.gz")
+}
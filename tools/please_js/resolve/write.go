@@ -1,7 +1,9 @@
 package resolve
 
 import (
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -17,24 +19,138 @@ func writePlzConfig(outDir string) error {
 	return os.WriteFile(filepath.Join(outDir, ".plzconfig"), []byte(content), 0644)
 }
 
-// writeBuildFile generates a BUILD file for a single npm package using
-// the buildtools AST for correct formatting.
-func writeBuildFile(outDir string, pkg resolvedPackage, subincludePath string) error {
-	pkgDir := filepath.Join(outDir, common.FlattenPkgName(pkg.Name))
-	if err := os.MkdirAll(pkgDir, 0755); err != nil {
-		return err
+// buildFiles accumulates the generated BUILD files, using the buildtools
+// AST for correct formatting, until they're written. Each is formatted
+// once, however many packages' rules it holds.
+type buildFiles struct {
+	outDir         string
+	subincludePath string
+	layout         layout
+	visibility     []string               // of every rule
+	labels         []string               // added to every rule
+	typesLabel     bool                   // label @types packages npm:types
+	files          map[string]*build.File // by subrepo directory
+}
+
+func newBuildFiles(outDir, subincludePath string, l layout, visibility, labels []string, typesLabel bool) *buildFiles {
+	return &buildFiles{
+		outDir:         outDir,
+		subincludePath: subincludePath,
+		layout:         l,
+		visibility:     visibility,
+		labels:         labels,
+		typesLabel:     typesLabel,
+		files:          map[string]*build.File{},
 	}
+}
 
-	f := &build.File{
-		Path: filepath.Join(pkgDir, "BUILD"),
-		Type: build.TypeBuild,
+// parseVisibility makes the visibility of the generated rules from lists of
+// labels, each of which may also be comma-separated: PUBLIC if there are
+// none. Labels are in the repo the subrepo is defined in, so are written
+// as @//app/..., and the subrepo's own packages can always see each other.
+func parseVisibility(lists []string) ([]string, error) {
+	var visibility []string
+	for _, label := range splitList(lists) {
+		switch {
+		case label == "PUBLIC":
+			return []string{"PUBLIC"}, nil
+		case strings.HasPrefix(label, "//"):
+			visibility = append(visibility, "@"+label)
+		default:
+			return nil, fmt.Errorf("invalid visibility %q: must be PUBLIC or a label, //path/...", label)
+		}
+	}
+	if len(visibility) == 0 {
+		return []string{"PUBLIC"}, nil
 	}
+	return append([]string{"//..."}, visibility...), nil
+}
 
-	// subinclude(...)
-	f.Stmt = append(f.Stmt, &build.CallExpr{
-		X:    &build.Ident{Name: "subinclude"},
-		List: []build.Expr{&build.StringExpr{Value: subincludePath}},
-	})
+// splitList splits lists of values, each of which may also be
+// comma-separated, into the values.
+func splitList(lists []string) []string {
+	var values []string
+	for _, list := range lists {
+		for _, value := range strings.Split(list, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// ruleLabels returns the labels of a generated rule for a package, which
+// is dev-only or has install scripts.
+func (b *buildFiles) ruleLabels(name string, dev, scripts bool) []string {
+	var labels []string
+	if dev {
+		labels = append(labels, "npm:dev")
+	}
+	if scripts {
+		labels = append(labels, "npm:has-scripts")
+	}
+	if b.typesLabel && isTypesPackage(name) {
+		labels = append(labels, "npm:types")
+	}
+	return append(labels, b.labels...)
+}
+
+// file returns the BUILD file for a subrepo directory, creating the
+// directory if need be. With subinclude, the file subincludes the build
+// defs, for npm_module.
+func (b *buildFiles) file(dir string, subinclude bool) (*build.File, error) {
+	f := b.files[dir]
+	if f == nil {
+		if err := os.MkdirAll(filepath.Join(b.outDir, dir), 0755); err != nil {
+			return nil, err
+		}
+		f = &build.File{
+			Path: filepath.Join(b.outDir, dir, "BUILD"),
+			Type: build.TypeBuild,
+		}
+		b.files[dir] = f
+	}
+	if subinclude && !hasSubinclude(f) {
+		// subinclude(...)
+		f.Stmt = append([]build.Expr{&build.CallExpr{
+			X:    &build.Ident{Name: "subinclude"},
+			List: []build.Expr{&build.StringExpr{Value: b.subincludePath}},
+		}}, f.Stmt...)
+	}
+	return f, nil
+}
+
+// hasSubinclude reports whether a BUILD file starts with a subinclude.
+func hasSubinclude(f *build.File) bool {
+	if len(f.Stmt) == 0 {
+		return false
+	}
+	call, ok := f.Stmt[0].(*build.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := call.X.(*build.Ident)
+	return ok && ident.Name == "subinclude"
+}
+
+// write writes out the BUILD files.
+func (b *buildFiles) write() error {
+	for _, f := range b.files {
+		if err := os.WriteFile(f.Path, build.Format(f), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addPackage adds the npm_module rule for a single npm package.
+func (b *buildFiles) addPackage(pkg resolvedPackage) error {
+	dir := b.layout.dir(pkg.Name)
+	f, err := b.file(dir, true)
+	if err != nil {
+		return err
+	}
 
 	// npm_module(...)
 	call := &build.CallExpr{
@@ -59,11 +175,15 @@ func writeBuildFile(outDir string, pkg resolvedPackage, subincludePath string) e
 		addStringArg(call, "import_name", pkgName)
 	}
 	addStringArg(call, "version", pkg.Version)
+	if pkg.Integrity != "" {
+		addStringArg(call, "integrity", pkg.Integrity)
+	}
+	addSourceArgs(call, pkg.Source)
 
 	if len(pkg.Deps) > 0 {
 		depTargets := make([]string, len(pkg.Deps))
 		for i, dep := range pkg.Deps {
-			depTargets[i] = common.DepTarget(dep)
+			depTargets[i] = b.layout.depLabel(dep)
 		}
 		addListArg(call, "deps", depTargets)
 	}
@@ -71,28 +191,98 @@ func writeBuildFile(outDir string, pkg resolvedPackage, subincludePath string) e
 	if len(pkg.NestedDeps) > 0 {
 		addDictArg(call, "nested_deps", pkg.NestedDeps)
 	}
+	b.addPlatformDepsArg(call, pkg.PlatformDeps)
 
-	if pkg.Dev {
-		addListArg(call, "labels", []string{"npm:dev"})
+	if err := addPatchesArg(call, filepath.Join(b.outDir, dir), pkg.Patches); err != nil {
+		return err
 	}
 
-	addListArg(call, "visibility", []string{"PUBLIC"})
+	addListArg(call, "licences", licenseList(pkg.License))
+
+	addListArg(call, "labels", b.ruleLabels(pkg.Name, pkg.Dev, pkg.HasScripts))
+	addListArg(call, "visibility", b.visibility)
+
+	for _, note := range pkg.Overrides {
+		call.Comments.Before = append(call.Comments.Before, build.Comment{Token: "# " + note})
+	}
+	addBundledComment(call, pkg.Bundled)
 
 	f.Stmt = append(f.Stmt, call)
+	b.addBins(f, pkg)
+	return nil
+}
+
+// addBins adds an npm_bin rule for each of a package's bin entries, named
+// after the command, tsc_bin, or in a BUILD file shared with other
+// packages, after the package too if another has the same command.
+func (b *buildFiles) addBins(f *build.File, pkg resolvedPackage) {
+	names := make([]string, 0, len(pkg.Bins))
+	for name := range pkg.Bins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target := common.FlattenPkgName(name) + "_bin"
+		if hasRule(f, target) {
+			target = pkg.targetName() + "_" + target
+		}
+		call := &build.CallExpr{
+			X:              &build.Ident{Name: "npm_bin"},
+			ForceMultiLine: true,
+		}
+		addStringArg(call, "name", target)
+		addStringArg(call, "module", ":"+pkg.targetName())
+		addStringArg(call, "import_name", pkg.Name)
+		addStringArg(call, "bin", path.Clean(pkg.Bins[name]))
+		addListArg(call, "labels", b.ruleLabels(pkg.Name, pkg.Dev, false))
+		addListArg(call, "visibility", b.visibility)
+		f.Stmt = append(f.Stmt, call)
+	}
+}
 
-	return os.WriteFile(f.Path, build.Format(f), 0644)
+// hasRule reports whether a BUILD file has a rule with the given name.
+func hasRule(f *build.File, name string) bool {
+	for _, stmt := range f.Stmt {
+		if call, ok := stmt.(*build.CallExpr); ok {
+			for _, arg := range call.List {
+				if assign, ok := arg.(*build.AssignExpr); ok {
+					if lhs, ok := assign.LHS.(*build.Ident); ok && lhs.Name == "name" {
+						if value, ok := assign.RHS.(*build.StringExpr); ok && value.Value == name {
+							return true
+						}
+					}
+				}
+			}
+		}
+	}
+	return false
 }
 
-// appendConflictTarget appends a version-conflict npm_module target to an
-// existing BUILD file using the buildtools AST.
-func appendConflictTarget(outDir string, ct conflictTarget) error {
-	buildPath := filepath.Join(outDir, ct.Dir, "BUILD")
-	data, err := os.ReadFile(buildPath)
+// addWorkspace adds the rule for a workspace package: a filegroup
+// exporting the in-repo target, so that depending on the package depends
+// on its source, and its moduleconfig, instead.
+func (b *buildFiles) addWorkspace(pkg resolvedPackage) error {
+	f, err := b.file(b.layout.dir(pkg.Name), false)
 	if err != nil {
 		return err
 	}
+	call := &build.CallExpr{
+		X:              &build.Ident{Name: "filegroup"},
+		ForceMultiLine: true,
+	}
+	addStringArg(call, "name", pkg.targetName())
+	// @// is the repo the subrepo is defined in.
+	addListArg(call, "exported_deps", []string{"@" + pkg.Workspace})
+	addListArg(call, "labels", b.ruleLabels(pkg.Name, pkg.Dev, false))
+	addListArg(call, "visibility", b.visibility)
+	f.Stmt = append(f.Stmt, call)
+	return nil
+}
 
-	f, err := build.ParseBuild(buildPath, data)
+// addConflictTarget adds a version-conflict npm_module target, alongside
+// the package's main one.
+func (b *buildFiles) addConflictTarget(ct conflictTarget) error {
+	f, err := b.file(ct.Dir, true)
 	if err != nil {
 		return err
 	}
@@ -104,20 +294,61 @@ func appendConflictTarget(outDir string, ct conflictTarget) error {
 	addStringArg(call, "name", ct.TargetName)
 	addStringArg(call, "pkg_name", ct.PkgName)
 	addStringArg(call, "version", ct.Version)
+	if ct.Integrity != "" {
+		addStringArg(call, "integrity", ct.Integrity)
+	}
+	addSourceArgs(call, ct.Source)
 
 	if len(ct.Deps) > 0 {
 		depTargets := make([]string, len(ct.Deps))
 		for i, dep := range ct.Deps {
-			depTargets[i] = common.DepTarget(dep)
+			depTargets[i] = b.layout.depLabel(dep)
 		}
 		addListArg(call, "deps", depTargets)
 	}
+	b.addPlatformDepsArg(call, ct.PlatformDeps)
+	if err := addPatchesArg(call, filepath.Join(b.outDir, ct.Dir), ct.Patches); err != nil {
+		return err
+	}
+	addListArg(call, "licences", licenseList(ct.License))
 
-	addListArg(call, "visibility", []string{"PUBLIC"})
+	addListArg(call, "labels", b.ruleLabels(ct.PkgName, false, ct.HasScripts))
+	addListArg(call, "visibility", b.visibility)
+	addBundledComment(call, ct.Bundled)
 
 	f.Stmt = append(f.Stmt, call)
+	return nil
+}
 
-	return os.WriteFile(buildPath, build.Format(f), 0644)
+// addTypesGroup adds the types filegroup, //types, exporting every @types
+// package, so TypeScript sources can depend on them all at once.
+func (b *buildFiles) addTypesGroup(packages []resolvedPackage) error {
+	var deps []string
+	for _, pkg := range packages {
+		if isTypesPackage(pkg.Name) {
+			deps = append(deps, ":"+pkg.targetName())
+		}
+	}
+	if len(deps) == 0 {
+		return nil
+	}
+	f, err := b.file(typesDir, false)
+	if err != nil {
+		return err
+	}
+	if hasRule(f, typesDir) {
+		return fmt.Errorf("the types package's rule is in the way of the types filegroup")
+	}
+	call := &build.CallExpr{
+		X:              &build.Ident{Name: "filegroup"},
+		ForceMultiLine: true,
+	}
+	addStringArg(call, "name", typesDir)
+	addListArg(call, "exported_deps", deps)
+	addListArg(call, "labels", b.ruleLabels("@types/", false, false))
+	addListArg(call, "visibility", b.visibility)
+	f.Stmt = append(f.Stmt, call)
+	return nil
 }
 
 // addStringArg appends a named string argument to a CallExpr.
@@ -176,3 +407,82 @@ func addDictArg(call *build.CallExpr, name string, m map[string]string) {
 		},
 	})
 }
+
+// addSourceArgs appends the arguments telling npm_module where to fetch a
+// package from, if it isn't the npm registry.
+func addSourceArgs(call *build.CallExpr, source packageSource) {
+	switch {
+	case source.URL != "":
+		addStringArg(call, "url", source.URL)
+		if source.TokenEnv != "" {
+			addStringArg(call, "token_env", source.TokenEnv)
+		}
+	case source.Src != "":
+		// @// is the repo the subrepo is defined in.
+		addStringArg(call, "src", "@"+source.Src)
+	case source.GitRepo != "":
+		addStringArg(call, "git_repo", source.GitRepo)
+		addStringArg(call, "revision", source.Revision)
+	}
+}
+
+// addBundledComment notes the dependencies a package bundles, which it has
+// no deps on.
+func addBundledComment(call *build.CallExpr, bundled []string) {
+	if len(bundled) > 0 {
+		note := "# Bundles " + strings.Join(bundled, ", ") + " in its tarball."
+		call.Comments.Before = append(call.Comments.Before, build.Comment{Token: note})
+	}
+}
+
+// addPatchesArg copies a package's patches into its directory, alongside
+// its BUILD file, and appends them as its patches.
+func addPatchesArg(call *build.CallExpr, dir string, patches []packagePatch) error {
+	var files []string
+	for _, patch := range patches {
+		file, err := writePatch(dir, patch)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+	if len(files) > 0 {
+		addListArg(call, "patches", files)
+	}
+	return nil
+}
+
+// addPlatformDepsArg appends platform_deps, the dependencies only installed
+// on some platforms, as a dict of platform to dependency targets.
+func (b *buildFiles) addPlatformDepsArg(call *build.CallExpr, platformDeps map[string][]string) {
+	if len(platformDeps) == 0 {
+		return
+	}
+	platforms := make([]string, 0, len(platformDeps))
+	for platform := range platformDeps {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	entries := make([]*build.KeyValueExpr, len(platforms))
+	for i, platform := range platforms {
+		deps := platformDeps[platform]
+		targets := make([]build.Expr, len(deps))
+		for j, dep := range deps {
+			targets[j] = &build.StringExpr{Value: b.layout.depLabel(dep)}
+		}
+		entries[i] = &build.KeyValueExpr{
+			Key:   &build.StringExpr{Value: platform},
+			Value: &build.ListExpr{List: targets},
+		}
+	}
+
+	call.List = append(call.List, &build.AssignExpr{
+		LHS: &build.Ident{Name: "platform_deps"},
+		Op:  "=",
+		RHS: &build.DictExpr{
+			List:           entries,
+			ForceMultiLine: true,
+		},
+	})
+}
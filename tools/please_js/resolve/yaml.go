@@ -0,0 +1,355 @@
+package resolve
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a non-blank, non-comment line of a YAML document.
+type yamlLine struct {
+	num    int // 1-based, for errors
+	indent int
+	text   string
+}
+
+// parseYAML parses the subset of YAML pnpm writes lockfiles in: block
+// mappings and sequences indented with spaces, including mappings and
+// sequences inside sequence items, plain and quoted scalars, single-line
+// flow mappings and sequences, and comments. Mappings decode to
+// map[string]any, sequences to []any and scalars to strings.
+func parseYAML(data []byte) (map[string]any, error) {
+	lines := splitYAMLLines(data)
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+	p := &yamlParser{lines: lines}
+	doc, err := p.parseMapping(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[p.pos].num)
+	}
+	return doc, nil
+}
+
+// splitYAMLLines splits a document into lines, dropping blank lines and
+// comments.
+func splitYAMLLines(data []byte) []yamlLine {
+	var lines []yamlLine
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(stripYAMLComment(line), " \t\r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(line) - len(text), text: text})
+	}
+	return lines
+}
+
+// stripYAMLComment removes a trailing comment from a line: a # at its
+// start or after a space, outside quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '\'' && c == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			i++ // '' is an escaped quote
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			// Quotes only open a string at the start of a scalar; the ' in
+			// a plain scalar such as it's is literal.
+			if i == 0 || strings.ContainsRune(" \t:,[{", rune(line[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses the mapping or sequence starting at the current line.
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseMapping(indent int) (map[string]any, error) {
+	m := map[string]any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		key, rest, err := splitYAMLKey(line.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.num, err)
+		}
+		p.pos++
+		var value any = ""
+		switch {
+		case rest != "":
+			if value, err = parseYAMLValue(rest); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line.num, err)
+			}
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			if value, err = p.parseBlock(p.lines[p.pos].indent); err != nil {
+				return nil, err
+			}
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text):
+			// A sequence may sit at its key's indentation.
+			if value, err = p.parseSequence(indent); err != nil {
+				return nil, err
+			}
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) ([]any, error) {
+	var seq []any
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isSequenceItem(line.text) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		var value any = ""
+		var err error
+		switch {
+		case rest == "":
+			// A bare - with the item's block indented below it.
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				value, err = p.parseBlock(p.lines[p.pos].indent)
+			}
+		case isSequenceItem(rest) || isYAMLKey(rest):
+			// A compact nested sequence, - - a, or mapping, - key: value,
+			// whose other entries line up with its first on the lines
+			// after. The item's line is parsed again from where it starts.
+			p.lines[p.pos] = yamlLine{num: line.num, indent: line.indent + len(line.text) - len(rest), text: rest}
+			value, err = p.parseBlock(p.lines[p.pos].indent)
+		default:
+			p.pos++
+			if value, err = parseYAMLValue(rest); err != nil {
+				err = fmt.Errorf("line %d: %w", line.num, err)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, value)
+	}
+	return seq, nil
+}
+
+// isYAMLKey reports whether a line starts a mapping entry, key: value,
+// rather than being a scalar or flow collection.
+func isYAMLKey(text string) bool {
+	if text[0] == '{' || text[0] == '[' {
+		return false
+	}
+	_, _, err := splitYAMLKey(text)
+	return err == nil
+}
+
+// splitYAMLKey splits "key: value" into its key and the rest of the line.
+func splitYAMLKey(text string) (key, rest string, err error) {
+	if text[0] == '\'' || text[0] == '"' {
+		s := &flowScanner{s: text}
+		if key, err = s.quoted(); err != nil {
+			return "", "", err
+		}
+		rest = strings.TrimSpace(text[s.i:])
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("expected : after key %q", key)
+		}
+		return key, strings.TrimSpace(rest[1:]), nil
+	}
+	if i := strings.Index(text, ": "); i >= 0 {
+		return text[:i], strings.TrimSpace(text[i+2:]), nil
+	}
+	if strings.HasSuffix(text, ":") {
+		return text[:len(text)-1], "", nil
+	}
+	return "", "", fmt.Errorf("expected key: value, got %q", text)
+}
+
+// parseYAMLValue parses an inline value: a flow mapping or sequence, or a
+// scalar.
+func parseYAMLValue(text string) (any, error) {
+	if text == "" {
+		return "", nil
+	}
+	switch text[0] {
+	case '|', '>':
+		return nil, fmt.Errorf("block scalars aren't supported")
+	case '{', '[', '\'', '"':
+		s := &flowScanner{s: text}
+		v, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		if s.skipSpace(); s.i < len(s.s) {
+			return nil, fmt.Errorf("unexpected %q after value", s.s[s.i:])
+		}
+		return v, nil
+	}
+	return text, nil
+}
+
+// flowScanner parses flow-style values, such as {integrity: sha512-...}.
+type flowScanner struct {
+	s string
+	i int
+}
+
+func (s *flowScanner) skipSpace() {
+	for s.i < len(s.s) && s.s[s.i] == ' ' {
+		s.i++
+	}
+}
+
+func (s *flowScanner) value() (any, error) {
+	s.skipSpace()
+	if s.i >= len(s.s) {
+		return nil, fmt.Errorf("unexpected end of value")
+	}
+	switch s.s[s.i] {
+	case '{':
+		return s.mapping()
+	case '[':
+		return s.sequence()
+	case '\'', '"':
+		return s.quoted()
+	}
+	return s.plain(), nil
+}
+
+func (s *flowScanner) mapping() (map[string]any, error) {
+	m := map[string]any{}
+	s.i++ // {
+	for {
+		s.skipSpace()
+		if s.i < len(s.s) && s.s[s.i] == '}' {
+			s.i++
+			return m, nil
+		}
+		var key string
+		if s.i < len(s.s) && (s.s[s.i] == '\'' || s.s[s.i] == '"') {
+			k, err := s.quoted()
+			if err != nil {
+				return nil, err
+			}
+			key = k
+		} else {
+			start := s.i
+			for s.i < len(s.s) && s.s[s.i] != ':' && s.s[s.i] != ',' && s.s[s.i] != '}' {
+				s.i++
+			}
+			key = strings.TrimSpace(s.s[start:s.i])
+		}
+		s.skipSpace()
+		if s.i >= len(s.s) || s.s[s.i] != ':' {
+			return nil, fmt.Errorf("expected : after key %q in flow mapping", key)
+		}
+		s.i++
+		v, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+		if err := s.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *flowScanner) sequence() ([]any, error) {
+	seq := []any{}
+	s.i++ // [
+	for {
+		s.skipSpace()
+		if s.i < len(s.s) && s.s[s.i] == ']' {
+			s.i++
+			return seq, nil
+		}
+		v, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+		if err := s.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator consumes the comma after a flow entry, leaving end in place.
+func (s *flowScanner) separator(end byte) error {
+	s.skipSpace()
+	if s.i >= len(s.s) {
+		return fmt.Errorf("unterminated flow collection")
+	}
+	switch s.s[s.i] {
+	case ',':
+		s.i++
+		return nil
+	case end:
+		return nil
+	}
+	return fmt.Errorf("unexpected %q in flow collection", s.s[s.i])
+}
+
+// plain scans a plain scalar, which in flow context ends at , } or ].
+func (s *flowScanner) plain() string {
+	start := s.i
+	for s.i < len(s.s) && !strings.ContainsRune(",}]", rune(s.s[s.i])) {
+		s.i++
+	}
+	return strings.TrimSpace(s.s[start:s.i])
+}
+
+func (s *flowScanner) quoted() (string, error) {
+	quote := s.s[s.i]
+	for j := s.i + 1; j < len(s.s); j++ {
+		switch {
+		case quote == '"' && s.s[j] == '\\':
+			j++
+		case s.s[j] == quote && quote == '\'' && j+1 < len(s.s) && s.s[j+1] == '\'':
+			j++ // '' is an escaped quote
+		case s.s[j] == quote:
+			raw := s.s[s.i : j+1]
+			s.i = j + 1
+			if quote == '\'' {
+				return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'"), nil
+			}
+			return strconv.Unquote(raw)
+		}
+	}
+	return "", fmt.Errorf("unterminated string %s", s.s[s.i:])
+}
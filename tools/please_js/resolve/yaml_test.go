@@ -0,0 +1,139 @@
+package resolve
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want map[string]any
+	}{
+		{
+			name: "nested mappings and scalars",
+			doc: `lockfileVersion: '9.0'
+settings:
+  autoInstallPeers: true
+  "quoted key": "a \"b\""
+empty:
+`,
+			want: map[string]any{
+				"lockfileVersion": "9.0",
+				"settings":        map[string]any{"autoInstallPeers": "true", "quoted key": `a "b"`},
+				"empty":           "",
+			},
+		},
+		{
+			name: "flow collections",
+			doc: `resolution: {integrity: sha512-abc==, tarball: 'https://x.test/a.tgz'}
+os: [darwin, linux]
+none: []
+`,
+			want: map[string]any{
+				"resolution": map[string]any{"integrity": "sha512-abc==", "tarball": "https://x.test/a.tgz"},
+				"os":         []any{"darwin", "linux"},
+				"none":       []any{},
+			},
+		},
+		{
+			name: "sequences indented and at their key's indentation",
+			doc: `a:
+  - one
+  - 'two'
+b:
+- three
+`,
+			want: map[string]any{"a": []any{"one", "two"}, "b": []any{"three"}},
+		},
+		{
+			name: "comments",
+			doc: `# header
+key: value # trailing
+url: git+https://x.test/a.git#abc123
+quoted: 'it''s # not a comment' # but this is
+    # indented comment
+list:
+  - a # first
+`,
+			want: map[string]any{
+				"key":    "value",
+				"url":    "git+https://x.test/a.git#abc123",
+				"quoted": "it's # not a comment",
+				"list":   []any{"a"},
+			},
+		},
+		{
+			name: "bare - with an indented mapping",
+			doc: `items:
+  -
+    name: a
+    version: 1.0.0
+  -
+  - b
+`,
+			want: map[string]any{"items": []any{map[string]any{"name": "a", "version": "1.0.0"}, "", "b"}},
+		},
+		{
+			name: "mappings in sequence items",
+			doc: `items:
+  - name: a
+    deps:
+      b: ^1.0.0
+  - name: c
+    tags: [x]
+  -   name: d
+      version: 2.0.0
+`,
+			want: map[string]any{"items": []any{
+				map[string]any{"name": "a", "deps": map[string]any{"b": "^1.0.0"}},
+				map[string]any{"name": "c", "tags": []any{"x"}},
+				map[string]any{"name": "d", "version": "2.0.0"},
+			}},
+		},
+		{
+			name: "nested sequences",
+			doc: `matrix:
+  - - a
+    - b
+  - - c
+`,
+			want: map[string]any{"matrix": []any{[]any{"a", "b"}, []any{"c"}}},
+		},
+		{
+			name: "empty document",
+			doc:  "# nothing\n---\n",
+			want: map[string]any{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.doc))
+			if err != nil {
+				t.Fatalf("parseYAML() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYAML() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAML_Errors(t *testing.T) {
+	tests := map[string]string{
+		"bad indentation":     "a: 1\n  b: 2\n",
+		"not a mapping":       "just text\n",
+		"block scalar":        "a: |\n  text\n",
+		"unterminated string": "a: 'text\n",
+		"unterminated flow":   "a: {b: c\n",
+		"trailing text":       "a: [b] c\n",
+	}
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseYAML([]byte(doc)); err == nil {
+				t.Error("parseYAML() succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package resolve
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// isYarnLockfile reports whether path is named like a yarn lockfile.
+func isYarnLockfile(path string) bool {
+	return filepath.Base(path) == "yarn.lock"
+}
+
+// parseYarnLockfile reads a yarn.lock, classic (v1) or berry (v2+), into
+// the package-lock.json layout the rest of resolve works on. Neither
+// records a node_modules layout, so packages are hoisted as npm would.
+//
+// Neither records which packages are devDependencies either, so none are
+// marked dev. Berry lockfiles list each workspace's dependencies, which
+// become the top-level packages. Classic lockfiles don't list workspaces,
+// so the packages no other package depends on are taken as the direct
+// dependencies.
+func parseYarnLockfile(path string) (*packageLock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+	var entries map[string]any
+	berry := bytes.Contains(data, []byte("\n__metadata:")) || bytes.HasPrefix(data, []byte("__metadata:"))
+	if berry {
+		entries, err = parseYAML(data)
+	} else {
+		entries, err = parseYarnV1(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	// Each entry is headed by the descriptors (name@range) that resolve to
+	// it; dependencies name a descriptor, which finds their entry.
+	headers := make([]string, 0, len(entries))
+	for header := range entries {
+		if header != "__metadata" {
+			headers = append(headers, header)
+		}
+	}
+	sort.Strings(headers)
+	packages := map[string]*lockedPackage{}
+	descriptors := map[string]packageRef{}
+	var workspaces []map[string]any
+	for _, header := range headers {
+		fields := yamlMap(entries[header])
+		ref, ok := yarnEntryRef(header, fields, berry)
+		if !ok {
+			// The root workspace goes first, so its dependencies win.
+			switch resolution := yamlString(fields["resolution"]); {
+			case strings.HasSuffix(resolution, "@workspace:."):
+				workspaces = append([]map[string]any{fields}, workspaces...)
+			case strings.Contains(resolution, "@workspace:"):
+				workspaces = append(workspaces, fields)
+			}
+			continue
+		}
+		for _, d := range splitYarnDescriptors(header) {
+			descriptors[d] = ref
+		}
+		if packages[ref.key()] != nil {
+			continue
+		}
+		pkg := &lockedPackage{name: ref.name, version: ref.version, deps: map[string]packageRef{}}
+		pkg.info.Resolved = registryTarball(ref.name, ref.version)
+		if resolved := yarnResolved(fields, berry); resolved != "" {
+			pkg.info.Version = yamlString(fields["version"])
+			pkg.info.Resolved = resolved
+		}
+		pkg.info.Integrity = yamlString(fields["integrity"])
+		if peers := yamlMap(fields["peerDependencies"]); peers != nil {
+			pkg.info.PeerDependencies = yamlStrings(peers)
+		}
+		for dep, meta := range yamlMap(fields["peerDependenciesMeta"]) {
+			if pkg.info.PeerDependenciesMeta == nil {
+				pkg.info.PeerDependenciesMeta = map[string]peerDepMeta{}
+			}
+			pkg.info.PeerDependenciesMeta[dep] = peerDepMeta{Optional: yamlString(yamlMap(meta)["optional"]) == "true"}
+		}
+		pkg.info.OS, pkg.info.CPU = parseYarnConditions(yamlString(fields["conditions"]))
+		packages[ref.key()] = pkg
+	}
+
+	// Dependencies are resolved once every descriptor is known.
+	lookup := func(name, rng string) (packageRef, bool) {
+		if berry && !yarnProtocolRe.MatchString(rng) {
+			rng = "npm:" + rng
+		}
+		ref, ok := descriptors[name+"@"+rng]
+		return ref, ok
+	}
+	referenced := map[string]bool{}
+	for _, header := range headers {
+		fields := yamlMap(entries[header])
+		ref, ok := yarnEntryRef(header, fields, berry)
+		if !ok {
+			continue
+		}
+		pkg := packages[ref.key()]
+		for _, section := range []string{"dependencies", "optionalDependencies"} {
+			for dep, rng := range yamlMap(fields[section]) {
+				if depRef, ok := lookup(dep, yamlString(rng)); ok {
+					pkg.deps[dep] = depRef
+					referenced[dep+"@"+depRef.key()] = true
+				}
+			}
+		}
+	}
+
+	var roots []rootDep
+	if berry {
+		for _, ws := range workspaces {
+			for _, section := range []string{"dependencies", "optionalDependencies"} {
+				deps := yamlMap(ws[section])
+				names := make([]string, 0, len(deps))
+				for name := range deps {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					if ref, ok := lookup(name, yamlString(deps[name])); ok {
+						roots = append(roots, rootDep{name, ref})
+					}
+				}
+			}
+		}
+	}
+	if len(roots) == 0 {
+		seen := map[string]bool{}
+		for _, header := range headers {
+			for _, d := range splitYarnDescriptors(header) {
+				ref, ok := descriptors[d]
+				name, _ := splitYarnDescriptor(d)
+				if !ok || referenced[name+"@"+ref.key()] || seen[name+"@"+ref.key()] {
+					continue
+				}
+				seen[name+"@"+ref.key()] = true
+				roots = append(roots, rootDep{name, ref})
+			}
+		}
+	}
+	return hoist(packages, roots, nil), nil
+}
+
+// yarnProtocolRe matches a range that names its protocol, such as "npm:"
+// or "workspace:". Berry ranges without one are npm ranges.
+var yarnProtocolRe = regexp.MustCompile(`^[a-z]+:`)
+
+// yarnEntryRef returns the package a lockfile entry resolved to: a registry
+// package, or a git or tarball one, versioned by where it's from. Entries
+// for workspaces, patches and local packages have none.
+func yarnEntryRef(header string, fields map[string]any, berry bool) (packageRef, bool) {
+	version := yamlString(fields["version"])
+	resolved := yarnResolved(fields, berry)
+	if berry {
+		// resolution: "ms@npm:2.1.3", which also holds the real name of
+		// an aliased package.
+		resolution := yamlString(fields["resolution"])
+		if name, version, ok := strings.Cut(resolution, "@npm:"); ok {
+			return packageRef{name: name, version: version}, true
+		}
+		if resolved == "" {
+			return packageRef{}, false
+		}
+		name, _ := splitYarnDescriptor(resolution)
+		return packageRef{name: name, version: resolved}, true
+	}
+	tarball, _, _ := strings.Cut(yamlString(fields["resolved"]), "#")
+	if version == "" || (!isRegistryTarball(tarball) && resolved == "") {
+		return packageRef{}, false
+	}
+	if resolved != "" {
+		version = resolved
+	}
+	descriptors := splitYarnDescriptors(header)
+	if len(descriptors) == 0 {
+		return packageRef{}, false
+	}
+	name, rng := splitYarnDescriptor(descriptors[0])
+	// An alias ("my-ms@npm:ms@^2.1.3") names the real package in its range.
+	if real, ok := strings.CutPrefix(rng, "npm:"); ok && strings.LastIndex(real, "@") > 0 {
+		name = real[:strings.LastIndex(real, "@")]
+	}
+	return packageRef{name: name, version: version}, true
+}
+
+// yarnResolved returns where a git or tarball dependency's entry says it
+// was fetched from, as package-lock.json records it, or "" for registry
+// packages and any other entry.
+func yarnResolved(fields map[string]any, berry bool) string {
+	if !berry {
+		resolved := yamlString(fields["resolved"])
+		tarball, _, _ := strings.Cut(resolved, "#")
+		if resolved == "" || isRegistryTarball(tarball) || strings.HasPrefix(resolved, "file:") {
+			return ""
+		}
+		return resolved
+	}
+	// resolution: "foo@https://github.com/acme/foo.git#commit=<sha>", or
+	// "foo@https://example.com/foo-1.0.0.tgz".
+	resolution := yamlString(fields["resolution"])
+	if resolution == "" {
+		return ""
+	}
+	_, rng := splitYarnDescriptor(resolution)
+	if repo, commit, ok := strings.Cut(rng, "#commit="); ok {
+		return "git+" + strings.TrimPrefix(repo, "git+") + "#" + commit
+	}
+	if strings.HasPrefix(rng, "https://") || strings.HasPrefix(rng, "http://") {
+		return rng
+	}
+	return ""
+}
+
+// splitYarnDescriptors splits an entry's header into its descriptors:
+// `"@babel/core@^7.0.0", "@babel/core@^7.1.0"` or, in berry,
+// "@babel/core@npm:^7.0.0, @babel/core@npm:^7.1.0".
+func splitYarnDescriptors(header string) []string {
+	var result []string
+	for _, d := range strings.Split(header, ",") {
+		d = strings.TrimSpace(d)
+		if unquoted, err := strconv.Unquote(d); err == nil {
+			d = unquoted
+		}
+		if d != "" {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// splitYarnDescriptor splits "name@range" into the name and range. Scoped
+// names start with @, so the separator is the first @ after that.
+func splitYarnDescriptor(d string) (name, rng string) {
+	i := strings.Index(d[1:], "@")
+	if i < 0 {
+		return d, ""
+	}
+	return d[:i+1], d[i+2:]
+}
+
+// parseYarnV1 parses a classic yarn.lock into entries keyed by their
+// headers, in the shape parseYAML gives a berry lockfile. The format is
+// YAML-like: "key value" lines, and "key:" lines heading an indented block.
+func parseYarnV1(data []byte) (map[string]any, error) {
+	root := map[string]any{}
+	type block struct {
+		indent int
+		m      map[string]any
+	}
+	stack := []block{{-1, root}}
+	for _, line := range splitYAMLLines(data) {
+		for line.indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1].m
+		if header, ok := strings.CutSuffix(line.text, ":"); ok {
+			// Entry headers keep their quotes, as they list several
+			// descriptors; nested keys such as dependencies have none.
+			m := map[string]any{}
+			parent[header] = m
+			stack = append(stack, block{line.indent, m})
+			continue
+		}
+		s := &flowScanner{s: line.text}
+		var key string
+		if line.text[0] == '"' {
+			k, err := s.quoted()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line.num, err)
+			}
+			key = k
+		} else {
+			key, _, _ = strings.Cut(line.text, " ")
+			s.i = len(key)
+		}
+		value := strings.TrimSpace(line.text[s.i:])
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		parent[key] = value
+	}
+	return root, nil
+}
+
+// parseYarnConditions parses the platforms berry records a package installs
+// on, such as "os=darwin & cpu=arm64", into os and cpu fields. Conditions
+// other than a conjunction of these are ignored, leaving the package
+// unrestricted.
+func parseYarnConditions(conditions string) (osList, cpuList []string) {
+	if conditions == "" || strings.ContainsAny(conditions, "|()") {
+		return nil, nil
+	}
+	for _, cond := range strings.Split(conditions, "&") {
+		key, value, _ := strings.Cut(strings.TrimSpace(cond), "=")
+		switch key {
+		case "os":
+			osList = append(osList, value)
+		case "cpu":
+			cpuList = append(cpuList, value)
+		}
+	}
+	return osList, cpuList
+}
@@ -0,0 +1,69 @@
+package transpile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// tscOutputsGenerated is tsc's exit code when it reported errors but still
+// wrote its outputs.
+const tscOutputsGenerated = 2
+
+// emitDeclarations runs tsc over the TypeScript sources to write their .d.ts
+// declarations into args.OutDir, laid out as the sources are under --root
+// or, without one, their common directory. Declarations only need each file's own annotations, so
+// type errors (typically types of dependencies tsc can't find in the build
+// sandbox) are reported as warnings as long as tsc still wrote them.
+func emitDeclarations(args Args, srcs []string) error {
+	if len(srcs) == 0 {
+		return nil
+	}
+	rootDir := args.Root
+	if rootDir == "" {
+		rootDir = commonDir(srcs)
+	}
+	cmdArgs := []string{
+		"--declaration",
+		"--emitDeclarationOnly",
+		"--outDir", args.OutDir,
+		"--rootDir", rootDir,
+		"--target", "esnext",
+		"--module", "esnext",
+		"--moduleResolution", "bundler",
+		"--jsx", "react-jsx",
+		"--allowImportingTsExtensions",
+		"--skipLibCheck",
+		"--pretty", "false",
+	}
+	cmdArgs = append(cmdArgs, srcs...)
+
+	bin, binArgs := args.TscBin, cmdArgs
+	if args.Node != "" {
+		bin, binArgs = args.Node, append([]string{args.TscBin}, cmdArgs...)
+	}
+	output, err := exec.Command(bin, binArgs...).CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == tscOutputsGenerated {
+		fmt.Fprintf(os.Stderr, "warning: tsc reported errors while emitting declarations:\n%s\n", strings.TrimSpace(string(output)))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("tsc failed to emit declarations: %w\n%s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// commonDir returns the deepest directory containing all of paths.
+func commonDir(paths []string) string {
+	dir := filepath.Dir(paths[0])
+	for _, p := range paths[1:] {
+		for dir != "." && dir != string(filepath.Separator) && !strings.HasPrefix(filepath.Dir(p)+string(filepath.Separator), dir+string(filepath.Separator)) {
+			dir = filepath.Dir(dir)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,212 @@
+package transpile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"tools/please_js/common"
+)
+
+// Module formats for --formats.
+const (
+	formatESM = "esm"
+	formatCJS = "cjs"
+)
+
+// target is a module format the sources are transpiled to.
+type target struct {
+	name   string
+	dir    string
+	format api.Format
+	// ext is the extension of transpiled outputs.
+	ext string
+	// convertJS is set if JS sources need converting to the format rather
+	// than copying as they are.
+	convertJS bool
+	// esTarget and engines are the syntax outputs are down-leveled to. JS
+	// sources are down-leveled too, in place, unless they're converted.
+	esTarget api.Target
+	engines  []api.Engine
+	// loaders overrides the loader of sources by extension.
+	loaders map[string]api.Loader
+	// root is the absolute directory whose layout outputs mirror, or "" to
+	// write them all directly into dir.
+	root string
+	// imports rewrites the imports in the outputs, or is nil if none need it.
+	imports *importRewriter
+}
+
+// newTargets returns the targets for --formats. A single format is written
+// to outDir as .js files, as before. With several, each is written to its
+// own directory, named for the format, with extensions that tell node which
+// one it is (.mjs, .cjs) whatever the nearest package.json says.
+func newTargets(args Args, aliases []pathAlias) ([]*target, error) {
+	esTarget, engines, err := common.ParseTarget(args.Target)
+	if err != nil {
+		return nil, err
+	}
+	loaders, err := parseLoaders(args.Loaders)
+	if err != nil {
+		return nil, err
+	}
+	var root string
+	if args.Root != "" {
+		if root, err = filepath.Abs(args.Root); err != nil {
+			return nil, err
+		}
+	}
+	formats := args.Formats
+	if len(formats) == 0 {
+		formats = []string{formatESM}
+	}
+	var targets []*target
+	seen := map[string]bool{}
+	for _, name := range formats {
+		if seen[name] {
+			return nil, fmt.Errorf("--formats lists %s twice", name)
+		}
+		seen[name] = true
+		t := &target{name: name, dir: args.OutDir, ext: ".js", esTarget: esTarget, engines: engines, loaders: loaders, root: root}
+		switch name {
+		case formatESM:
+			t.format = api.FormatESModule
+		case formatCJS:
+			t.format = api.FormatCommonJS
+			t.convertJS = true
+		default:
+			return nil, fmt.Errorf("invalid format %q: must be esm or cjs", name)
+		}
+		if len(formats) > 1 {
+			t.dir = filepath.Join(args.OutDir, name)
+			t.ext = map[string]string{formatESM: ".mjs", formatCJS: ".cjs"}[name]
+			t.convertJS = true
+		}
+		targets = append(targets, t)
+	}
+	if err := checkOutputs(args.Srcs, targets[0].outputName, root); err != nil {
+		return nil, err
+	}
+	for _, t := range targets {
+		// Imports name the file as it is on disk, so renamed outputs need
+		// their relative imports rewritten too.
+		relative := t.ext != ".js"
+		if aliases != nil || relative {
+			if t.imports, err = newImportRewriter(args.Srcs, t.outputName, aliases, relative); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return targets, nil
+}
+
+// loaderFor returns the esbuild loader for a source file in t.
+func (t *target) loaderFor(src string) api.Loader {
+	if loader, ok := t.loaders[filepath.Ext(src)]; ok {
+		return loader
+	}
+	return loaderFor(src)
+}
+
+// downlevels reports whether t's outputs are down-leveled from esnext.
+func (t *target) downlevels() bool {
+	return t.esTarget != api.ESNext || len(t.engines) > 0
+}
+
+// transpiles reports whether src is transpiled for t rather than copied.
+func (t *target) transpiles(src string) bool {
+	switch t.loaderFor(src) {
+	case api.LoaderTSX, api.LoaderTS, api.LoaderJSX:
+		return true
+	case api.LoaderJS:
+		return t.convertJS || t.downlevels()
+	}
+	return false
+}
+
+// outputName returns the path of a source file's output in t, relative to
+// its directory.
+func (t *target) outputName(src string) string {
+	name := filepath.Base(src)
+	if t.transpiles(src) && (t.convertJS || t.loaderFor(src) != api.LoaderJS) {
+		name = strings.TrimSuffix(name, filepath.Ext(src)) + t.ext
+	}
+	if t.root == "" {
+		return name
+	}
+	abs, _ := filepath.Abs(src)
+	rel, _ := filepath.Rel(t.root, abs)
+	return filepath.Join(filepath.Dir(rel), name)
+}
+
+// checkOutputs reports sources outside root, and sources whose outputs
+// would overwrite each other, as they do when sources from several
+// directories share a name and there's no root to lay them out under.
+func checkOutputs(srcs []string, outputName func(string) string, root string) error {
+	written := make(map[string]string, len(srcs))
+	for _, src := range srcs {
+		if root != "" {
+			abs, err := filepath.Abs(src)
+			if err != nil {
+				return err
+			}
+			if rel, err := filepath.Rel(root, abs); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("%s isn't under --root %s", src, root)
+			}
+		}
+		name := outputName(src)
+		if other, ok := written[name]; ok {
+			if root == "" {
+				return fmt.Errorf("%s and %s both write %s; set --root to mirror their directories", other, src, name)
+			}
+			return fmt.Errorf("%s and %s both write %s", other, src, name)
+		}
+		written[name] = src
+	}
+	return nil
+}
+
+// exportConditions are the conditions of a package.json exports entry, in
+// the order resolvers try them: TypeScript takes the first it understands,
+// so types must come before import and require.
+type exportConditions struct {
+	Types   string `json:"types,omitempty"`
+	Import  string `json:"import,omitempty"`
+	Require string `json:"require,omitempty"`
+}
+
+// writePackageJSON writes a package.json whose exports point import and
+// require at the matching format's directory, so a library with several
+// formats resolves to the right one from node and from the bundler alike.
+// With dts, a types condition points TypeScript at the declarations, which
+// emitDeclarations writes to the root of outDir for every format. entryPoint
+// names the library's entry within the sources, e.g. index.ts.
+func writePackageJSON(outDir, entryPoint string, targets []*target, dts bool) error {
+	entry := strings.TrimSuffix(entryPoint, filepath.Ext(entryPoint))
+	var root, subpaths exportConditions
+	if dts {
+		root.Types = "./" + entry + ".d.ts"
+		subpaths.Types = "./*.d.ts"
+	}
+	for _, t := range targets {
+		switch t.name {
+		case formatESM:
+			root.Import = "./" + t.name + "/" + entry + t.ext
+			subpaths.Import = "./" + t.name + "/*" + t.ext
+		case formatCJS:
+			root.Require = "./" + t.name + "/" + entry + t.ext
+			subpaths.Require = "./" + t.name + "/*" + t.ext
+		}
+	}
+	data, err := json.MarshalIndent(map[string]any{
+		"exports": map[string]exportConditions{".": root, "./*": subpaths},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outDir, "package.json"), append(data, '\n'), 0644)
+}
@@ -0,0 +1,125 @@
+package transpile
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dualFormatSrcs writes a small library under dir/src and returns its
+// sources.
+func dualFormatSrcs(t *testing.T, dir string) []string {
+	t.Helper()
+	writeFiles(t, dir, map[string]string{
+		"src/index.ts":    `import { add } from "./lib/math";` + "\nexport const three: number = add(1, 2);\n",
+		"src/lib/math.ts": "export function add(a: number, b: number): number { return a + b; }\n",
+		"src/plain.js":    "export const plain = 1;\n",
+	})
+	var srcs []string
+	for _, src := range []string{"src/index.ts", "src/lib/math.ts", "src/plain.js"} {
+		srcs = append(srcs, filepath.Join(dir, filepath.FromSlash(src)))
+	}
+	return srcs
+}
+
+// readExports returns the exports map of the package.json in dir.
+func readExports(t *testing.T, dir string) map[string]map[string]string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkg struct {
+		Exports map[string]map[string]string `json:"exports"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		t.Fatal(err)
+	}
+	return pkg.Exports
+}
+
+func TestRun_DualFormat(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	err := Run(Args{
+		OutDir:     out,
+		Srcs:       dualFormatSrcs(t, dir),
+		Formats:    []string{formatESM, formatCJS},
+		EntryPoint: "index.ts",
+		Root:       filepath.Join(dir, "src"),
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	// Each format mirrors the sources under its own directory, with
+	// relative imports renamed to match.
+	for path, want := range map[string]string{
+		"esm/index.mjs":    `from "./lib/math.mjs"`,
+		"esm/lib/math.mjs": "export {",
+		"esm/plain.mjs":    "export {",
+		"cjs/index.cjs":    `require("./lib/math.cjs")`,
+		"cjs/lib/math.cjs": "module.exports",
+		"cjs/plain.cjs":    "module.exports",
+	} {
+		data, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(path)))
+		if err != nil {
+			t.Errorf("missing output %s: %v", path, err)
+			continue
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s doesn't contain %q:\n%s", path, want, data)
+		}
+	}
+
+	want := map[string]map[string]string{
+		".":   {"import": "./esm/index.mjs", "require": "./cjs/index.cjs"},
+		"./*": {"import": "./esm/*.mjs", "require": "./cjs/*.cjs"},
+	}
+	if got := readExports(t, out); !reflect.DeepEqual(got, want) {
+		t.Errorf("exports = %v, want %v", got, want)
+	}
+}
+
+func TestRun_DualFormatTypes(t *testing.T) {
+	// Declarations come from tsc, which isn't available here; one that
+	// writes nothing is enough to check what package.json says of them.
+	tsc, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("no true binary to stand in for tsc")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	err = Run(Args{
+		OutDir:     out,
+		Srcs:       dualFormatSrcs(t, dir),
+		Formats:    []string{formatESM, formatCJS},
+		EntryPoint: "index.ts",
+		Root:       filepath.Join(dir, "src"),
+		Dts:        true,
+		TscBin:     tsc,
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	want := map[string]map[string]string{
+		".":   {"types": "./index.d.ts", "import": "./esm/index.mjs", "require": "./cjs/index.cjs"},
+		"./*": {"types": "./*.d.ts", "import": "./esm/*.mjs", "require": "./cjs/*.cjs"},
+	}
+	if got := readExports(t, out); !reflect.DeepEqual(got, want) {
+		t.Errorf("exports = %v, want %v", got, want)
+	}
+	// TypeScript uses the first condition it understands.
+	data, err := os.ReadFile(filepath.Join(out, "package.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types, imp := strings.Index(string(data), `"types"`), strings.Index(string(data), `"import"`); types > imp {
+		t.Errorf("types condition comes after import:\n%s", data)
+	}
+}
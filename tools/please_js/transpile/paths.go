@@ -0,0 +1,296 @@
+package transpile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"tools/please_js/common"
+)
+
+// importSpecRe matches the specifier of static imports, re-exports, dynamic
+// imports and requires. Group 1 is everything before the specifier's
+// opening quote, group 2 the quote and group 3 the specifier.
+var importSpecRe = regexp.MustCompile(`(\bfrom\s*|\bimport\s*\(\s*|\bimport\s*|\brequire\s*\(\s*)(["'])([^"'\n]+)["']`)
+
+// importExts are the extensions tried for an import without one, as
+// TypeScript does.
+var importExts = []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".json"}
+
+// pathAlias is one compilerOptions.paths entry. A wildcard entry like
+// "@/*": ["./src/*"] matches any specifier with its prefix and suffix and
+// substitutes the part in between into its targets.
+type pathAlias struct {
+	prefix   string
+	suffix   string
+	wildcard bool
+	targets  []string // absolute, with at most one "*"
+}
+
+// importRewriter rewrites imports of a tsconfig's path aliases, and
+// optionally relative imports, to the transpiled file they resolve to,
+// relative to the importing file's output.
+type importRewriter struct {
+	aliases  []pathAlias       // exact entries first, then the longest prefix first
+	outputs  map[string]string // absolute source path → output path in the output directory
+	relative bool              // also rewrite relative imports, for outputs not named .js
+}
+
+// newImportRewriter returns a rewriter for imports of srcs, whose outputs
+// are named by outputName.
+func newImportRewriter(srcs []string, outputName func(string) string, aliases []pathAlias, relative bool) (*importRewriter, error) {
+	r := &importRewriter{aliases: aliases, outputs: make(map[string]string, len(srcs)), relative: relative}
+	for _, src := range srcs {
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			return nil, err
+		}
+		r.outputs[abs] = outputName(src)
+	}
+	return r, nil
+}
+
+// loadPathAliases reads the paths of tsconfigPath, following extends,
+// resolved against the baseUrl in effect or, without one, the directory of
+// the tsconfig declaring them.
+func loadPathAliases(tsconfigPath string) ([]pathAlias, error) {
+	opts, err := readPathOptions(tsconfigPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	baseDir := opts.baseURL
+	if baseDir == "" {
+		baseDir = opts.pathsDir
+	}
+
+	var aliases []pathAlias
+	for pattern, targets := range opts.paths {
+		a := pathAlias{prefix: pattern}
+		if prefix, suffix, ok := strings.Cut(pattern, "*"); ok {
+			a = pathAlias{prefix: prefix, suffix: suffix, wildcard: true}
+		}
+		for _, target := range targets {
+			a.targets = append(a.targets, filepath.Join(baseDir, filepath.FromSlash(target)))
+		}
+		aliases = append(aliases, a)
+	}
+	// TypeScript prefers an exact match, then the longest prefix.
+	sort.Slice(aliases, func(i, j int) bool {
+		ai, aj := aliases[i], aliases[j]
+		if ai.wildcard != aj.wildcard {
+			return !ai.wildcard
+		}
+		if len(ai.prefix) != len(aj.prefix) {
+			return len(ai.prefix) > len(aj.prefix)
+		}
+		return ai.prefix < aj.prefix
+	})
+	return aliases, nil
+}
+
+// pathOptions are the compilerOptions path aliases come from, after extends.
+type pathOptions struct {
+	baseURL  string // absolute, or "" if unset
+	paths    map[string][]string
+	pathsDir string // directory of the tsconfig declaring paths
+}
+
+// readPathOptions reads baseUrl and paths from tsconfigPath and the configs
+// it extends, in order, each overriding what came before. chain holds the
+// configs being read, to reject extends cycles.
+func readPathOptions(tsconfigPath string, chain []string) (pathOptions, error) {
+	abs, err := filepath.Abs(tsconfigPath)
+	if err != nil {
+		return pathOptions{}, err
+	}
+	for _, prev := range chain {
+		if prev == abs {
+			return pathOptions{}, fmt.Errorf("tsconfig extends cycle: %s", strings.Join(append(chain, abs), " -> "))
+		}
+	}
+	chain = append(chain, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return pathOptions{}, fmt.Errorf("failed to read tsconfig: %w", err)
+	}
+	var tsconfig struct {
+		Extends         json.RawMessage `json:"extends"`
+		CompilerOptions struct {
+			BaseUrl *string             `json:"baseUrl"`
+			Paths   map[string][]string `json:"paths"`
+		} `json:"compilerOptions"`
+	}
+	if err := json.Unmarshal(common.StripJSONC(data), &tsconfig); err != nil {
+		return pathOptions{}, fmt.Errorf("failed to parse %s: %w", tsconfigPath, err)
+	}
+	dir := filepath.Dir(abs)
+
+	// extends is a string or, since TypeScript 5.0, an array.
+	var bases []string
+	if len(tsconfig.Extends) > 0 {
+		var base string
+		if err := json.Unmarshal(tsconfig.Extends, &base); err == nil {
+			bases = []string{base}
+		} else if err := json.Unmarshal(tsconfig.Extends, &bases); err != nil {
+			return pathOptions{}, fmt.Errorf("%s: extends must be a string or an array of strings", tsconfigPath)
+		}
+	}
+
+	var opts pathOptions
+	for _, base := range bases {
+		basePath, err := resolveExtends(dir, base)
+		if err != nil {
+			return pathOptions{}, fmt.Errorf("%s: %w", tsconfigPath, err)
+		}
+		inherited, err := readPathOptions(basePath, chain)
+		if err != nil {
+			return pathOptions{}, err
+		}
+		if inherited.baseURL != "" {
+			opts.baseURL = inherited.baseURL
+		}
+		if inherited.paths != nil {
+			opts.paths, opts.pathsDir = inherited.paths, inherited.pathsDir
+		}
+	}
+	if tsconfig.CompilerOptions.BaseUrl != nil {
+		opts.baseURL = filepath.Join(dir, filepath.FromSlash(*tsconfig.CompilerOptions.BaseUrl))
+	}
+	if tsconfig.CompilerOptions.Paths != nil {
+		opts.paths, opts.pathsDir = tsconfig.CompilerOptions.Paths, dir
+	}
+	return opts, nil
+}
+
+// resolveExtends finds the tsconfig an extends entry in dir refers to: a
+// path, with or without .json, or a package's config in node_modules.
+func resolveExtends(dir, spec string) (string, error) {
+	var candidates []string
+	if strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") || filepath.IsAbs(spec) {
+		path := filepath.FromSlash(spec)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		candidates = []string{path, path + ".json"}
+	} else {
+		for d := dir; ; d = filepath.Dir(d) {
+			path := filepath.Join(d, "node_modules", filepath.FromSlash(spec))
+			candidates = append(candidates, path, path+".json", filepath.Join(path, "tsconfig.json"))
+			if filepath.Dir(d) == d {
+				break
+			}
+		}
+	}
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("can't find tsconfig %q to extend", spec)
+}
+
+// resolve returns the output path of the source file spec, imported from
+// dir, resolves to. ok is false if spec isn't to be rewritten: a relative
+// import of a file that isn't transpiled, or a specifier no alias matches
+// or none of whose matching alias's targets exist, in which case
+// TypeScript falls back to resolving it as a package. An alias target that
+// exists but isn't one of the transpiled files is an error, as the import
+// would break.
+func (r *importRewriter) resolve(dir, spec string) (name string, ok bool, err error) {
+	if strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") {
+		if !r.relative {
+			return "", false, nil
+		}
+		name, ok := r.outputs[resolveImportPath(filepath.Join(dir, filepath.FromSlash(spec)))]
+		return name, ok, nil
+	}
+	for _, a := range r.aliases {
+		var match string
+		if a.wildcard {
+			if len(spec) < len(a.prefix)+len(a.suffix) || !strings.HasPrefix(spec, a.prefix) || !strings.HasSuffix(spec, a.suffix) {
+				continue
+			}
+			match = spec[len(a.prefix) : len(spec)-len(a.suffix)]
+		} else if spec != a.prefix {
+			continue
+		}
+		for _, target := range a.targets {
+			path := resolveImportPath(strings.Replace(target, "*", filepath.FromSlash(match), 1))
+			if path == "" {
+				continue
+			}
+			if name, ok := r.outputs[path]; ok {
+				return name, true, nil
+			}
+			return "", false, fmt.Errorf("%q resolves to %s, which isn't in srcs", spec, path)
+		}
+		return "", false, nil
+	}
+	return "", false, nil
+}
+
+// rewrite replaces the imports in the transpiled code of src.
+func (r *importRewriter) rewrite(src string, code []byte) ([]byte, error) {
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return nil, err
+	}
+	var errs []string
+	out := importSpecRe.ReplaceAllFunc(code, func(match []byte) []byte {
+		m := importSpecRe.FindSubmatch(match)
+		name, ok, err := r.resolve(filepath.Dir(abs), string(m[3]))
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		if !ok {
+			return match
+		}
+		spec, err := filepath.Rel(filepath.Dir(r.outputs[abs]), name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			return match
+		}
+		if spec = filepath.ToSlash(spec); !strings.HasPrefix(spec, "../") {
+			spec = "./" + spec
+		}
+		return []byte(string(m[1]) + string(m[2]) + spec + string(m[2]))
+	})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s: %s", src, strings.Join(errs, "; "))
+	}
+	return out, nil
+}
+
+// resolveImportPath finds the file an import path refers to: the path
+// itself, the TypeScript source of a .js path, the path with an extension
+// added, or an index file in it. It returns "" if there is none.
+func resolveImportPath(path string) string {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return path
+	}
+	if ext := filepath.Ext(path); ext == ".js" || ext == ".jsx" {
+		base := strings.TrimSuffix(path, ext)
+		for _, tsExt := range []string{".ts", ".tsx"} {
+			if info, err := os.Stat(base + tsExt); err == nil && !info.IsDir() {
+				return base + tsExt
+			}
+		}
+	}
+	for _, ext := range importExts {
+		if info, err := os.Stat(path + ext); err == nil && !info.IsDir() {
+			return path + ext
+		}
+	}
+	for _, ext := range importExts {
+		index := filepath.Join(path, "index"+ext)
+		if info, err := os.Stat(index); err == nil && !info.IsDir() {
+			return index
+		}
+	}
+	return ""
+}
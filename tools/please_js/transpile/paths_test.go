@@ -0,0 +1,251 @@
+package transpile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files under dir from a map of slash-separated relative
+// paths to contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// aliasTargets returns the aliases as "pattern=target,target" strings with
+// targets relative to dir.
+func aliasTargets(t *testing.T, dir string, aliases []pathAlias) []string {
+	t.Helper()
+	var got []string
+	for _, a := range aliases {
+		pattern := a.prefix
+		if a.wildcard {
+			pattern += "*" + a.suffix
+		}
+		var targets []string
+		for _, target := range a.targets {
+			rel, err := filepath.Rel(dir, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			targets = append(targets, filepath.ToSlash(rel))
+		}
+		got = append(got, pattern+"="+strings.Join(targets, ","))
+	}
+	return got
+}
+
+func TestLoadPathAliases(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "relative to the tsconfig without baseUrl",
+			files: map[string]string{"tsconfig.json": `{
+  // JSONC is allowed
+  "compilerOptions": {"paths": {"@/*": ["./src/*"], "~config": ["./config.ts"],}},
+}`},
+			want: []string{"~config=config.ts", "@/*=src/*"},
+		},
+		{
+			name:  "baseUrl",
+			files: map[string]string{"tsconfig.json": `{"compilerOptions": {"baseUrl": "src", "paths": {"@lib/*": ["lib/*", "vendor/*"]}}}`},
+			want:  []string{"@lib/*=src/lib/*,src/vendor/*"},
+		},
+		{
+			name:  "longest prefix first",
+			files: map[string]string{"tsconfig.json": `{"compilerOptions": {"paths": {"@/*": ["./src/*"], "@/ui/*": ["./ui/*"], "*.css": ["./styles/*.css"]}}}`},
+			want:  []string{"@/ui/*=ui/*", "@/*=src/*", "*.css=styles/*.css"},
+		},
+		{
+			name: "paths inherited through extends resolve against the base",
+			files: map[string]string{
+				"tsconfig.base.json": `{"compilerOptions": {"paths": {"@/*": ["./src/*"]}}}`,
+				"app/tsconfig.json":  `{"extends": "../tsconfig.base", "compilerOptions": {"strict": true}}`,
+			},
+			want: []string{"@/*=src/*"},
+		},
+		{
+			name: "extending config's baseUrl applies to inherited paths",
+			files: map[string]string{
+				"tsconfig.base.json": `{"compilerOptions": {"paths": {"@/*": ["src/*"]}}}`,
+				"app/tsconfig.json":  `{"extends": "../tsconfig.base.json", "compilerOptions": {"baseUrl": "."}}`,
+			},
+			want: []string{"@/*=app/src/*"},
+		},
+		{
+			name: "own paths replace inherited ones",
+			files: map[string]string{
+				"tsconfig.base.json": `{"compilerOptions": {"baseUrl": "lib", "paths": {"@base/*": ["*"]}}}`,
+				"app/tsconfig.json":  `{"extends": "../tsconfig.base.json", "compilerOptions": {"paths": {"@/*": ["./*"]}}}`,
+			},
+			want: []string{"@/*=lib/*"},
+		},
+		{
+			name: "extends array, later entries win",
+			files: map[string]string{
+				"a.json":        `{"compilerOptions": {"paths": {"@a/*": ["./a/*"]}}}`,
+				"b.json":        `{"compilerOptions": {"paths": {"@b/*": ["./b/*"]}}}`,
+				"tsconfig.json": `{"extends": ["./a.json", "./b.json"]}`,
+			},
+			want: []string{"@b/*=b/*"},
+		},
+		{
+			name: "extends a package in node_modules",
+			files: map[string]string{
+				"node_modules/@acme/tsconfig/tsconfig.json": `{"compilerOptions": {"baseUrl": "../../..", "paths": {"@/*": ["./src/*"]}}}`,
+				"app/tsconfig.json":                         `{"extends": "@acme/tsconfig"}`,
+			},
+			want: []string{"@/*=src/*"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			tsconfig := filepath.Join(dir, "tsconfig.json")
+			if _, ok := tt.files["app/tsconfig.json"]; ok {
+				tsconfig = filepath.Join(dir, "app", "tsconfig.json")
+			}
+			aliases, err := loadPathAliases(tsconfig)
+			if err != nil {
+				t.Fatalf("loadPathAliases() error: %v", err)
+			}
+			if got := aliasTargets(t, dir, aliases); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("loadPathAliases() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPathAliases_Errors(t *testing.T) {
+	tests := map[string]map[string]string{
+		"missing base":  {"tsconfig.json": `{"extends": "./missing.json"}`},
+		"cycle":         {"tsconfig.json": `{"extends": "./b.json"}`, "b.json": `{"extends": "./tsconfig.json"}`},
+		"bad extends":   {"tsconfig.json": `{"extends": 3}`},
+		"invalid JSONC": {"tsconfig.json": `{"compilerOptions": }`},
+	}
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, files)
+			if _, err := loadPathAliases(filepath.Join(dir, "tsconfig.json")); err == nil {
+				t.Error("loadPathAliases() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestResolveImportPath(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"exact.js":            "",
+		"util.ts":             "",
+		"button.tsx":          "",
+		"data.json":           "",
+		"components/index.ts": "",
+		"both.ts":             "",
+		"both/index.ts":       "",
+	})
+	tests := map[string]string{
+		"exact.js":   "exact.js",
+		"util":       "util.ts",
+		"util.js":    "util.ts",
+		"button.jsx": "button.tsx",
+		"data":       "data.json",
+		"components": "components/index.ts",
+		"both":       "both.ts",
+		"missing":    "",
+		"missing.js": "",
+	}
+	for spec, want := range tests {
+		got := resolveImportPath(filepath.Join(dir, spec))
+		if want != "" {
+			want = filepath.Join(dir, filepath.FromSlash(want))
+		}
+		if got != want {
+			t.Errorf("resolveImportPath(%q) = %q, want %q", spec, got, want)
+		}
+	}
+}
+
+func TestImportRewriter(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"tsconfig.json":          `{"compilerOptions": {"baseUrl": ".", "paths": {"@/*": ["src/*"], "~fmt": ["src/utils/format.ts"]}}}`,
+		"src/app.ts":             "",
+		"src/utils/format.ts":    "",
+		"src/utils/index.ts":     "",
+		"src/components/Btn.tsx": "",
+		"src/outside.ts":         "",
+	})
+	srcs := []string{"src/app.ts", "src/utils/format.ts", "src/utils/index.ts", "src/components/Btn.tsx"}
+	for i, src := range srcs {
+		srcs[i] = filepath.Join(dir, filepath.FromSlash(src))
+	}
+	aliases, err := loadPathAliases(filepath.Join(dir, "tsconfig.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Outputs mirror the sources under out/, as with --root src.
+	outputName := func(src string) string {
+		rel, _ := filepath.Rel(filepath.Join(dir, "src"), src)
+		return filepath.Join(dir, "out", strings.TrimSuffix(rel, filepath.Ext(rel))+".mjs")
+	}
+	r, err := newImportRewriter(srcs, outputName, aliases, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := `import { format } from "@/utils/format";
+import * as utils from '@/utils';
+import Btn from "@/components/Btn.js";
+import f from "~fmt";
+export { x } from "./utils/format";
+const lazy = () => import("@/components/Btn");
+const react = require("react");
+import "@scope/pkg";
+`
+	got, err := r.rewrite(srcs[0], []byte(code))
+	if err != nil {
+		t.Fatalf("rewrite() error: %v", err)
+	}
+	want := `import { format } from "./utils/format.mjs";
+import * as utils from './utils/index.mjs';
+import Btn from "./components/Btn.mjs";
+import f from "./utils/format.mjs";
+export { x } from "./utils/format.mjs";
+const lazy = () => import("./components/Btn.mjs");
+const react = require("react");
+import "@scope/pkg";
+`
+	if string(got) != want {
+		t.Errorf("rewrite() =\n%s\nwant:\n%s", got, want)
+	}
+
+	// From a nested file, rewritten imports go up a directory.
+	got, err = r.rewrite(srcs[3], []byte(`import { format } from "@/utils/format";`))
+	if err != nil {
+		t.Fatalf("rewrite() error: %v", err)
+	}
+	if want := `import { format } from "../utils/format.mjs";`; string(got) != want {
+		t.Errorf("rewrite() from a nested file = %s, want %s", got, want)
+	}
+
+	// An alias resolving to a file that isn't transpiled would break.
+	if _, err := r.rewrite(srcs[0], []byte(`import "@/outside";`)); err == nil || !strings.Contains(err.Error(), "isn't in srcs") {
+		t.Errorf("rewrite() of an alias outside srcs: err = %v", err)
+	}
+}
@@ -1,10 +1,14 @@
 package transpile
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/evanw/esbuild/pkg/api"
 
@@ -13,66 +17,274 @@ import (
 
 // Args holds the arguments for the transpile subcommand.
 type Args struct {
-	OutDir string
-	Srcs   []string
+	OutDir     string
+	Srcs       []string
+	Sourcemap  string
+	Formats    []string
+	EntryPoint string
+	Tsconfig   string
+	Root       string
+	Target     string
+	Loaders    []string
+	Dts        bool
+	TscBin     string
+	Node       string
+	Watch      bool
 }
 
+// Source map modes for --sourcemap.
+const (
+	sourcemapInline   = "inline"
+	sourcemapExternal = "external"
+)
+
 // Run transpiles individual source files (TS->JS, JSX->JS) without bundling.
+// Each transpiled file carries a source map back to its source: embedded as
+// a data URL, or with --sourcemap external written next to it as
+// <name>.js.map, for tools that read per-file maps from disk. With
+// --formats esm,cjs the sources are written in both module formats, in
+// esm/ and cjs/ under a package.json whose exports pick between them. With
+// --tsconfig, imports of its path aliases (such as "@/utils/format") are
+// rewritten to the transpiled file they resolve to, as nothing resolves
+// them once the library is consumed outside a bundler. Outputs are
+// written directly into the output directory unless --root is set, in which
+// case they mirror the sources' layout under it. With --dts the
+// TypeScript sources' declarations are emitted too. --target down-levels
+// the output, JS sources included, for older runtimes, and --loader
+// overrides the loader for an extension. With --watch, files are
+// re-transpiled as they change, for iterating outside plz.
 func Run(args Args) error {
-	if err := os.MkdirAll(args.OutDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	if args.Dts && args.TscBin == "" {
+		return fmt.Errorf("--dts requires --tsc-bin")
+	}
+	if args.Dts && args.Watch {
+		return fmt.Errorf("--watch doesn't support --dts; run tsc --watch alongside it for declarations")
+	}
+	sourcemap := api.SourceMapInline
+	switch args.Sourcemap {
+	case "", sourcemapInline:
+	case sourcemapExternal:
+		sourcemap = api.SourceMapExternal
+	default:
+		return fmt.Errorf("invalid --sourcemap %q: must be inline or external", args.Sourcemap)
 	}
 
-	for _, src := range args.Srcs {
-		data, err := os.ReadFile(src)
-		if err != nil {
-			return fmt.Errorf("failed to read %s: %w", src, err)
+	var aliases []pathAlias
+	if args.Tsconfig != "" {
+		var err error
+		if aliases, err = loadPathAliases(args.Tsconfig); err != nil {
+			return err
 		}
+	}
+	targets, err := newTargets(args, aliases)
+	if err != nil {
+		return err
+	}
 
-		ext := filepath.Ext(src)
-		loader, ok := common.Loaders[ext]
-		if !ok {
-			loader = api.LoaderJS
+	for _, t := range targets {
+		if err := os.MkdirAll(t.dir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
 		}
+	}
+	if len(targets) > 1 {
+		if err := writePackageJSON(args.OutDir, args.EntryPoint, targets, args.Dts); err != nil {
+			return fmt.Errorf("failed to write package.json: %w", err)
+		}
+	}
+
+	var declSrcs []string
+	for _, src := range args.Srcs {
+		if loader := loaderFor(src); (loader == api.LoaderTS || loader == api.LoaderTSX) && !strings.HasSuffix(src, ".d.ts") {
+			declSrcs = append(declSrcs, src)
+		}
+	}
 
-		// Only TS, TSX, and JSX need transpilation; everything else is copied as-is.
-		needsTranspile := loader == api.LoaderTSX || loader == api.LoaderTS || loader == api.LoaderJSX
-		if !needsTranspile {
-			outPath := filepath.Join(args.OutDir, filepath.Base(src))
-			if err := os.WriteFile(outPath, data, 0644); err != nil {
-				return fmt.Errorf("failed to write %s: %w", outPath, err)
+	// Files are independent, so a pool of workers transpiles them, each
+	// taking the next file as it finishes one. Every failure is reported,
+	// not just the first, in the order the files were given.
+	start := time.Now()
+	errs := make([]error, len(args.Srcs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.NumCPU(), len(args.Srcs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = transpileSrc(targets, args.Srcs[i], sourcemap)
 			}
+		}()
+	}
+	for i := range args.Srcs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var failed []string
+	for i, err := range errs {
+		if err == nil {
 			continue
 		}
+		printError(err)
+		failed = append(failed, args.Srcs[i])
+	}
+	if args.Watch {
+		// Failures are fixed by editing the file, so keep watching.
+		fmt.Printf("Transpiled %d files in %dms\n", len(args.Srcs)-len(failed), time.Since(start).Milliseconds())
+		return watch(args.Srcs, targets, sourcemap)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("transpilation failed for %s", strings.Join(failed, ", "))
+	}
+	if args.Dts {
+		return emitDeclarations(args, declSrcs)
+	}
+	return nil
+}
 
-		// Transpile TS/TSX/JSX using esbuild Transform API
-		result := api.Transform(string(data), api.TransformOptions{
-			Loader:      loader,
-			Format:      api.FormatESModule,
-			Target:      api.ESNext,
-			JSX:         api.JSXAutomatic,
-			Sourcemap:   api.SourceMapInline,
-			SourceRoot:  filepath.Dir(src),
-			Sourcefile:  filepath.Base(src),
-		})
-
-		if len(result.Errors) > 0 {
-			for _, e := range result.Errors {
-				if e.Location != nil {
-					fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", src, e.Location.Line, e.Location.Column, e.Text)
-				} else {
-					fmt.Fprintf(os.Stderr, "%s: %s\n", src, e.Text)
-				}
-			}
-			return fmt.Errorf("transpilation failed for %s", src)
+// transpileSrc transpiles one source file for each target.
+func transpileSrc(targets []*target, src string, sourcemap api.SourceMap) error {
+	for _, t := range targets {
+		if err := transpileFile(t, src, sourcemap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printError writes a file's transpilation error to stderr.
+func printError(err error) {
+	var terr *transformError
+	if errors.As(err, &terr) {
+		terr.print()
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// transformError holds esbuild's errors for a file that failed to transpile.
+type transformError struct {
+	src    string
+	errors []api.Message
+}
+
+func (e *transformError) Error() string {
+	return fmt.Sprintf("transpilation failed for %s", e.src)
+}
+
+// print writes each error to stderr prefixed with its file and position.
+func (e *transformError) print() {
+	for _, m := range e.errors {
+		if m.Location != nil {
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", e.src, m.Location.Line, m.Location.Column, m.Text)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", e.src, m.Text)
 		}
+	}
+}
+
+// loaderFor returns the esbuild loader for a source file.
+func loaderFor(src string) api.Loader {
+	if loader, ok := common.Loaders[filepath.Ext(src)]; ok {
+		return loader
+	}
+	return api.LoaderJS
+}
+
+// overrideLoaders are the loaders --loader can give an extension: the
+// code loaders, or copy to copy its files as they are.
+var overrideLoaders = map[string]api.Loader{
+	"js":   api.LoaderJS,
+	"jsx":  api.LoaderJSX,
+	"ts":   api.LoaderTS,
+	"tsx":  api.LoaderTSX,
+	"copy": api.LoaderCopy,
+}
+
+// parseLoaders parses --loader overrides of the form .ext=loader, such as
+// .js=jsx for JSX written in .js files.
+func parseLoaders(overrides []string) (map[string]api.Loader, error) {
+	loaders := make(map[string]api.Loader, len(overrides))
+	for _, override := range overrides {
+		ext, name, ok := strings.Cut(override, "=")
+		if !ok || !strings.HasPrefix(ext, ".") {
+			return nil, fmt.Errorf("invalid --loader %q: must be .ext=loader", override)
+		}
+		loader, ok := overrideLoaders[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid --loader %q: loader must be js, jsx, ts, tsx or copy", override)
+		}
+		loaders[ext] = loader
+	}
+	return loaders, nil
+}
 
-		outName := strings.TrimSuffix(filepath.Base(src), ext) + ".js"
-		outPath := filepath.Join(args.OutDir, outName)
-		if err := os.WriteFile(outPath, result.Code, 0644); err != nil {
+// transpileFile transpiles one source file into t's directory. Only TS,
+// TSX and JSX need it, and JS when converting it to another format;
+// everything else is copied as-is, apart from rewriting imports in JS.
+func transpileFile(t *target, src string, sourcemap api.SourceMap) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+
+	loader := t.loaderFor(src)
+	outName := t.outputName(src)
+	outPath := filepath.Join(t.dir, outName)
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if !t.transpiles(src) {
+		if t.imports != nil && loader == api.LoaderJS {
+			if data, err = t.imports.rewrite(src, data); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(outPath, data, 0644); err != nil {
 			return fmt.Errorf("failed to write %s: %w", outPath, err)
 		}
+		return nil
+	}
+
+	format := t.format
+	if loader == api.LoaderJS && !t.convertJS {
+		// Only down-leveled, so it keeps whichever module syntax it has.
+		format = api.FormatDefault
+	}
+
+	// Transpile using esbuild Transform API
+	result := api.Transform(string(data), api.TransformOptions{
+		Loader:     loader,
+		Format:     format,
+		Target:     t.esTarget,
+		Engines:    t.engines,
+		JSX:        api.JSXAutomatic,
+		Sourcemap:  sourcemap,
+		SourceRoot: filepath.Dir(src),
+		Sourcefile: filepath.Base(src),
+	})
+	if len(result.Errors) > 0 {
+		return &transformError{src: src, errors: result.Errors}
+	}
+
+	code := result.Code
+	if t.imports != nil {
+		// Specifiers sit at the end of their line in esbuild's output, so
+		// changing their length doesn't shift anything the map points at.
+		if code, err = t.imports.rewrite(src, code); err != nil {
+			return err
+		}
+	}
+	if sourcemap == api.SourceMapExternal {
+		// esbuild leaves linking an external map to the caller.
+		if err := os.WriteFile(outPath+".map", result.Map, 0644); err != nil {
+			return fmt.Errorf("failed to write %s.map: %w", outPath, err)
+		}
+		code = append(code, "//# sourceMappingURL="+filepath.Base(outName)+".map\n"...)
+	}
+	if err := os.WriteFile(outPath, code, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outPath, err)
 	}
 	return nil
 }
-
@@ -0,0 +1,54 @@
+package transpile
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// watchInterval is how often the sources are polled for changes.
+const watchInterval = 100 * time.Millisecond
+
+// watch polls srcs and re-transpiles each one whose mtime changes, printing
+// how long it took, until interrupted. Only the changed files are redone:
+// outputs don't depend on other files' contents.
+func watch(srcs []string, targets []*target, sourcemap api.SourceMap) error {
+	mtimes := make(map[string]time.Time, len(srcs))
+	for _, src := range srcs {
+		if info, err := os.Stat(src); err == nil {
+			mtimes[src] = info.ModTime()
+		}
+	}
+	fmt.Printf("Watching %d files for changes...\n", len(srcs))
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sigCh:
+			return nil
+		case <-ticker.C:
+		}
+		for _, src := range srcs {
+			info, err := os.Stat(src)
+			if err != nil || info.ModTime().Equal(mtimes[src]) {
+				continue
+			}
+			mtimes[src] = info.ModTime()
+			start := time.Now()
+			if err := transpileSrc(targets, src, sourcemap); err != nil {
+				printError(err)
+				fmt.Printf("  \033[31m%s failed\033[0m\n", src)
+				continue
+			}
+			// Single files usually take well under a millisecond.
+			fmt.Printf("  %s (%s)\n", src, time.Since(start).Round(time.Microsecond))
+		}
+	}
+}